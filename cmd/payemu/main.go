@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bank-api/internal/payment/emulator"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Локальный эмулятор Stripe и YooKassa: go run ./cmd/payemu
+// Сценарии платежей описаны в пакете internal/payment/emulator.
+func main() {
+	addr := flag.String("addr", ":8090", "listen address")
+	stripeWebhook := flag.String("stripe-webhook-url", "http://localhost:8080/webhooks/stripe", "where to send Stripe events, empty to disable")
+	stripeSecret := flag.String("stripe-webhook-secret", "whsec_emulator", "secret for the Stripe-Signature header")
+	yookassaWebhook := flag.String("yookassa-webhook-url", "http://localhost:8080/webhooks/yookassa", "where to send YooKassa notifications, empty to disable")
+	delay := flag.Duration("webhook-delay", 3*time.Second, "delay before a delayed payment settles")
+	flag.Parse()
+
+	srv := emulator.New(emulator.Config{
+		StripeWebhookURL:    *stripeWebhook,
+		StripeWebhookSecret: *stripeSecret,
+		YooKassaWebhookURL:  *yookassaWebhook,
+		WebhookDelay:        *delay,
+	})
+
+	log.Printf("payment provider emulator listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, srv); err != nil {
+		log.Fatalf("emulator stopped: %v", err)
+	}
+}
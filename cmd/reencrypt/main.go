@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bank-api/internal/config"
+	"bank-api/internal/kms"
+	"bank-api/internal/repositories"
+	"bank-api/internal/service"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// Перешифровывает карты ключами данных под текущим мастер-ключом KMS и выводит
+// из оборота неиспользуемые статические ключи.
+// Можно безопасно прервать: следующий запуск продолжит с места остановки.
+func main() {
+	configPath := flag.String("config", "configs/config.yaml", "path to config file")
+	batch := flag.Int("batch", 0, "cards per batch (defaults to encryption.reencrypt_batch)")
+	flag.Parse()
+
+	if err := logger.Init("pkg/utils/logger/config.yaml"); err != nil {
+		log.Fatalf("Could not initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	if err := config.Load(*configPath); err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	cfg := config.AppConfig
+
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Database.Host, cfg.Database.Port, cfg.Database.User,
+		cfg.Database.Password, cfg.Database.DBName, cfg.Database.SSLMode)
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		log.Fatalf("Failed to connect to DB: %v", err)
+	}
+	defer db.Close()
+
+	if *batch <= 0 {
+		*batch = cfg.Encryption.ReencryptBatch
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	keyManager, keyring, err := kms.CardKeys(ctx, cfg)
+	if err != nil {
+		log.Fatalf("failed to initialize card encryption: %v", err)
+	}
+
+	cardRepo := &repositories.CardRepository{DB: db}
+	issuer, err := cfg.CardIssuer()
+	if err != nil {
+		log.Fatalf("invalid cards config: %v", err)
+	}
+	softHSM, err := cfg.SoftHSM()
+	if err != nil {
+		log.Fatalf("invalid hsm config: %v", err)
+	}
+	cardService := service.NewCardService(cardRepo, &repositories.AccountRepository{DB: db}, keyManager, keyring, issuer, softHSM, cfg.Cards.DefaultProduct)
+	rotation := service.NewCardKeyRotationService(cardService, cardRepo,
+		&repositories.KeyRotationRepository{DB: db}, keyring, *batch)
+
+	if err := rotation.ApplyRetired(ctx); err != nil {
+		log.Fatalf("failed to load retired keys: %v", err)
+	}
+	if err := rotation.Run(ctx); err != nil {
+		log.Fatalf("re-encryption stopped: %v", err)
+	}
+}
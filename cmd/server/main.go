@@ -3,7 +3,9 @@ package server
 import (
 	"bank-api/internal/api"
 	"bank-api/internal/config"
+	"bank-api/internal/jobs"
 	"bank-api/pkg/utils/logger"
+	"context"
 	"fmt"
 	"log"
 
@@ -25,6 +27,9 @@ func StartServer() {
 	if err != nil {
 		logger.Fatalf("failed to load config: %v", err)
 	}
+	if err := config.AppConfig.Validate(); err != nil {
+		logger.Fatalf("invalid config: %v", err)
+	}
 
 	// DB initialization
 	db := initDB(config.AppConfig)
@@ -32,8 +37,14 @@ func StartServer() {
 	// Server initialization
 	srv := NewServer()
 
+	// Background jobs
+	scheduler := jobs.NewScheduler()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	// Register routes with DB
-	api.RegisterRoutes(srv.Router, db)
+	api.RegisterRoutes(srv.Router, db, scheduler)
+	scheduler.Start(ctx)
 
 	// HTTP-server start
 	logger.Info("Starting server on :8080...")
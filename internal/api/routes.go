@@ -4,19 +4,25 @@ import (
 	"bank-api/internal/cbr"
 	"bank-api/internal/config"
 	"bank-api/internal/handler"
+	"bank-api/internal/iso8583"
+	"bank-api/internal/jobs"
+	"bank-api/internal/kms"
 	"bank-api/internal/middleware"
 	"bank-api/internal/payment"
 	"bank-api/internal/payment/providers"
 	"bank-api/internal/repositories"
 	"bank-api/internal/service"
+	"bank-api/pkg/utils/logger"
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 
 	"github.com/gorilla/mux"
 )
 
-func RegisterRoutes(router *mux.Router, db *sqlx.DB) {
+func RegisterRoutes(router *mux.Router, db *sqlx.DB, scheduler *jobs.Scheduler) {
 
 	cfg := config.AppConfig
 
@@ -30,16 +36,72 @@ func RegisterRoutes(router *mux.Router, db *sqlx.DB) {
 	accountHandler := handler.NewAccountHandler(accountService)
 
 	cardRepo := &repositories.CardRepository{DB: db}
-	encryptionKey := []byte(cfg.Encryption.Secret)
-	hmacKey := []byte(cfg.Encryption.HMACKey)
+	keyManager, keyring, err := kms.CardKeys(context.Background(), cfg)
+	if err != nil {
+		logger.Sugared().Fatalf("failed to initialize card encryption: %v", err)
+	}
+
+	cardIssuer, err := cfg.CardIssuer()
+	if err != nil {
+		logger.Sugared().Fatalf("invalid cards config: %v", err)
+	}
+
+	softHSM, err := cfg.SoftHSM()
+	if err != nil {
+		logger.Sugared().Fatalf("invalid hsm config: %v", err)
+	}
+
+	cardService := service.NewCardService(cardRepo, accountRepo, keyManager, keyring, cardIssuer, softHSM, cfg.Cards.DefaultProduct)
+	keyRotationRepo := &repositories.KeyRotationRepository{DB: db}
+	keyRotationService := service.NewCardKeyRotationService(cardService, cardRepo, keyRotationRepo, keyring, cfg.Encryption.ReencryptBatch)
+	if err := keyRotationService.ApplyRetired(context.Background()); err != nil {
+		logger.Sugared().Errorf("failed to load retired encryption keys: %v", err)
+	}
+	cardLifecycleService := service.NewCardLifecycleService(cardService, cardRepo)
+	cardHandler := handler.NewCardHandler(cardService, cardLifecycleService)
 
-	cardService := service.NewCardService(cardRepo, encryptionKey, hmacKey)
-	cardHandler := handler.NewCardHandler(cardService)
+	var feeRules []service.FeeRule
+	for _, rule := range cfg.Pricing.Rules {
+		feeRules = append(feeRules, service.FeeRule{
+			Name:           rule.Name,
+			Operation:      rule.Operation,
+			Channels:       rule.Channels,
+			Currencies:     rule.Currencies,
+			Tariffs:        rule.Tariffs,
+			MinAmount:      rule.MinAmount,
+			MaxAmount:      rule.MaxAmount,
+			Percent:        rule.FeePercent,
+			Fixed:          rule.FeeFixed,
+			MinFee:         rule.MinFee,
+			MaxFee:         rule.MaxFee,
+			FreeOperations: rule.FreeOperations,
+			FreeAmount:     rule.FreeAmount,
+		})
+	}
+	pricingService, err := service.NewPricingService(repositories.NewPricingRepository(db), userRepo, cfg.Pricing.Tariffs, feeRules)
+	if err != nil {
+		logger.Sugared().Fatalf("invalid pricing config: %v", err)
+	}
+	pricingHandler := handler.NewPricingHandler(pricingService)
 
 	transactionRepo := &repositories.TransactionRepository{DB: db}
-	transactionService := service.NewTransactionService(*transactionRepo, *accountRepo)
+	transactionService := service.NewTransactionService(*transactionRepo, *accountRepo, pricingService)
 	transactionHandler := handler.NewTransactionHandler(transactionService)
 
+	beneficiaryRepo := &repositories.BeneficiaryRepository{DB: db}
+	beneficiaryService := service.NewBeneficiaryService(
+		beneficiaryRepo,
+		nil, // отправки SMS/push пока нет
+		cfg.Beneficiaries.RequireConfirmation,
+		cfg.Beneficiaries.ConfirmationTTL,
+		cfg.Beneficiaries.MaxConfirmationAttempts,
+	)
+	beneficiaryHandler := handler.NewBeneficiaryHandler(beneficiaryService)
+
+	templateRepo := &repositories.TransferTemplateRepository{DB: db}
+	templateService := service.NewTransferTemplateService(templateRepo, accountRepo, beneficiaryService, transactionService)
+	templateHandler := handler.NewTransferTemplateHandler(templateService)
+
 	httpClient := &http.Client{}
 	cbrService := cbr.NewCBRService(httpClient)
 
@@ -47,55 +109,208 @@ func RegisterRoutes(router *mux.Router, db *sqlx.DB) {
 	loanService := service.NewLoanService(loanRepo, accountRepo, cbrService, transactionService)
 	loanHandler := handler.NewLoanHandler(loanService)
 
-	paymentMethodRepo := &repositories.PaymentMethodRepository{DB: db}
-	paymentMethodService := service.NewPaymentService(paymentMethodRepo)
-	paymentMethodHandler := handler.NewPaymentHandler(paymentMethodService)
+	depositRepo := &repositories.DepositRepository{DB: db}
+	depositService := service.NewDepositService(depositRepo, accountRepo, cbrService, transactionService)
+	depositHandler := handler.NewDepositHandler(depositService)
+
+	goalRepo := &repositories.SavingsGoalRepository{DB: db}
+	goalService := service.NewSavingsGoalService(goalRepo, accountRepo, transactionService)
+	goalHandler := handler.NewSavingsGoalHandler(goalService)
+	transactionService.AddHook(goalService.OnTransaction)
+
+	overdraftRepo := &repositories.OverdraftRepository{DB: db}
+	overdraftService := service.NewOverdraftService(overdraftRepo, accountRepo, transactionService)
+	overdraftHandler := handler.NewOverdraftHandler(overdraftService)
+	transactionService.AddHook(overdraftService.OnTransaction)
 
-	stripeProvider := providers.NewStripeProvider("your-stripe-secret-key")
-	yoomoneyProvider := providers.NewYooMoneyProvider("your-yoomoney-token")
+	rewardRepo := &repositories.RewardRepository{DB: db}
+	rewardService := service.NewRewardService(rewardRepo, accountRepo, transactionService, cfg.Rewards.MaxOptInCategories)
+	rewardHandler := handler.NewRewardHandler(rewardService)
+	transactionService.AddHook(rewardService.OnTransaction)
 
-	providerList := []providers.PaymentProvider{
-		stripeProvider,
-		yoomoneyProvider,
+	cardControlRepo := &repositories.CardControlRepository{DB: db}
+	cardControlService := service.NewCardControlService(cardControlRepo, cardService, cfg.Cards.HomeCountry)
+	cardControlHandler := handler.NewCardControlHandler(cardControlService)
+
+	virtualCardService := service.NewVirtualCardService(cardService)
+	virtualCardHandler := handler.NewVirtualCardHandler(virtualCardService)
+
+	cardRevealRepo := &repositories.CardRevealRepository{DB: db}
+	cardRevealService := service.NewCardRevealService(cardService, cardRevealRepo, userService.VerifyPassword,
+		cfg.Cards.Reveal.TokenTTL, cfg.Cards.Reveal.MaxPerHour)
+	cardRevealHandler := handler.NewCardRevealHandler(cardRevealService)
+
+	cardPINService := service.NewCardPINService(cardService, cardRepo, softHSM, cfg.Cards.PINMaxTries)
+	cardPINHandler := handler.NewCardPINHandler(cardPINService)
+
+	cardAuthRepo := &repositories.CardAuthorizationRepository{DB: db}
+	cardAuthService := service.NewCardAuthorizationService(
+		cardService,
+		cardControlService,
+		cardPINService,
+		cardRepo,
+		cardAuthRepo,
+		transactionService,
+		cfg.Cards.Authorization.HoldTTL,
+	)
+	cardAuthHandler := handler.NewCardAuthorizationHandler(cardAuthService)
+	if addr := cfg.Cards.Authorization.ISO8583Addr; addr != "" {
+		isoServer := &iso8583.Server{Addr: addr, Handler: iso8583.NewHost(cardAuthService).Handle}
+		go func() {
+			if err := isoServer.ListenAndServe(context.Background()); err != nil {
+				logger.Sugared().Errorf("ISO 8583 listener stopped: %v", err)
+			}
+		}()
 	}
 
+	paymentsCfg := cfg.Payments
+	providerClient := &http.Client{Timeout: paymentsCfg.Timeout}
+	var routingRules []providers.RoutingRule
+	for _, rule := range paymentsCfg.Routing.Rules {
+		routingRules = append(routingRules, providers.RoutingRule{
+			Name:       rule.Name,
+			Currencies: rule.Currencies,
+			Methods:    rule.Methods,
+			MinAmount:  rule.MinAmount,
+			MaxAmount:  rule.MaxAmount,
+			Providers:  rule.Providers,
+		})
+	}
+	providerRegistry := providers.NewRegistry(providers.BreakerSettings{
+		FailureThreshold: paymentsCfg.Routing.Breaker.FailureThreshold,
+		OpenTimeout:      paymentsCfg.Routing.Breaker.OpenTimeout,
+	}, routingRules)
+	returnURLs := providers.NewReturnURLs(paymentsCfg.ReturnURL, paymentsCfg.ReturnURLSecret)
+	if paymentsCfg.Stripe.APIKey != "" {
+		providerRegistry.Register(providers.NewStripeProvider(providerClient,
+			paymentsCfg.Stripe.BaseURL, paymentsCfg.Stripe.APIKey, paymentsCfg.Stripe.WebhookSecret, returnURLs),
+			providerProfile(paymentsCfg.Stripe.ProviderRoutingConfig))
+	}
+	if paymentsCfg.YooKassa.ShopID != "" {
+		yookassaProvider, err := providers.NewYooKassaProvider(providerClient, paymentsCfg.YooKassa.BaseURL,
+			paymentsCfg.YooKassa.ShopID, paymentsCfg.YooKassa.SecretKey, returnURLs, paymentsCfg.YooKassa.WebhookAllowedIPs)
+		if err != nil {
+			logger.Sugared().Fatalf("invalid payments config: %v", err)
+		}
+		providerRegistry.Register(yookassaProvider, providerProfile(paymentsCfg.YooKassa.ProviderRoutingConfig))
+	}
+
+	notificationRepo := repositories.NewNotificationRepository(db)
+	notificationService := service.NewNotificationService(notificationRepo)
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+
+	paymentMethodRepo := repositories.NewPaymentMethodRepository(db)
+	paymentMethodService := service.NewPaymentMethodService(paymentMethodRepo, providerRegistry, notificationService)
+	paymentMethodHandler := handler.NewPaymentMethodHandler(paymentMethodService)
+
 	paymentRepo := payment.NewPaymentRepository(db)
 	paymentService := payment.NewPaymentService(
 		paymentRepo,
-		providerList,
+		accountRepo,
+		providerRegistry,
 		transactionService,
+		paymentMethodService,
+		payment.PaymentConfig{
+			ActionTimeout: paymentsCfg.ActionTimeout,
+			ReturnURLs:    returnURLs,
+			Payouts: payment.PayoutConfig{
+				MinAmount:  paymentsCfg.Payouts.MinAmount,
+				DailyLimit: paymentsCfg.Payouts.DailyLimit,
+			},
+		},
 	)
 	paymentHandler := payment.NewPaymentHandler(paymentService)
 
+	recurringRepo := payment.NewRecurringRepository(db)
+	recurringService := payment.NewRecurringService(recurringRepo, paymentService, paymentMethodService, notificationService,
+		payment.RecurringConfig{
+			MaxFailures:    paymentsCfg.Recurring.MaxFailures,
+			RetryIntervals: paymentsCfg.Recurring.RetryIntervals,
+		})
+	recurringHandler := payment.NewRecurringHandler(recurringService)
+
+	reconciliationRepo := payment.NewReconciliationRepository(db)
+	reconciliationService := payment.NewReconciliationService(reconciliationRepo, paymentService, payment.ReconciliationConfig{
+		Dir:           paymentsCfg.Reconciliation.Dir,
+		SettlementLag: paymentsCfg.Reconciliation.SettlementLag,
+	})
+	reconciliationHandler := payment.NewReconciliationHandler(reconciliationService)
+
+	// background jobs
+	scheduler.Daily("deposit-interest-accrual", 0, 30, depositService.AccrueInterest)
+	scheduler.Daily("savings-weekly-sweep", 9, 0, goalService.RunWeeklySweeps)
+	scheduler.Daily("overdraft-interest-accrual", 0, 15, overdraftService.AccrueDaily)
+	scheduler.Daily("overdraft-interest-charge", 1, 0, overdraftService.ChargeMonthly)
+	scheduler.Daily("cashback-settlement", 2, 0, rewardService.SettleMonthly)
+	scheduler.Daily("card-reencryption", 3, 0, keyRotationService.Run)
+	scheduler.Daily("card-expiry", 0, 5, cardLifecycleService.ProcessExpiry)
+	scheduler.Every("card-hold-expiry", time.Hour, cardAuthService.ExpireHolds)
+	scheduler.Every("payment-refund-sync", 10*time.Minute, paymentService.SyncPendingRefunds)
+	scheduler.Every("payment-action-timeout", time.Minute, paymentService.ExpireAbandonedActions)
+	scheduler.Every("payout-sync", 10*time.Minute, paymentService.SyncPendingPayouts)
+	scheduler.Every("recurring-charges", 15*time.Minute, recurringService.RunDue)
+	scheduler.Daily("payment-method-expiry", 0, 10, paymentMethodService.ProcessExpiry)
+	scheduler.Daily("payment-reconciliation", 4, 0, reconciliationService.Run)
+
 	// Public route
 	router.HandleFunc("/register", userHandler.Register).Methods(http.MethodPost)
 	router.HandleFunc("/login", userHandler.Login).Methods(http.MethodPost)
 
+	// уведомления платёжных провайдеров: подлинность проверяет сам провайдер
+	router.HandleFunc("/webhooks/{provider}", paymentHandler.Webhook).Methods(http.MethodPost)
+	// сюда провайдер возвращает клиента после подтверждения платежа (return_url)
+	router.HandleFunc("/payments/return/{id:[0-9]+}", paymentHandler.ReturnFromConfirmation).Methods(http.MethodGet)
+
 	// Здесь позже добавим middleware и защищённые маршруты
 	secured := router.PathPrefix("/").Subrouter()
 	secured.Use(middleware.JWTMiddleware)
 
 	secured.HandleFunc("/me", userHandler.GetProfile).Methods(http.MethodGet)
-	secured.HandleFunc("/cards", cardHandler.CreateCard).Methods(http.MethodPost)
 	secured.HandleFunc("/accounts", accountHandler.CreateAccount).Methods(http.MethodPost)
-	secured.HandleFunc("/cards/{id:[0-9]+}", cardHandler.GetCardByID).Methods(http.MethodGet)
 
 	// accounts
 	auth := router.PathPrefix("/").Subrouter()
 	auth.Use(middleware.JWTAuth)
 	auth.HandleFunc("/accounts", accountHandler.CreateAccount).Methods("POST")
 
-	// cards
-	authCard := router.PathPrefix("/").Subrouter()
-	authCard.Use(middleware.JWTAuth)
-	authCard.HandleFunc("/cards", cardHandler.CreateCard).Methods("POST")
-	authCard.HandleFunc("/cards", cardHandler.GetAllCards).Methods("GET")
-	authCard.HandleFunc("/cards/{id}/block", cardHandler.BlockCard).Methods("PATCH")
-	authCard.HandleFunc("/cards/{id}", cardHandler.DeleteCard).Methods("DELETE")
+	securedAccounts := router.PathPrefix("/accounts").Subrouter()
+	securedAccounts.Use(middleware.JWTMiddleware)
+	securedAccounts.HandleFunc("/{id:[0-9]+}/balance", accountHandler.GetBalance).Methods("GET")
+
+	// Cards
+	securedCards := router.PathPrefix("/cards").Subrouter()
+	securedCards.Use(middleware.JWTMiddleware)
+
+	securedCards.HandleFunc("", cardHandler.CreateCard).Methods("POST")
+	securedCards.HandleFunc("", cardHandler.GetAllCards).Methods("GET")
+	securedCards.HandleFunc("/virtual", virtualCardHandler.CreateVirtualCard).Methods("POST")
+	securedCards.HandleFunc("/reveal/{token:[0-9a-f]+}", cardRevealHandler.Reveal).Methods("GET")
+	securedCards.HandleFunc("/{id:[0-9]+}", cardHandler.GetCardByID).Methods("GET")
+	securedCards.HandleFunc("/{id:[0-9]+}", cardHandler.DeleteCard).Methods("DELETE")
+	securedCards.HandleFunc("/{id:[0-9]+}/block", cardHandler.BlockCard).Methods("PATCH")
+	securedCards.HandleFunc("/{id:[0-9]+}/freeze", cardHandler.FreezeCard).Methods("POST")
+	securedCards.HandleFunc("/{id:[0-9]+}/unfreeze", cardHandler.UnfreezeCard).Methods("POST")
+	securedCards.HandleFunc("/{id:[0-9]+}/activate", cardHandler.ActivateCard).Methods("POST")
+	securedCards.HandleFunc("/{id:[0-9]+}/reissue", cardHandler.ReissueCard).Methods("POST")
+	securedCards.HandleFunc("/{id:[0-9]+}/history", cardHandler.GetCardHistory).Methods("GET")
+	securedCards.HandleFunc("/{id:[0-9]+}/reveal", cardRevealHandler.RequestReveal).Methods("POST")
+	securedCards.HandleFunc("/{id:[0-9]+}/pin", cardPINHandler.SetPIN).Methods("POST")
+	securedCards.HandleFunc("/{id:[0-9]+}/pin", cardPINHandler.ChangePIN).Methods("PUT")
+	securedCards.HandleFunc("/{id:[0-9]+}/authorizations", cardAuthHandler.ListByCard).Methods("GET")
+	securedCards.HandleFunc("/{id:[0-9]+}/controls", cardControlHandler.GetControls).Methods("GET")
+	securedCards.HandleFunc("/{id:[0-9]+}/controls", cardControlHandler.UpdateControls).Methods("PUT")
+
+	// Acquirer: авторизация и клиринг по картам
+	acquirer := router.PathPrefix("/acquirer").Subrouter()
+	acquirer.Use(middleware.RequireAPIKey("X-Acquirer-Key", cfg.Cards.Authorization.APIKey))
+
+	acquirer.HandleFunc("/authorizations", cardAuthHandler.Authorize).Methods("POST")
+	acquirer.HandleFunc("/authorizations/{rrn}/capture", cardAuthHandler.Capture).Methods("POST")
+	acquirer.HandleFunc("/authorizations/{rrn}/reversal", cardAuthHandler.Reverse).Methods("POST")
 
 	// transactions
 	securedTransaction := router.PathPrefix("/transactions").Subrouter()
-	securedTransaction.Use(middleware.JWTAuth)
+	securedTransaction.Use(middleware.JWTMiddleware)
 
 	securedTransaction.HandleFunc("/deposit", transactionHandler.Deposit).Methods("POST")
 	securedTransaction.HandleFunc("/withdraw", transactionHandler.Withdraw).Methods("POST")
@@ -104,6 +319,31 @@ func RegisterRoutes(router *mux.Router, db *sqlx.DB) {
 	securedTransaction.HandleFunc("/reverse", transactionHandler.ReverseTransaction).Methods("POST")
 	securedTransaction.HandleFunc("/history/{accountID:[0-9]+}", transactionHandler.GetHistory).Methods("GET")
 
+	// комиссии: расчёт до выполнения операции
+	securedFees := router.PathPrefix("/fees").Subrouter()
+	securedFees.Use(middleware.JWTMiddleware)
+	securedFees.HandleFunc("/quote", pricingHandler.Quote).Methods("GET")
+
+	// Beneficiaries
+	securedBeneficiaries := router.PathPrefix("/beneficiaries").Subrouter()
+	securedBeneficiaries.Use(middleware.JWTMiddleware)
+
+	securedBeneficiaries.HandleFunc("", beneficiaryHandler.AddBeneficiary).Methods("POST")
+	securedBeneficiaries.HandleFunc("", beneficiaryHandler.ListBeneficiaries).Methods("GET")
+	securedBeneficiaries.HandleFunc("/{id:[0-9]+}", beneficiaryHandler.UpdateBeneficiary).Methods("PUT")
+	securedBeneficiaries.HandleFunc("/{id:[0-9]+}", beneficiaryHandler.DeleteBeneficiary).Methods("DELETE")
+	securedBeneficiaries.HandleFunc("/{id:[0-9]+}/confirm", beneficiaryHandler.ConfirmBeneficiary).Methods("POST")
+
+	// Transfer templates
+	securedTemplates := router.PathPrefix("/templates").Subrouter()
+	securedTemplates.Use(middleware.JWTMiddleware)
+
+	securedTemplates.HandleFunc("", templateHandler.CreateTemplate).Methods("POST")
+	securedTemplates.HandleFunc("", templateHandler.ListTemplates).Methods("GET")
+	securedTemplates.HandleFunc("/{id:[0-9]+}", templateHandler.UpdateTemplate).Methods("PUT")
+	securedTemplates.HandleFunc("/{id:[0-9]+}", templateHandler.DeleteTemplate).Methods("DELETE")
+	securedTemplates.HandleFunc("/{id:[0-9]+}/pay", templateHandler.PayFromTemplate).Methods("POST")
+
 	// Loan
 	securedLoans := router.PathPrefix("/loans").Subrouter()
 	securedLoans.Use(middleware.JWTAuth)
@@ -115,20 +355,103 @@ func RegisterRoutes(router *mux.Router, db *sqlx.DB) {
 	securedLoans.HandleFunc("/{id:[0-9]+}/debt", loanHandler.GetOutstandingDebt).Methods("GET")
 	securedLoans.HandleFunc("/{id:[0-9]+}/repay-partial", loanHandler.RepayPartial).Methods("POST")
 
-	// payment methods
+	// Deposits
+	securedDeposits := router.PathPrefix("/deposits").Subrouter()
+	securedDeposits.Use(middleware.JWTMiddleware)
+
+	securedDeposits.HandleFunc("/products", depositHandler.ListProducts).Methods("GET")
+	securedDeposits.HandleFunc("", depositHandler.OpenDeposit).Methods("POST")
+	securedDeposits.HandleFunc("", depositHandler.ListDeposits).Methods("GET")
+	securedDeposits.HandleFunc("/{id:[0-9]+}", depositHandler.GetDeposit).Methods("GET")
+	securedDeposits.HandleFunc("/{id:[0-9]+}/top-up", depositHandler.TopUp).Methods("POST")
+	securedDeposits.HandleFunc("/{id:[0-9]+}/withdraw", depositHandler.Withdraw).Methods("POST")
+	securedDeposits.HandleFunc("/{id:[0-9]+}/close", depositHandler.CloseDeposit).Methods("POST")
+
+	// Savings goals
+	securedGoals := router.PathPrefix("/goals").Subrouter()
+	securedGoals.Use(middleware.JWTMiddleware)
+
+	securedGoals.HandleFunc("", goalHandler.CreateGoal).Methods("POST")
+	securedGoals.HandleFunc("", goalHandler.ListGoals).Methods("GET")
+	securedGoals.HandleFunc("/{id:[0-9]+}", goalHandler.GetGoal).Methods("GET")
+	securedGoals.HandleFunc("/{id:[0-9]+}/deposit", goalHandler.MoveIn).Methods("POST")
+	securedGoals.HandleFunc("/{id:[0-9]+}/withdraw", goalHandler.MoveOut).Methods("POST")
+	securedGoals.HandleFunc("/{id:[0-9]+}/close", goalHandler.CloseGoal).Methods("POST")
+	securedGoals.HandleFunc("/{id:[0-9]+}/rules", goalHandler.AddRule).Methods("POST")
+	securedGoals.HandleFunc("/{id:[0-9]+}/rules", goalHandler.ListRules).Methods("GET")
+	securedGoals.HandleFunc("/{id:[0-9]+}/rules/{ruleID:[0-9]+}", goalHandler.DeleteRule).Methods("DELETE")
+
+	// Rewards
+	securedRewards := router.PathPrefix("/rewards").Subrouter()
+	securedRewards.Use(middleware.JWTMiddleware)
+
+	securedRewards.HandleFunc("/campaigns", rewardHandler.ListCampaigns).Methods("GET")
+	securedRewards.HandleFunc("/campaigns/{id:[0-9]+}/opt-in", rewardHandler.OptIn).Methods("POST")
+	securedRewards.HandleFunc("/campaigns/{id:[0-9]+}/opt-in", rewardHandler.OptOut).Methods("DELETE")
+	securedRewards.HandleFunc("/summary", rewardHandler.Summary).Methods("GET")
+
+	// Admin
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.JWTMiddleware, middleware.RequireRole("admin", userRepo.GetUserRole))
+
+	admin.HandleFunc("/accounts/{id:[0-9]+}/overdraft", overdraftHandler.GrantOverdraft).Methods("PUT")
+	admin.HandleFunc("/accounts/{id:[0-9]+}/overdraft", overdraftHandler.GetOverdraft).Methods("GET")
+	admin.HandleFunc("/accounts/{id:[0-9]+}/overdraft", overdraftHandler.RevokeOverdraft).Methods("DELETE")
+	admin.HandleFunc("/rewards/campaigns", rewardHandler.CreateCampaign).Methods("POST")
+	admin.HandleFunc("/rewards/campaigns/{id:[0-9]+}", rewardHandler.DeactivateCampaign).Methods("DELETE")
+	admin.HandleFunc("/cards/{id:[0-9]+}/status", cardHandler.SetCardStatus).Methods("PUT")
+	admin.HandleFunc("/users/{id:[0-9]+}/tariff", pricingHandler.SetTariff).Methods("PUT")
+	admin.HandleFunc("/payments/providers", paymentHandler.ProviderStats).Methods("GET")
+	admin.HandleFunc("/reconciliation/run", reconciliationHandler.Run).Methods("POST")
+	admin.HandleFunc("/reconciliation/breaks", reconciliationHandler.ListBreaks).Methods("GET")
+	admin.HandleFunc("/reconciliation/breaks/{id:[0-9]+}", reconciliationHandler.GetBreak).Methods("GET")
+	admin.HandleFunc("/reconciliation/breaks/{id:[0-9]+}/resolve", reconciliationHandler.ResolveBreak).Methods("POST")
+
+	// Notifications
+	securedNotifications := router.PathPrefix("/notifications").Subrouter()
+	securedNotifications.Use(middleware.JWTMiddleware)
+
+	securedNotifications.HandleFunc("", notificationHandler.ListNotifications).Methods("GET")
+	securedNotifications.HandleFunc("/{id:[0-9]+}/read", notificationHandler.MarkRead).Methods("POST")
+
+	// payment methods: прежний адрес хранилища, то же, что /api/payment-methods
 	securedPaymentsMethod := router.PathPrefix("/payments").Subrouter()
-	securedPaymentsMethod.Use(middleware.JWTAuth)
+	securedPaymentsMethod.Use(middleware.JWTMiddleware)
 	securedPaymentsMethod.HandleFunc("", paymentMethodHandler.AddPaymentMethod).Methods("POST")
-	securedPaymentsMethod.HandleFunc("", paymentMethodHandler.GetUserMethods).Methods("GET")
-	securedPaymentsMethod.HandleFunc("/{id}", paymentMethodHandler.DeactivateMethod).Methods("DELETE")
+	securedPaymentsMethod.HandleFunc("", paymentMethodHandler.GetPaymentMethods).Methods("GET")
+	securedPaymentsMethod.HandleFunc("/{id:[0-9]+}", paymentMethodHandler.DeletePaymentMethod).Methods("DELETE")
+	securedPaymentsMethod.HandleFunc("/{id:[0-9]+}/default", paymentMethodHandler.SetDefault).Methods("POST")
 
 	// Payment
 	securedPayments := router.PathPrefix("/api").Subrouter()
 	securedPayments.Use(middleware.JWTMiddleware)
 	securedPayments.HandleFunc("/payments", paymentHandler.ProcessPayment).Methods("POST")
-	securedPayments.HandleFunc("/payments/{id:[0-9]+}/refund", paymentHandler.RefundPayment).Methods("POST")
+	securedPayments.HandleFunc("/payments/{id:[0-9]+}", paymentHandler.GetPayment).Methods("GET")
+	securedPayments.HandleFunc("/payments/{id:[0-9]+}/refund", paymentHandler.CreateRefund).Methods("POST") // прежний адрес полного возврата
+	securedPayments.HandleFunc("/payments/{id:[0-9]+}/refunds", paymentHandler.CreateRefund).Methods("POST")
+	securedPayments.HandleFunc("/payments/{id:[0-9]+}/refunds", paymentHandler.ListRefunds).Methods("GET")
+
+	securedPayments.HandleFunc("/payouts", paymentHandler.CreatePayout).Methods("POST")
+	securedPayments.HandleFunc("/payouts", paymentHandler.ListPayouts).Methods("GET")
+	securedPayments.HandleFunc("/payouts/{id:[0-9]+}", paymentHandler.GetPayout).Methods("GET")
+
+	securedPayments.HandleFunc("/recurring", recurringHandler.CreateRecurring).Methods("POST")
+	securedPayments.HandleFunc("/recurring", recurringHandler.ListRecurring).Methods("GET")
+	securedPayments.HandleFunc("/recurring/{id:[0-9]+}", recurringHandler.GetRecurring).Methods("GET")
+	securedPayments.HandleFunc("/recurring/{id:[0-9]+}", recurringHandler.CancelRecurring).Methods("DELETE")
+	securedPayments.HandleFunc("/recurring/{id:[0-9]+}/charge", recurringHandler.ChargeNow).Methods("POST")
 
-	securedPayments.HandleFunc("/payment-methods", paymentHandler.AddPaymentMethod).Methods("POST")
-	securedPayments.HandleFunc("/payment-methods", paymentHandler.GetPaymentMethods).Methods("GET")
-	securedPayments.HandleFunc("/payment-methods/{id:[0-9]+}", paymentHandler.DeletePaymentMethod).Methods("DELETE")
+	securedPayments.HandleFunc("/payment-methods", paymentMethodHandler.AddPaymentMethod).Methods("POST")
+	securedPayments.HandleFunc("/payment-methods", paymentMethodHandler.GetPaymentMethods).Methods("GET")
+	securedPayments.HandleFunc("/payment-methods/{id:[0-9]+}", paymentMethodHandler.DeletePaymentMethod).Methods("DELETE")
+	securedPayments.HandleFunc("/payment-methods/{id:[0-9]+}/default", paymentMethodHandler.SetDefault).Methods("POST")
+}
+
+func providerProfile(cfg config.ProviderRoutingConfig) providers.Profile {
+	return providers.Profile{
+		Currencies: cfg.Currencies,
+		Methods:    cfg.Methods,
+		FeePercent: cfg.FeePercent,
+		FeeFixed:   cfg.FeeFixed,
+	}
 }
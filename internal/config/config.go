@@ -1,14 +1,154 @@
 package config
 
 import (
+	"bank-api/internal/hsm"
+	"bank-api/internal/pan"
+	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 var AppConfig *Config
 
+// KMSConfig — источник мастер-ключа для ключей данных карт
+type KMSConfig struct {
+	Provider     string `yaml:"provider"` // local, vault
+	LocalKeyFile string `yaml:"local_key_file"`
+	Vault        struct {
+		Address           string `yaml:"address"`
+		Token             string `yaml:"token"`
+		Mount             string `yaml:"mount"`
+		KeyName           string `yaml:"key_name"`
+		HMACKeyCiphertext string `yaml:"hmac_key_ciphertext"` // ключ HMAC, обёрнутый ключом Transit
+	} `yaml:"vault"`
+}
+
+// CardProduct — карточный продукт и его диапазон BIN
+type CardProduct struct {
+	Name    string `yaml:"name"`
+	Brand   string `yaml:"brand"` // visa, mastercard, mir
+	BINFrom string `yaml:"bin_from"`
+	BINTo   string `yaml:"bin_to"`
+}
+
+// EncryptionKey — версия ключа AES-256-GCM для данных карт
+type EncryptionKey struct {
+	ID  string `yaml:"id"`
+	Key string `yaml:"key"` // base64, 32 байта; пусто — берётся из переменной EnvName()
+}
+
+// EnvName — переменная окружения с ключом: CARD_ENCRYPTION_KEY_<ID>
+func (k EncryptionKey) EnvName() string {
+	return "CARD_ENCRYPTION_KEY_" + strings.ToUpper(strings.ReplaceAll(k.ID, "-", "_"))
+}
+
+// CardAuthorizationConfig — приём авторизаций от эквайера
+type CardAuthorizationConfig struct {
+	HoldTTL     time.Duration `yaml:"hold_ttl"`     // через сколько снимается hold без клиринга
+	APIKey      string        `yaml:"api_key"`      // ключ эквайера для /acquirer, пусто — API выключен
+	ISO8583Addr string        `yaml:"iso8583_addr"` // адрес TCP-listener ISO 8583, пусто — выключен
+}
+
+// HSMConfig — ключи программного HSM в hex. Только для разработки и тестовых стендов.
+type HSMConfig struct {
+	ZonePINKey          string `yaml:"zone_pin_key"`          // TDES, PIN-блоки формата 0
+	ZonePINKeyAES       string `yaml:"zone_pin_key_aes"`      // AES, PIN-блоки формата 4
+	PINVerificationKey  string `yaml:"pin_verification_key"`  // TDES, смещения PIN IBM 3624
+	CardVerificationKey string `yaml:"card_verification_key"` // CVK: пара DES-ключей для CVV2
+}
+
+// CardRevealConfig — показ полных реквизитов карты
+type CardRevealConfig struct {
+	TokenTTL   time.Duration `yaml:"token_ttl"`    // сколько действует одноразовый токен
+	MaxPerHour int           `yaml:"max_per_hour"` // запросов на показ от одного пользователя в час
+}
+
+// PaymentsConfig — внешние платёжные провайдеры. Провайдер без ключей не подключается.
+type PaymentsConfig struct {
+	Timeout         time.Duration `yaml:"timeout"`           // таймаут HTTP-запроса к провайдеру
+	ReturnURL       string        `yaml:"return_url"`        // куда провайдер вернёт клиента после 3-D Secure; к адресу добавляется /<id платежа>?token=...
+	ReturnURLSecret string        `yaml:"return_url_secret"` // ключ подписи адреса возврата
+	ActionTimeout   time.Duration `yaml:"action_timeout"`    // неподтверждённый клиентом платёж отменяется по истечении
+	Stripe          struct {
+		BaseURL               string `yaml:"base_url"` // https://api.stripe.com или адрес эмулятора
+		APIKey                string `yaml:"api_key"`
+		WebhookSecret         string `yaml:"webhook_secret"` // whsec_..., пусто — уведомления отклоняются
+		ProviderRoutingConfig `yaml:",inline"`
+	} `yaml:"stripe"`
+	YooKassa struct {
+		BaseURL               string   `yaml:"base_url"` // https://api.yookassa.ru/v3 или адрес эмулятора
+		ShopID                string   `yaml:"shop_id"`
+		SecretKey             string   `yaml:"secret_key"`
+		WebhookAllowedIPs     []string `yaml:"webhook_allowed_ips"` // пусто — адреса YooKassa по умолчанию
+		ProviderRoutingConfig `yaml:",inline"`
+	} `yaml:"yookassa"`
+	Routing   PaymentRoutingConfig `yaml:"routing"`
+	Recurring struct {
+		MaxFailures    int             `yaml:"max_failures"`    // неудач подряд до отключения подписки
+		RetryIntervals []time.Duration `yaml:"retry_intervals"` // паузы перед повторами неудачного списания
+	} `yaml:"recurring"`
+	Payouts struct {
+		MinAmount  float64 `yaml:"min_amount"`  // комиссия за вывод — в pricing`
+		DailyLimit float64 `yaml:"daily_limit"` // сумма выплат пользователя за сутки, 0 — без лимита
+	} `yaml:"payouts"`
+	Reconciliation struct {
+		Dir           string        `yaml:"dir"`            // отчёты о расчётах: <dir>/stripe/*.csv, <dir>/yookassa/*.csv
+		SettlementLag time.Duration `yaml:"settlement_lag"` // за сколько операция попадает в отчёт провайдера
+	} `yaml:"reconciliation"`
+}
+
+// PricingConfig — комиссии банка за операции клиентов. Правила проверяются
+// по порядку, применяется первое подошедшее; без подходящего правила
+// операция бесплатна. Комиссия зачисляется на счёт доходов банка.
+type PricingConfig struct {
+	Tariffs []string `yaml:"tariffs"` // тарифные планы клиентов; basic есть всегда
+	Rules   []struct {
+		Name           string   `yaml:"name"`
+		Operation      string   `yaml:"operation"` // transfer, withdrawal, payment, payout
+		Channels       []string `yaml:"channels"`  // пусто — любые
+		Currencies     []string `yaml:"currencies"`
+		Tariffs        []string `yaml:"tariffs"`
+		MinAmount      float64  `yaml:"min_amount"`
+		MaxAmount      float64  `yaml:"max_amount"` // 0 — без верхней границы
+		FeePercent     float64  `yaml:"fee_percent"`
+		FeeFixed       float64  `yaml:"fee_fixed"`
+		MinFee         float64  `yaml:"min_fee"`
+		MaxFee         float64  `yaml:"max_fee"`         // 0 — без ограничения
+		FreeOperations int      `yaml:"free_operations"` // бесплатных операций в месяц
+		FreeAmount     float64  `yaml:"free_amount"`     // сумма операций в месяц без комиссии
+	} `yaml:"rules"`
+}
+
+// ProviderRoutingConfig — что принимает провайдер и его комиссия
+type ProviderRoutingConfig struct {
+	Currencies []string `yaml:"currencies"` // пусто — любые
+	Methods    []string `yaml:"methods"`    // пусто — любые
+	FeePercent float64  `yaml:"fee_percent"`
+	FeeFixed   float64  `yaml:"fee_fixed"`
+}
+
+// PaymentRoutingConfig — выбор провайдера и переключение при сбоях
+type PaymentRoutingConfig struct {
+	Breaker struct {
+		FailureThreshold int           `yaml:"failure_threshold"` // подряд ошибок доступности до исключения провайдера
+		OpenTimeout      time.Duration `yaml:"open_timeout"`      // на сколько провайдер исключается
+	} `yaml:"breaker"`
+	// Rules проверяются по порядку, применяется первое подошедшее. Без правил
+	// провайдеры упорядочиваются по комиссии.
+	Rules []struct {
+		Name       string   `yaml:"name"`
+		Currencies []string `yaml:"currencies"`
+		Methods    []string `yaml:"methods"`
+		MinAmount  float64  `yaml:"min_amount"`
+		MaxAmount  float64  `yaml:"max_amount"` // 0 — без верхней границы
+		Providers  []string `yaml:"providers"`  // по приоритету
+	} `yaml:"rules"`
+}
+
 type Config struct {
 	Server struct {
 		Port int `yaml:"port"`
@@ -19,10 +159,38 @@ type Config struct {
 	} `yaml:"jwt"`
 
 	Encryption struct {
-		Secret  string `yaml:"secret"`
-		HMACKey string `yaml:"hmac_key"`
+		KMS              KMSConfig       `yaml:"kms"`
+		Keys             []EncryptionKey `yaml:"keys"`   // статические ключи, только для чтения старых записей
+		Secret           string          `yaml:"secret"` // старый ключ CBC, нужен только для чтения старых записей
+		PreviousHMACKeys []string        `yaml:"previous_hmac_keys"`
+		ReencryptBatch   int             `yaml:"reencrypt_batch"`
 	} `yaml:"encryption"`
 
+	Cards struct {
+		DefaultProduct string                  `yaml:"default_product"`
+		Products       []CardProduct           `yaml:"products"`
+		HomeCountry    string                  `yaml:"home_country"` // ISO 3166 numeric, остальные страны — международные операции
+		Authorization  CardAuthorizationConfig `yaml:"authorization"`
+		Reveal         CardRevealConfig        `yaml:"reveal"`
+		PINMaxTries    int                     `yaml:"pin_max_tries"` // после стольких неверных PIN карта блокируется
+	} `yaml:"cards"`
+
+	HSM HSMConfig `yaml:"hsm"`
+
+	Payments PaymentsConfig `yaml:"payments"`
+
+	Pricing PricingConfig `yaml:"pricing"`
+
+	Beneficiaries struct {
+		RequireConfirmation     bool          `yaml:"require_confirmation"`
+		ConfirmationTTL         time.Duration `yaml:"confirmation_ttl"`
+		MaxConfirmationAttempts int           `yaml:"max_confirmation_attempts"`
+	} `yaml:"beneficiaries"`
+
+	Rewards struct {
+		MaxOptInCategories int `yaml:"max_opt_in_categories"`
+	} `yaml:"rewards"`
+
 	Database struct {
 		Host           string `yaml:"host"`
 		Port           int    `yaml:"port"`
@@ -46,6 +214,61 @@ func Load(path string) error {
 		log.Fatalf("Failed to unmarshal config: %v", err)
 	}
 
+	// ключи карт в файле не хранятся — только в окружении
+	for i := range cfg.Encryption.Keys {
+		if k := &cfg.Encryption.Keys[i]; k.Key == "" {
+			k.Key = os.Getenv(k.EnvName())
+		}
+	}
+
 	AppConfig = &cfg
 	return nil
 }
+
+// CardIssuer создаёт выпускающий модуль номеров карт по секции cards
+func (c *Config) CardIssuer() (*pan.Issuer, error) {
+	ranges := make([]pan.BINRange, 0, len(c.Cards.Products))
+	for _, p := range c.Cards.Products {
+		ranges = append(ranges, pan.BINRange{Product: p.Name, Brand: p.Brand, From: p.BINFrom, To: p.BINTo})
+	}
+	issuer, err := pan.NewIssuer(ranges)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := issuer.Product(c.Cards.DefaultProduct); !ok {
+		return nil, fmt.Errorf("cards.default_product %q is not configured", c.Cards.DefaultProduct)
+	}
+	return issuer, nil
+}
+
+// SoftHSM создаёт программный HSM из секции hsm
+func (c *Config) SoftHSM() (*hsm.SoftHSM, error) {
+	return hsm.NewSoftHSM(c.HSM.ZonePINKey, c.HSM.ZonePINKeyAES, c.HSM.PINVerificationKey, c.HSM.CardVerificationKey)
+}
+
+// Validate проверяет параметры, без которых сервер не должен стартовать
+func (c *Config) Validate() error {
+	if _, err := c.CardIssuer(); err != nil {
+		return fmt.Errorf("cards: %w", err)
+	}
+	if _, err := c.SoftHSM(); err != nil {
+		return err
+	}
+	switch c.Encryption.KMS.Provider {
+	case "local":
+		if c.Encryption.KMS.LocalKeyFile == "" {
+			return fmt.Errorf("encryption.kms.local_key_file is required")
+		}
+	case "vault":
+		v := c.Encryption.KMS.Vault
+		if v.Address == "" || v.Token == "" || v.KeyName == "" || v.HMACKeyCiphertext == "" {
+			return fmt.Errorf("encryption.kms.vault: address, token, key_name and hmac_key_ciphertext are required")
+		}
+	default:
+		return fmt.Errorf("encryption.kms.provider must be local or vault, got %q", c.Encryption.KMS.Provider)
+	}
+	if c.Payments.ReturnURL != "" && c.Payments.ReturnURLSecret == "" {
+		return fmt.Errorf("payments.return_url_secret is required when return_url is set")
+	}
+	return nil
+}
@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"bank-api/internal/middleware"
 	"bank-api/internal/service"
 	"bank-api/internal/utils"
 	"context"
 	"errors"
 	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
 )
 
 type AccountHandler struct {
@@ -36,3 +40,30 @@ func (h *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 
 	utils.RespondJSON(w, http.StatusCreated, account)
 }
+
+// GET /accounts/{id}/balance
+func (h *AccountHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	accountID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid account ID"})
+		return
+	}
+
+	balance, err := h.accountService.GetBalanceDetails(r.Context(), userID, accountID)
+	if err != nil {
+		if errors.Is(err, service.ErrAccountNotFound) {
+			utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": "account not found"})
+			return
+		}
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal server error"})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, balance)
+}
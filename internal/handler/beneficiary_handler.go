@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"bank-api/internal/middleware"
+	"bank-api/internal/models"
+	"bank-api/internal/service"
+	"bank-api/internal/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type BeneficiaryHandler struct {
+	beneficiaryService *service.BeneficiaryService
+}
+
+func NewBeneficiaryHandler(beneficiaryService *service.BeneficiaryService) *BeneficiaryHandler {
+	return &BeneficiaryHandler{beneficiaryService: beneficiaryService}
+}
+
+type confirmBeneficiaryRequest struct {
+	Code string `json:"code"`
+}
+
+// POST /beneficiaries
+func (h *BeneficiaryHandler) AddBeneficiary(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var b models.Beneficiary
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
+	}
+	b.UserID = userID
+
+	if err := h.beneficiaryService.AddBeneficiary(r.Context(), &b); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusCreated
+	if b.Status != "active" {
+		status = http.StatusAccepted
+	}
+	utils.RespondJSON(w, status, b)
+}
+
+// GET /beneficiaries
+func (h *BeneficiaryHandler) ListBeneficiaries(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	list, err := h.beneficiaryService.ListBeneficiaries(r.Context(), userID)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch beneficiaries"})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, list)
+}
+
+// PUT /beneficiaries/{id}
+func (h *BeneficiaryHandler) UpdateBeneficiary(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid beneficiary ID"})
+		return
+	}
+
+	var update models.Beneficiary
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
+	}
+	update.ID = id
+
+	b, err := h.beneficiaryService.UpdateBeneficiary(r.Context(), userID, &update)
+	if err != nil {
+		respondBeneficiaryError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, b)
+}
+
+// POST /beneficiaries/{id}/confirm
+func (h *BeneficiaryHandler) ConfirmBeneficiary(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid beneficiary ID"})
+		return
+	}
+
+	var req confirmBeneficiaryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "code is required"})
+		return
+	}
+
+	b, err := h.beneficiaryService.ConfirmBeneficiary(r.Context(), userID, id, req.Code)
+	if err != nil {
+		respondBeneficiaryError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, b)
+}
+
+// DELETE /beneficiaries/{id}
+func (h *BeneficiaryHandler) DeleteBeneficiary(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid beneficiary ID"})
+		return
+	}
+
+	if err := h.beneficiaryService.DeleteBeneficiary(r.Context(), userID, id); err != nil {
+		respondBeneficiaryError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func respondBeneficiaryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrBeneficiaryNotFound):
+		utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidConfirmationCode):
+		utils.RespondJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, service.ErrConfirmationAttempts):
+		utils.RespondJSON(w, http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+	default:
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/service"
+	"bank-api/internal/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	validChannels = map[string]bool{
+		"": true, models.ChannelPOS: true, models.ChannelEcommerce: true, models.ChannelATM: true,
+	}
+	validEntryModes = map[string]bool{
+		"": true, models.EntryChip: true, models.EntryContactless: true, models.EntryMagstripe: true, models.EntryManual: true,
+	}
+	validPINFormats = map[string]bool{"": true, "iso0": true, "iso4": true}
+)
+
+type CardAuthorizationHandler struct {
+	authService *service.CardAuthorizationService
+}
+
+func NewCardAuthorizationHandler(authService *service.CardAuthorizationService) *CardAuthorizationHandler {
+	return &CardAuthorizationHandler{authService: authService}
+}
+
+// POST /acquirer/authorizations
+// Отказ возвращается со статусом 200: результат — в response_code.
+func (h *CardAuthorizationHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	var req models.AuthorizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
+	}
+	if req.PAN == "" || req.Expiry == "" {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "pan and expiry are required"})
+		return
+	}
+	if !validChannels[req.Channel] || !validEntryModes[req.EntryMode] || !validPINFormats[req.PINFormat] {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown channel, entry_mode or pin_format"})
+		return
+	}
+	if len(req.RRN) > 12 || len(req.STAN) > 6 || len(req.TerminalID) > 8 || len(req.MerchantID) > 15 || len(req.MCC) > 4 || len(req.Country) > 3 {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "rrn, stan, terminal_id, merchant_id, mcc or country is too long"})
+		return
+	}
+
+	auth, err := h.authService.Authorize(r.Context(), &req)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "authorization failed"})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, auth)
+}
+
+type captureRequest struct {
+	Amount float64 `json:"amount"` // 0 — вся сумма авторизации
+}
+
+// POST /acquirer/authorizations/{rrn}/capture
+func (h *CardAuthorizationHandler) Capture(w http.ResponseWriter, r *http.Request) {
+	var req captureRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+			return
+		}
+	}
+
+	auth, err := h.authService.Capture(r.Context(), mux.Vars(r)["rrn"], req.Amount)
+	if err != nil {
+		respondAuthorizationError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, auth)
+}
+
+// POST /acquirer/authorizations/{rrn}/reversal
+func (h *CardAuthorizationHandler) Reverse(w http.ResponseWriter, r *http.Request) {
+	auth, err := h.authService.Reverse(r.Context(), mux.Vars(r)["rrn"])
+	if err != nil {
+		respondAuthorizationError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, auth)
+}
+
+// GET /cards/{id}/authorizations
+func (h *CardAuthorizationHandler) ListByCard(w http.ResponseWriter, r *http.Request) {
+	userID, cardID, ok := cardRequest(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.authService.ListByCard(r.Context(), userID, cardID)
+	if err != nil {
+		respondCardError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, list)
+}
+
+func respondAuthorizationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrAuthorizationNotFound):
+		utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, service.ErrAuthorizationNotHeld):
+		utils.RespondJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidCaptureAmount):
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+	}
+}
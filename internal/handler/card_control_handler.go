@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/service"
+	"bank-api/internal/utils"
+	"encoding/json"
+	"net/http"
+)
+
+type CardControlHandler struct {
+	controlService *service.CardControlService
+}
+
+func NewCardControlHandler(controlService *service.CardControlService) *CardControlHandler {
+	return &CardControlHandler{controlService: controlService}
+}
+
+// GET /cards/{id}/controls
+func (h *CardControlHandler) GetControls(w http.ResponseWriter, r *http.Request) {
+	userID, cardID, ok := cardRequest(w, r)
+	if !ok {
+		return
+	}
+
+	controls, err := h.controlService.Get(r.Context(), userID, cardID)
+	if err != nil {
+		respondCardError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, controls)
+}
+
+// PUT /cards/{id}/controls — заменяет все ограничения карты
+func (h *CardControlHandler) UpdateControls(w http.ResponseWriter, r *http.Request) {
+	userID, cardID, ok := cardRequest(w, r)
+	if !ok {
+		return
+	}
+
+	controls := models.DefaultCardControls(cardID)
+	if err := json.NewDecoder(r.Body).Decode(controls); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
+	}
+
+	if err := h.controlService.Update(r.Context(), userID, cardID, controls); err != nil {
+		respondCardError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, controls)
+}
@@ -2,8 +2,11 @@ package handler
 
 import (
 	"bank-api/internal/middleware"
+	"bank-api/internal/models"
+	"bank-api/internal/repositories"
 	"bank-api/internal/service"
 	"bank-api/internal/utils"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -13,23 +16,39 @@ import (
 )
 
 type CardHandler struct {
-	cardService *service.CardService
+	cardService      *service.CardService
+	lifecycleService *service.CardLifecycleService
 }
 
-func NewCardHandler(cardService *service.CardService) *CardHandler {
-	return &CardHandler{cardService: cardService}
+func NewCardHandler(cardService *service.CardService, lifecycleService *service.CardLifecycleService) *CardHandler {
+	return &CardHandler{cardService: cardService, lifecycleService: lifecycleService}
 }
 
+type createCardRequest struct {
+	AccountID int64  `json:"account_id"`
+	Product   string `json:"product"`
+}
+
+// POST /cards {"account_id": 1, "product": "visa_classic"}; без product — продукт по умолчанию
 func (h *CardHandler) CreateCard(w http.ResponseWriter, r *http.Request) {
-	// Получаем userID из контекста
-	userID, ok := r.Context().Value("userID").(int64)
-	if !ok {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
 		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
 		return
 	}
 
-	card, err := h.cardService.CreateCard(r.Context(), userID)
+	var req createCardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AccountID <= 0 {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "account_id is required"})
+		return
+	}
+
+	card, err := h.cardService.CreateCard(r.Context(), userID, req.AccountID, req.Product)
 	if err != nil {
+		if errors.Is(err, service.ErrCardNotFound) {
+			utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": "account not found"})
+			return
+		}
 		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "could not create card"})
 		return
 	}
@@ -89,38 +108,188 @@ func (h *CardHandler) GetAllCards(w http.ResponseWriter, r *http.Request) {
 	utils.RespondJSON(w, http.StatusOK, cards)
 }
 
-func (h *CardHandler) BlockCard(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	cardIDStr := vars["id"]
-	cardID, err := strconv.ParseInt(cardIDStr, 10, 64)
+type cardReasonRequest struct {
+	Reason string `json:"reason"`
+}
+
+// decodeReason читает необязательное тело {"reason": "..."}
+func decodeReason(r *http.Request) (string, error) {
+	var req cardReasonRequest
+	if r.ContentLength == 0 {
+		return "", nil
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return "", err
+	}
+	return req.Reason, nil
+}
+
+// cardRequest достаёт пользователя и ID карты из запроса
+func cardRequest(w http.ResponseWriter, r *http.Request) (userID, cardID int64, ok bool) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return 0, 0, false
+	}
+	cardID, err = strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
 		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid card ID"})
+		return 0, 0, false
+	}
+	return userID, cardID, true
+}
+
+func respondCardError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrCardNotFound):
+		utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": "card not found"})
+	case errors.Is(err, service.ErrCardBlockedByBank):
+		utils.RespondJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, service.ErrCardTransitionNotAllowed),
+		errors.Is(err, service.ErrCardReplacementPending),
+		errors.Is(err, repositories.ErrCardStatusChanged):
+		utils.RespondJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+}
+
+// PATCH /cards/{id}/block {"reason": "lost"|"stolen"}; без причины карта замораживается
+func (h *CardHandler) BlockCard(w http.ResponseWriter, r *http.Request) {
+	userID, cardID, ok := cardRequest(w, r)
+	if !ok {
+		return
+	}
+	reason, err := decodeReason(r)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
 		return
 	}
 
-	err = h.cardService.BlockCard(r.Context(), cardID)
+	var card *models.Card
+	if reason == service.ReissueLost || reason == service.ReissueStolen {
+		card, err = h.lifecycleService.Block(r.Context(), userID, cardID, reason)
+	} else {
+		card, err = h.lifecycleService.Freeze(r.Context(), userID, cardID)
+	}
 	if err != nil {
-		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "could not block card"})
+		respondCardError(w, err)
 		return
 	}
+	utils.RespondJSON(w, http.StatusOK, card)
+}
 
-	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "card blocked"})
+// POST /cards/{id}/freeze
+func (h *CardHandler) FreezeCard(w http.ResponseWriter, r *http.Request) {
+	userID, cardID, ok := cardRequest(w, r)
+	if !ok {
+		return
+	}
+	card, err := h.lifecycleService.Freeze(r.Context(), userID, cardID)
+	if err != nil {
+		respondCardError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, card)
 }
 
-func (h *CardHandler) DeleteCard(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	cardIDStr := vars["id"]
-	cardID, err := strconv.ParseInt(cardIDStr, 10, 64)
+// POST /cards/{id}/unfreeze
+func (h *CardHandler) UnfreezeCard(w http.ResponseWriter, r *http.Request) {
+	userID, cardID, ok := cardRequest(w, r)
+	if !ok {
+		return
+	}
+	card, err := h.lifecycleService.Unfreeze(r.Context(), userID, cardID)
 	if err != nil {
-		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid card ID"})
+		respondCardError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, card)
+}
+
+// POST /cards/{id}/activate
+func (h *CardHandler) ActivateCard(w http.ResponseWriter, r *http.Request) {
+	userID, cardID, ok := cardRequest(w, r)
+	if !ok {
+		return
+	}
+	card, err := h.lifecycleService.Activate(r.Context(), userID, cardID)
+	if err != nil {
+		respondCardError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, card)
+}
+
+// POST /cards/{id}/reissue {"reason": "damaged"|"lost"|"stolen"}
+func (h *CardHandler) ReissueCard(w http.ResponseWriter, r *http.Request) {
+	userID, cardID, ok := cardRequest(w, r)
+	if !ok {
+		return
+	}
+	reason, err := decodeReason(r)
+	if err != nil || reason == "" {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "reason is required"})
+		return
+	}
+
+	card, err := h.lifecycleService.Reissue(r.Context(), userID, cardID, reason)
+	if err != nil {
+		respondCardError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusCreated, card)
+}
+
+// GET /cards/{id}/history
+func (h *CardHandler) GetCardHistory(w http.ResponseWriter, r *http.Request) {
+	userID, cardID, ok := cardRequest(w, r)
+	if !ok {
+		return
+	}
+	history, err := h.lifecycleService.History(r.Context(), userID, cardID)
+	if err != nil {
+		respondCardError(w, err)
 		return
 	}
+	utils.RespondJSON(w, http.StatusOK, history)
+}
 
-	err = h.cardService.DeleteCard(r.Context(), cardID)
+// DELETE /cards/{id} — карта закрывается, запись остаётся
+func (h *CardHandler) DeleteCard(w http.ResponseWriter, r *http.Request) {
+	userID, cardID, ok := cardRequest(w, r)
+	if !ok {
+		return
+	}
+	card, err := h.lifecycleService.Close(r.Context(), userID, cardID)
 	if err != nil {
-		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "could not delete card"})
+		respondCardError(w, err)
 		return
 	}
+	utils.RespondJSON(w, http.StatusOK, card)
+}
 
-	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "card deleted"})
+type setCardStatusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// PUT /admin/cards/{id}/status
+func (h *CardHandler) SetCardStatus(w http.ResponseWriter, r *http.Request) {
+	adminID, cardID, ok := cardRequest(w, r)
+	if !ok {
+		return
+	}
+	var req setCardStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
+	}
+
+	card, err := h.lifecycleService.SetStatus(r.Context(), adminID, cardID, req.Status, req.Reason)
+	if err != nil {
+		respondCardError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, card)
 }
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"bank-api/internal/service"
+	"bank-api/internal/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type CardPINHandler struct {
+	pinService *service.CardPINService
+}
+
+func NewCardPINHandler(pinService *service.CardPINService) *CardPINHandler {
+	return &CardPINHandler{pinService: pinService}
+}
+
+type changePINRequest struct {
+	CurrentPINBlock string `json:"current_pin_block"`
+	service.PINBlock
+}
+
+// POST /cards/{id}/pin {"pin_block": "<hex>", "format": "iso0"|"iso4"}
+func (h *CardPINHandler) SetPIN(w http.ResponseWriter, r *http.Request) {
+	userID, cardID, ok := cardRequest(w, r)
+	if !ok {
+		return
+	}
+	var req service.PINBlock
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Block == "" {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "pin_block is required"})
+		return
+	}
+
+	if err := h.pinService.SetPIN(r.Context(), userID, cardID, req); err != nil {
+		respondPINError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "PIN set"})
+}
+
+// PUT /cards/{id}/pin {"current_pin_block": "<hex>", "pin_block": "<hex>", "format": "iso0"|"iso4"}
+func (h *CardPINHandler) ChangePIN(w http.ResponseWriter, r *http.Request) {
+	userID, cardID, ok := cardRequest(w, r)
+	if !ok {
+		return
+	}
+	var req changePINRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.CurrentPINBlock == "" || req.Block == "" {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "current_pin_block and pin_block are required"})
+		return
+	}
+
+	current := service.PINBlock{Block: req.CurrentPINBlock, Format: req.Format}
+	if err := h.pinService.ChangePIN(r.Context(), userID, cardID, current, req.PINBlock); err != nil {
+		respondPINError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "PIN changed"})
+}
+
+func respondPINError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrPINAlreadySet), errors.Is(err, service.ErrPINNotSet):
+		utils.RespondJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, service.ErrIncorrectPIN), errors.Is(err, service.ErrPINTriesExceeded):
+		utils.RespondJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	default:
+		respondCardError(w, err)
+	}
+}
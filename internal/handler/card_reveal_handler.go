@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"bank-api/internal/middleware"
+	"bank-api/internal/service"
+	"bank-api/internal/utils"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type CardRevealHandler struct {
+	revealService *service.CardRevealService
+}
+
+func NewCardRevealHandler(revealService *service.CardRevealService) *CardRevealHandler {
+	return &CardRevealHandler{revealService: revealService}
+}
+
+type revealRequest struct {
+	Password string `json:"password"`
+}
+
+// POST /cards/{id}/reveal {"password": "..."} — одноразовый токен для показа реквизитов
+func (h *CardRevealHandler) RequestReveal(w http.ResponseWriter, r *http.Request) {
+	userID, cardID, ok := cardRequest(w, r)
+	if !ok {
+		return
+	}
+	var req revealRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "password is required"})
+		return
+	}
+
+	token, reveal, err := h.revealService.RequestReveal(r.Context(), userID, cardID, req.Password, remoteIP(r), r.UserAgent())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRevealReauth):
+			utils.RespondJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		case errors.Is(err, service.ErrRevealRateLimited):
+			utils.RespondJSON(w, http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+		default:
+			respondCardError(w, err)
+		}
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	utils.RespondJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":      token,
+		"expires_at": reveal.ExpiresAt,
+	})
+}
+
+// GET /cards/reveal/{token} — номер, срок и CVV; токен действует один раз
+func (h *CardRevealHandler) Reveal(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	details, err := h.revealService.Reveal(r.Context(), userID, mux.Vars(r)["token"], remoteIP(r))
+	if err != nil {
+		if errors.Is(err, service.ErrRevealTokenInvalid) {
+			utils.RespondJSON(w, http.StatusGone, map[string]string{"error": err.Error()})
+			return
+		}
+		respondCardError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	utils.RespondJSON(w, http.StatusOK, details)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"bank-api/internal/middleware"
+	"bank-api/internal/service"
+	"bank-api/internal/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type DepositHandler struct {
+	depositService *service.DepositService
+}
+
+func NewDepositHandler(depositService *service.DepositService) *DepositHandler {
+	return &DepositHandler{depositService: depositService}
+}
+
+type openDepositRequest struct {
+	ProductID       int64   `json:"product_id"`
+	LinkedAccountID int64   `json:"linked_account_id"`
+	Amount          float64 `json:"amount"`
+}
+
+type depositAmountRequest struct {
+	Amount float64 `json:"amount"`
+}
+
+// GET /deposits/products
+func (h *DepositHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
+	products, err := h.depositService.ListProducts(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch products"})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, products)
+}
+
+// POST /deposits
+func (h *DepositHandler) OpenDeposit(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var req openDepositRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
+	}
+
+	deposit, err := h.depositService.OpenDeposit(r.Context(), userID, req.ProductID, req.LinkedAccountID, req.Amount)
+	if err != nil {
+		respondDepositError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, deposit)
+}
+
+// GET /deposits
+func (h *DepositHandler) ListDeposits(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	deposits, err := h.depositService.ListDeposits(r.Context(), userID)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch deposits"})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, deposits)
+}
+
+// GET /deposits/{id}
+func (h *DepositHandler) GetDeposit(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid deposit ID"})
+		return
+	}
+
+	deposit, err := h.depositService.GetDeposit(r.Context(), userID, id)
+	if err != nil {
+		respondDepositError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, deposit)
+}
+
+// POST /deposits/{id}/top-up
+func (h *DepositHandler) TopUp(w http.ResponseWriter, r *http.Request) {
+	h.amountOperation(w, r, h.depositService.TopUp, "topped up")
+}
+
+// POST /deposits/{id}/withdraw
+func (h *DepositHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
+	h.amountOperation(w, r, h.depositService.Withdraw, "withdrawn")
+}
+
+// POST /deposits/{id}/close
+func (h *DepositHandler) CloseDeposit(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid deposit ID"})
+		return
+	}
+
+	if err := h.depositService.CloseDeposit(r.Context(), userID, id); err != nil {
+		respondDepositError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "closed"})
+}
+
+func (h *DepositHandler) amountOperation(
+	w http.ResponseWriter,
+	r *http.Request,
+	op func(ctx context.Context, userID, id int64, amount float64) error,
+	status string,
+) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid deposit ID"})
+		return
+	}
+
+	var req depositAmountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Amount <= 0 {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid amount"})
+		return
+	}
+
+	if err := op(r.Context(), userID, id, req.Amount); err != nil {
+		respondDepositError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"status": status})
+}
+
+func respondDepositError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrDepositNotFound), errors.Is(err, service.ErrProductNotFound):
+		utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, service.ErrOperationDenied), errors.Is(err, service.ErrDepositNotOpen):
+		utils.RespondJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"bank-api/internal/middleware"
+	"bank-api/internal/service"
+	"bank-api/internal/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type NotificationHandler struct {
+	notificationService *service.NotificationService
+}
+
+func NewNotificationHandler(notificationService *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notificationService: notificationService}
+}
+
+// GET /notifications?unread=true
+func (h *NotificationHandler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+	list, err := h.notificationService.ListNotifications(r.Context(), userID, unreadOnly)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch notifications"})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, list)
+}
+
+// POST /notifications/{id}/read
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid notification ID"})
+		return
+	}
+
+	if err := h.notificationService.MarkRead(r.Context(), userID, id); err != nil {
+		if errors.Is(err, service.ErrNotificationNotFound) {
+			utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "read"})
+}
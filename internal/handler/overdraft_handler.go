@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"bank-api/internal/middleware"
+	"bank-api/internal/service"
+	"bank-api/internal/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type OverdraftHandler struct {
+	overdraftService *service.OverdraftService
+}
+
+func NewOverdraftHandler(overdraftService *service.OverdraftService) *OverdraftHandler {
+	return &OverdraftHandler{overdraftService: overdraftService}
+}
+
+type grantOverdraftRequest struct {
+	Limit        float64 `json:"limit"`
+	InterestRate float64 `json:"interest_rate"`
+}
+
+// PUT /admin/accounts/{id}/overdraft
+func (h *OverdraftHandler) GrantOverdraft(w http.ResponseWriter, r *http.Request) {
+	adminID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	accountID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid account ID"})
+		return
+	}
+
+	var req grantOverdraftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
+	}
+
+	overdraft, err := h.overdraftService.Grant(r.Context(), adminID, accountID, req.Limit, req.InterestRate)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, overdraft)
+}
+
+// GET /admin/accounts/{id}/overdraft
+func (h *OverdraftHandler) GetOverdraft(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid account ID"})
+		return
+	}
+
+	overdraft, err := h.overdraftService.GetByAccount(r.Context(), accountID)
+	if err != nil {
+		if errors.Is(err, service.ErrOverdraftNotFound) {
+			utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, overdraft)
+}
+
+// DELETE /admin/accounts/{id}/overdraft
+func (h *OverdraftHandler) RevokeOverdraft(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid account ID"})
+		return
+	}
+
+	if err := h.overdraftService.Revoke(r.Context(), accountID); err != nil {
+		utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
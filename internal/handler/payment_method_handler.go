@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"bank-api/internal/middleware"
+	"bank-api/internal/payment/providers"
+	"bank-api/internal/service"
+	"bank-api/internal/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// PaymentMethodHandler обслуживает хранилище способов оплаты на /payments и /api/payment-methods
+type PaymentMethodHandler struct {
+	paymentMethodService *service.PaymentMethodService
+}
+
+func NewPaymentMethodHandler(paymentMethodService *service.PaymentMethodService) *PaymentMethodHandler {
+	return &PaymentMethodHandler{paymentMethodService: paymentMethodService}
+}
+
+// POST /payments, POST /api/payment-methods. 201 — способ добавлен,
+// 200 — такой уже был сохранён.
+func (h *PaymentMethodHandler) AddPaymentMethod(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var input service.PaymentMethodInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
+	}
+
+	method, created, err := h.paymentMethodService.AddPaymentMethod(r.Context(), userID, input)
+	if err != nil {
+		utils.RespondJSON(w, paymentMethodErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+
+	code := http.StatusOK
+	if created {
+		code = http.StatusCreated
+	}
+	utils.RespondJSON(w, code, method)
+}
+
+// GET /payments, GET /api/payment-methods
+func (h *PaymentMethodHandler) GetPaymentMethods(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	methods, err := h.paymentMethodService.GetPaymentMethods(r.Context(), userID)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch payment methods"})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, methods)
+}
+
+// POST /payments/{id}/default, POST /api/payment-methods/{id}/default
+func (h *PaymentMethodHandler) SetDefault(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := paymentMethodRequest(w, r)
+	if !ok {
+		return
+	}
+
+	method, err := h.paymentMethodService.SetDefault(r.Context(), userID, id)
+	if err != nil {
+		utils.RespondJSON(w, paymentMethodErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, method)
+}
+
+// DELETE /payments/{id}, DELETE /api/payment-methods/{id}
+func (h *PaymentMethodHandler) DeletePaymentMethod(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := paymentMethodRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.paymentMethodService.DeletePaymentMethod(r.Context(), userID, id); err != nil {
+		utils.RespondJSON(w, paymentMethodErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func paymentMethodRequest(w http.ResponseWriter, r *http.Request) (userID, id int64, ok bool) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return 0, 0, false
+	}
+	id, err = strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid payment method ID"})
+		return 0, 0, false
+	}
+	return userID, id, true
+}
+
+func paymentMethodErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrPaymentMethodNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidPaymentMethod), errors.Is(err, service.ErrTokenizationUnsupported):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrPaymentMethodExpired):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, providers.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
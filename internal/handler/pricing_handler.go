@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"bank-api/internal/middleware"
+	"bank-api/internal/models"
+	"bank-api/internal/service"
+	"bank-api/internal/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type PricingHandler struct {
+	pricingService *service.PricingService
+}
+
+func NewPricingHandler(pricingService *service.PricingService) *PricingHandler {
+	return &PricingHandler{pricingService: pricingService}
+}
+
+// GET /fees/quote?operation=transfer&amount=1500&channel=p2p&currency=RUB
+// Комиссия за операцию по тарифу клиента до её выполнения. Каналы: для
+// transfer — own или p2p, для payment — способ оплаты (card, sbp,
+// yoo_money), для payout — тип получателя (card, yoo_money).
+func (h *PricingHandler) Quote(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	query := r.URL.Query()
+	amount, err := strconv.ParseFloat(query.Get("amount"), 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid amount"})
+		return
+	}
+
+	quote, err := h.pricingService.Quote(r.Context(), models.FeeRequest{
+		UserID:    userID,
+		Operation: query.Get("operation"),
+		Channel:   query.Get("channel"),
+		Currency:  query.Get("currency"),
+		Amount:    amount,
+	})
+	if errors.Is(err, service.ErrUserNotFound) {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, quote)
+}
+
+type setTariffRequest struct {
+	Tariff string `json:"tariff"`
+}
+
+// PUT /admin/users/{id}/tariff
+func (h *PricingHandler) SetTariff(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user ID"})
+		return
+	}
+
+	var req setTariffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
+	}
+
+	err = h.pricingService.SetTariff(r.Context(), userID, req.Tariff)
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, service.ErrUnknownTariff):
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case err != nil:
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+	default:
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"tariff": req.Tariff})
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"bank-api/internal/middleware"
+	"bank-api/internal/models"
+	"bank-api/internal/service"
+	"bank-api/internal/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type RewardHandler struct {
+	rewardService *service.RewardService
+}
+
+func NewRewardHandler(rewardService *service.RewardService) *RewardHandler {
+	return &RewardHandler{rewardService: rewardService}
+}
+
+// GET /rewards/campaigns
+func (h *RewardHandler) ListCampaigns(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	campaigns, err := h.rewardService.ListCampaigns(r.Context(), userID)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch campaigns"})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, campaigns)
+}
+
+// POST /rewards/campaigns/{id}/opt-in
+func (h *RewardHandler) OptIn(w http.ResponseWriter, r *http.Request) {
+	h.toggleOptIn(w, r, true)
+}
+
+// DELETE /rewards/campaigns/{id}/opt-in
+func (h *RewardHandler) OptOut(w http.ResponseWriter, r *http.Request) {
+	h.toggleOptIn(w, r, false)
+}
+
+func (h *RewardHandler) toggleOptIn(w http.ResponseWriter, r *http.Request, enable bool) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	campaignID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid campaign ID"})
+		return
+	}
+
+	status := "opted_in"
+	if enable {
+		err = h.rewardService.OptIn(r.Context(), userID, campaignID)
+	} else {
+		status = "opted_out"
+		err = h.rewardService.OptOut(r.Context(), userID, campaignID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrCampaignNotFound):
+			utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, service.ErrOptInLimitReached):
+			utils.RespondJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"status": status})
+}
+
+// GET /rewards/summary?month=2024-05
+func (h *RewardHandler) Summary(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	month := time.Now()
+	if m := r.URL.Query().Get("month"); m != "" {
+		month, err = time.Parse("2006-01", m)
+		if err != nil {
+			utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "month must be in YYYY-MM format"})
+			return
+		}
+	}
+
+	summary, err := h.rewardService.Summary(r.Context(), userID, month)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch cashback summary"})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, summary)
+}
+
+// POST /admin/rewards/campaigns
+func (h *RewardHandler) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	var campaign models.RewardCampaign
+	if err := json.NewDecoder(r.Body).Decode(&campaign); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
+	}
+
+	if err := h.rewardService.CreateCampaign(r.Context(), &campaign); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.RespondJSON(w, http.StatusCreated, campaign)
+}
+
+// DELETE /admin/rewards/campaigns/{id}
+func (h *RewardHandler) DeactivateCampaign(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid campaign ID"})
+		return
+	}
+
+	if err := h.rewardService.DeactivateCampaign(r.Context(), id); err != nil {
+		utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "deactivated"})
+}
@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"bank-api/internal/middleware"
+	"bank-api/internal/models"
+	"bank-api/internal/service"
+	"bank-api/internal/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type SavingsGoalHandler struct {
+	goalService *service.SavingsGoalService
+}
+
+func NewSavingsGoalHandler(goalService *service.SavingsGoalService) *SavingsGoalHandler {
+	return &SavingsGoalHandler{goalService: goalService}
+}
+
+type createGoalRequest struct {
+	Name            string  `json:"name"`
+	TargetAmount    float64 `json:"target_amount"`
+	TargetDate      string  `json:"target_date,omitempty"` // YYYY-MM-DD
+	SourceAccountID int64   `json:"source_account_id"`
+}
+
+type goalAmountRequest struct {
+	Amount float64 `json:"amount"`
+}
+
+// POST /goals
+func (h *SavingsGoalHandler) CreateGoal(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var req createGoalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
+	}
+
+	goal := &models.SavingsGoal{
+		UserID:          userID,
+		Name:            req.Name,
+		TargetAmount:    req.TargetAmount,
+		SourceAccountID: req.SourceAccountID,
+	}
+	if req.TargetDate != "" {
+		date, err := time.Parse("2006-01-02", req.TargetDate)
+		if err != nil {
+			utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "target_date must be YYYY-MM-DD"})
+			return
+		}
+		goal.TargetDate = &date
+	}
+
+	if err := h.goalService.CreateGoal(r.Context(), goal); err != nil {
+		respondGoalError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, goal)
+}
+
+// GET /goals
+func (h *SavingsGoalHandler) ListGoals(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	goals, err := h.goalService.ListGoals(r.Context(), userID)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch goals"})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, goals)
+}
+
+// GET /goals/{id}
+func (h *SavingsGoalHandler) GetGoal(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := goalRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	progress, err := h.goalService.GetProgress(r.Context(), userID, id)
+	if err != nil {
+		respondGoalError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, progress)
+}
+
+// POST /goals/{id}/deposit
+func (h *SavingsGoalHandler) MoveIn(w http.ResponseWriter, r *http.Request) {
+	h.amountOperation(w, r, h.goalService.MoveIn)
+}
+
+// POST /goals/{id}/withdraw
+func (h *SavingsGoalHandler) MoveOut(w http.ResponseWriter, r *http.Request) {
+	h.amountOperation(w, r, h.goalService.MoveOut)
+}
+
+// POST /goals/{id}/close
+func (h *SavingsGoalHandler) CloseGoal(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := goalRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.goalService.CloseGoal(r.Context(), userID, id); err != nil {
+		respondGoalError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "closed"})
+}
+
+// POST /goals/{id}/rules
+func (h *SavingsGoalHandler) AddRule(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := goalRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var rule models.SavingsRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
+	}
+	rule.GoalID = id
+
+	if err := h.goalService.AddRule(r.Context(), userID, &rule); err != nil {
+		respondGoalError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, rule)
+}
+
+// GET /goals/{id}/rules
+func (h *SavingsGoalHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := goalRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	rules, err := h.goalService.ListRules(r.Context(), userID, id)
+	if err != nil {
+		respondGoalError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, rules)
+}
+
+// DELETE /goals/{id}/rules/{ruleID}
+func (h *SavingsGoalHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := goalRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	ruleID, err := strconv.ParseInt(mux.Vars(r)["ruleID"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid rule ID"})
+		return
+	}
+
+	if err := h.goalService.DeleteRule(r.Context(), userID, id, ruleID); err != nil {
+		respondGoalError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (h *SavingsGoalHandler) amountOperation(
+	w http.ResponseWriter,
+	r *http.Request,
+	op func(ctx context.Context, userID, id int64, amount float64) error,
+) {
+	userID, id, ok := goalRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req goalAmountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Amount <= 0 {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid amount"})
+		return
+	}
+
+	if err := op(r.Context(), userID, id, req.Amount); err != nil {
+		respondGoalError(w, err)
+		return
+	}
+
+	progress, err := h.goalService.GetProgress(r.Context(), userID, id)
+	if err != nil {
+		respondGoalError(w, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, progress)
+}
+
+func goalRequestIDs(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return 0, 0, false
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid goal ID"})
+		return 0, 0, false
+	}
+	return userID, id, true
+}
+
+func respondGoalError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrGoalNotFound):
+		utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, service.ErrGoalClosed):
+		utils.RespondJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"bank-api/internal/middleware"
+	"bank-api/internal/models"
+	"bank-api/internal/service"
+	"bank-api/internal/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type TransferTemplateHandler struct {
+	templateService *service.TransferTemplateService
+}
+
+func NewTransferTemplateHandler(templateService *service.TransferTemplateService) *TransferTemplateHandler {
+	return &TransferTemplateHandler{templateService: templateService}
+}
+
+type payFromTemplateRequest struct {
+	Amount float64 `json:"amount,omitempty"`
+}
+
+// POST /templates
+func (h *TransferTemplateHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var t models.TransferTemplate
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
+	}
+	t.UserID = userID
+
+	if err := h.templateService.CreateTemplate(r.Context(), &t); err != nil {
+		respondTemplateError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, t)
+}
+
+// GET /templates
+func (h *TransferTemplateHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	list, err := h.templateService.ListTemplates(r.Context(), userID)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch templates"})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, list)
+}
+
+// PUT /templates/{id}
+func (h *TransferTemplateHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid template ID"})
+		return
+	}
+
+	var t models.TransferTemplate
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
+	}
+	t.ID = id
+	t.UserID = userID
+
+	if err := h.templateService.UpdateTemplate(r.Context(), &t); err != nil {
+		respondTemplateError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, t)
+}
+
+// DELETE /templates/{id}
+func (h *TransferTemplateHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid template ID"})
+		return
+	}
+
+	if err := h.templateService.DeleteTemplate(r.Context(), userID, id); err != nil {
+		respondTemplateError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// POST /templates/{id}/pay
+func (h *TransferTemplateHandler) PayFromTemplate(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid template ID"})
+		return
+	}
+
+	// Тело запроса необязательно: без него берётся сумма из шаблона
+	var req payFromTemplateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+			return
+		}
+	}
+
+	txnID, err := h.templateService.PayFromTemplate(r.Context(), userID, id, req.Amount)
+	if err != nil {
+		respondTemplateError(w, err)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, map[string]int64{"transaction_id": txnID})
+}
+
+func respondTemplateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrTemplateNotFound), errors.Is(err, service.ErrBeneficiaryNotFound):
+		utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, service.ErrBeneficiaryNotConfirmed):
+		utils.RespondJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	default:
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"bank-api/internal/middleware"
+	"bank-api/internal/service"
+	"bank-api/internal/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type VirtualCardHandler struct {
+	virtualService *service.VirtualCardService
+}
+
+func NewVirtualCardHandler(virtualService *service.VirtualCardService) *VirtualCardHandler {
+	return &VirtualCardHandler{virtualService: virtualService}
+}
+
+// POST /cards/virtual {"account_id": 1, "type": "single_use", "spend_cap": 5000, "expires_in_days": 7}
+func (h *VirtualCardHandler) CreateVirtualCard(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var req service.VirtualCardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AccountID <= 0 {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "account_id is required"})
+		return
+	}
+
+	card, err := h.virtualService.Create(r.Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrCardNotFound) {
+			utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": "account not found"})
+			return
+		}
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.RespondJSON(w, http.StatusCreated, card)
+}
@@ -0,0 +1,39 @@
+// Package hsm — программная замена платёжного HSM для операций с PIN и CVV.
+// PIN в открытом виде существует только внутри вызова: наружу выходят
+// зашифрованные PIN-блоки и смещения IBM 3624.
+package hsm
+
+import "errors"
+
+// Форматы PIN-блока ISO 9564-1
+const (
+	FormatISO0 = 0 // 8 байт, шифруется TDES-ключом зоны
+	FormatISO4 = 4 // 16 байт, шифруется AES-ключом зоны
+)
+
+const (
+	MinPINLength = 4
+	MaxPINLength = 12
+)
+
+var (
+	ErrInvalidPINBlock = errors.New("hsm: invalid PIN block")
+	ErrWeakPIN         = errors.New("hsm: PIN is too simple")
+	ErrUnsupported     = errors.New("hsm: unsupported PIN block format")
+	ErrInvalidCVVData  = errors.New("hsm: invalid CVV input")
+)
+
+// HSM — операции с PIN, которые банк выполняет в HSM
+type HSM interface {
+	// GeneratePINOffset расшифровывает PIN-блок ключом зоны и вычисляет смещение PIN
+	GeneratePINOffset(block []byte, format int, pan string) (string, error)
+	// VerifyPIN проверяет PIN из блока по сохранённому смещению
+	VerifyPIN(block []byte, format int, pan, offset string) (bool, error)
+	// EncryptPINBlock формирует PIN-блок, как это делает терминал (для тестовых стендов)
+	EncryptPINBlock(pin string, format int, pan string) ([]byte, error)
+	// GenerateCVV вычисляет CVV по номеру, сроку YYMM и сервисному коду ключом CVK
+	GenerateCVV(pan, expiry, serviceCode string) (string, error)
+}
+
+// ServiceCodeCVV2 — сервисный код для CVV2, который печатается на карте
+const ServiceCodeCVV2 = "000"
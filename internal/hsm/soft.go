@@ -0,0 +1,285 @@
+package hsm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// decimalization — таблица перевода hex-цифр в десятичные для IBM 3624
+const decimalization = "0123456789012345"
+
+// SoftHSM хранит ключи в памяти процесса. Только для разработки и тестовых стендов.
+type SoftHSM struct {
+	zoneTDES cipher.Block // ZPK для блоков формата 0
+	zoneAES  cipher.Block // ZPK для блоков формата 4
+	pvk      cipher.Block // ключ проверки PIN
+	cvkA     cipher.Block // левая половина CVK
+	cvk      cipher.Block // CVK целиком (TDES)
+}
+
+// NewSoftHSM принимает ключи в hex: TDES — 16 или 24 байта, AES — 16, 24 или 32 байта,
+// CVK — пара DES-ключей A и B, 16 байт
+func NewSoftHSM(zoneKeyTDES, zoneKeyAES, pinVerificationKey, cardVerificationKey string) (*SoftHSM, error) {
+	zoneTDES, err := tdesCipher(zoneKeyTDES)
+	if err != nil {
+		return nil, fmt.Errorf("hsm: zone TDES key: %w", err)
+	}
+	pvk, err := tdesCipher(pinVerificationKey)
+	if err != nil {
+		return nil, fmt.Errorf("hsm: PIN verification key: %w", err)
+	}
+	key, err := hex.DecodeString(zoneKeyAES)
+	if err != nil {
+		return nil, fmt.Errorf("hsm: zone AES key: %w", err)
+	}
+	zoneAES, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("hsm: zone AES key: %w", err)
+	}
+	cvkKey, err := hex.DecodeString(cardVerificationKey)
+	if err != nil || len(cvkKey) != 16 {
+		return nil, fmt.Errorf("hsm: card verification key must be 16 bytes in hex")
+	}
+	cvkA, _ := des.NewCipher(cvkKey[:8])
+	cvk, _ := tdesCipher(cardVerificationKey)
+	return &SoftHSM{zoneTDES: zoneTDES, zoneAES: zoneAES, pvk: pvk, cvkA: cvkA, cvk: cvk}, nil
+}
+
+func tdesCipher(hexKey string) (cipher.Block, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, err
+	}
+	switch len(key) {
+	case 16: // двухключевой TDES: K1 K2 K1
+		key = append(key, key[:8]...)
+	case 24:
+	default:
+		return nil, fmt.Errorf("key must be 16 or 24 bytes, got %d", len(key))
+	}
+	return des.NewTripleDESCipher(key)
+}
+
+func (h *SoftHSM) GeneratePINOffset(block []byte, format int, pan string) (string, error) {
+	pin, err := h.decodePINBlock(block, format, pan)
+	if err != nil {
+		return "", err
+	}
+	if weakPIN(pin) {
+		return "", ErrWeakPIN
+	}
+	natural, err := h.naturalPIN(pan, len(pin))
+	if err != nil {
+		return "", err
+	}
+
+	offset := make([]byte, len(pin))
+	for i := range pin {
+		offset[i] = '0' + (pin[i]-natural[i]+10)%10
+	}
+	return string(offset), nil
+}
+
+func (h *SoftHSM) VerifyPIN(block []byte, format int, pan, offset string) (bool, error) {
+	pin, err := h.decodePINBlock(block, format, pan)
+	if err != nil {
+		return false, err
+	}
+	if len(pin) != len(offset) {
+		return false, nil
+	}
+	natural, err := h.naturalPIN(pan, len(offset))
+	if err != nil {
+		return false, err
+	}
+
+	expected := make([]byte, len(offset))
+	for i := range offset {
+		expected[i] = '0' + (natural[i]-'0'+offset[i]-'0')%10
+	}
+	return subtle.ConstantTimeCompare([]byte(pin), expected) == 1, nil
+}
+
+// naturalPIN — «естественный» PIN IBM 3624: номер карты, зашифрованный PVK
+// и переведённый в десятичные цифры
+func (h *SoftHSM) naturalPIN(pan string, length int) (string, error) {
+	if len(pan) < 16 || !isDigits(pan) {
+		return "", fmt.Errorf("hsm: invalid PAN")
+	}
+	validation, _ := hex.DecodeString(pan[len(pan)-16:])
+	encrypted := make([]byte, 8)
+	h.pvk.Encrypt(encrypted, validation)
+
+	var natural strings.Builder
+	for _, c := range hex.EncodeToString(encrypted)[:length] {
+		natural.WriteByte(decimalization[strings.IndexRune("0123456789abcdef", c)])
+	}
+	return natural.String(), nil
+}
+
+func (h *SoftHSM) EncryptPINBlock(pin string, format int, pan string) ([]byte, error) {
+	if len(pin) < MinPINLength || len(pin) > MaxPINLength || !isDigits(pin) {
+		return nil, ErrInvalidPINBlock
+	}
+	switch format {
+	case FormatISO0:
+		panField, err := iso0PANField(pan)
+		if err != nil {
+			return nil, err
+		}
+		pinField, _ := hex.DecodeString(fmt.Sprintf("0%X%s", len(pin), pin) + strings.Repeat("F", 14-len(pin)))
+		clear := xor(pinField, panField)
+		block := make([]byte, 8)
+		h.zoneTDES.Encrypt(block, clear)
+		return block, nil
+
+	case FormatISO4:
+		panField, err := iso4PANField(pan)
+		if err != nil {
+			return nil, err
+		}
+		random := make([]byte, 8)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		pinField, _ := hex.DecodeString(fmt.Sprintf("4%X%s", len(pin), pin) + strings.Repeat("A", 14-len(pin)))
+		pinField = append(pinField, random...)
+
+		intermediate := make([]byte, 16)
+		h.zoneAES.Encrypt(intermediate, pinField)
+		block := make([]byte, 16)
+		h.zoneAES.Encrypt(block, xor(intermediate, panField))
+		return block, nil
+	}
+	return nil, ErrUnsupported
+}
+
+// GenerateCVV — алгоритм CVV/CVV2: номер, срок и сервисный код дополняются
+// нулями до 32 цифр, первая половина шифруется ключом A, складывается со второй
+// по XOR и шифруется CVK целиком; из результата берутся первые три цифры.
+func (h *SoftHSM) GenerateCVV(pan, expiry, serviceCode string) (string, error) {
+	data := pan + expiry + serviceCode
+	if len(pan) < 12 || len(expiry) != 4 || len(serviceCode) != 3 || !isDigits(data) || len(data) > 32 {
+		return "", ErrInvalidCVVData
+	}
+	data += strings.Repeat("0", 32-len(data))
+	raw, _ := hex.DecodeString(data)
+
+	block := make([]byte, 8)
+	h.cvkA.Encrypt(block, raw[:8])
+	result := make([]byte, 8)
+	h.cvk.Encrypt(result, xor(block, raw[8:]))
+
+	encoded := strings.ToUpper(hex.EncodeToString(result))
+	var digits, rest strings.Builder
+	for _, c := range encoded {
+		if c >= '0' && c <= '9' {
+			digits.WriteRune(c)
+		} else {
+			rest.WriteByte(byte('0' + c - 'A'))
+		}
+	}
+	return (digits.String() + rest.String())[:3], nil
+}
+
+// decodePINBlock расшифровывает блок и возвращает PIN
+func (h *SoftHSM) decodePINBlock(block []byte, format int, pan string) (string, error) {
+	var pinField []byte
+	switch format {
+	case FormatISO0:
+		if len(block) != 8 {
+			return "", ErrInvalidPINBlock
+		}
+		panField, err := iso0PANField(pan)
+		if err != nil {
+			return "", err
+		}
+		clear := make([]byte, 8)
+		h.zoneTDES.Decrypt(clear, block)
+		pinField = xor(clear, panField)
+
+	case FormatISO4:
+		if len(block) != 16 {
+			return "", ErrInvalidPINBlock
+		}
+		panField, err := iso4PANField(pan)
+		if err != nil {
+			return "", err
+		}
+		intermediate := make([]byte, 16)
+		h.zoneAES.Decrypt(intermediate, block)
+		pinField = make([]byte, 16)
+		h.zoneAES.Decrypt(pinField, xor(intermediate, panField))
+
+	default:
+		return "", ErrUnsupported
+	}
+
+	// управляющий полубайт, длина, цифры PIN, заполнитель (F или A) до 16-го полубайта
+	nibbles := strings.ToUpper(hex.EncodeToString(pinField))
+	fill := map[int]byte{FormatISO0: 'F', FormatISO4: 'A'}[format]
+	if nibbles[0] != byte('0'+format) {
+		return "", ErrInvalidPINBlock
+	}
+	length := strings.IndexByte("0123456789ABCDEF", nibbles[1])
+	if length < MinPINLength || length > MaxPINLength {
+		return "", ErrInvalidPINBlock
+	}
+	pin := nibbles[2 : 2+length]
+	if !isDigits(pin) || strings.Trim(nibbles[2+length:16], string(fill)) != "" {
+		return "", ErrInvalidPINBlock
+	}
+	return pin, nil
+}
+
+// iso0PANField — 0000 и 12 правых цифр номера без контрольной
+func iso0PANField(pan string) ([]byte, error) {
+	if len(pan) < 13 || !isDigits(pan) {
+		return nil, fmt.Errorf("hsm: invalid PAN")
+	}
+	digits := pan[len(pan)-13 : len(pan)-1]
+	return hex.DecodeString("0000" + digits)
+}
+
+// iso4PANField — длина номера сверх 12 цифр, номер целиком, нули до 32 полубайт
+func iso4PANField(pan string) ([]byte, error) {
+	if len(pan) < 12 || len(pan) > 19 || !isDigits(pan) {
+		return nil, fmt.Errorf("hsm: invalid PAN")
+	}
+	field := fmt.Sprintf("%d%s", len(pan)-12, pan)
+	return hex.DecodeString(field + strings.Repeat("0", 32-len(field)))
+}
+
+// weakPIN — одинаковые цифры или последовательность: 1111, 1234, 9876
+func weakPIN(pin string) bool {
+	same, up, down := true, true, true
+	for i := 1; i < len(pin); i++ {
+		same = same && pin[i] == pin[0]
+		up = up && pin[i] == pin[i-1]+1
+		down = down && pin[i] == pin[i-1]-1
+	}
+	return same || up || down
+}
+
+func xor(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
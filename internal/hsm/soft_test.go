@@ -0,0 +1,284 @@
+package hsm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/des"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+const (
+	testZoneTDES = "0123456789ABCDEFFEDCBA9876543210"
+	testZoneAES  = "00112233445566778899AABBCCDDEEFF"
+	testPVK      = "0123456789ABCDEF0123456789ABCDEF" // K1 = K2: TDES сводится к DES 0123456789ABCDEF
+	testCVK      = "0123456789ABCDEFFEDCBA9876543210"
+)
+
+func newTestHSM(t *testing.T) *SoftHSM {
+	t.Helper()
+	h, err := NewSoftHSM(testZoneTDES, testZoneAES, testPVK, testCVK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// testPIN превращает произвольные байты в PIN допустимой длины
+func testPIN(raw [MaxPINLength]byte, n uint8) string {
+	length := MinPINLength + int(n)%(MaxPINLength-MinPINLength+1)
+	pin := make([]byte, length)
+	for i := range pin {
+		pin[i] = '0' + raw[i]%10
+	}
+	return string(pin)
+}
+
+// Пример ISO 9564-1: PIN 1234, PAN 43219876543210987 — открытый блок формата 0
+// 041234FFFFFFFFFF XOR 0000987654321098 = 0412AC89ABCDEF67
+func TestEncryptPINBlockISO0KnownAnswer(t *testing.T) {
+	h := newTestHSM(t)
+	block, err := h.EncryptPINBlock("1234", FormatISO0, "43219876543210987")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := mustHex(t, testZoneTDES)
+	zpk, err := des.NewTripleDESCipher(append(key, key[:8]...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clear := make([]byte, 8)
+	zpk.Decrypt(clear, block)
+	if got := strings.ToUpper(hex.EncodeToString(clear)); got != "0412AC89ABCDEF67" {
+		t.Errorf("clear ISO 0 block = %s, want 0412AC89ABCDEF67", got)
+	}
+}
+
+func TestISO4PANField(t *testing.T) {
+	for pan, want := range map[string]string{
+		"1234567890123456789": "71234567890123456789000000000000",
+		"432198765432":        "04321987654320000000000000000000",
+		"4111111111111111":    "44111111111111111000000000000000",
+	} {
+		field, err := iso4PANField(pan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.ToUpper(hex.EncodeToString(field)); got != want {
+			t.Errorf("PAN field for %s = %s, want %s", pan, got, want)
+		}
+	}
+}
+
+// Блок формата 4 — AES(AES(PIN-поле) XOR PAN-поле); PIN-поле начинается
+// с 4, длины и цифр, дополняется A и случайными 8 байтами
+func TestEncryptPINBlockISO4Layout(t *testing.T) {
+	h := newTestHSM(t)
+	pan := "1234567890123456789"
+	zpk, err := aes.NewCipher(mustHex(t, testZoneAES))
+	if err != nil {
+		t.Fatal(err)
+	}
+	panField, _ := iso4PANField(pan)
+
+	var randoms [][]byte
+	for i := 0; i < 2; i++ {
+		block, err := h.EncryptPINBlock("1234", FormatISO4, pan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		intermediate := make([]byte, 16)
+		zpk.Decrypt(intermediate, block)
+		pinField := make([]byte, 16)
+		zpk.Decrypt(pinField, xor(intermediate, panField))
+
+		if got := strings.ToUpper(hex.EncodeToString(pinField[:8])); got != "441234AAAAAAAAAA" {
+			t.Errorf("ISO 4 PIN field = %s, want 441234AAAAAAAAAA", got)
+		}
+		randoms = append(randoms, pinField[8:])
+	}
+	if bytes.Equal(randoms[0], randoms[1]) {
+		t.Error("ISO 4 blocks of the same PIN share the random fill")
+	}
+}
+
+func TestPINBlockRoundTrip(t *testing.T) {
+	h := newTestHSM(t)
+	const pan, otherPAN = "4111111111111111", "4111111111111129"
+
+	for _, format := range []int{FormatISO0, FormatISO4} {
+		property := func(raw [MaxPINLength]byte, n uint8) bool {
+			pin := testPIN(raw, n)
+			block, err := h.EncryptPINBlock(pin, format, pan)
+			if err != nil {
+				t.Log(err)
+				return false
+			}
+			got, err := h.decodePINBlock(block, format, pan)
+			if err != nil || got != pin {
+				return false
+			}
+			// блок привязан к номеру карты: с другим PAN тот же PIN не получается
+			other, err := h.decodePINBlock(block, format, otherPAN)
+			return err != nil || other != pin
+		}
+		if err := quick.Check(property, nil); err != nil {
+			t.Errorf("format %d: %v", format, err)
+		}
+	}
+}
+
+func TestDecodePINBlockRejectsMalformed(t *testing.T) {
+	h := newTestHSM(t)
+	const pan = "4111111111111111"
+
+	for _, tc := range []struct {
+		block  []byte
+		format int
+		want   error
+	}{
+		{make([]byte, 16), FormatISO0, ErrInvalidPINBlock},
+		{make([]byte, 8), FormatISO4, ErrInvalidPINBlock},
+		{make([]byte, 8), 1, ErrUnsupported},
+	} {
+		if _, err := h.decodePINBlock(tc.block, tc.format, pan); !errors.Is(err, tc.want) {
+			t.Errorf("format %d, %d bytes: err = %v, want %v", tc.format, len(tc.block), err, tc.want)
+		}
+	}
+}
+
+// IBM 3624: DES(0123456789ABCDEF, 4000001234562000) = C693B53645D13B68,
+// после децимализации естественный PIN 2693; смещение для PIN 1357 — 9764
+func TestPINOffsetKnownAnswer(t *testing.T) {
+	h := newTestHSM(t)
+	const pan = "4000001234562000"
+
+	natural, err := h.naturalPIN(pan, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if natural != "2693" {
+		t.Errorf("natural PIN = %s, want 2693", natural)
+	}
+
+	for _, format := range []int{FormatISO0, FormatISO4} {
+		block, err := h.EncryptPINBlock("1357", format, pan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offset, err := h.GeneratePINOffset(block, format, pan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offset != "9764" {
+			t.Errorf("format %d: offset = %s, want 9764", format, offset)
+		}
+
+		ok, err := h.VerifyPIN(block, format, pan, "9764")
+		if err != nil || !ok {
+			t.Errorf("format %d: correct PIN rejected: %v", format, err)
+		}
+		wrong, err := h.EncryptPINBlock("1358", format, pan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := h.VerifyPIN(wrong, format, pan, "9764"); err != nil || ok {
+			t.Errorf("format %d: wrong PIN accepted (err %v)", format, err)
+		}
+	}
+}
+
+func TestPINOffsetRoundTrip(t *testing.T) {
+	h := newTestHSM(t)
+	const pan = "2200123456789010"
+
+	property := func(raw [MaxPINLength]byte, n, pos uint8) bool {
+		pin := testPIN(raw, n)
+		if weakPIN(pin) {
+			return true
+		}
+		block, _ := h.EncryptPINBlock(pin, FormatISO0, pan)
+		offset, err := h.GeneratePINOffset(block, FormatISO0, pan)
+		if err != nil || len(offset) != len(pin) {
+			return false
+		}
+		if ok, err := h.VerifyPIN(block, FormatISO0, pan, offset); err != nil || !ok {
+			return false
+		}
+
+		// PIN с одной изменённой цифрой не проходит
+		wrong := []byte(pin)
+		i := int(pos) % len(wrong)
+		wrong[i] = '0' + (wrong[i]-'0'+1)%10
+		block, _ = h.EncryptPINBlock(string(wrong), FormatISO0, pan)
+		ok, err := h.VerifyPIN(block, FormatISO0, pan, offset)
+		return err == nil && !ok
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGeneratePINOffsetRejectsWeakPIN(t *testing.T) {
+	h := newTestHSM(t)
+	const pan = "4000001234562000"
+	for _, pin := range []string{"1111", "1234", "9876", "000000"} {
+		block, err := h.EncryptPINBlock(pin, FormatISO0, pan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := h.GeneratePINOffset(block, FormatISO0, pan); !errors.Is(err, ErrWeakPIN) {
+			t.Errorf("PIN %s: err = %v, want ErrWeakPIN", pin, err)
+		}
+	}
+}
+
+// Пример алгоритма CVV Visa: CVK A 0123456789ABCDEF, B FEDCBA9876543210,
+// PAN 4123456789012345, срок 8701, сервисный код 101 — CVV 561. CVV2 считается
+// тем же алгоритмом с сервисным кодом 000.
+func TestGenerateCVVKnownAnswer(t *testing.T) {
+	h := newTestHSM(t)
+	for _, tc := range []struct {
+		pan, expiry, serviceCode, want string
+	}{
+		{"4123456789012345", "8701", "101", "561"},
+		{"4123456789012345", "8701", ServiceCodeCVV2, "636"},
+		{"4000001234562000", "2812", ServiceCodeCVV2, "843"},
+	} {
+		got, err := h.GenerateCVV(tc.pan, tc.expiry, tc.serviceCode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("CVV(%s, %s, %s) = %s, want %s", tc.pan, tc.expiry, tc.serviceCode, got, tc.want)
+		}
+	}
+}
+
+func TestGenerateCVVRejectsInvalidInput(t *testing.T) {
+	h := newTestHSM(t)
+	for _, tc := range [][3]string{
+		{"41234567890", "8701", "101"},
+		{"4123456789012345", "871", "101"},
+		{"4123456789012345", "8701", "10"},
+		{"412345678901234a", "8701", "101"},
+		{"4123456789012345678901234567", "8701", "101"},
+	} {
+		if _, err := h.GenerateCVV(tc[0], tc[1], tc[2]); !errors.Is(err, ErrInvalidCVVData) {
+			t.Errorf("GenerateCVV%q: err = %v, want ErrInvalidCVVData", tc, err)
+		}
+	}
+}
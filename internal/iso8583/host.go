@@ -0,0 +1,173 @@
+package iso8583
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/service"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// поля запроса, которые возвращаются в ответе без изменений
+var echoFields = []int{
+	FieldPAN, FieldProcessingCode, FieldAmount, FieldTransmissionTime, FieldSTAN,
+	FieldLocalTime, FieldLocalDate, FieldRRN, FieldTerminalID, FieldMerchantID, FieldCurrency,
+}
+
+// Host переводит сообщения ISO 8583 в вызовы сервиса авторизации
+type Host struct {
+	authService *service.CardAuthorizationService
+}
+
+func NewHost(authService *service.CardAuthorizationService) *Host {
+	return &Host{authService: authService}
+}
+
+// Handle реализует HandlerFunc
+func (h *Host) Handle(ctx context.Context, req *Message) *Message {
+	resp := NewMessage(ResponseMTI(req.MTI))
+	for _, field := range echoFields {
+		if value, ok := req.Fields[field]; ok {
+			resp.Set(field, value)
+		}
+	}
+
+	var auth *models.CardAuthorization
+	var err error
+	switch req.MTI {
+	case "0100", "0200":
+		if code := req.Get(FieldProcessingCode); code != "" && !strings.HasPrefix(code, "00") && !strings.HasPrefix(code, "01") {
+			resp.Set(FieldResponseCode, models.ResponseInvalidTransaction)
+			return resp
+		}
+		authReq, convErr := authorizationRequest(req)
+		if convErr != nil {
+			resp.Set(FieldResponseCode, models.ResponseFormatError)
+			return resp
+		}
+		auth, err = h.authService.Authorize(ctx, authReq)
+	case "0220":
+		amount, convErr := parseAmount(req.Get(FieldAmount))
+		if convErr != nil {
+			resp.Set(FieldResponseCode, models.ResponseFormatError)
+			return resp
+		}
+		auth, err = h.authService.Capture(ctx, req.Get(FieldRRN), amount)
+	case "0400", "0420":
+		auth, err = h.authService.Reverse(ctx, req.Get(FieldRRN))
+	default:
+		resp.Set(FieldResponseCode, models.ResponseInvalidTransaction)
+		return resp
+	}
+
+	if err != nil {
+		code := responseCode(err)
+		resp.Set(FieldResponseCode, code)
+		if code == models.ResponseSystemError {
+			logger.Sugared().Errorf("ISO 8583 %s failed: %v", req.MTI, err)
+		}
+		return resp
+	}
+
+	resp.Set(FieldRRN, auth.RRN)
+	resp.Set(FieldResponseCode, auth.ResponseCode)
+	if auth.AuthCode != "" {
+		resp.Set(FieldAuthCode, auth.AuthCode)
+	}
+	// причина отказа для отладки интеграции
+	if auth.DeclineReason != "" {
+		resp.Set(FieldAdditionalData, auth.DeclineReason)
+	}
+	return resp
+}
+
+func authorizationRequest(req *Message) (*models.AuthorizationRequest, error) {
+	amount, err := parseAmount(req.Get(FieldAmount))
+	if err != nil {
+		return nil, err
+	}
+
+	// YYMM -> MM/YY
+	expiry := req.Get(FieldExpiry)
+	if len(expiry) != 4 {
+		return nil, errors.New("expiry is required")
+	}
+
+	channel, entryMode := posEntry(req.Get(FieldPOSEntryMode))
+	// processing code 01xxxx — снятие наличных
+	if strings.HasPrefix(req.Get(FieldProcessingCode), "01") {
+		channel = models.ChannelATM
+	}
+
+	return &models.AuthorizationRequest{
+		MessageType: req.MTI,
+		PAN:         req.Get(FieldPAN),
+		Expiry:      expiry[2:] + "/" + expiry[:2],
+		CVV:         req.Get(FieldAdditionalData),
+		PINBlock:    req.Get(FieldPINData),
+		Amount:      amount,
+		MCC:         req.Get(FieldMCC),
+		Merchant:    strings.TrimSpace(req.Get(FieldMerchantNameAddress)),
+		MerchantID:  strings.TrimSpace(req.Get(FieldMerchantID)),
+		TerminalID:  strings.TrimSpace(req.Get(FieldTerminalID)),
+		Channel:     channel,
+		EntryMode:   entryMode,
+		Country:     req.Get(FieldAcquirerCountry),
+		STAN:        req.Get(FieldSTAN),
+		RRN:         strings.TrimSpace(req.Get(FieldRRN)),
+		Capture:     req.MTI == "0200",
+	}, nil
+}
+
+// posEntry определяет канал и способ ввода карты по первым двум цифрам поля 22
+func posEntry(mode string) (channel, entryMode string) {
+	if len(mode) < 2 {
+		return models.ChannelPOS, ""
+	}
+	switch mode[:2] {
+	case "01":
+		return models.ChannelPOS, models.EntryManual
+	case "02", "90":
+		return models.ChannelPOS, models.EntryMagstripe
+	case "05":
+		return models.ChannelPOS, models.EntryChip
+	case "07", "91":
+		return models.ChannelPOS, models.EntryContactless
+	case "10", "81":
+		return models.ChannelEcommerce, models.EntryManual
+	}
+	return models.ChannelPOS, ""
+}
+
+// parseAmount переводит сумму из минимальных единиц (копеек)
+func parseAmount(value string) (float64, error) {
+	if value == "" {
+		return 0, errors.New("amount is required")
+	}
+	minor, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return float64(minor) / 100, nil
+}
+
+// FormatAmount переводит сумму в минимальные единицы для поля 4
+func FormatAmount(amount float64) string {
+	return fmt.Sprintf("%012d", int64(math.Round(amount*100)))
+}
+
+func responseCode(err error) string {
+	switch {
+	case errors.Is(err, service.ErrAuthorizationNotFound):
+		return models.ResponseNoOriginal
+	case errors.Is(err, service.ErrAuthorizationNotHeld):
+		return models.ResponseInvalidTransaction
+	case errors.Is(err, service.ErrInvalidCaptureAmount):
+		return models.ResponseInvalidAmount
+	}
+	return models.ResponseSystemError
+}
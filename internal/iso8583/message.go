@@ -0,0 +1,217 @@
+// Package iso8583 реализует подмножество ISO 8583 (1987), достаточное для
+// тестирования POS-интеграции: MTI 0100/0110, 0200/0210, 0220/0230, 0400/0410.
+//
+// Формат сообщения: MTI (4 ASCII-цифры), первичный битмап (16 hex-символов),
+// затем поля в ASCII. Вторичный битмап не поддерживается.
+package iso8583
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Поля, которые понимает хост
+const (
+	FieldPAN                 = 2
+	FieldProcessingCode      = 3
+	FieldAmount              = 4 // в минимальных единицах валюты
+	FieldTransmissionTime    = 7
+	FieldSTAN                = 11
+	FieldLocalTime           = 12
+	FieldLocalDate           = 13
+	FieldExpiry              = 14 // YYMM
+	FieldMCC                 = 18
+	FieldAcquirerCountry     = 19
+	FieldPOSEntryMode        = 22
+	FieldAcquirerID          = 32
+	FieldRRN                 = 37
+	FieldAuthCode            = 38
+	FieldResponseCode        = 39
+	FieldTerminalID          = 41
+	FieldMerchantID          = 42
+	FieldMerchantNameAddress = 43
+	FieldAdditionalData      = 48 // CVV2 в нашем подмножестве
+	FieldCurrency            = 49
+	FieldPINData             = 52 // PIN-блок ISO 9564 формата 0 в hex
+)
+
+type fieldSpec struct {
+	length  int  // длина фиксированного поля или максимальная длина переменного
+	prefix  int  // 0 — фиксированная длина, 2 — LLVAR, 3 — LLLVAR
+	numeric bool // фиксированные числовые поля дополняются нулями слева
+}
+
+var specs = map[int]fieldSpec{
+	FieldPAN:                 {length: 19, prefix: 2, numeric: true},
+	FieldProcessingCode:      {length: 6, numeric: true},
+	FieldAmount:              {length: 12, numeric: true},
+	FieldTransmissionTime:    {length: 10, numeric: true},
+	FieldSTAN:                {length: 6, numeric: true},
+	FieldLocalTime:           {length: 6, numeric: true},
+	FieldLocalDate:           {length: 4, numeric: true},
+	FieldExpiry:              {length: 4, numeric: true},
+	FieldMCC:                 {length: 4, numeric: true},
+	FieldAcquirerCountry:     {length: 3, numeric: true},
+	FieldPOSEntryMode:        {length: 3, numeric: true},
+	FieldAcquirerID:          {length: 11, prefix: 2, numeric: true},
+	FieldRRN:                 {length: 12},
+	FieldAuthCode:            {length: 6},
+	FieldResponseCode:        {length: 2},
+	FieldTerminalID:          {length: 8},
+	FieldMerchantID:          {length: 15},
+	FieldMerchantNameAddress: {length: 40},
+	FieldAdditionalData:      {length: 999, prefix: 3},
+	FieldCurrency:            {length: 3, numeric: true},
+	FieldPINData:             {length: 16},
+}
+
+var ErrFormat = errors.New("iso8583: format error")
+
+type Message struct {
+	MTI    string
+	Fields map[int]string
+}
+
+func NewMessage(mti string) *Message {
+	return &Message{MTI: mti, Fields: map[int]string{}}
+}
+
+func (m *Message) Get(field int) string {
+	return m.Fields[field]
+}
+
+func (m *Message) Set(field int, value string) {
+	m.Fields[field] = value
+}
+
+// ResponseMTI — MTI ответа: 0100 -> 0110, 0400 -> 0410
+func ResponseMTI(mti string) string {
+	if len(mti) != 4 {
+		return mti
+	}
+	return mti[:2] + string(mti[2]+1) + mti[3:]
+}
+
+// Pack кодирует сообщение
+func (m *Message) Pack() ([]byte, error) {
+	if len(m.MTI) != 4 || !isDigits(m.MTI) {
+		return nil, fmt.Errorf("%w: invalid MTI %q", ErrFormat, m.MTI)
+	}
+
+	fields := make([]int, 0, len(m.Fields))
+	for field := range m.Fields {
+		fields = append(fields, field)
+	}
+	sort.Ints(fields)
+
+	var bitmap uint64
+	var body strings.Builder
+	for _, field := range fields {
+		spec, ok := specs[field]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported field %d", ErrFormat, field)
+		}
+		value, err := encodeField(field, spec, m.Fields[field])
+		if err != nil {
+			return nil, err
+		}
+		bitmap |= 1 << (64 - field)
+		body.WriteString(value)
+	}
+
+	return []byte(fmt.Sprintf("%s%016X%s", m.MTI, bitmap, body.String())), nil
+}
+
+func encodeField(field int, spec fieldSpec, value string) (string, error) {
+	if len(value) > spec.length {
+		return "", fmt.Errorf("%w: field %d is longer than %d", ErrFormat, field, spec.length)
+	}
+	if spec.numeric && !isDigits(value) {
+		return "", fmt.Errorf("%w: field %d must be numeric", ErrFormat, field)
+	}
+
+	switch spec.prefix {
+	case 2:
+		return fmt.Sprintf("%02d%s", len(value), value), nil
+	case 3:
+		return fmt.Sprintf("%03d%s", len(value), value), nil
+	}
+	if spec.numeric {
+		return strings.Repeat("0", spec.length-len(value)) + value, nil
+	}
+	return value + strings.Repeat(" ", spec.length-len(value)), nil
+}
+
+// Unpack разбирает сообщение
+func Unpack(data []byte) (*Message, error) {
+	if len(data) < 20 {
+		return nil, fmt.Errorf("%w: message too short", ErrFormat)
+	}
+	raw := string(data)
+
+	m := NewMessage(raw[:4])
+	if !isDigits(m.MTI) {
+		return nil, fmt.Errorf("%w: invalid MTI %q", ErrFormat, m.MTI)
+	}
+	bitmap, err := strconv.ParseUint(raw[4:20], 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid bitmap", ErrFormat)
+	}
+	if bitmap&(1<<63) != 0 {
+		return nil, fmt.Errorf("%w: secondary bitmap is not supported", ErrFormat)
+	}
+
+	pos := 20
+	for field := 2; field <= 64; field++ {
+		if bitmap&(1<<(64-field)) == 0 {
+			continue
+		}
+		spec, ok := specs[field]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported field %d", ErrFormat, field)
+		}
+
+		length := spec.length
+		if spec.prefix > 0 {
+			if pos+spec.prefix > len(raw) {
+				return nil, fmt.Errorf("%w: field %d truncated", ErrFormat, field)
+			}
+			prefix := raw[pos : pos+spec.prefix]
+			// Atoi принимает знак, поэтому префикс проверяется на цифры отдельно
+			length, err = strconv.Atoi(prefix)
+			if err != nil || !isDigits(prefix) || length < 0 || length > spec.length {
+				return nil, fmt.Errorf("%w: invalid length of field %d", ErrFormat, field)
+			}
+			pos += spec.prefix
+		}
+		if pos+length > len(raw) {
+			return nil, fmt.Errorf("%w: field %d truncated", ErrFormat, field)
+		}
+
+		value := raw[pos : pos+length]
+		if spec.numeric && !isDigits(value) {
+			return nil, fmt.Errorf("%w: field %d must be numeric", ErrFormat, field)
+		}
+		if spec.prefix == 0 && !spec.numeric {
+			value = strings.TrimRight(value, " ")
+		}
+		m.Fields[field] = value
+		pos += length
+	}
+	if pos != len(raw) {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrFormat, len(raw)-pos)
+	}
+	return m, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
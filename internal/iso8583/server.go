@@ -0,0 +1,140 @@
+package iso8583
+
+import (
+	"bank-api/internal/models"
+	"bank-api/pkg/utils/logger"
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	maxMessageSize = 4096
+	idleTimeout    = 5 * time.Minute
+)
+
+// HandlerFunc обрабатывает запрос и возвращает ответ
+type HandlerFunc func(ctx context.Context, req *Message) *Message
+
+// Server принимает TCP-соединения от терминалов. Каждое сообщение
+// предваряется двухбайтовой длиной (big-endian).
+type Server struct {
+	Addr    string
+	Handler HandlerFunc
+}
+
+// ListenAndServe работает до отмены ctx
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	logger.Sugared().Infof("ISO 8583 listener started on %s", s.Addr)
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serve(ctx, conn)
+		}()
+	}
+}
+
+func (s *Server) serve(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	// паника на одном соединении не должна останавливать весь процесс
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Sugared().Errorf("ISO 8583 connection %s: panic: %v", conn.RemoteAddr(), r)
+		}
+	}()
+	reader := bufio.NewReader(conn)
+
+	for ctx.Err() == nil {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		frame, err := ReadFrame(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logger.Sugared().Warnf("ISO 8583 connection %s closed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		resp := s.handle(ctx, frame)
+		if resp == nil {
+			continue
+		}
+		packed, err := resp.Pack()
+		if err != nil {
+			logger.Sugared().Errorf("failed to pack ISO 8583 response: %v", err)
+			return
+		}
+		if err := WriteFrame(conn, packed); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) handle(ctx context.Context, frame []byte) *Message {
+	req, err := Unpack(frame)
+	if err != nil {
+		logger.Sugared().Warnf("invalid ISO 8583 message: %v", err)
+		// если MTI прочитан, отвечаем ошибкой формата
+		if len(frame) >= 4 && isDigits(string(frame[:4])) {
+			resp := NewMessage(ResponseMTI(string(frame[:4])))
+			resp.Set(FieldResponseCode, models.ResponseFormatError)
+			return resp
+		}
+		return nil
+	}
+	return s.Handler(ctx, req)
+}
+
+// ReadFrame читает сообщение с двухбайтовым заголовком длины
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint16(header[:]))
+	if size == 0 || size > maxMessageSize {
+		return nil, fmt.Errorf("%w: invalid frame size %d", ErrFormat, size)
+	}
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// WriteFrame пишет сообщение с двухбайтовым заголовком длины
+func WriteFrame(w io.Writer, data []byte) error {
+	if len(data) > maxMessageSize {
+		return fmt.Errorf("%w: message too long", ErrFormat)
+	}
+	frame := make([]byte, 2+len(data))
+	binary.BigEndian.PutUint16(frame, uint16(len(data)))
+	copy(frame[2:], data)
+	_, err := w.Write(frame)
+	return err
+}
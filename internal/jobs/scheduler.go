@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"bank-api/pkg/utils/logger"
+	"context"
+	"sync"
+	"time"
+)
+
+// Func — фоновая задача, которую запускает планировщик
+type Func func(ctx context.Context) error
+
+type job struct {
+	name string
+	next func(now time.Time) time.Time
+	run  Func
+}
+
+// Scheduler запускает фоновые задачи по расписанию
+type Scheduler struct {
+	jobs []job
+	wg   sync.WaitGroup
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every запускает задачу с фиксированным интервалом
+func (s *Scheduler) Every(name string, interval time.Duration, fn Func) {
+	s.jobs = append(s.jobs, job{
+		name: name,
+		next: func(now time.Time) time.Time { return now.Add(interval) },
+		run:  fn,
+	})
+}
+
+// Daily запускает задачу раз в сутки в заданное время (по локальному времени сервера)
+func (s *Scheduler) Daily(name string, hour, minute int, fn Func) {
+	s.jobs = append(s.jobs, job{
+		name: name,
+		next: func(now time.Time) time.Time {
+			at := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+			if !at.After(now) {
+				at = at.AddDate(0, 0, 1)
+			}
+			return at
+		},
+		run: fn,
+	})
+}
+
+// Start запускает все зарегистрированные задачи до отмены ctx
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, j)
+	}
+}
+
+// Wait ждёт завершения всех задач после отмены контекста
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j job) {
+	defer s.wg.Done()
+	log := logger.Sugared()
+
+	for {
+		timer := time.NewTimer(time.Until(j.next(time.Now())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		started := time.Now()
+		if err := j.run(ctx); err != nil {
+			log.Errorf("job %s failed: %v", j.name, err)
+			continue
+		}
+		log.Infof("job %s finished in %s", j.name, time.Since(started))
+	}
+}
@@ -0,0 +1,72 @@
+package kms
+
+import (
+	"bank-api/internal/config"
+	"bank-api/internal/security"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// FromConfig создаёт KeyManager по секции encryption.kms
+func FromConfig(cfg config.KMSConfig) (KeyManager, error) {
+	switch cfg.Provider {
+	case "local":
+		return NewLocalKMS(cfg.LocalKeyFile)
+	case "vault":
+		client := &http.Client{Timeout: 10 * time.Second}
+		return NewVaultTransit(client, cfg.Vault.Address, cfg.Vault.Token, cfg.Vault.Mount,
+			cfg.Vault.KeyName, cfg.Vault.HMACKeyCiphertext)
+	default:
+		return nil, fmt.Errorf("kms: unknown provider %q", cfg.Provider)
+	}
+}
+
+// CardKeys создаёт KeyManager и кольцо статических ключей карт, в котором
+// текущий ключ подписи HMAC выдаёт KeyManager
+func CardKeys(ctx context.Context, cfg *config.Config) (KeyManager, *security.Keyring, error) {
+	manager, err := FromConfig(cfg.Encryption.KMS)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyring, err := staticKeyring(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encryption: %w", err)
+	}
+
+	hmacKey, err := manager.HMACKey(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("kms: load HMAC key: %w", err)
+	}
+	keyring.SetSigningKey(hmacKey)
+
+	return manager, keyring, nil
+}
+
+// staticKeyring собирает кольцо статических ключей карт из конфига. Ключ, не
+// заданный ни в файле, ни в окружении, — ошибка: сервер без него не стартует.
+func staticKeyring(cfg *config.Config) (*security.Keyring, error) {
+	keys := make(map[string][]byte, len(cfg.Encryption.Keys))
+	for _, k := range cfg.Encryption.Keys {
+		if _, dup := keys[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate encryption key %q", k.ID)
+		}
+		if k.Key == "" {
+			return nil, fmt.Errorf("encryption key %q is not set, export %s", k.ID, k.EnvName())
+		}
+		key, err := security.ParseKey(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", k.ID, err)
+		}
+		keys[k.ID] = key
+	}
+
+	var hmacKeys [][]byte
+	for _, k := range cfg.Encryption.PreviousHMACKeys {
+		hmacKeys = append(hmacKeys, []byte(k))
+	}
+
+	return security.NewKeyring(keys, []byte(cfg.Encryption.Secret), hmacKeys)
+}
@@ -0,0 +1,29 @@
+package kms
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrInvalidCiphertext = errors.New("kms: invalid ciphertext")
+
+// DataKey — ключ шифрования данных (DEK). Plaintext используется один раз
+// и не сохраняется; в БД попадает только Wrapped.
+type DataKey struct {
+	Plaintext []byte
+	Wrapped   string
+}
+
+// KeyManager оборачивает ключи данных мастер-ключом (KEK), который не покидает KMS
+type KeyManager interface {
+	// KeyID идентифицирует текущий мастер-ключ; записи с другим KeyID подлежат перешифрованию
+	KeyID() string
+	// GenerateDataKey выдаёт новый 256-битный DEK
+	GenerateDataKey(ctx context.Context) (*DataKey, error)
+	// Encrypt оборачивает произвольный короткий секрет мастер-ключом
+	Encrypt(ctx context.Context, plaintext []byte) (string, error)
+	// Decrypt разворачивает результат Encrypt или GenerateDataKey
+	Decrypt(ctx context.Context, ciphertext string) ([]byte, error)
+	// HMACKey возвращает ключ подписи данных карт
+	HMACKey(ctx context.Context) ([]byte, error)
+}
@@ -0,0 +1,131 @@
+package kms
+
+import (
+	"bank-api/internal/security"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// localKeyFile — формат файла локального KMS
+type localKeyFile struct {
+	ActiveKeyID string            `json:"active_key_id"`
+	Keys        map[string]string `json:"keys"` // base64, 32 байта
+	HMACKey     string            `json:"hmac_key"`
+}
+
+// LocalKMS хранит мастер-ключи в файле. Только для разработки и тестов.
+type LocalKMS struct {
+	activeKeyID string
+	keys        map[string][]byte
+	hmacKey     []byte
+}
+
+// NewLocalKMS читает файл ключей; если файла нет, создаёт его со свежими ключами
+func NewLocalKMS(path string) (*LocalKMS, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = generateLocalKeyFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("kms: read key file: %w", err)
+	}
+
+	var file localKeyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("kms: parse key file: %w", err)
+	}
+
+	k := &LocalKMS{activeKeyID: file.ActiveKeyID, keys: make(map[string][]byte)}
+	for id, encoded := range file.Keys {
+		key, err := security.ParseKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("kms: key %q: %w", id, err)
+		}
+		k.keys[id] = key
+	}
+	if _, ok := k.keys[k.activeKeyID]; !ok {
+		return nil, fmt.Errorf("kms: active key %q not found", k.activeKeyID)
+	}
+
+	k.hmacKey, err = base64.StdEncoding.DecodeString(file.HMACKey)
+	if err != nil || len(k.hmacKey) == 0 {
+		return nil, errors.New("kms: invalid hmac_key")
+	}
+	return k, nil
+}
+
+func generateLocalKeyFile(path string) ([]byte, error) {
+	key := make([]byte, security.KeySize)
+	hmacKey := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(hmacKey); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(localKeyFile{
+		ActiveKeyID: "local-1",
+		Keys:        map[string]string{"local-1": base64.StdEncoding.EncodeToString(key)},
+		HMACKey:     base64.StdEncoding.EncodeToString(hmacKey),
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (k *LocalKMS) KeyID() string {
+	return "local:" + k.activeKeyID
+}
+
+func (k *LocalKMS) GenerateDataKey(ctx context.Context) (*DataKey, error) {
+	dek := make([]byte, security.KeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	wrapped, err := k.Encrypt(ctx, dek)
+	if err != nil {
+		return nil, err
+	}
+	return &DataKey{Plaintext: dek, Wrapped: wrapped}, nil
+}
+
+// Encrypt оборачивает данные активным ключом в конверт AES-GCM ("v2:<keyID>:...")
+func (k *LocalKMS) Encrypt(_ context.Context, plaintext []byte) (string, error) {
+	return security.Seal(string(plaintext), k.activeKeyID, k.keys[k.activeKeyID], []byte("kms"))
+}
+
+func (k *LocalKMS) Decrypt(_ context.Context, ciphertext string) ([]byte, error) {
+	if !security.IsEnvelope(ciphertext) {
+		return nil, ErrInvalidCiphertext
+	}
+	plaintext, err := security.Open(ciphertext, func(keyID string) ([]byte, error) {
+		key, ok := k.keys[keyID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", security.ErrUnknownKey, keyID)
+		}
+		return key, nil
+	}, []byte("kms"))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(plaintext), nil
+}
+
+func (k *LocalKMS) HMACKey(_ context.Context) ([]byte, error) {
+	return k.hmacKey, nil
+}
@@ -0,0 +1,145 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// VaultTransit — адаптер к HashiCorp Vault Transit secrets engine.
+// Мастер-ключ хранится в Vault, наружу выходят только обёрнутые ключи данных.
+type VaultTransit struct {
+	client  *http.Client
+	address string
+	token   string
+	mount   string
+	keyName string
+
+	// ключ HMAC хранится в конфиге обёрнутым ключом Transit и разворачивается один раз
+	hmacCiphertext string
+	hmacOnce       sync.Once
+	hmacKey        []byte
+	hmacErr        error
+}
+
+func NewVaultTransit(client *http.Client, address, token, mount, keyName, hmacCiphertext string) (*VaultTransit, error) {
+	if address == "" || token == "" || keyName == "" {
+		return nil, errors.New("kms: vault address, token and key name are required")
+	}
+	if hmacCiphertext == "" {
+		return nil, errors.New("kms: vault hmac_key_ciphertext is required")
+	}
+	if mount == "" {
+		mount = "transit"
+	}
+	return &VaultTransit{
+		client:         client,
+		address:        strings.TrimRight(address, "/"),
+		token:          token,
+		mount:          strings.Trim(mount, "/"),
+		keyName:        keyName,
+		hmacCiphertext: hmacCiphertext,
+	}, nil
+}
+
+type vaultResponse struct {
+	Data struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func (v *VaultTransit) KeyID() string {
+	return "vault:" + v.keyName
+}
+
+// GenerateDataKey — POST /v1/{mount}/datakey/plaintext/{name}
+func (v *VaultTransit) GenerateDataKey(ctx context.Context) (*DataKey, error) {
+	resp, err := v.call(ctx, "datakey/plaintext", map[string]interface{}{"bits": 256})
+	if err != nil {
+		return nil, err
+	}
+	dek, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("kms: vault returned invalid data key: %w", err)
+	}
+	return &DataKey{Plaintext: dek, Wrapped: resp.Data.Ciphertext}, nil
+}
+
+// Encrypt — POST /v1/{mount}/encrypt/{name}
+func (v *VaultTransit) Encrypt(ctx context.Context, plaintext []byte) (string, error) {
+	resp, err := v.call(ctx, "encrypt", map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Data.Ciphertext, nil
+}
+
+// Decrypt — POST /v1/{mount}/decrypt/{name}
+func (v *VaultTransit) Decrypt(ctx context.Context, ciphertext string) ([]byte, error) {
+	if !strings.HasPrefix(ciphertext, "vault:") {
+		return nil, ErrInvalidCiphertext
+	}
+	resp, err := v.call(ctx, "decrypt", map[string]interface{}{"ciphertext": ciphertext})
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("kms: vault returned invalid plaintext: %w", err)
+	}
+	return plaintext, nil
+}
+
+func (v *VaultTransit) HMACKey(ctx context.Context) ([]byte, error) {
+	v.hmacOnce.Do(func() {
+		v.hmacKey, v.hmacErr = v.Decrypt(ctx, v.hmacCiphertext)
+	})
+	return v.hmacKey, v.hmacErr
+}
+
+func (v *VaultTransit) call(ctx context.Context, operation string, payload map[string]interface{}) (*vaultResponse, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/%s/%s", v.address, v.mount, operation, v.keyName)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kms: vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var result vaultResponse
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("kms: unexpected vault response (status %d)", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kms: vault %s failed with status %d: %s",
+			operation, resp.StatusCode, strings.Join(result.Errors, "; "))
+	}
+	return &result, nil
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"bank-api/internal/utils"
+)
+
+// RequireAPIKey пропускает запросы с ключом в заголовке header.
+// Используется для системных клиентов (эквайер), у которых нет JWT.
+func RequireAPIKey(header, key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided := r.Header.Get(header)
+			if key == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(key)) != 1 {
+				utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
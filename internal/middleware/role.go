@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"bank-api/internal/utils"
+)
+
+// RoleLookup returns user's role by ID
+type RoleLookup func(ctx context.Context, userID int64) (string, error)
+
+// RequireRole allows only users with given role. Must be used after JWTMiddleware
+func RequireRole(role string, lookup RoleLookup) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, err := GetUserID(r.Context())
+			if err != nil {
+				utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+				return
+			}
+
+			userRole, err := lookup(r.Context(), userID)
+			if err != nil {
+				utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to check permissions"})
+				return
+			}
+			if userRole != role {
+				utils.RespondJSON(w, http.StatusForbidden, map[string]string{"error": "access denied"})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -6,12 +6,16 @@ type Account struct {
 	ID        int64     `json:"id"`
 	UserID    int64     `json:"user_id"`
 	Balance   float64   `json:"balance"`
+	Kind      string    `json:"kind"` // current, deposit, pot
 	CreatedAt time.Time `json:"created_at"`
 }
 
 type AccountBalance struct {
-	AccountID int64     `json:"account_id"`
-	Currency  string    `json:"currency"`
-	Balance   float64   `json:"balance"`
-	CreatedAt time.Time `json:"created_at"`
+	AccountID      int64     `json:"account_id"`
+	Currency       string    `json:"currency"`
+	Balance        float64   `json:"balance"`
+	OverdraftLimit float64   `json:"overdraft_limit"`
+	Held           float64   `json:"held"`      // заблокировано авторизациями по картам
+	Available      float64   `json:"available"` // остаток с учётом овердрафта и заблокированных по картам сумм
+	CreatedAt      time.Time `json:"created_at"`
 }
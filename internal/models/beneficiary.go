@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type Beneficiary struct {
+	ID          int64     `db:"id" json:"id"`
+	UserID      int64     `db:"user_id" json:"user_id"`
+	Name        string    `db:"name" json:"name"`
+	AccountID   int64     `db:"account_id" json:"account_id,omitempty"` // счёт получателя внутри банка
+	Phone       string    `db:"phone" json:"phone,omitempty"`
+	BankName    string    `db:"bank_name" json:"bank_name,omitempty"`
+	Nickname    string    `db:"nickname" json:"nickname,omitempty"`
+	IsFavourite bool      `db:"is_favourite" json:"is_favourite"`
+	Status      string    `db:"status" json:"status"` // pending_confirmation, active
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
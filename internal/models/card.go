@@ -2,13 +2,124 @@ package models
 
 import "time"
 
+// Статусы карты
+const (
+	CardStatusInactive      = "inactive" // выпущена, ждёт активации
+	CardStatusActive        = "active"
+	CardStatusTempBlocked   = "temp_blocked"   // заморожена, можно разморозить
+	CardStatusBlockedLost   = "blocked_lost"   // утеряна, только перевыпуск
+	CardStatusBlockedStolen = "blocked_stolen" // украдена, только перевыпуск
+	CardStatusExpired       = "expired"
+	CardStatusClosed        = "closed" // конечный статус
+)
+
+// Виды карт
+const (
+	CardFormPhysical = "physical"
+	CardFormVirtual  = "virtual"
+
+	VirtualStandard       = "standard"
+	VirtualSingleUse      = "single_use"      // закрывается после первой одобренной операции
+	VirtualMerchantLocked = "merchant_locked" // работает только у первого продавца
+)
+
 type Card struct {
-	ID             int64     `json:"id"`
-	AccountID      int64     `json:"account_id"`
-	EncryptedData  string    `json:"-"`
-	HMAC           string    `json:"-"`
-	CVV            string    `json:"cvv,omitempty"` // показывать только в нужных случаях
-	CardNumber     string    `json:"card_number,omitempty"`
-	ExpirationDate time.Time `json:"expiration_date,omitempty"`
-	CreatedAt      time.Time `json:"created_at"`
+	ID              int64      `json:"id"`
+	AccountID       int64      `json:"account_id"`
+	EncryptedData   string     `json:"-"`
+	HMAC            string     `json:"-"`
+	WrappedDEK      string     `json:"-"` // ключ данных карты, обёрнутый мастер-ключом KMS
+	KeyID           string     `json:"-"` // ключ, которым зашифрованы данные
+	PANHash         string     `json:"-"` // ключевой хеш номера для проверки уникальности
+	Product         string     `json:"product,omitempty"`
+	Brand           string     `json:"brand,omitempty"`
+	Status          string     `json:"status"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+	ExpiresAt       *time.Time `json:"-"` // последний день срока действия
+	ReplacesCardID  *int64     `json:"replaces_card_id,omitempty"`
+	FormFactor      string     `json:"form_factor"`
+	VirtualType     string     `json:"virtual_type,omitempty"`
+	SpendCap        *float64   `json:"spend_cap,omitempty"` // лимит за весь срок действия
+	LockedMerchant  string     `json:"locked_merchant,omitempty"`
+	PINSet          bool       `json:"pin_set"`
+	CVV             string     `json:"-"`                    // зашифрованный CVV старых карт; новые карты его не хранят
+	MaskedPAN       string     `json:"masked_pan,omitempty"` // первые 6 и последние 4 цифры
+	ExpirationDate  time.Time  `json:"expiration_date,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// IsVirtual — карта выпущена без пластика, реквизиты показываются только через reveal
+func (c *Card) IsVirtual() bool {
+	return c.FormFactor == CardFormVirtual
+}
+
+// CardStatusChange — запись журнала смены статуса карты
+type CardStatusChange struct {
+	ID         int64     `db:"id" json:"id"`
+	CardID     int64     `db:"card_id" json:"card_id"`
+	FromStatus string    `db:"from_status" json:"from_status"`
+	ToStatus   string    `db:"to_status" json:"to_status"`
+	Reason     string    `db:"reason" json:"reason"`
+	ActorType  string    `db:"actor_type" json:"actor_type"` // user, admin, system
+	ActorID    *int64    `db:"actor_id" json:"actor_id,omitempty"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// CardControls — ограничения, которые владелец задаёт для карты.
+// Лимит nil — без ограничения.
+type CardControls struct {
+	CardID               int64     `db:"card_id" json:"card_id"`
+	DailyLimit           *float64  `db:"daily_limit" json:"daily_limit"`
+	MonthlyLimit         *float64  `db:"monthly_limit" json:"monthly_limit"`
+	PerTransactionLimit  *float64  `db:"per_transaction_limit" json:"per_transaction_limit"`
+	ATMDailyLimit        *float64  `db:"atm_daily_limit" json:"atm_daily_limit"`
+	ATMMonthlyLimit      *float64  `db:"atm_monthly_limit" json:"atm_monthly_limit"`
+	BlockedCategories    string    `db:"blocked_categories" json:"blocked_categories"` // через запятую: "gambling,crypto"
+	BlockedMCCCodes      string    `db:"blocked_mcc_codes" json:"blocked_mcc_codes"`   // через запятую: "5993,7273"
+	OnlineEnabled        bool      `db:"online_enabled" json:"online_enabled"`
+	ContactlessEnabled   bool      `db:"contactless_enabled" json:"contactless_enabled"`
+	MagstripeEnabled     bool      `db:"magstripe_enabled" json:"magstripe_enabled"`
+	InternationalEnabled bool      `db:"international_enabled" json:"international_enabled"`
+	UpdatedAt            time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// DefaultCardControls — карта без ограничений
+func DefaultCardControls(cardID int64) *CardControls {
+	return &CardControls{
+		CardID:               cardID,
+		OnlineEnabled:        true,
+		ContactlessEnabled:   true,
+		MagstripeEnabled:     true,
+		InternationalEnabled: true,
+	}
+}
+
+// Результат запроса на показ реквизитов
+const (
+	RevealIssued = "issued" // токен выдан
+	RevealDenied = "denied" // неверный пароль
+)
+
+// CardReveal — запрос на показ реквизитов карты (журнал аудита)
+type CardReveal struct {
+	ID         int64      `db:"id" json:"-"`
+	CardID     int64      `db:"card_id" json:"card_id"`
+	UserID     int64      `db:"user_id" json:"-"`
+	TokenHash  *string    `db:"token_hash" json:"-"` // nil, если токен не выдан
+	Outcome    string     `db:"outcome" json:"-"`
+	IP         string     `db:"ip" json:"-"`
+	UserAgent  string     `db:"user_agent" json:"-"`
+	ExpiresAt  time.Time  `db:"expires_at" json:"expires_at"`
+	RevealedAt *time.Time `db:"revealed_at" json:"-"`
+	RevealedIP *string    `db:"revealed_ip" json:"-"`
+	CreatedAt  time.Time  `db:"created_at" json:"-"`
+}
+
+// CardDetails — реквизиты карты, которые отдаются только через reveal
+type CardDetails struct {
+	CardID         int64  `json:"card_id"`
+	CardNumber     string `json:"card_number"`
+	ExpirationDate string `json:"expiration_date"` // MM/YY
+	CVV            string `json:"cvv"`
 }
@@ -0,0 +1,148 @@
+package models
+
+import "time"
+
+// Коды ответа авторизации (поле 39 ISO 8583)
+const (
+	ResponseApproved           = "00"
+	ResponseDoNotHonor         = "05"
+	ResponseInvalidTransaction = "12"
+	ResponseInvalidAmount      = "13"
+	ResponseInvalidCard        = "14"
+	ResponseNoOriginal         = "25"
+	ResponseFormatError        = "30"
+	ResponseLostCard           = "41"
+	ResponseStolenCard         = "43"
+	ResponseInsufficientFunds  = "51"
+	ResponseExpiredCard        = "54"
+	ResponseIncorrectPIN       = "55"
+	ResponseNotPermitted       = "57"
+	ResponseExceedsLimit       = "61"
+	ResponseRestrictedCard     = "62"
+	ResponsePINTriesExceeded   = "75"
+	ResponseInactiveCard       = "78"
+	ResponseCVVMismatch        = "82"
+	ResponseSystemError        = "96"
+)
+
+// Статусы авторизации
+const (
+	AuthStatusDeclined = "declined"
+	AuthStatusHeld     = "held"     // средства заблокированы до списания
+	AuthStatusCaptured = "captured" // списано транзакцией
+	AuthStatusReversed = "reversed"
+	AuthStatusExpired  = "expired" // hold снят по истечении срока
+)
+
+// Причины отказа, которые возвращаются вместе с кодом ответа
+const (
+	DeclineInvalidCard           = "invalid_card"
+	DeclineCardInactive          = "card_inactive"
+	DeclineCardFrozen            = "card_frozen"
+	DeclineCardLost              = "card_lost"
+	DeclineCardStolen            = "card_stolen"
+	DeclineCardExpired           = "card_expired"
+	DeclineCVVMismatch           = "cvv_mismatch"
+	DeclineInvalidAmount         = "invalid_amount"
+	DeclineInsufficientFunds     = "insufficient_funds"
+	DeclineSystemError           = "system_error"
+	DeclineTransactionLimit      = "transaction_limit_exceeded"
+	DeclineDailyLimit            = "daily_limit_exceeded"
+	DeclineMonthlyLimit          = "monthly_limit_exceeded"
+	DeclineATMDailyLimit         = "atm_daily_limit_exceeded"
+	DeclineATMMonthlyLimit       = "atm_monthly_limit_exceeded"
+	DeclineMerchantBlocked       = "merchant_category_blocked"
+	DeclineOnlineDisabled        = "online_disabled"
+	DeclineContactlessDisabled   = "contactless_disabled"
+	DeclineMagstripeDisabled     = "magstripe_disabled"
+	DeclineInternationalDisabled = "international_disabled"
+	DeclineCardNotPresentOnly    = "card_not_present_only" // виртуальная карта — только онлайн
+	DeclineSpendCap              = "spend_cap_exceeded"
+	DeclineSingleUseUsed         = "single_use_card_used"
+	DeclineMerchantLocked        = "merchant_locked"
+	DeclineIncorrectPIN          = "incorrect_pin"
+	DeclinePINTriesExceeded      = "pin_tries_exceeded"
+	DeclinePINNotSet             = "pin_not_set"
+	DeclinePINRequired           = "pin_required" // снятие наличных без PIN
+)
+
+// Каналы и способы ввода карты
+const (
+	ChannelPOS       = "pos"
+	ChannelEcommerce = "ecommerce"
+	ChannelATM       = "atm"
+
+	EntryChip        = "chip"
+	EntryContactless = "contactless"
+	EntryMagstripe   = "magstripe"
+	EntryManual      = "manual"
+)
+
+// AuthorizationRequest — запрос эквайера на авторизацию по карте
+type AuthorizationRequest struct {
+	MessageType string  `json:"-"`
+	PAN         string  `json:"pan"`
+	Expiry      string  `json:"expiry"` // MM/YY
+	CVV         string  `json:"cvv,omitempty"`
+	PINBlock    string  `json:"pin_block,omitempty"`  // hex, зашифрован ключом зоны; не сохраняется
+	PINFormat   string  `json:"pin_format,omitempty"` // iso0 (по умолчанию) или iso4
+	Amount      float64 `json:"amount"`
+	MCC         string  `json:"mcc,omitempty"`
+	Merchant    string  `json:"merchant,omitempty"`
+	MerchantID  string  `json:"merchant_id,omitempty"`
+	TerminalID  string  `json:"terminal_id,omitempty"`
+	Channel     string  `json:"channel,omitempty"`    // pos, ecommerce, atm; по умолчанию pos
+	EntryMode   string  `json:"entry_mode,omitempty"` // chip, contactless, magstripe, manual
+	Country     string  `json:"country,omitempty"`    // ISO 3166 numeric страны эквайера
+	STAN        string  `json:"stan,omitempty"`
+	RRN         string  `json:"rrn,omitempty"` // если пусто — присваивается банком
+	Capture     bool    `json:"capture"`       // одностадийная операция (0200)
+}
+
+type CardAuthorization struct {
+	ID             int64      `db:"id" json:"id"`
+	CardID         *int64     `db:"card_id" json:"card_id,omitempty"`
+	AccountID      *int64     `db:"account_id" json:"-"`
+	MessageType    string     `db:"message_type" json:"message_type"`
+	RRN            string     `db:"rrn" json:"rrn"`
+	STAN           string     `db:"stan" json:"stan,omitempty"`
+	TerminalID     string     `db:"terminal_id" json:"terminal_id,omitempty"`
+	MerchantID     string     `db:"merchant_id" json:"merchant_id,omitempty"`
+	Merchant       string     `db:"merchant" json:"merchant,omitempty"`
+	MCC            string     `db:"mcc" json:"mcc,omitempty"`
+	Amount         float64    `db:"amount" json:"amount"`
+	CapturedAmount *float64   `db:"captured_amount" json:"captured_amount,omitempty"`
+	AuthCode       string     `db:"auth_code" json:"auth_code,omitempty"`
+	ResponseCode   string     `db:"response_code" json:"response_code"`
+	DeclineReason  string     `db:"decline_reason" json:"decline_reason,omitempty"`
+	Channel        string     `db:"channel" json:"channel"`
+	EntryMode      string     `db:"entry_mode" json:"entry_mode,omitempty"`
+	Country        string     `db:"country" json:"country,omitempty"`
+	Status         string     `db:"status" json:"status"`
+	TransactionID  *int64     `db:"transaction_id" json:"transaction_id,omitempty"`
+	ExpiresAt      *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	CreatedAt      time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// Approved — авторизация одобрена (в том числе уже списана или отменена)
+func (a *CardAuthorization) Approved() bool {
+	return a.ResponseCode == ResponseApproved
+}
+
+// SpendLimit — лимит суммы операций по карте за период. Проверяется вместе
+// с остатком при блокировке средств.
+type SpendLimit struct {
+	Since  time.Time
+	ATM    bool // учитываются только снятия наличных, иначе — только покупки
+	Any    bool // учитываются все операции независимо от канала
+	Limit  float64
+	Reason string // причина отказа при превышении
+}
+
+// HoldRules — проверки, которые выполняются атомарно с блокировкой средств
+type HoldRules struct {
+	Limits    []SpendLimit
+	SingleUse bool   // по карте ещё не должно быть одобренных операций
+	Merchant  string // продавец для карты merchant_locked; пусто — без привязки
+}
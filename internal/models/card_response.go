@@ -5,7 +5,9 @@ import "time"
 type CardResponse struct {
 	ID             int64     `json:"id"`
 	AccountID      int64     `json:"account_id"`
-	CardNumber     string    `json:"card_number"`
+	MaskedPAN      string    `json:"masked_pan"` // первые 6 и последние 4 цифры
+	Brand          string    `json:"brand,omitempty"`
+	Status         string    `json:"status"`
 	ExpirationDate string    `json:"expiration_date"` // формат MM/YY
 	CreatedAt      time.Time `json:"created_at"`
 }
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+type DepositProduct struct {
+	ID                     int64     `db:"id" json:"id"`
+	Name                   string    `db:"name" json:"name"`
+	Kind                   string    `db:"kind" json:"kind"`               // term, savings
+	RateSource             string    `db:"rate_source" json:"rate_source"` // fixed, cbr_key_rate
+	BaseRate               float64   `db:"base_rate" json:"base_rate"`     // ставка или спред к ключевой ставке
+	OnDemandRate           float64   `db:"on_demand_rate" json:"on_demand_rate"`
+	TermMonths             int       `db:"term_months" json:"term_months"`
+	InterestPeriod         string    `db:"interest_period" json:"interest_period"` // monthly, end_of_term
+	InterestMode           string    `db:"interest_mode" json:"interest_mode"`     // capitalize, payout
+	AllowTopUp             bool      `db:"allow_top_up" json:"allow_top_up"`
+	AllowPartialWithdrawal bool      `db:"allow_partial_withdrawal" json:"allow_partial_withdrawal"`
+	MinAmount              float64   `db:"min_amount" json:"min_amount"`
+	IsActive               bool      `db:"is_active" json:"is_active"`
+	CreatedAt              time.Time `db:"created_at" json:"created_at"`
+}
+
+type Deposit struct {
+	ID              int64      `db:"id" json:"id"`
+	UserID          int64      `db:"user_id" json:"user_id"`
+	ProductID       int64      `db:"product_id" json:"product_id"`
+	AccountID       int64      `db:"account_id" json:"account_id"`
+	LinkedAccountID int64      `db:"linked_account_id" json:"linked_account_id"`
+	Balance         float64    `db:"balance" json:"balance"`
+	Rate            float64    `db:"rate" json:"rate"`
+	AccruedInterest float64    `db:"accrued_interest" json:"accrued_interest"`
+	Status          string     `db:"status" json:"status"` // open, matured, closed
+	OpenedAt        time.Time  `db:"opened_at" json:"opened_at"`
+	MaturityDate    *time.Time `db:"maturity_date" json:"maturity_date,omitempty"`
+	LastAccrualDate *time.Time `db:"last_accrual_date" json:"last_accrual_date,omitempty"`
+	ClosedAt        *time.Time `db:"closed_at" json:"closed_at,omitempty"`
+}
+
+type DepositAccrual struct {
+	ID          int64     `db:"id" json:"id"`
+	DepositID   int64     `db:"deposit_id" json:"deposit_id"`
+	AccrualDate time.Time `db:"accrual_date" json:"accrual_date"`
+	Balance     float64   `db:"balance" json:"balance"`
+	Rate        float64   `db:"rate" json:"rate"`
+	Amount      float64   `db:"amount" json:"amount"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Notification — сообщение пользователю во входящих
+type Notification struct {
+	ID        int64      `db:"id" json:"id"`
+	UserID    int64      `db:"user_id" json:"user_id"`
+	Kind      string     `db:"kind" json:"kind"` // например recurring_charge_failed
+	Message   string     `db:"message" json:"message"`
+	ReadAt    *time.Time `db:"read_at" json:"read_at,omitempty"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+}
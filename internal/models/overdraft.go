@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type Overdraft struct {
+	ID                int64      `db:"id" json:"id"`
+	AccountID         int64      `db:"account_id" json:"account_id"`
+	LimitAmount       float64    `db:"limit_amount" json:"limit_amount"`
+	InterestRate      float64    `db:"interest_rate" json:"interest_rate"` // годовая ставка
+	AccruedInterest   float64    `db:"accrued_interest" json:"accrued_interest"`
+	Status            string     `db:"status" json:"status"` // active, revoked
+	GrantedBy         *int64     `db:"granted_by" json:"granted_by,omitempty"`
+	LastAccrualDate   *time.Time `db:"last_accrual_date" json:"last_accrual_date,omitempty"`
+	InterestChargedOn *time.Time `db:"interest_charged_on" json:"interest_charged_on,omitempty"` // последнее списание процентов за период
+	Balance           float64    `db:"balance" json:"balance"`
+	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time  `db:"updated_at" json:"updated_at"`
+}
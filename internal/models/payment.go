@@ -2,17 +2,98 @@ package models
 
 import "time"
 
+// Статусы платежа
+const (
+	PaymentStatusCreated           = "created"
+	PaymentStatusRequiresAction    = "requires_action" // нужно подтверждение клиента (3DS, редирект)
+	PaymentStatusProcessing        = "processing"
+	PaymentStatusSucceeded         = "succeeded" // только в этом статусе счёт пополняется
+	PaymentStatusFailed            = "failed"
+	PaymentStatusCanceled          = "canceled"
+	PaymentStatusRefunded          = "refunded"
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+)
+
+// Инициатор платежа
+const (
+	PaymentInitiatorCustomer = "customer" // клиент присутствует и может пройти 3-D Secure
+	PaymentInitiatorMerchant = "merchant" // списание по сохранённому способу оплаты без клиента
+)
+
 type Payment struct {
-	ID            int64     `db:"id" json:"id"`
-	UserID        int64     `db:"user_id" json:"user_id"`
-	AccountID     int64     `db:"account_id" json:"account_id"`
-	Amount        float64   `db:"amount" json:"amount"`
-	Currency      string    `db:"currency" json:"currency"`
-	Method        string    `db:"method" json:"method"`     // eg: "card", "yoomoney", "stripe"
-	Provider      string    `db:"provider" json:"provider"` // eg: "stripe", "yoomoney"
-	Status        string    `db:"status" json:"status"`     // "pending", "completed", "failed"
-	TransactionID string    `db:"transaction_id" json:"transaction_id"`
-	IsRefunded    bool      `db:"is_refunded" json:"is_refunded"`
-	CreatedAt     time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
+	ID                int64   `db:"id" json:"id"`
+	UserID            int64   `db:"user_id" json:"user_id"`
+	AccountID         int64   `db:"account_id" json:"account_id"`
+	Amount            float64 `db:"amount" json:"amount"`
+	Currency          string  `db:"currency" json:"currency"`
+	Method            string  `db:"method" json:"method"`              // eg: "card"
+	Provider          string  `db:"provider" json:"provider"`          // eg: "stripe", "yookassa"
+	PaymentMethod     string  `db:"-" json:"payment_method,omitempty"` // токен способа оплаты у провайдера (pm_..., payment_method_id)
+	Status            string  `db:"status" json:"status"`
+	ProviderPaymentID string  `db:"provider_payment_id" json:"provider_payment_id,omitempty"`
+	FailureReason     string  `db:"failure_reason" json:"failure_reason,omitempty"`
+	TransactionID     string  `db:"transaction_id" json:"transaction_id"`   // транзакция зачисления на счёт
+	RefundedAmount    float64 `db:"refunded_amount" json:"refunded_amount"` // сумма успешных возвратов
+	RoutingRule       string  `db:"routing_rule" json:"routing_rule,omitempty"`
+	Initiator         string  `db:"initiator" json:"initiator"`
+	RecurringID       *int64  `db:"recurring_id" json:"recurring_id,omitempty"`
+	// SavePaymentMethod — попросить провайдера сохранить способ оплаты для
+	// будущих списаний; его ID приходит в SavedPaymentMethodID
+	SavePaymentMethod    bool   `db:"-" json:"save_payment_method,omitempty"`
+	SavedPaymentMethodID string `db:"saved_payment_method_id" json:"saved_payment_method_id,omitempty"`
+	// ConfirmationURL — куда отправить клиента в статусе requires_action;
+	// после ActionExpiresAt неподтверждённый платёж отменяется
+	ConfirmationURL string     `db:"confirmation_url" json:"confirmation_url,omitempty"`
+	ActionExpiresAt *time.Time `db:"action_expires_at" json:"action_expires_at,omitempty"`
+	StatusChangedAt *time.Time `db:"status_changed_at" json:"status_changed_at,omitempty"`
+	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt       time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// PaymentStatusChange — запись журнала смены статуса платежа
+type PaymentStatusChange struct {
+	ID         int64     `db:"id" json:"id"`
+	PaymentID  int64     `db:"payment_id" json:"payment_id"`
+	FromStatus string    `db:"from_status" json:"from_status"`
+	ToStatus   string    `db:"to_status" json:"to_status"`
+	Reason     string    `db:"reason" json:"reason,omitempty"`
+	Source     string    `db:"source" json:"source"` // api, provider, webhook, system
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// PaymentRoutingAttempt — обращение к провайдеру при маршрутизации платежа
+type PaymentRoutingAttempt struct {
+	ID        int64     `db:"id" json:"id"`
+	PaymentID int64     `db:"payment_id" json:"payment_id"`
+	Attempt   int       `db:"attempt" json:"attempt"`
+	Provider  string    `db:"provider" json:"provider"`
+	Outcome   string    `db:"outcome" json:"outcome"` // accepted, declined, unavailable, error
+	Error     string    `db:"error" json:"error,omitempty"`
+	LatencyMS int64     `db:"latency_ms" json:"latency_ms"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// Статусы возврата
+const (
+	RefundStatusPending   = "pending" // отправлен провайдеру, ждём результат
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+)
+
+// Refund — возврат по платежу. Сумма списывается со счёта при создании
+// возврата и зачисляется обратно, если провайдер его отклонил.
+type Refund struct {
+	ID                    int64     `db:"id" json:"id"`
+	PaymentID             int64     `db:"payment_id" json:"payment_id"`
+	Amount                float64   `db:"amount" json:"amount"`
+	Currency              string    `db:"currency" json:"currency"`
+	Reason                string    `db:"reason" json:"reason,omitempty"`
+	Status                string    `db:"status" json:"status"`
+	ProviderRefundID      string    `db:"provider_refund_id" json:"provider_refund_id,omitempty"`
+	FailureReason         string    `db:"failure_reason" json:"failure_reason,omitempty"`
+	TransactionID         *int64    `db:"transaction_id" json:"transaction_id,omitempty"`
+	ReversalTransactionID *int64    `db:"reversal_transaction_id" json:"reversal_transaction_id,omitempty"`
+	RequestedBy           *int64    `db:"requested_by" json:"requested_by,omitempty"`
+	CreatedAt             time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt             time.Time `db:"updated_at" json:"updated_at"`
 }
@@ -1,8 +1,14 @@
 package models
 
+// PaymentResult — ответ провайдера на создание платежа
 type PaymentResult struct {
-	TransactionID string `json:"transaction_id"`
-	Status        string `json:"status"` // "pending", "completed", "failed"
+	TransactionID string `json:"transaction_id"` // идентификатор платежа у провайдера
+	Status        string `json:"status"`         // статус платежа: succeeded, processing, requires_action, failed
 	Provider      string `json:"provider"`
 	Error         string `json:"error,omitempty"` // если есть ошибка
+	// PaymentMethodID — способ оплаты, сохранённый провайдером по SavePaymentMethod
+	PaymentMethodID string `json:"payment_method_id,omitempty"`
+	// ConfirmationURL — страница 3-D Secure (ACS банка) или подтверждения у
+	// провайдера, куда нужно направить клиента при статусе requires_action
+	ConfirmationURL string `json:"confirmation_url,omitempty"`
 }
@@ -2,13 +2,40 @@ package models
 
 import "time"
 
+// PaymentMethod — способ оплаты в хранилище. Реквизиты карты не хранятся:
+// только токен провайдера и сведения для показа клиенту.
 type PaymentMethod struct {
-	ID          int64     `db:"id"`
-	UserID      int64     `db:"user_id"`
-	Type        string    `db:"type"`     // card, bank_account, crypto, wallet и т.п.
-	Provider    string    `db:"provider"` // Visa, Mastercard, YooMoney и т.п.
-	Token       string    `db:"token"`    // токенизированные данные (PCI DSS безопасный storage)
-	CreatedAt   time.Time `db:"created_at"`
-	IsActive    bool      `db:"is_active"`
-	Description string    `db:"description"`
+	ID               int64      `db:"id" json:"id"`
+	UserID           int64      `db:"user_id" json:"user_id"`
+	Type             string     `db:"type" json:"type"`             // card, wallet, sbp и т.п.
+	Provider         string     `db:"provider" json:"provider"`     // платёжный провайдер токена: stripe, yookassa
+	Token            string     `db:"token" json:"token"`           // токен у провайдера (pm_..., payment_method_id)
+	Brand            string     `db:"brand" json:"brand,omitempty"` // visa, mastercard, mir, yoo_money и т.п.
+	Last4            string     `db:"last4" json:"last4,omitempty"`
+	ExpMonth         int        `db:"exp_month" json:"exp_month,omitempty"` // 0 — без срока действия
+	ExpYear          int        `db:"exp_year" json:"exp_year,omitempty"`
+	Fingerprint      string     `db:"fingerprint" json:"-"` // одинаков для одной карты: по нему отсекаются дубли
+	IsDefault        bool       `db:"is_default" json:"is_default"`
+	IsActive         bool       `db:"is_active" json:"is_active"`
+	Expired          bool       `db:"-" json:"expired"`
+	Description      string     `db:"description" json:"description,omitempty"`
+	ExpiryNotifiedAt *time.Time `db:"expiry_notified_at" json:"-"`
+	CreatedAt        time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time  `db:"updated_at" json:"updated_at"`
+	DeletedAt        *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+}
+
+// ExpiresAt — момент, с которого способ оплаты недействителен: начало месяца,
+// следующего за сроком. Нулевое время — срока нет.
+func (m *PaymentMethod) ExpiresAt() time.Time {
+	if m.ExpMonth == 0 || m.ExpYear == 0 {
+		return time.Time{}
+	}
+	return time.Date(m.ExpYear, time.Month(m.ExpMonth)+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// IsExpired сообщает, истёк ли срок действия к моменту now
+func (m *PaymentMethod) IsExpired(now time.Time) bool {
+	expiresAt := m.ExpiresAt()
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
 }
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Статусы выплаты
+const (
+	PayoutStatusPending    = "pending"    // средства заблокированы, провайдер выплату ещё не принял
+	PayoutStatusProcessing = "processing" // провайдер принял выплату, ждём результат
+	PayoutStatusSucceeded  = "succeeded"
+	PayoutStatusFailed     = "failed"
+)
+
+// Куда выплачиваются средства
+const (
+	PayoutDestinationCard     = "card"      // сохранённая карта из хранилища способов оплаты
+	PayoutDestinationYooMoney = "yoo_money" // кошелёк ЮMoney
+)
+
+// Payout — вывод средств со счёта на внешнюю карту или кошелёк. Сумма с
+// комиссией блокируется на счёте при создании; при успехе блокировка
+// заменяется списанием выплаты и комиссии, при отказе — снимается.
+type Payout struct {
+	ID                   int64      `db:"id" json:"id"`
+	UserID               int64      `db:"user_id" json:"user_id"`
+	AccountID            int64      `db:"account_id" json:"account_id"`
+	Amount               float64    `db:"amount" json:"amount"`
+	Fee                  float64    `db:"fee" json:"fee"`
+	Currency             string     `db:"currency" json:"currency"`
+	Provider             string     `db:"provider" json:"provider"`
+	DestinationType      string     `db:"destination_type" json:"destination_type"`
+	Destination          string     `db:"destination" json:"-"`                 // токен карты или номер кошелька
+	DestinationLabel     string     `db:"destination_label" json:"destination"` // маска для показа: visa •••• 4242
+	PaymentMethodID      *int64     `db:"payment_method_id" json:"payment_method_id,omitempty"`
+	Status               string     `db:"status" json:"status"`
+	ProviderPayoutID     string     `db:"provider_payout_id" json:"provider_payout_id,omitempty"`
+	FailureReason        string     `db:"failure_reason" json:"failure_reason,omitempty"`
+	HoldTransactionID    *int64     `db:"hold_transaction_id" json:"hold_transaction_id,omitempty"`
+	ReleaseTransactionID *int64     `db:"release_transaction_id" json:"release_transaction_id,omitempty"`
+	TransactionID        *int64     `db:"transaction_id" json:"transaction_id,omitempty"`
+	FeeTransactionID     *int64     `db:"fee_transaction_id" json:"fee_transaction_id,omitempty"`
+	CreatedAt            time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt            time.Time  `db:"updated_at" json:"updated_at"`
+	CompletedAt          *time.Time `db:"completed_at" json:"completed_at,omitempty"`
+}
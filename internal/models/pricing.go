@@ -0,0 +1,53 @@
+package models
+
+// Операции, за которые банк может взимать комиссию
+const (
+	FeeOperationTransfer   = "transfer"   // перевод со счёта; канал own — между своими счетами, p2p — другому клиенту
+	FeeOperationWithdrawal = "withdrawal" // снятие со счёта
+	FeeOperationPayment    = "payment"    // пополнение через внешнего провайдера; канал — способ оплаты
+	FeeOperationPayout     = "payout"     // вывод на внешнюю карту или кошелёк; канал — тип получателя
+)
+
+// Каналы перевода
+const (
+	FeeChannelOwn = "own"
+	FeeChannelP2P = "p2p"
+)
+
+// TariffBasic — тарифный план клиента по умолчанию
+const TariffBasic = "basic"
+
+// FeeRequest — операция, для которой рассчитывается комиссия
+type FeeRequest struct {
+	UserID    int64   `json:"-"`
+	Operation string  `json:"operation"`
+	Channel   string  `json:"channel,omitempty"`
+	Currency  string  `json:"currency"`
+	Amount    float64 `json:"amount"`
+}
+
+// FeeQuote — комиссия за операцию, рассчитанная до её выполнения
+type FeeQuote struct {
+	FeeRequest
+	Tariff string  `json:"tariff"`
+	Rule   string  `json:"rule,omitempty"` // пусто — ни одно правило не подошло, операция бесплатна
+	Fee    float64 `json:"fee"`
+	// остаток бесплатной квоты на месяц до этой операции; nil — квоты у правила нет
+	FreeOperationsLeft *int     `json:"free_operations_left,omitempty"`
+	FreeAmountLeft     *float64 `json:"free_amount_left,omitempty"`
+	// Quota — часть квоты, которую расходует операция; nil — квота не расходуется
+	Quota *FeeQuotaClaim `json:"-"`
+}
+
+// FeeQuotaClaim — расход бесплатной квоты операцией. Проводится в одной
+// транзакции БД с операцией; если квоту успели израсходовать, операция
+// не проводится.
+type FeeQuotaClaim struct {
+	UserID         int64
+	Rule           string
+	Period         string // YYYY-MM
+	Operations     int
+	Amount         float64
+	FreeOperations int     // квота правила; 0 — без квоты по числу операций
+	FreeAmount     float64 // 0 — без квоты по сумме
+}
@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+// Виды операций в отчёте провайдера
+const (
+	SettlementKindPayment = "payment"
+	SettlementKindRefund  = "refund"
+	SettlementKindPayout  = "payout"
+)
+
+// Типы расхождений сверки
+const (
+	BreakMissingInternal   = "missing_internal"    // провайдер провёл операцию, у нас её нет или она не проведена по счёту
+	BreakMissingAtProvider = "missing_at_provider" // у нас операция успешна, в отчётах провайдера её нет
+	BreakAmountMismatch    = "amount_mismatch"     // суммы или валюты расходятся
+)
+
+// Статусы расхождения
+const (
+	BreakStatusOpen     = "open"
+	BreakStatusResolved = "resolved"
+)
+
+// Способы разбора расхождения
+const (
+	BreakResolutionCredited = "credited" // платёж зачислен на счёт
+	BreakResolutionAccepted = "accepted" // оператор принял расхождение с комментарием
+	BreakResolutionMatched  = "matched"  // операция нашлась в следующем отчёте
+)
+
+// SettlementFile — импортированный файл отчёта провайдера
+type SettlementFile struct {
+	ID         int64     `db:"id" json:"id"`
+	Provider   string    `db:"provider" json:"provider"`
+	Name       string    `db:"name" json:"name"`
+	Checksum   string    `db:"checksum" json:"checksum"`
+	Entries    int       `db:"entries" json:"entries"`
+	ImportedAt time.Time `db:"imported_at" json:"imported_at"`
+}
+
+// SettlementEntry — строка отчёта провайдера
+type SettlementEntry struct {
+	ID               int64      `db:"id" json:"id"`
+	FileID           int64      `db:"file_id" json:"file_id"`
+	Provider         string     `db:"provider" json:"provider"`
+	Kind             string     `db:"kind" json:"kind"`
+	ProviderObjectID string     `db:"provider_object_id" json:"provider_object_id"`
+	Amount           float64    `db:"amount" json:"amount"`
+	Fee              float64    `db:"fee" json:"fee"`
+	Currency         string     `db:"currency" json:"currency"`
+	SettledAt        time.Time  `db:"settled_at" json:"settled_at"`
+	InternalID       *int64     `db:"internal_id" json:"internal_id,omitempty"` // платёж, возврат или выплата по kind
+	ReconciledAt     *time.Time `db:"reconciled_at" json:"reconciled_at,omitempty"`
+}
+
+// ReconciliationBreak — расхождение между отчётом провайдера и нашими данными
+type ReconciliationBreak struct {
+	ID               int64      `db:"id" json:"id"`
+	Provider         string     `db:"provider" json:"provider"`
+	Kind             string     `db:"kind" json:"kind"`
+	Type             string     `db:"type" json:"type"`
+	ProviderObjectID string     `db:"provider_object_id" json:"provider_object_id,omitempty"`
+	EntryID          *int64     `db:"entry_id" json:"entry_id,omitempty"`
+	InternalID       *int64     `db:"internal_id" json:"internal_id,omitempty"`
+	InternalAmount   *float64   `db:"internal_amount" json:"internal_amount,omitempty"`
+	SettledAmount    *float64   `db:"settled_amount" json:"settled_amount,omitempty"`
+	Currency         string     `db:"currency" json:"currency"`
+	Details          string     `db:"details" json:"details"`
+	Status           string     `db:"status" json:"status"`
+	Resolution       string     `db:"resolution" json:"resolution,omitempty"`
+	Note             string     `db:"note" json:"note,omitempty"`
+	TransactionID    *int64     `db:"transaction_id" json:"transaction_id,omitempty"`
+	ResolvedBy       *int64     `db:"resolved_by" json:"resolved_by,omitempty"`
+	ResolvedAt       *time.Time `db:"resolved_at" json:"resolved_at,omitempty"`
+	CreatedAt        time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time  `db:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Статусы регулярного списания
+const (
+	RecurringStatusPendingAuthorization = "pending_authorization" // ждём первый платёж, которым клиент разрешает списания
+	RecurringStatusActive               = "active"
+	RecurringStatusPastDue              = "past_due" // последнее списание не прошло, идут повторы
+	RecurringStatusCanceled             = "canceled"
+)
+
+// Периоды регулярного списания
+const (
+	RecurringPeriodDay   = "day"
+	RecurringPeriodWeek  = "week"
+	RecurringPeriodMonth = "month"
+)
+
+// RecurringPayment — регулярное пополнение или подписка: клиент один раз
+// разрешает списания с сохранённого способа оплаты, дальше их инициирует банк
+type RecurringPayment struct {
+	ID               int64      `db:"id" json:"id"`
+	UserID           int64      `db:"user_id" json:"user_id"`
+	AccountID        int64      `db:"account_id" json:"account_id"`
+	PaymentMethodID  int64      `db:"payment_method_id" json:"payment_method_id"`
+	Provider         string     `db:"provider" json:"provider,omitempty"`
+	ProviderMethodID string     `db:"provider_method_id" json:"-"`
+	Amount           float64    `db:"amount" json:"amount"`
+	Currency         string     `db:"currency" json:"currency"`
+	Period           string     `db:"period" json:"period"` // day, week, month; пусто — только по запросу
+	Description      string     `db:"description" json:"description,omitempty"`
+	Status           string     `db:"status" json:"status"`
+	NextChargeAt     *time.Time `db:"next_charge_at" json:"next_charge_at,omitempty"`
+	FailedAttempts   int        `db:"failed_attempts" json:"failed_attempts"`
+	MaxFailures      int        `db:"max_failures" json:"max_failures"`
+	LastPaymentID    *int64     `db:"last_payment_id" json:"last_payment_id,omitempty"`
+	CancelReason     string     `db:"cancel_reason" json:"cancel_reason,omitempty"`
+	CreatedAt        time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time  `db:"updated_at" json:"updated_at"`
+	CanceledAt       *time.Time `db:"canceled_at" json:"canceled_at,omitempty"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ReencryptionJob — прогресс перешифрования карт на ключ TargetKeyID
+type ReencryptionJob struct {
+	ID          int64      `db:"id" json:"id"`
+	TargetKeyID string     `db:"target_key_id" json:"target_key_id"`
+	LastCardID  int64      `db:"last_card_id" json:"last_card_id"`
+	Processed   int        `db:"processed" json:"processed"`
+	Failed      int        `db:"failed" json:"failed"`
+	Status      string     `db:"status" json:"status"` // running, completed
+	StartedAt   time.Time  `db:"started_at" json:"started_at"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+	CompletedAt *time.Time `db:"completed_at" json:"completed_at,omitempty"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+type RewardCampaign struct {
+	ID              int64      `db:"id" json:"id"`
+	Name            string     `db:"name" json:"name"`
+	Category        string     `db:"category" json:"category"`
+	MCCCodes        string     `db:"mcc_codes" json:"mcc_codes,omitempty"` // через запятую: "5812,5814"
+	Merchant        string     `db:"merchant" json:"merchant,omitempty"`
+	TransactionType string     `db:"transaction_type" json:"transaction_type,omitempty"`
+	Percent         float64    `db:"percent" json:"percent"`
+	MonthlyCap      float64    `db:"monthly_cap" json:"monthly_cap"` // 0 = без ограничения
+	RequiresOptIn   bool       `db:"requires_opt_in" json:"requires_opt_in"`
+	StartsAt        time.Time  `db:"starts_at" json:"starts_at"`
+	EndsAt          *time.Time `db:"ends_at" json:"ends_at,omitempty"`
+	IsActive        bool       `db:"is_active" json:"is_active"`
+	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
+	OptedIn         bool       `db:"opted_in" json:"opted_in"`
+}
+
+type CashbackAccrual struct {
+	ID                  int64      `db:"id" json:"id"`
+	UserID              int64      `db:"user_id" json:"user_id"`
+	AccountID           int64      `db:"account_id" json:"account_id"`
+	CampaignID          int64      `db:"campaign_id" json:"campaign_id"`
+	TransactionID       int64      `db:"transaction_id" json:"transaction_id"`
+	Category            string     `db:"category" json:"category"`
+	Amount              float64    `db:"amount" json:"amount"`
+	Status              string     `db:"status" json:"status"` // pending, paid
+	Period              time.Time  `db:"period" json:"period"`
+	PayoutTransactionID *int64     `db:"payout_transaction_id" json:"payout_transaction_id,omitempty"`
+	CreatedAt           time.Time  `db:"created_at" json:"created_at"`
+	PaidAt              *time.Time `db:"paid_at" json:"paid_at,omitempty"`
+}
+
+// CashbackSummary — кэшбэк пользователя по категории за период
+type CashbackSummary struct {
+	Category string  `db:"category" json:"category"`
+	Accrued  float64 `db:"accrued" json:"accrued"`
+	Pending  float64 `db:"pending" json:"pending"`
+	Paid     float64 `db:"paid" json:"paid"`
+}
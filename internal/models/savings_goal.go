@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+type SavingsGoal struct {
+	ID              int64      `db:"id" json:"id"`
+	UserID          int64      `db:"user_id" json:"user_id"`
+	AccountID       int64      `db:"account_id" json:"account_id"`
+	SourceAccountID int64      `db:"source_account_id" json:"source_account_id"`
+	Name            string     `db:"name" json:"name"`
+	TargetAmount    float64    `db:"target_amount" json:"target_amount"`
+	TargetDate      *time.Time `db:"target_date" json:"target_date,omitempty"`
+	Status          string     `db:"status" json:"status"` // active, closed
+	Balance         float64    `db:"balance" json:"balance"`
+	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
+	ClosedAt        *time.Time `db:"closed_at" json:"closed_at,omitempty"`
+}
+
+type SavingsGoalProgress struct {
+	SavingsGoal
+	Progress      float64 `json:"progress"` // процент выполнения цели
+	Remaining     float64 `json:"remaining"`
+	MonthlyNeeded float64 `json:"monthly_needed,omitempty"` // сколько откладывать в месяц, чтобы успеть к target_date
+}
+
+type SavingsRule struct {
+	ID        int64      `db:"id" json:"id"`
+	GoalID    int64      `db:"goal_id" json:"goal_id"`
+	Type      string     `db:"type" json:"type"` // round_up, income_percent, weekly_sweep
+	RoundTo   int        `db:"round_to" json:"round_to,omitempty"`
+	Percent   float64    `db:"percent" json:"percent,omitempty"`
+	Amount    float64    `db:"amount" json:"amount,omitempty"`
+	Weekday   int        `db:"weekday" json:"weekday"`
+	IsActive  bool       `db:"is_active" json:"is_active"`
+	LastRunAt *time.Time `db:"last_run_at" json:"last_run_at,omitempty"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+}
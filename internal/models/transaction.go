@@ -7,8 +7,10 @@ type Transaction struct {
 	FromAccount int64     `json:"from_account,omitempty"`
 	ToAccount   int64     `json:"to_account,omitempty"`
 	Amount      float64   `json:"amount"`
-	Type        string    `json:"type"` // deposit, transfer, withdraw, credit_payment
+	Type        string    `json:"type"` // deposit, transfer, withdraw, credit_payment, card_purchase, interest, pot_transfer, ...
 	Timestamp   time.Time `json:"timestamp"`
 	Description string    `json:"description,omitempty"`
 	IsReversal  bool      `json:"is_reversal"`
+	MCC         string    `json:"mcc,omitempty"` // код категории продавца для карточных операций
+	Merchant    string    `json:"merchant,omitempty"`
 }
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type TransferTemplate struct {
+	ID            int64     `db:"id" json:"id"`
+	UserID        int64     `db:"user_id" json:"user_id"`
+	BeneficiaryID int64     `db:"beneficiary_id" json:"beneficiary_id"`
+	FromAccountID int64     `db:"from_account_id" json:"from_account_id"`
+	Name          string    `db:"name" json:"name"`
+	DefaultAmount float64   `db:"default_amount" json:"default_amount"`
+	Description   string    `db:"description" json:"description,omitempty"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
+}
@@ -9,5 +9,7 @@ type User struct {
 	UserName  string    `json:"username" validate:"required,alphanum"`
 	Email     string    `json:"email" validate:"required,email"`
 	Password  string    `json:"-"`
+	Role      string    `json:"role,omitempty"`   // user, admin
+	Tariff    string    `json:"tariff,omitempty"` // тарифный план: от него зависят комиссии
 	CreatedAt time.Time `json:"created_at"`
 }
@@ -0,0 +1,59 @@
+package pan
+
+import (
+	"testing"
+	"testing/quick"
+)
+
+var testRanges = []BINRange{
+	{Product: "mir-debit", Brand: BrandMir, From: "2200", To: "2204"},
+	{Product: "mc-classic", Brand: BrandMastercard, From: "510000", To: "559999"},
+	{Product: "mc-2series", Brand: BrandMastercard, From: "2221", To: "2720"},
+	{Product: "visa-classic", Brand: BrandVisa, From: "427638", To: "427638"},
+	{Product: "visa-wide", Brand: BrandVisa, From: "40", To: "49"},
+}
+
+func TestGenerateStaysInProductRange(t *testing.T) {
+	issuer, err := NewIssuer(testRanges)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	property := func(choice uint8) bool {
+		r := testRanges[int(choice)%len(testRanges)]
+		number, err := issuer.Generate(r.Product)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		prefix := number[:len(r.From)]
+		return Valid(number) && prefix >= r.From && prefix <= r.To && Brand(number) == r.Brand
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 1000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGenerateUnknownProduct(t *testing.T) {
+	issuer, err := NewIssuer(testRanges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.Generate("unknown"); err == nil {
+		t.Error("Generate accepted an unknown product")
+	}
+}
+
+func TestNewIssuerRejectsInvalidRanges(t *testing.T) {
+	for _, r := range []BINRange{
+		{Product: "", Brand: BrandVisa, From: "4", To: "4"},
+		{Product: "uneven", Brand: BrandVisa, From: "40", To: "499"},
+		{Product: "reversed", Brand: BrandVisa, From: "49", To: "40"},
+		{Product: "foreign", Brand: BrandMir, From: "4000", To: "4999"},
+		{Product: "mixed", Brand: BrandMastercard, From: "2200", To: "2720"},
+	} {
+		if _, err := NewIssuer([]BINRange{r}); err == nil {
+			t.Errorf("NewIssuer accepted invalid range %+v", r)
+		}
+	}
+}
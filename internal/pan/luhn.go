@@ -0,0 +1,92 @@
+package pan
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+const Length = 16
+
+// CheckDigit вычисляет контрольную цифру Луна для номера без неё
+func CheckDigit(partial string) (byte, bool) {
+	sum := 0
+	double := true // справа налево, начиная с позиции перед контрольной цифрой
+	for i := len(partial) - 1; i >= 0; i-- {
+		c := partial[i]
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return byte('0' + (10-sum%10)%10), true
+}
+
+// Valid проверяет, что номер состоит из 16 цифр и проходит проверку Луна
+func Valid(number string) bool {
+	if len(number) != Length {
+		return false
+	}
+	check, ok := CheckDigit(number[:Length-1])
+	return ok && check == number[Length-1]
+}
+
+// Normalize убирает пробелы и дефисы, которыми пользователи разделяют группы цифр
+func Normalize(number string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(number)
+}
+
+// Brand определяет платёжную систему по префиксу номера
+func Brand(number string) string {
+	switch {
+	case inPrefixRange(number, 2200, 2204, 4):
+		return BrandMir
+	case inPrefixRange(number, 51, 55, 2), inPrefixRange(number, 2221, 2720, 4):
+		return BrandMastercard
+	case strings.HasPrefix(number, "4"):
+		return BrandVisa
+	default:
+		return ""
+	}
+}
+
+func inPrefixRange(number string, from, to int64, digits int) bool {
+	if len(number) < digits {
+		return false
+	}
+	n, ok := new(big.Int).SetString(number[:digits], 10)
+	if !ok {
+		return false
+	}
+	return n.Int64() >= from && n.Int64() <= to
+}
+
+// RandomDigits возвращает n случайных цифр без смещения распределения
+func RandomDigits(n int) (string, error) {
+	var b strings.Builder
+	ten := big.NewInt(10)
+	for i := 0; i < n; i++ {
+		d, err := rand.Int(rand.Reader, ten)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(byte('0' + d.Int64()))
+	}
+	return b.String(), nil
+}
+
+// Mask оставляет BIN и последние 4 цифры: 4276380000001234 -> 427638******1234
+func Mask(number string) string {
+	if len(number) < 10 {
+		return strings.Repeat("*", len(number))
+	}
+	return number[:6] + strings.Repeat("*", len(number)-10) + number[len(number)-4:]
+}
@@ -0,0 +1,64 @@
+package pan
+
+import (
+	"testing"
+	"testing/quick"
+)
+
+// digits превращает произвольные байты в строку цифр той же длины
+func digits(b []byte) string {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[i] = '0' + c%10
+	}
+	return string(out)
+}
+
+func TestCheckDigitRoundTrip(t *testing.T) {
+	property := func(raw [Length - 1]byte) bool {
+		partial := digits(raw[:])
+		check, ok := CheckDigit(partial)
+		if !ok || check < '0' || check > '9' {
+			return false
+		}
+		if !Valid(partial + string(check)) {
+			return false
+		}
+		// любая другая контрольная цифра не проходит проверку
+		for d := byte('0'); d <= '9'; d++ {
+			if d != check && Valid(partial+string(d)) {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidDetectsSingleDigitChange(t *testing.T) {
+	property := func(raw [Length - 1]byte, pos uint8, delta uint8) bool {
+		partial := digits(raw[:])
+		check, _ := CheckDigit(partial)
+		number := []byte(partial + string(check))
+
+		i := int(pos) % Length
+		number[i] = '0' + (number[i]-'0'+1+delta%9)%10
+		return !Valid(string(number))
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckDigitRejectsNonDigits(t *testing.T) {
+	for _, partial := range []string{"42763800000012a", "4276-3800000012", " 27638000000012"} {
+		if _, ok := CheckDigit(partial); ok {
+			t.Errorf("CheckDigit(%q) accepted a non-digit", partial)
+		}
+	}
+	if Valid("427638000000123") || Valid("42763800000012345") {
+		t.Error("Valid accepted a number of the wrong length")
+	}
+}
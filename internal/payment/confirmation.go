@@ -0,0 +1,119 @@
+package payment
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/payment/providers"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"errors"
+	"time"
+)
+
+// ResumePayment вызывается, когда провайдер вернул клиента после
+// подтверждения платежа. Статус запрашивается у провайдера сразу, не
+// дожидаясь уведомления; окончательный платёж не трогается. Если провайдер
+// не ответил, возвращается сохранённый статус. Без верной подписи адреса
+// возврата платёж считается не найденным.
+func (s *PaymentService) ResumePayment(ctx context.Context, paymentID int64, token string) (*models.Payment, error) {
+	if !s.cfg.ReturnURLs.Verify(paymentID, token) {
+		return nil, ErrPaymentNotFound
+	}
+	p, err := s.repo.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if p.ProviderPaymentID == "" ||
+		(p.Status != models.PaymentStatusRequiresAction && p.Status != models.PaymentStatusProcessing) {
+		return p, nil
+	}
+
+	provider, err := s.provider(p.Provider)
+	if err != nil {
+		return nil, err
+	}
+	result, err := provider.GetPayment(ctx, p.ProviderPaymentID)
+	if err != nil {
+		// клиенту отдаём сохранённый статус, итог придёт уведомлением
+		logger.Sugared().Warnf("payment %d: failed to fetch status from %s: %v", p.ID, p.Provider, err)
+		return p, nil
+	}
+	if err := s.applyResult(ctx, p, result, result.Error); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ExpireAbandonedActions отменяет платежи, которые клиент не подтвердил за
+// ActionTimeout. Перед отменой статус сверяется с провайдером: клиент мог
+// пройти 3-D Secure, а уведомление — потеряться.
+func (s *PaymentService) ExpireAbandonedActions(ctx context.Context) error {
+	payments, err := s.repo.ListExpiredActions(ctx, time.Now().Add(-s.cfg.ActionTimeout), 100)
+	if err != nil {
+		return err
+	}
+
+	for i := range payments {
+		p := &payments[i]
+		if err := s.expireAction(ctx, p); err != nil {
+			logger.Sugared().Errorf("payment %d: failed to expire confirmation: %v", p.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *PaymentService) expireAction(ctx context.Context, p *models.Payment) error {
+	if p.ProviderPaymentID != "" {
+		provider, err := s.provider(p.Provider)
+		if err != nil {
+			return err
+		}
+		// Stripe отменяет PaymentIntent по запросу. YooKassa сама отменяет
+		// неподтверждённый платёж, поэтому у неё статус только запрашивается.
+		var result *models.PaymentResult
+		if canceler, ok := provider.(providers.PaymentCanceler); ok {
+			result, err = canceler.CancelPayment(ctx, p.ProviderPaymentID)
+		} else {
+			result, err = provider.GetPayment(ctx, p.ProviderPaymentID)
+		}
+		if err != nil {
+			return err
+		}
+		if result.Status != models.PaymentStatusRequiresAction {
+			reason := result.Error
+			if result.Status == models.PaymentStatusCanceled {
+				reason = FailureActionAbandoned
+			}
+			return s.applyResult(ctx, p, result, reason)
+		}
+	}
+
+	// провайдер ещё ждёт клиента: у себя платёж отменяем, а если он всё же
+	// пройдёт, его найдёт сверка с отчётом провайдера
+	err := s.apply(ctx, p, models.PaymentStatusCanceled, FailureActionAbandoned, SourceSystem)
+	if errors.Is(err, ErrPaymentStatusChanged) {
+		return nil
+	}
+	return err
+}
+
+// applyResult переводит платёж в статус, полученный запросом к провайдеру
+func (s *PaymentService) applyResult(ctx context.Context, p *models.Payment, result *models.PaymentResult, reason string) error {
+	if result.PaymentMethodID != "" && p.SavedPaymentMethodID == "" {
+		if err := s.repo.SetSavedPaymentMethod(ctx, p.ID, result.PaymentMethodID); err != nil {
+			return err
+		}
+		p.SavedPaymentMethodID = result.PaymentMethodID
+	}
+
+	err := s.apply(ctx, p, result.Status, reason, SourceProvider)
+	if errors.Is(err, ErrPaymentStatusChanged) {
+		// статус успело поменять уведомление
+		fresh, gerr := s.repo.GetPaymentByID(ctx, p.ID)
+		if gerr != nil {
+			return gerr
+		}
+		*p = *fresh
+		return nil
+	}
+	return err
+}
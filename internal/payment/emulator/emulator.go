@@ -0,0 +1,399 @@
+// Package emulator — локальный эмулятор Stripe PaymentIntents и YooKassa v3
+// для разработки и интеграционных тестов без доступа в интернет.
+//
+// Сценарий платежа выбирается тестовой картой, номер которой входит в токен
+// способа оплаты (например pm_card_4000000000000002), или копейками суммы:
+//
+//	4242424242424242, 5555555555554444, 2200000000000004 — успех
+//	4000000000000002, сумма *.02  — отказ (generic_decline)
+//	4000000000009995              — отказ: недостаточно средств
+//	4000000000000069              — отказ: карта просрочена
+//	4000000000003220, сумма *.05  — требуется 3-D Secure
+//	4000000000000077, сумма *.07  — processing, успех приходит уведомлением
+//	4000000000000341, сумма *.08  — processing, отказ приходит уведомлением
+//
+// Остальные платежи успешны. 3-D Secure проходит на странице ACS
+// /acs/{provider}/{id}: кнопки «Подтвердить» и «Отклонить» (или параметр
+// result=success|fail) завершают платёж и возвращают клиента на return_url.
+// POST /v1/payment_intents/{id}/cancel отменяет платёж, ожидающий 3-D Secure.
+// Списание без клиента (off_session у Stripe, платёж YooKassa без confirmation)
+// по карте, требующей 3-D Secure, отклоняется с authentication_required.
+// Способ оплаты, сохранённый YooKassa, сохраняет сценарий карты.
+//
+// POST /v1/payment_methods токенизирует карту (номер проверяется по Луну,
+// срок — не в прошлом); платёж по полученному pm_... идёт по сценарию этой
+// карты. GET /v1/payment_methods/{id} отдаёт бренд, last4, срок и отпечаток.
+//
+// Возвраты по копейкам суммы возврата: *.09 — pending, успех приходит
+// уведомлением; *.04 — pending, затем отказ (YooKassa об отказе не уведомляет,
+// его видно только запросом GET /v3/refunds/{id}). Остальные возвраты успешны сразу.
+//
+// Выплаты (POST /v1/payouts, POST /v3/payouts) идут по тем же сценариям
+// карты-получателя или копеек суммы: отказ — выплата отклонена сразу,
+// processing — завершается уведомлением, остальные выплачиваются сразу.
+// Выплата на кошелёк ЮMoney выбирает сценарий по копейкам.
+//
+// GET /emulator/reports/{stripe|yookassa}?date=YYYY-MM-DD отдаёт отчёт о
+// расчётах по успешным операциям в формате провайдера — его можно положить в
+// каталог сверки.
+//
+// POST /emulator/outage/{stripe|yookassa}?for=30s имитирует недоступность
+// провайдера: его API отвечает 503, пока не истечёт срок (for=0 — снять).
+package emulator
+
+import (
+	"bank-api/internal/utils"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota
+	outcomeDecline
+	outcome3DS
+	outcomeDelayedSuccess
+	outcomeDelayedDecline
+)
+
+type scenario struct {
+	outcome     outcome
+	declineCode string // в терминах Stripe: generic_decline, insufficient_funds, expired_card
+}
+
+var testCards = map[string]scenario{
+	"4242424242424242": {outcome: outcomeSuccess},
+	"5555555555554444": {outcome: outcomeSuccess},
+	"2200000000000004": {outcome: outcomeSuccess},
+	"4000000000000002": {outcome: outcomeDecline, declineCode: "generic_decline"},
+	"4000000000009995": {outcome: outcomeDecline, declineCode: "insufficient_funds"},
+	"4000000000000069": {outcome: outcomeDecline, declineCode: "expired_card"},
+	"4000000000003220": {outcome: outcome3DS},
+	"4000000000000077": {outcome: outcomeDelayedSuccess},
+	"4000000000000341": {outcome: outcomeDelayedDecline, declineCode: "generic_decline"},
+}
+
+var (
+	cardNumberRe = regexp.MustCompile(`\d{13,19}`)
+	walletRe     = regexp.MustCompile(`\d{11,20}`)
+)
+
+// pickScenario выбирает сценарий: тестовая карта важнее суммы
+func pickScenario(amountMinor int64, paymentMethod string) scenario {
+	if sc, ok := testCards[cardNumberRe.FindString(paymentMethod)]; ok {
+		return sc
+	}
+	switch amountMinor % 100 {
+	case 2:
+		return scenario{outcome: outcomeDecline, declineCode: "generic_decline"}
+	case 5:
+		return scenario{outcome: outcome3DS}
+	case 7:
+		return scenario{outcome: outcomeDelayedSuccess}
+	case 8:
+		return scenario{outcome: outcomeDelayedDecline, declineCode: "generic_decline"}
+	}
+	return scenario{outcome: outcomeSuccess}
+}
+
+// offSession — сценарий списания без клиента: 3-D Secure пройти некому
+func offSession(sc scenario) scenario {
+	if sc.outcome == outcome3DS {
+		return scenario{outcome: outcomeDecline, declineCode: "authentication_required"}
+	}
+	return sc
+}
+
+// cardBrand определяет платёжную систему по номеру карты
+func cardBrand(number string) string {
+	switch {
+	case strings.HasPrefix(number, "4"):
+		return "visa"
+	case strings.HasPrefix(number, "220"):
+		return "mir"
+	case strings.HasPrefix(number, "34"), strings.HasPrefix(number, "37"):
+		return "amex"
+	case strings.HasPrefix(number, "5"), strings.HasPrefix(number, "2"):
+		return "mastercard"
+	}
+	return "unknown"
+}
+
+// luhnValid проверяет контрольную цифру номера карты
+func luhnValid(number string) bool {
+	sum := 0
+	for i := 0; i < len(number); i++ {
+		d := int(number[len(number)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// refundOutcome выбирает сценарий возврата по копейкам суммы
+func refundOutcome(amountMinor int64) outcome {
+	switch amountMinor % 100 {
+	case 9:
+		return outcomeDelayedSuccess
+	case 4:
+		return outcomeDelayedDecline
+	}
+	return outcomeSuccess
+}
+
+// Config — куда и с какой задержкой эмулятор отправляет уведомления
+type Config struct {
+	StripeWebhookURL    string // пусто — уведомления Stripe не отправляются
+	StripeWebhookSecret string
+	YooKassaWebhookURL  string        // пусто — уведомления YooKassa не отправляются
+	WebhookDelay        time.Duration // через сколько завершается отложенный платёж
+}
+
+type cachedResponse struct {
+	code int
+	body []byte
+}
+
+// Server — http.Handler эмулятора. Состояние хранится в памяти.
+type Server struct {
+	cfg    Config
+	client *http.Client
+	router *mux.Router
+
+	mu               sync.Mutex
+	stripeIntents    map[string]*stripeIntent
+	stripeRefunds    map[string]*stripeRefund
+	stripeMethods    map[string]*stripePaymentMethod
+	stripePayouts    map[string]*stripePayout
+	yookassaPayments map[string]*yookassaPayment
+	yookassaRefunds  map[string]*yookassaRefund
+	yookassaSaved    map[string]scenario // сохранённые способы оплаты
+	yookassaPayouts  map[string]*yookassaPayout
+	idempotency      map[string]cachedResponse
+	outages          map[string]time.Time // провайдер -> до какого момента недоступен
+}
+
+func New(cfg Config) *Server {
+	s := &Server{
+		cfg:              cfg,
+		client:           &http.Client{Timeout: 10 * time.Second},
+		router:           mux.NewRouter(),
+		stripeIntents:    make(map[string]*stripeIntent),
+		stripeRefunds:    make(map[string]*stripeRefund),
+		stripeMethods:    make(map[string]*stripePaymentMethod),
+		stripePayouts:    make(map[string]*stripePayout),
+		yookassaPayments: make(map[string]*yookassaPayment),
+		yookassaRefunds:  make(map[string]*yookassaRefund),
+		yookassaSaved:    make(map[string]scenario),
+		yookassaPayouts:  make(map[string]*yookassaPayout),
+		idempotency:      make(map[string]cachedResponse),
+		outages:          make(map[string]time.Time),
+	}
+
+	stripe := s.router.PathPrefix("/v1").Subrouter()
+	stripe.Use(s.outage("stripe"))
+	stripe.HandleFunc("/payment_intents", s.stripeCreateIntent).Methods(http.MethodPost)
+	stripe.HandleFunc("/payment_intents/{id}", s.stripeGetIntent).Methods(http.MethodGet)
+	stripe.HandleFunc("/payment_intents/{id}/cancel", s.stripeCancelIntent).Methods(http.MethodPost)
+	stripe.HandleFunc("/payment_methods", s.stripeCreatePaymentMethod).Methods(http.MethodPost)
+	stripe.HandleFunc("/payment_methods/{id}", s.stripeGetPaymentMethod).Methods(http.MethodGet)
+	stripe.HandleFunc("/payouts", s.stripeCreatePayout).Methods(http.MethodPost)
+	stripe.HandleFunc("/payouts/{id}", s.stripeGetPayout).Methods(http.MethodGet)
+	stripe.HandleFunc("/refunds", s.stripeCreateRefund).Methods(http.MethodPost)
+	stripe.HandleFunc("/refunds/{id}", s.stripeGetRefund).Methods(http.MethodGet)
+
+	yookassa := s.router.PathPrefix("/v3").Subrouter()
+	yookassa.Use(s.outage("yookassa"))
+	yookassa.HandleFunc("/payments", s.yookassaCreatePayment).Methods(http.MethodPost)
+	yookassa.HandleFunc("/payments/{id}", s.yookassaGetPayment).Methods(http.MethodGet)
+	yookassa.HandleFunc("/payouts", s.yookassaCreatePayout).Methods(http.MethodPost)
+	yookassa.HandleFunc("/payouts/{id}", s.yookassaGetPayout).Methods(http.MethodGet)
+	yookassa.HandleFunc("/refunds", s.yookassaCreateRefund).Methods(http.MethodPost)
+	yookassa.HandleFunc("/refunds/{id}", s.yookassaGetRefund).Methods(http.MethodGet)
+
+	s.router.HandleFunc("/emulator/outage/{provider:stripe|yookassa}", s.setOutage).Methods(http.MethodPost)
+	s.router.HandleFunc("/emulator/reports/stripe", s.stripeReport).Methods(http.MethodGet)
+	s.router.HandleFunc("/emulator/reports/yookassa", s.yookassaReport).Methods(http.MethodGet)
+
+	s.router.HandleFunc("/acs/stripe/{id}", s.stripeACS).Methods(http.MethodGet, http.MethodPost)
+	s.router.HandleFunc("/acs/yookassa/{id}", s.yookassaACS).Methods(http.MethodGet, http.MethodPost)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// outage отвечает 503, пока провайдер «лежит»
+func (s *Server) outage(provider string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			until := s.outages[provider]
+			s.mu.Unlock()
+			if time.Now().Before(until) {
+				w.Header().Set("Retry-After", fmt.Sprint(int(time.Until(until).Seconds())+1))
+				http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Выплаты (POST /v1/payouts, POST /v3/payouts) идут по тем же сценариям
+// карты-получателя или копеек суммы: отказ — выплата отклонена сразу,
+// processing — завершается уведомлением, остальные выплачиваются сразу.
+// Выплата на кошелёк ЮMoney выбирает сценарий по копейкам.
+//
+// POST /emulator/outage/{provider}?for=30s
+func (s *Server) setOutage(w http.ResponseWriter, r *http.Request) {
+	d, err := time.ParseDuration(r.URL.Query().Get("for"))
+	if err != nil {
+		http.Error(w, "for must be a duration, e.g. 30s", http.StatusBadRequest)
+		return
+	}
+	provider := mux.Vars(r)["provider"]
+	until := time.Now().Add(d)
+	s.mu.Lock()
+	s.outages[provider] = until
+	s.mu.Unlock()
+	utils.RespondJSON(w, http.StatusOK, map[string]interface{}{"provider": provider, "unavailable_until": until.UTC()})
+}
+
+// replay отвечает сохранённым ответом на повтор запроса с тем же ключом идемпотентности
+func (s *Server) replay(w http.ResponseWriter, key string) bool {
+	if key == "" {
+		return false
+	}
+	s.mu.Lock()
+	cached, ok := s.idempotency[key]
+	s.mu.Unlock()
+	if ok {
+		writeRaw(w, cached.code, cached.body)
+	}
+	return ok
+}
+
+// respond отвечает и запоминает ответ для повторов: состояние объекта
+// фиксируется на момент первого ответа, как у настоящих провайдеров
+func (s *Server) respond(w http.ResponseWriter, key string, code int, body interface{}) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if key != "" {
+		s.mu.Lock()
+		s.idempotency[key] = cachedResponse{code: code, body: raw}
+		s.mu.Unlock()
+	}
+	writeRaw(w, code, raw)
+}
+
+func writeRaw(w http.ResponseWriter, code int, raw []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(raw)
+}
+
+// deliver отправляет уведомление в фоне, повторяя при ошибке до трёх раз
+func (s *Server) deliver(url string, body []byte, header http.Header) {
+	if url == "" {
+		return
+	}
+	go func() {
+		for attempt := 1; attempt <= 3; attempt++ {
+			req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			if err != nil {
+				log.Printf("emulator: webhook %s: %v", url, err)
+				return
+			}
+			for k, v := range header {
+				req.Header[k] = v
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := s.client.Do(req)
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode < 300 {
+					return
+				}
+				err = fmt.Errorf("status %d", resp.StatusCode)
+			}
+			log.Printf("emulator: webhook %s attempt %d: %v", url, attempt, err)
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}()
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func baseURL(r *http.Request) string {
+	return "http://" + r.Host
+}
+
+var acsPage = template.Must(template.New("acs").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head><meta charset="utf-8"><title>3-D Secure</title></head>
+<body style="font-family: sans-serif; max-width: 420px; margin: 40px auto">
+<h2>Подтверждение платежа</h2>
+<p>{{.Provider}} · платёж {{.ID}}</p>
+<p style="font-size: 1.4em">{{.Amount}} {{.Currency}}</p>
+<p>Тестовый ACS эмулятора: код из SMS не нужен.</p>
+<form method="post" action="{{.Action}}">
+<button name="result" value="success">Подтвердить</button>
+<button name="result" value="fail">Отклонить</button>
+</form>
+</body>
+</html>
+`))
+
+// renderACS показывает страницу ACS банка-эмитента
+func renderACS(w http.ResponseWriter, r *http.Request, provider, id, amount, currency string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = acsPage.Execute(w, map[string]string{
+		"Provider": provider,
+		"ID":       id,
+		"Amount":   amount,
+		"Currency": currency,
+		"Action":   r.URL.Path,
+	})
+}
+
+// finishACS возвращает клиента на return_url после 3-D Secure
+func finishACS(w http.ResponseWriter, r *http.Request, returnURL, param, id string) {
+	if returnURL == "" {
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "authenticated", param: id})
+		return
+	}
+	sep := "?"
+	if strings.Contains(returnURL, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, returnURL+sep+param+"="+url.QueryEscape(id), http.StatusFound)
+}
@@ -0,0 +1,124 @@
+package emulator
+
+import (
+	"encoding/csv"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// reportRow — строка отчёта о расчётах
+type reportRow struct {
+	created time.Time
+	fields  []string
+}
+
+// reportDay — фильтр ?date=YYYY-MM-DD (UTC); без него в отчёт попадает всё
+func reportDay(r *http.Request) (time.Time, bool, error) {
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		return time.Time{}, false, nil
+	}
+	day, err := time.Parse("2006-01-02", date)
+	return day, true, err
+}
+
+func writeReport(w http.ResponseWriter, comma rune, header []string, rows []reportRow) {
+	sort.Slice(rows, func(i, j int) bool { return rows[i].created.Before(rows[j].created) })
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	out := csv.NewWriter(w)
+	out.Comma = comma
+	_ = out.Write(header)
+	for _, row := range rows {
+		_ = out.Write(row.fields)
+	}
+	out.Flush()
+}
+
+// GET /emulator/reports/stripe — балансовые транзакции в формате выгрузки
+// balance_change_from_activity.itemized: успешные платежи, возвраты и выплаты
+func (s *Server) stripeReport(w http.ResponseWriter, r *http.Request) {
+	day, filter, err := reportDay(r)
+	if err != nil {
+		http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	inDay := func(unix int64) bool {
+		t := time.Unix(unix, 0).UTC()
+		return !filter || (!t.Before(day) && t.Before(day.AddDate(0, 0, 1)))
+	}
+	row := func(created int64, category, source, intent string, minor int64, currency string) reportRow {
+		t := time.Unix(created, 0).UTC()
+		return reportRow{created: t, fields: []string{
+			"txn_" + randomID(12), t.Format("2006-01-02 15:04:05"), strings.ToLower(currency),
+			formatMinor(minor), "0.00", formatMinor(minor), category, source, intent,
+		}}
+	}
+
+	s.mu.Lock()
+	var rows []reportRow
+	for _, pi := range s.stripeIntents {
+		if pi.Status == "succeeded" && inDay(pi.Created) {
+			rows = append(rows, row(pi.Created, "charge", "ch_"+strings.TrimPrefix(pi.ID, "pi_"), pi.ID, pi.AmountReceived, pi.Currency))
+		}
+	}
+	for _, re := range s.stripeRefunds {
+		if re.Status == "succeeded" && inDay(re.Created) {
+			rows = append(rows, row(re.Created, "refund", re.ID, re.PaymentIntent, -re.Amount, re.Currency))
+		}
+	}
+	for _, po := range s.stripePayouts {
+		if po.Status == "paid" && inDay(po.Created) {
+			rows = append(rows, row(po.Created, "payout", po.ID, "", -po.Amount, po.Currency))
+		}
+	}
+	s.mu.Unlock()
+
+	writeReport(w, ',', []string{
+		"balance_transaction_id", "created_utc", "currency", "gross", "fee", "net",
+		"reporting_category", "source_id", "payment_intent_id",
+	}, rows)
+}
+
+// GET /emulator/reports/yookassa — реестр успешных операций магазина
+func (s *Server) yookassaReport(w http.ResponseWriter, r *http.Request) {
+	day, filter, err := reportDay(r)
+	if err != nil {
+		http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	inDay := func(t time.Time) bool {
+		return !filter || (!t.Before(day) && t.Before(day.AddDate(0, 0, 1)))
+	}
+	row := func(created time.Time, kind, id string, amount yookassaAmount) reportRow {
+		return reportRow{created: created, fields: []string{
+			id, kind, created.Format("02.01.2006 15:04:05"),
+			strings.Replace(amount.Value, ".", ",", 1), "0,00", amount.Currency,
+		}}
+	}
+
+	s.mu.Lock()
+	var rows []reportRow
+	for _, p := range s.yookassaPayments {
+		if p.Status == "succeeded" && inDay(p.CreatedAt) {
+			rows = append(rows, row(p.CreatedAt, "Платёж", p.ID, p.Amount))
+		}
+	}
+	for _, re := range s.yookassaRefunds {
+		if re.Status == "succeeded" && inDay(re.CreatedAt) {
+			rows = append(rows, row(re.CreatedAt, "Возврат", re.ID, re.Amount))
+		}
+	}
+	for _, po := range s.yookassaPayouts {
+		if po.Status == "succeeded" && inDay(po.CreatedAt) {
+			rows = append(rows, row(po.CreatedAt, "Выплата", po.ID, po.Amount))
+		}
+	}
+	s.mu.Unlock()
+
+	writeReport(w, ';', []string{
+		"Идентификатор операции", "Тип операции", "Дата операции", "Сумма", "Комиссия", "Валюта",
+	}, rows)
+}
@@ -0,0 +1,634 @@
+package emulator
+
+import (
+	"bank-api/internal/utils"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type stripeError struct {
+	Type          string        `json:"type"`
+	Code          string        `json:"code,omitempty"`
+	DeclineCode   string        `json:"decline_code,omitempty"`
+	Message       string        `json:"message"`
+	Param         string        `json:"param,omitempty"`
+	PaymentIntent *stripeIntent `json:"payment_intent,omitempty"`
+}
+
+type stripeNextAction struct {
+	Type          string `json:"type"`
+	RedirectToURL struct {
+		URL       string `json:"url"`
+		ReturnURL string `json:"return_url"`
+	} `json:"redirect_to_url"`
+}
+
+type stripeIntent struct {
+	ID                 string            `json:"id"`
+	Object             string            `json:"object"`
+	Amount             int64             `json:"amount"`
+	AmountReceived     int64             `json:"amount_received"`
+	Currency           string            `json:"currency"`
+	Status             string            `json:"status"`
+	PaymentMethod      string            `json:"payment_method"`
+	SetupFutureUsage   string            `json:"setup_future_usage,omitempty"`
+	Metadata           map[string]string `json:"metadata"`
+	NextAction         *stripeNextAction `json:"next_action"`
+	LastPaymentError   *stripeError      `json:"last_payment_error"`
+	CancellationReason string            `json:"cancellation_reason,omitempty"`
+	Created            int64             `json:"created"`
+
+	refunded int64
+	scenario scenario
+}
+
+type stripeRefund struct {
+	ID            string            `json:"id"`
+	Object        string            `json:"object"`
+	Amount        int64             `json:"amount"`
+	Currency      string            `json:"currency"`
+	PaymentIntent string            `json:"payment_intent"`
+	Status        string            `json:"status"`
+	FailureReason string            `json:"failure_reason,omitempty"`
+	Metadata      map[string]string `json:"metadata"`
+	Created       int64             `json:"created"`
+}
+
+func stripeFail(w http.ResponseWriter, code int, e stripeError) {
+	utils.RespondJSON(w, code, map[string]interface{}{"error": e})
+}
+
+func (s *Server) stripeAuth(w http.ResponseWriter, r *http.Request) bool {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer sk_") {
+		stripeFail(w, http.StatusUnauthorized, stripeError{Type: "invalid_request_error", Message: "Invalid API Key provided"})
+		return false
+	}
+	return true
+}
+
+// POST /v1/payment_intents
+func (s *Server) stripeCreateIntent(w http.ResponseWriter, r *http.Request) {
+	if !s.stripeAuth(w, r) {
+		return
+	}
+	key := r.Header.Get("Idempotency-Key")
+	if key != "" {
+		key = "stripe:" + key
+	}
+	if s.replay(w, key) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		stripeFail(w, http.StatusBadRequest, stripeError{Type: "invalid_request_error", Message: err.Error()})
+		return
+	}
+
+	amount, err := strconv.ParseInt(r.PostForm.Get("amount"), 10, 64)
+	if err != nil || amount <= 0 {
+		stripeFail(w, http.StatusBadRequest, stripeError{Type: "invalid_request_error", Code: "parameter_invalid_integer", Param: "amount", Message: "Invalid integer: amount"})
+		return
+	}
+	for _, param := range []string{"currency", "payment_method"} {
+		if r.PostForm.Get(param) == "" {
+			stripeFail(w, http.StatusBadRequest, stripeError{Type: "invalid_request_error", Code: "parameter_missing", Param: param, Message: "Missing required param: " + param})
+			return
+		}
+	}
+
+	intent := &stripeIntent{
+		ID:               "pi_" + randomID(12),
+		Object:           "payment_intent",
+		Amount:           amount,
+		Currency:         strings.ToLower(r.PostForm.Get("currency")),
+		Status:           "requires_confirmation",
+		PaymentMethod:    r.PostForm.Get("payment_method"),
+		SetupFutureUsage: r.PostForm.Get("setup_future_usage"),
+		Metadata:         map[string]string{},
+		Created:          time.Now().Unix(),
+		scenario:         pickScenario(amount, s.stripeMethodCard(r.PostForm.Get("payment_method"))),
+	}
+	if r.PostForm.Get("off_session") == "true" {
+		intent.scenario = offSession(intent.scenario)
+	}
+	for k, v := range r.PostForm {
+		if strings.HasPrefix(k, "metadata[") && strings.HasSuffix(k, "]") && len(v) > 0 {
+			intent.Metadata[k[len("metadata["):len(k)-1]] = v[0]
+		}
+	}
+
+	s.mu.Lock()
+	s.stripeIntents[intent.ID] = intent
+	code, body := http.StatusOK, interface{}(intent)
+	if r.PostForm.Get("confirm") == "true" {
+		code, body = s.stripeConfirm(intent, baseURL(r), r.PostForm.Get("return_url"))
+	}
+	raw, _ := json.Marshal(body)
+	s.mu.Unlock()
+	s.respond(w, key, code, json.RawMessage(raw))
+}
+
+// stripeConfirm проводит платёж по сценарию и возвращает ответ API. Вызывается под s.mu.
+func (s *Server) stripeConfirm(intent *stripeIntent, base, returnURL string) (int, interface{}) {
+	switch intent.scenario.outcome {
+	case outcomeSuccess:
+		s.stripeSucceed(intent)
+	case outcomeDecline:
+		s.stripeDecline(intent)
+		return http.StatusPaymentRequired, map[string]interface{}{"error": stripeError{
+			Type:          "card_error",
+			Code:          "card_declined",
+			DeclineCode:   intent.scenario.declineCode,
+			Message:       "Your card was declined.",
+			PaymentIntent: intent,
+		}}
+	case outcome3DS:
+		intent.Status = "requires_action"
+		intent.NextAction = &stripeNextAction{Type: "redirect_to_url"}
+		intent.NextAction.RedirectToURL.URL = base + "/acs/stripe/" + intent.ID
+		intent.NextAction.RedirectToURL.ReturnURL = returnURL
+		s.stripeEvent("payment_intent.requires_action", intent)
+	case outcomeDelayedSuccess, outcomeDelayedDecline:
+		intent.Status = "processing"
+		s.stripeEvent("payment_intent.processing", intent)
+		id, success := intent.ID, intent.scenario.outcome == outcomeDelayedSuccess
+		time.AfterFunc(s.cfg.WebhookDelay, func() { s.stripeSettle(id, success) })
+	}
+	return http.StatusOK, intent
+}
+
+// GET /v1/payment_intents/{id}
+func (s *Server) stripeGetIntent(w http.ResponseWriter, r *http.Request) {
+	if !s.stripeAuth(w, r) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	intent, ok := s.stripeIntents[mux.Vars(r)["id"]]
+	if !ok {
+		stripeFail(w, http.StatusNotFound, stripeError{Type: "invalid_request_error", Code: "resource_missing", Message: "No such payment_intent"})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, intent)
+}
+
+// POST /v1/refunds
+func (s *Server) stripeCreateRefund(w http.ResponseWriter, r *http.Request) {
+	if !s.stripeAuth(w, r) {
+		return
+	}
+	key := r.Header.Get("Idempotency-Key")
+	if key != "" {
+		key = "stripe:" + key
+	}
+	if s.replay(w, key) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		stripeFail(w, http.StatusBadRequest, stripeError{Type: "invalid_request_error", Message: err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	intent, ok := s.stripeIntents[r.PostForm.Get("payment_intent")]
+	if !ok {
+		s.mu.Unlock()
+		stripeFail(w, http.StatusNotFound, stripeError{Type: "invalid_request_error", Code: "resource_missing", Param: "payment_intent", Message: "No such payment_intent"})
+		return
+	}
+	if intent.Status != "succeeded" {
+		s.mu.Unlock()
+		stripeFail(w, http.StatusBadRequest, stripeError{Type: "invalid_request_error", Code: "charge_not_refundable", Message: "This PaymentIntent has not succeeded"})
+		return
+	}
+
+	amount := intent.AmountReceived - intent.refunded
+	if v := r.PostForm.Get("amount"); v != "" {
+		amount, _ = strconv.ParseInt(v, 10, 64)
+	}
+	if amount <= 0 || amount > intent.AmountReceived-intent.refunded {
+		s.mu.Unlock()
+		stripeFail(w, http.StatusBadRequest, stripeError{Type: "invalid_request_error", Code: "amount_too_large", Param: "amount", Message: "Refund amount is greater than unrefunded amount on charge"})
+		return
+	}
+	intent.refunded += amount
+	refund := &stripeRefund{
+		ID:            "re_" + randomID(12),
+		Object:        "refund",
+		Amount:        amount,
+		Currency:      intent.Currency,
+		PaymentIntent: intent.ID,
+		Status:        "succeeded",
+		Metadata:      map[string]string{},
+		Created:       time.Now().Unix(),
+	}
+	if reason := r.PostForm.Get("metadata[reason]"); reason != "" {
+		refund.Metadata["reason"] = reason
+	}
+	s.stripeRefunds[refund.ID] = refund
+
+	if o := refundOutcome(amount); o != outcomeSuccess {
+		refund.Status = "pending"
+		id, success := refund.ID, o == outcomeDelayedSuccess
+		time.AfterFunc(s.cfg.WebhookDelay, func() { s.stripeSettleRefund(id, success) })
+	}
+	s.stripeEvent("refund.created", refund)
+	raw, _ := json.Marshal(refund)
+	s.mu.Unlock()
+	s.respond(w, key, http.StatusOK, json.RawMessage(raw))
+}
+
+// GET /v1/refunds/{id}
+func (s *Server) stripeGetRefund(w http.ResponseWriter, r *http.Request) {
+	if !s.stripeAuth(w, r) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	refund, ok := s.stripeRefunds[mux.Vars(r)["id"]]
+	if !ok {
+		stripeFail(w, http.StatusNotFound, stripeError{Type: "invalid_request_error", Code: "resource_missing", Message: "No such refund"})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, refund)
+}
+
+// stripeSettleRefund завершает отложенный возврат. Вызывается по таймеру.
+func (s *Server) stripeSettleRefund(id string, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	refund, ok := s.stripeRefunds[id]
+	if !ok || refund.Status != "pending" {
+		return
+	}
+	if success {
+		refund.Status = "succeeded"
+		s.stripeEvent("refund.updated", refund)
+		return
+	}
+	refund.Status = "failed"
+	refund.FailureReason = "declined"
+	s.stripeIntents[refund.PaymentIntent].refunded -= refund.Amount
+	s.stripeEvent("refund.failed", refund)
+}
+
+// POST /v1/payment_intents/{id}/cancel
+func (s *Server) stripeCancelIntent(w http.ResponseWriter, r *http.Request) {
+	if !s.stripeAuth(w, r) {
+		return
+	}
+	key := r.Header.Get("Idempotency-Key")
+	if key != "" {
+		key = "stripe:" + key
+	}
+	if s.replay(w, key) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		stripeFail(w, http.StatusBadRequest, stripeError{Type: "invalid_request_error", Message: err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	intent, ok := s.stripeIntents[mux.Vars(r)["id"]]
+	if !ok {
+		s.mu.Unlock()
+		stripeFail(w, http.StatusNotFound, stripeError{Type: "invalid_request_error", Code: "resource_missing", Message: "No such payment_intent"})
+		return
+	}
+	switch intent.Status {
+	case "requires_action", "requires_confirmation", "requires_payment_method":
+	default:
+		s.mu.Unlock()
+		stripeFail(w, http.StatusBadRequest, stripeError{Type: "invalid_request_error", Code: "payment_intent_unexpected_state",
+			Message: "You cannot cancel this PaymentIntent because it has a status of " + intent.Status + "."})
+		return
+	}
+	intent.Status = "canceled"
+	intent.NextAction = nil
+	intent.CancellationReason = r.PostForm.Get("cancellation_reason")
+	s.stripeEvent("payment_intent.canceled", intent)
+	raw, _ := json.Marshal(intent)
+	s.mu.Unlock()
+	s.respond(w, key, http.StatusOK, json.RawMessage(raw))
+}
+
+// /acs/stripe/{id} — страница 3-D Secure; result=success|fail подтверждает или отклоняет платёж
+func (s *Server) stripeACS(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	intent, ok := s.stripeIntents[mux.Vars(r)["id"]]
+	if !ok || intent.Status != "requires_action" {
+		s.mu.Unlock()
+		http.Error(w, "payment is not awaiting authentication", http.StatusNotFound)
+		return
+	}
+	result := r.FormValue("result")
+	if result == "" {
+		amount, currency := formatMinor(intent.Amount), strings.ToUpper(intent.Currency)
+		s.mu.Unlock()
+		renderACS(w, r, "Stripe", intent.ID, amount, currency)
+		return
+	}
+	returnURL := intent.NextAction.RedirectToURL.ReturnURL
+	intent.NextAction = nil
+	if result == "fail" {
+		intent.scenario.declineCode = "authentication_required"
+		s.stripeDecline(intent)
+	} else {
+		s.stripeSucceed(intent)
+	}
+	s.mu.Unlock()
+
+	finishACS(w, r, returnURL, "payment_intent", intent.ID)
+}
+
+// stripeSettle завершает отложенный платёж. Вызывается по таймеру.
+func (s *Server) stripeSettle(id string, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	intent, ok := s.stripeIntents[id]
+	if !ok || intent.Status != "processing" {
+		return
+	}
+	if success {
+		s.stripeSucceed(intent)
+	} else {
+		s.stripeDecline(intent)
+	}
+}
+
+// stripeSucceed и stripeDecline вызываются под s.mu
+func (s *Server) stripeSucceed(intent *stripeIntent) {
+	intent.Status = "succeeded"
+	intent.AmountReceived = intent.Amount
+	s.stripeEvent("payment_intent.succeeded", intent)
+}
+
+func (s *Server) stripeDecline(intent *stripeIntent) {
+	intent.Status = "requires_payment_method"
+	intent.LastPaymentError = &stripeError{
+		Type:        "card_error",
+		Code:        "card_declined",
+		DeclineCode: intent.scenario.declineCode,
+		Message:     "Your card was declined.",
+	}
+	s.stripeEvent("payment_intent.payment_failed", intent)
+}
+
+// stripeEvent отправляет событие с подписью Stripe-Signature. Вызывается под s.mu:
+// объект сериализуется в момент события.
+func (s *Server) stripeEvent(eventType string, object interface{}) {
+	if s.cfg.StripeWebhookURL == "" {
+		return
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"id":      "evt_" + randomID(12),
+		"object":  "event",
+		"type":    eventType,
+		"created": time.Now().Unix(),
+		"data":    map[string]interface{}{"object": object},
+	})
+	if err != nil {
+		return
+	}
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(s.cfg.StripeWebhookSecret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+
+	header := http.Header{}
+	header.Set("Stripe-Signature", fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(mac.Sum(nil))))
+	s.deliver(s.cfg.StripeWebhookURL, body, header)
+}
+
+type stripeCard struct {
+	Brand       string `json:"brand"`
+	Last4       string `json:"last4"`
+	ExpMonth    int    `json:"exp_month"`
+	ExpYear     int    `json:"exp_year"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+type stripePaymentMethod struct {
+	ID      string     `json:"id"`
+	Object  string     `json:"object"`
+	Type    string     `json:"type"`
+	Card    stripeCard `json:"card"`
+	Created int64      `json:"created"`
+
+	number string
+}
+
+func newStripePaymentMethod(id, number string, expMonth, expYear int) *stripePaymentMethod {
+	sum := sha256.Sum256([]byte(number))
+	return &stripePaymentMethod{
+		ID:     id,
+		Object: "payment_method",
+		Type:   "card",
+		Card: stripeCard{
+			Brand:       cardBrand(number),
+			Last4:       number[len(number)-4:],
+			ExpMonth:    expMonth,
+			ExpYear:     expYear,
+			Fingerprint: hex.EncodeToString(sum[:8]),
+		},
+		Created: time.Now().Unix(),
+		number:  number,
+	}
+}
+
+// POST /v1/payment_methods
+func (s *Server) stripeCreatePaymentMethod(w http.ResponseWriter, r *http.Request) {
+	if !s.stripeAuth(w, r) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		stripeFail(w, http.StatusBadRequest, stripeError{Type: "invalid_request_error", Message: err.Error()})
+		return
+	}
+	if r.PostForm.Get("type") != "card" {
+		stripeFail(w, http.StatusBadRequest, stripeError{Type: "invalid_request_error", Code: "parameter_invalid", Param: "type", Message: "Only card payment methods are supported"})
+		return
+	}
+
+	number := r.PostForm.Get("card[number]")
+	if number == "" || cardNumberRe.FindString(number) != number || !luhnValid(number) {
+		stripeFail(w, http.StatusPaymentRequired, stripeError{Type: "card_error", Code: "incorrect_number", Param: "card[number]", Message: "Your card number is incorrect."})
+		return
+	}
+	month, _ := strconv.Atoi(r.PostForm.Get("card[exp_month]"))
+	if month < 1 || month > 12 {
+		stripeFail(w, http.StatusPaymentRequired, stripeError{Type: "card_error", Code: "invalid_expiry_month", Param: "card[exp_month]", Message: "Your card's expiration month is invalid."})
+		return
+	}
+	year, _ := strconv.Atoi(r.PostForm.Get("card[exp_year]"))
+	now := time.Now()
+	if year < now.Year() || year == now.Year() && month < int(now.Month()) {
+		stripeFail(w, http.StatusPaymentRequired, stripeError{Type: "card_error", Code: "invalid_expiry_year", Param: "card[exp_year]", Message: "Your card's expiration year is invalid."})
+		return
+	}
+
+	method := newStripePaymentMethod("pm_"+randomID(12), number, month, year)
+	s.mu.Lock()
+	s.stripeMethods[method.ID] = method
+	s.mu.Unlock()
+	utils.RespondJSON(w, http.StatusOK, method)
+}
+
+// GET /v1/payment_methods/{id}. Тестовые токены pm_card_<номер> известны
+// всегда и действуют ещё три года.
+func (s *Server) stripeGetPaymentMethod(w http.ResponseWriter, r *http.Request) {
+	if !s.stripeAuth(w, r) {
+		return
+	}
+	id := mux.Vars(r)["id"]
+	s.mu.Lock()
+	method, ok := s.stripeMethods[id]
+	s.mu.Unlock()
+	if !ok {
+		number := strings.TrimPrefix(id, "pm_card_")
+		if number == "" || number == id || cardNumberRe.FindString(number) != number {
+			stripeFail(w, http.StatusNotFound, stripeError{Type: "invalid_request_error", Code: "resource_missing", Param: "payment_method", Message: "No such PaymentMethod: '" + id + "'"})
+			return
+		}
+		method = newStripePaymentMethod(id, number, 12, time.Now().Year()+3)
+	}
+	utils.RespondJSON(w, http.StatusOK, method)
+}
+
+// stripeMethodCard — номер карты токенизированного способа оплаты, по
+// которому выбирается сценарий; для прочих токенов — сам токен
+func (s *Server) stripeMethodCard(paymentMethod string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if method, ok := s.stripeMethods[paymentMethod]; ok {
+		return method.number
+	}
+	return paymentMethod
+}
+
+type stripePayout struct {
+	ID          string            `json:"id"`
+	Object      string            `json:"object"`
+	Amount      int64             `json:"amount"`
+	Currency    string            `json:"currency"`
+	Destination string            `json:"destination"`
+	Description string            `json:"description,omitempty"`
+	Status      string            `json:"status"`
+	FailureCode string            `json:"failure_code,omitempty"`
+	Metadata    map[string]string `json:"metadata"`
+	Created     int64             `json:"created"`
+}
+
+// POST /v1/payouts
+func (s *Server) stripeCreatePayout(w http.ResponseWriter, r *http.Request) {
+	if !s.stripeAuth(w, r) {
+		return
+	}
+	key := r.Header.Get("Idempotency-Key")
+	if key != "" {
+		key = "stripe:" + key
+	}
+	if s.replay(w, key) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		stripeFail(w, http.StatusBadRequest, stripeError{Type: "invalid_request_error", Message: err.Error()})
+		return
+	}
+
+	amount, err := strconv.ParseInt(r.PostForm.Get("amount"), 10, 64)
+	if err != nil || amount <= 0 {
+		stripeFail(w, http.StatusBadRequest, stripeError{Type: "invalid_request_error", Code: "parameter_invalid_integer", Param: "amount", Message: "Invalid integer: amount"})
+		return
+	}
+	for _, param := range []string{"currency", "destination"} {
+		if r.PostForm.Get(param) == "" {
+			stripeFail(w, http.StatusBadRequest, stripeError{Type: "invalid_request_error", Code: "parameter_missing", Param: param, Message: "Missing required param: " + param})
+			return
+		}
+	}
+
+	payout := &stripePayout{
+		ID:          "po_" + randomID(12),
+		Object:      "payout",
+		Amount:      amount,
+		Currency:    strings.ToLower(r.PostForm.Get("currency")),
+		Destination: r.PostForm.Get("destination"),
+		Description: r.PostForm.Get("description"),
+		Status:      "paid",
+		Metadata:    map[string]string{},
+		Created:     time.Now().Unix(),
+	}
+	for k, v := range r.PostForm {
+		if strings.HasPrefix(k, "metadata[") && strings.HasSuffix(k, "]") && len(v) > 0 {
+			payout.Metadata[k[len("metadata["):len(k)-1]] = v[0]
+		}
+	}
+	sc := pickScenario(amount, s.stripeMethodCard(payout.Destination))
+
+	s.mu.Lock()
+	s.stripePayouts[payout.ID] = payout
+	switch sc.outcome {
+	case outcomeDecline:
+		payout.Status = "failed"
+		payout.FailureCode = sc.declineCode
+		s.stripeEvent("payout.failed", payout)
+	case outcomeDelayedSuccess, outcomeDelayedDecline:
+		payout.Status = "in_transit"
+		id, code := payout.ID, ""
+		if sc.outcome == outcomeDelayedDecline {
+			code = sc.declineCode
+		}
+		time.AfterFunc(s.cfg.WebhookDelay, func() { s.stripeSettlePayout(id, code) })
+		s.stripeEvent("payout.created", payout)
+	default:
+		s.stripeEvent("payout.paid", payout)
+	}
+	raw, _ := json.Marshal(payout)
+	s.mu.Unlock()
+	s.respond(w, key, http.StatusOK, json.RawMessage(raw))
+}
+
+// GET /v1/payouts/{id}
+func (s *Server) stripeGetPayout(w http.ResponseWriter, r *http.Request) {
+	if !s.stripeAuth(w, r) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	payout, ok := s.stripePayouts[mux.Vars(r)["id"]]
+	if !ok {
+		stripeFail(w, http.StatusNotFound, stripeError{Type: "invalid_request_error", Code: "resource_missing", Message: "No such payout"})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, payout)
+}
+
+// stripeSettlePayout завершает отложенную выплату; failureCode пуст — успех.
+// Вызывается по таймеру.
+func (s *Server) stripeSettlePayout(id, failureCode string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	payout, ok := s.stripePayouts[id]
+	if !ok || payout.Status != "in_transit" {
+		return
+	}
+	if failureCode == "" {
+		payout.Status = "paid"
+		s.stripeEvent("payout.paid", payout)
+		return
+	}
+	payout.Status = "failed"
+	payout.FailureCode = failureCode
+	s.stripeEvent("payout.failed", payout)
+}
@@ -0,0 +1,513 @@
+package emulator
+
+import (
+	"bank-api/internal/utils"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// причины отказа YooKassa для кодов Stripe из сценариев
+var yookassaReasons = map[string]string{
+	"generic_decline":         "general_decline",
+	"insufficient_funds":      "insufficient_funds",
+	"expired_card":            "card_expired",
+	"authentication_required": "3d_secure_failed",
+}
+
+type yookassaAmount struct {
+	Value    string `json:"value"`
+	Currency string `json:"currency"`
+}
+
+type yookassaConfirmation struct {
+	Type            string `json:"type"`
+	ReturnURL       string `json:"return_url,omitempty"`
+	ConfirmationURL string `json:"confirmation_url,omitempty"`
+}
+
+type yookassaCancellation struct {
+	Party  string `json:"party"`
+	Reason string `json:"reason"`
+}
+
+type yookassaPaymentMethod struct {
+	Type  string `json:"type"`
+	ID    string `json:"id"`
+	Saved bool   `json:"saved"`
+}
+
+type yookassaPayment struct {
+	ID            string                 `json:"id"`
+	Status        string                 `json:"status"`
+	Paid          bool                   `json:"paid"`
+	Amount        yookassaAmount         `json:"amount"`
+	Description   string                 `json:"description,omitempty"`
+	PaymentMethod *yookassaPaymentMethod `json:"payment_method,omitempty"`
+	Confirmation  *yookassaConfirmation  `json:"confirmation,omitempty"`
+	Metadata      map[string]string      `json:"metadata,omitempty"`
+	Cancellation  *yookassaCancellation  `json:"cancellation_details,omitempty"`
+	CreatedAt     time.Time              `json:"created_at"`
+
+	minor    int64
+	refunded int64
+	scenario scenario
+	save     bool // сохранить способ оплаты после успешной оплаты
+}
+
+type yookassaRefund struct {
+	ID           string                `json:"id"`
+	PaymentID    string                `json:"payment_id"`
+	Status       string                `json:"status"`
+	Amount       yookassaAmount        `json:"amount"`
+	Description  string                `json:"description,omitempty"`
+	Cancellation *yookassaCancellation `json:"cancellation_details,omitempty"`
+	CreatedAt    time.Time             `json:"created_at"`
+
+	minor int64
+}
+
+func yookassaFail(w http.ResponseWriter, code int, errCode, description string) {
+	utils.RespondJSON(w, code, map[string]string{
+		"type":        "error",
+		"id":          randomID(16),
+		"code":        errCode,
+		"description": description,
+	})
+}
+
+// yookassaAuth проверяет Basic-авторизацию и обязательный Idempotence-Key у POST
+func (s *Server) yookassaAuth(w http.ResponseWriter, r *http.Request) bool {
+	if shopID, secret, ok := r.BasicAuth(); !ok || shopID == "" || secret == "" {
+		yookassaFail(w, http.StatusUnauthorized, "invalid_credentials", "Login and password required")
+		return false
+	}
+	if r.Method == http.MethodPost && r.Header.Get("Idempotence-Key") == "" {
+		yookassaFail(w, http.StatusBadRequest, "invalid_request", "Idempotence-Key header is required")
+		return false
+	}
+	return true
+}
+
+func parseMinor(value string) (int64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(math.Round(f * 100)), nil
+}
+
+func formatMinor(minor int64) string {
+	return fmt.Sprintf("%d.%02d", minor/100, minor%100)
+}
+
+// POST /v3/payments
+func (s *Server) yookassaCreatePayment(w http.ResponseWriter, r *http.Request) {
+	if !s.yookassaAuth(w, r) {
+		return
+	}
+	key := "yookassa:" + r.Header.Get("Idempotence-Key")
+	if s.replay(w, key) {
+		return
+	}
+
+	var req struct {
+		Amount            yookassaAmount        `json:"amount"`
+		Capture           bool                  `json:"capture"`
+		PaymentMethodID   string                `json:"payment_method_id"`
+		SavePaymentMethod bool                  `json:"save_payment_method"`
+		Description       string                `json:"description"`
+		Confirmation      *yookassaConfirmation `json:"confirmation"`
+		Metadata          map[string]string     `json:"metadata"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		yookassaFail(w, http.StatusBadRequest, "invalid_request", "Invalid JSON")
+		return
+	}
+	minor, err := parseMinor(req.Amount.Value)
+	if err != nil || minor <= 0 || req.Amount.Currency == "" {
+		yookassaFail(w, http.StatusBadRequest, "invalid_request", "Invalid amount")
+		return
+	}
+	if req.PaymentMethodID == "" {
+		yookassaFail(w, http.StatusBadRequest, "invalid_request", "payment_method_id is required")
+		return
+	}
+
+	p := &yookassaPayment{
+		ID:          fmt.Sprintf("%s-000f-5000-9000-%s", randomID(4), randomID(6)),
+		Status:      "pending",
+		Amount:      yookassaAmount{Value: formatMinor(minor), Currency: req.Amount.Currency},
+		Description: req.Description,
+		Metadata:    req.Metadata,
+		CreatedAt:   time.Now().UTC(),
+		minor:       minor,
+		save:        req.SavePaymentMethod,
+	}
+
+	s.mu.Lock()
+	p.PaymentMethod = &yookassaPaymentMethod{Type: "bank_card", ID: req.PaymentMethodID}
+	if sc, ok := s.yookassaSaved[req.PaymentMethodID]; ok {
+		p.scenario = sc
+		p.PaymentMethod.Saved = true
+	} else {
+		p.scenario = pickScenario(minor, req.PaymentMethodID)
+	}
+	if p.save && !p.PaymentMethod.Saved {
+		p.PaymentMethod.ID = fmt.Sprintf("%s-000f-5000-a000-%s", randomID(4), randomID(6))
+	}
+	if req.Confirmation == nil {
+		// автоплатёж: подтвердить оплату некому
+		p.scenario = offSession(p.scenario)
+	}
+	s.yookassaPayments[p.ID] = p
+	switch p.scenario.outcome {
+	case outcomeSuccess:
+		s.yookassaSucceed(p)
+	case outcomeDecline:
+		s.yookassaCancel(p)
+	case outcome3DS:
+		p.Confirmation = &yookassaConfirmation{
+			Type:            "redirect",
+			ConfirmationURL: baseURL(r) + "/acs/yookassa/" + p.ID,
+		}
+		if req.Confirmation != nil {
+			p.Confirmation.ReturnURL = req.Confirmation.ReturnURL
+		}
+	case outcomeDelayedSuccess, outcomeDelayedDecline:
+		// YooKassa не уведомляет о pending: первое уведомление — окончательный статус
+		id, success := p.ID, p.scenario.outcome == outcomeDelayedSuccess
+		time.AfterFunc(s.cfg.WebhookDelay, func() { s.yookassaSettle(id, success) })
+	}
+	raw, _ := json.Marshal(p)
+	s.mu.Unlock()
+	s.respond(w, key, http.StatusOK, json.RawMessage(raw))
+}
+
+// GET /v3/payments/{id}
+func (s *Server) yookassaGetPayment(w http.ResponseWriter, r *http.Request) {
+	if !s.yookassaAuth(w, r) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.yookassaPayments[mux.Vars(r)["id"]]
+	if !ok {
+		yookassaFail(w, http.StatusNotFound, "not_found", "Payment not found")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, p)
+}
+
+// POST /v3/refunds
+func (s *Server) yookassaCreateRefund(w http.ResponseWriter, r *http.Request) {
+	if !s.yookassaAuth(w, r) {
+		return
+	}
+	key := "yookassa:" + r.Header.Get("Idempotence-Key")
+	if s.replay(w, key) {
+		return
+	}
+
+	var req struct {
+		PaymentID   string         `json:"payment_id"`
+		Amount      yookassaAmount `json:"amount"`
+		Description string         `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		yookassaFail(w, http.StatusBadRequest, "invalid_request", "Invalid JSON")
+		return
+	}
+	minor, err := parseMinor(req.Amount.Value)
+	if err != nil || minor <= 0 {
+		yookassaFail(w, http.StatusBadRequest, "invalid_request", "Invalid amount")
+		return
+	}
+
+	s.mu.Lock()
+	p, ok := s.yookassaPayments[req.PaymentID]
+	if !ok {
+		s.mu.Unlock()
+		yookassaFail(w, http.StatusNotFound, "not_found", "Payment not found")
+		return
+	}
+	if p.Status != "succeeded" || minor > p.minor-p.refunded || req.Amount.Currency != p.Amount.Currency {
+		s.mu.Unlock()
+		yookassaFail(w, http.StatusBadRequest, "invalid_request", "Refund is not possible for this payment")
+		return
+	}
+	p.refunded += minor
+	refund := &yookassaRefund{
+		ID:          fmt.Sprintf("%s-0015-5000-8000-%s", randomID(4), randomID(6)),
+		PaymentID:   p.ID,
+		Status:      "succeeded",
+		Amount:      yookassaAmount{Value: formatMinor(minor), Currency: p.Amount.Currency},
+		Description: req.Description,
+		CreatedAt:   time.Now().UTC(),
+		minor:       minor,
+	}
+	s.yookassaRefunds[refund.ID] = refund
+
+	if o := refundOutcome(minor); o != outcomeSuccess {
+		refund.Status = "pending"
+		id, success := refund.ID, o == outcomeDelayedSuccess
+		time.AfterFunc(s.cfg.WebhookDelay, func() { s.yookassaSettleRefund(id, success) })
+	} else {
+		s.yookassaNotify("refund.succeeded", refund)
+	}
+	raw, _ := json.Marshal(refund)
+	s.mu.Unlock()
+	s.respond(w, key, http.StatusOK, json.RawMessage(raw))
+}
+
+// GET /v3/refunds/{id}
+func (s *Server) yookassaGetRefund(w http.ResponseWriter, r *http.Request) {
+	if !s.yookassaAuth(w, r) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	refund, ok := s.yookassaRefunds[mux.Vars(r)["id"]]
+	if !ok {
+		yookassaFail(w, http.StatusNotFound, "not_found", "Refund not found")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, refund)
+}
+
+// yookassaSettleRefund завершает отложенный возврат. Об отмене YooKassa не
+// уведомляет — отклонённый возврат видно только через GET.
+func (s *Server) yookassaSettleRefund(id string, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	refund, ok := s.yookassaRefunds[id]
+	if !ok || refund.Status != "pending" {
+		return
+	}
+	if success {
+		refund.Status = "succeeded"
+		s.yookassaNotify("refund.succeeded", refund)
+		return
+	}
+	refund.Status = "canceled"
+	refund.Cancellation = &yookassaCancellation{Party: "yoo_money", Reason: "rejected_by_payee"}
+	s.yookassaPayments[refund.PaymentID].refunded -= refund.minor
+}
+
+// /acs/yookassa/{id} — страница 3-D Secure; result=success|fail подтверждает или отклоняет платёж
+func (s *Server) yookassaACS(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	p, ok := s.yookassaPayments[mux.Vars(r)["id"]]
+	if !ok || p.Status != "pending" || p.Confirmation == nil {
+		s.mu.Unlock()
+		http.Error(w, "payment is not awaiting authentication", http.StatusNotFound)
+		return
+	}
+	result := r.FormValue("result")
+	if result == "" {
+		amount, currency := p.Amount.Value, p.Amount.Currency
+		s.mu.Unlock()
+		renderACS(w, r, "YooKassa", p.ID, amount, currency)
+		return
+	}
+	returnURL := p.Confirmation.ReturnURL
+	if result == "fail" {
+		p.scenario.declineCode = "authentication_required"
+		s.yookassaCancel(p)
+	} else {
+		s.yookassaSucceed(p)
+	}
+	s.mu.Unlock()
+
+	finishACS(w, r, returnURL, "payment_id", p.ID)
+}
+
+// yookassaSettle завершает отложенный платёж. Вызывается по таймеру.
+func (s *Server) yookassaSettle(id string, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.yookassaPayments[id]
+	if !ok || p.Status != "pending" {
+		return
+	}
+	if success {
+		s.yookassaSucceed(p)
+	} else {
+		s.yookassaCancel(p)
+	}
+}
+
+// yookassaSucceed и yookassaCancel вызываются под s.mu
+func (s *Server) yookassaSucceed(p *yookassaPayment) {
+	p.Status = "succeeded"
+	p.Paid = true
+	if p.save && !p.PaymentMethod.Saved {
+		p.PaymentMethod.Saved = true
+		s.yookassaSaved[p.PaymentMethod.ID] = p.scenario
+	}
+	s.yookassaNotify("payment.succeeded", p)
+}
+
+func (s *Server) yookassaCancel(p *yookassaPayment) {
+	p.Status = "canceled"
+	p.Cancellation = &yookassaCancellation{Party: "payment_network", Reason: yookassaReasons[p.scenario.declineCode]}
+	if p.scenario.declineCode == "authentication_required" {
+		p.Cancellation.Party = "yoo_money"
+	}
+	s.yookassaNotify("payment.canceled", p)
+}
+
+// yookassaNotify отправляет уведомление. Подписи у YooKassa нет — получатель
+// проверяет адрес отправителя, поэтому эмулятор должен быть в его списке.
+func (s *Server) yookassaNotify(event string, object interface{}) {
+	if s.cfg.YooKassaWebhookURL == "" {
+		return
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"type":   "notification",
+		"event":  event,
+		"object": object,
+	})
+	if err != nil {
+		return
+	}
+	s.deliver(s.cfg.YooKassaWebhookURL, body, nil)
+}
+
+type yookassaPayout struct {
+	ID                string                `json:"id"`
+	Status            string                `json:"status"`
+	Amount            yookassaAmount        `json:"amount"`
+	PayoutDestination map[string]string     `json:"payout_destination"`
+	Description       string                `json:"description,omitempty"`
+	Metadata          map[string]string     `json:"metadata,omitempty"`
+	Cancellation      *yookassaCancellation `json:"cancellation_details,omitempty"`
+	CreatedAt         time.Time             `json:"created_at"`
+}
+
+// POST /v3/payouts
+func (s *Server) yookassaCreatePayout(w http.ResponseWriter, r *http.Request) {
+	if !s.yookassaAuth(w, r) {
+		return
+	}
+	key := "yookassa:" + r.Header.Get("Idempotence-Key")
+	if s.replay(w, key) {
+		return
+	}
+
+	var req struct {
+		Amount      yookassaAmount `json:"amount"`
+		PayoutToken string         `json:"payout_token"`
+		Destination *struct {
+			Type          string `json:"type"`
+			AccountNumber string `json:"account_number"`
+		} `json:"payout_destination_data"`
+		Description string            `json:"description"`
+		Metadata    map[string]string `json:"metadata"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		yookassaFail(w, http.StatusBadRequest, "invalid_request", "Invalid JSON")
+		return
+	}
+	minor, err := parseMinor(req.Amount.Value)
+	if err != nil || minor <= 0 {
+		yookassaFail(w, http.StatusBadRequest, "invalid_request", "Invalid amount")
+		return
+	}
+
+	payout := &yookassaPayout{
+		ID:          fmt.Sprintf("po-%s-%s", randomID(4), randomID(8)),
+		Status:      "succeeded",
+		Amount:      yookassaAmount{Value: formatMinor(minor), Currency: req.Amount.Currency},
+		Description: req.Description,
+		Metadata:    req.Metadata,
+		CreatedAt:   time.Now().UTC(),
+	}
+	var destination string
+	switch {
+	case req.PayoutToken != "" && req.Destination == nil:
+		destination = req.PayoutToken
+		payout.PayoutDestination = map[string]string{"type": "bank_card"}
+	case req.PayoutToken == "" && req.Destination != nil && req.Destination.Type == "yoo_money":
+		destination = req.Destination.AccountNumber
+		if walletRe.FindString(destination) != destination || destination == "" {
+			yookassaFail(w, http.StatusBadRequest, "invalid_request", "Invalid yoo_money account_number")
+			return
+		}
+		payout.PayoutDestination = map[string]string{"type": "yoo_money", "account_number": destination}
+	default:
+		yookassaFail(w, http.StatusBadRequest, "invalid_request", "Pass either payout_token or payout_destination_data of type yoo_money")
+		return
+	}
+
+	s.mu.Lock()
+	sc, ok := s.yookassaSaved[destination]
+	if !ok {
+		sc = pickScenario(minor, destination)
+	}
+	s.yookassaPayouts[payout.ID] = payout
+	switch sc.outcome {
+	case outcomeDecline:
+		s.yookassaCancelPayout(payout, sc.declineCode)
+	case outcomeDelayedSuccess, outcomeDelayedDecline:
+		payout.Status = "pending"
+		id, code := payout.ID, ""
+		if sc.outcome == outcomeDelayedDecline {
+			code = sc.declineCode
+		}
+		time.AfterFunc(s.cfg.WebhookDelay, func() { s.yookassaSettlePayout(id, code) })
+	default:
+		s.yookassaNotify("payout.succeeded", payout)
+	}
+	raw, _ := json.Marshal(payout)
+	s.mu.Unlock()
+	s.respond(w, key, http.StatusOK, json.RawMessage(raw))
+}
+
+// GET /v3/payouts/{id}
+func (s *Server) yookassaGetPayout(w http.ResponseWriter, r *http.Request) {
+	if !s.yookassaAuth(w, r) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	payout, ok := s.yookassaPayouts[mux.Vars(r)["id"]]
+	if !ok {
+		yookassaFail(w, http.StatusNotFound, "not_found", "Payout not found")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, payout)
+}
+
+// yookassaSettlePayout завершает отложенную выплату; declineCode пуст — успех.
+// Вызывается по таймеру.
+func (s *Server) yookassaSettlePayout(id, declineCode string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	payout, ok := s.yookassaPayouts[id]
+	if !ok || payout.Status != "pending" {
+		return
+	}
+	if declineCode == "" {
+		payout.Status = "succeeded"
+		s.yookassaNotify("payout.succeeded", payout)
+		return
+	}
+	s.yookassaCancelPayout(payout, declineCode)
+}
+
+// yookassaCancelPayout вызывается под s.mu
+func (s *Server) yookassaCancelPayout(payout *yookassaPayout, declineCode string) {
+	reason, ok := yookassaReasons[declineCode]
+	if !ok {
+		reason = "general_decline"
+	}
+	payout.Status = "canceled"
+	payout.Cancellation = &yookassaCancellation{Party: "payment_network", Reason: reason}
+	s.yookassaNotify("payout.canceled", payout)
+}
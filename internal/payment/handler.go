@@ -2,12 +2,17 @@ package payment
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 
 	"bank-api/internal/middleware"
 	"bank-api/internal/models"
+	"bank-api/internal/payment/providers"
+	"bank-api/internal/repositories"
 	"bank-api/internal/utils"
+	"bank-api/pkg/utils/logger"
 
 	"github.com/gorilla/mux"
 )
@@ -22,10 +27,9 @@ func NewPaymentHandler(paymentService *PaymentService) *PaymentHandler {
 	}
 }
 
-// POST /payment-methods
-func (h *PaymentHandler) AddPaymentMethod(w http.ResponseWriter, r *http.Request) {
-	var input models.PaymentMethod
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+func (h *PaymentHandler) ProcessPayment(w http.ResponseWriter, r *http.Request) {
+	var payment models.Payment
+	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
 		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid input"})
 		return
 	}
@@ -36,60 +40,87 @@ func (h *PaymentHandler) AddPaymentMethod(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	input.UserID = userID
+	payment.UserID = userID
+	// списания без клиента создаёт только RecurringService
+	payment.Initiator = models.PaymentInitiatorCustomer
+	payment.RecurringID = nil
 
-	if err := h.paymentService.AddPaymentMethod(r.Context(), &input); err != nil {
-		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	result, err := h.paymentService.ProcessPayment(r.Context(), payment)
+	if err != nil {
+		utils.RespondJSON(w, paymentErrorStatus(err), map[string]string{"error": err.Error()})
 		return
 	}
 
-	utils.RespondJSON(w, http.StatusCreated, input)
+	code := http.StatusOK
+	if result.Status != models.PaymentStatusSucceeded {
+		code = http.StatusAccepted // статус придёт уведомлением провайдера
+	}
+	utils.RespondJSON(w, code, result)
 }
 
-// GET /payment-methods
-func (h *PaymentHandler) GetPaymentMethods(w http.ResponseWriter, r *http.Request) {
+// GET /payments/{id}
+func (h *PaymentHandler) GetPayment(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid payment ID"})
+		return
+	}
+
 	userID, err := middleware.GetUserID(r.Context())
 	if err != nil {
 		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
 		return
 	}
 
-	methods, err := h.paymentService.GetPaymentMethods(r.Context(), userID)
+	details, err := h.paymentService.GetPayment(r.Context(), userID, paymentID)
 	if err != nil {
-		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		utils.RespondJSON(w, paymentErrorStatus(err), map[string]string{"error": err.Error()})
 		return
 	}
 
-	utils.RespondJSON(w, http.StatusOK, methods)
+	utils.RespondJSON(w, http.StatusOK, details)
 }
 
-// DELETE /payment-methods/{id}
-func (h *PaymentHandler) DeletePaymentMethod(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-	id, err := strconv.ParseInt(idStr, 10, 64)
+// GET /payments/return/{id}?token=...
+// Сюда провайдер возвращает клиента после 3-D Secure. Авторизации нет —
+// клиент приходит из браузера, — поэтому платёж отдаётся только по
+// подписанному адресу, и то лишь его статус.
+func (h *PaymentHandler) ReturnFromConfirmation(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid payment method ID"})
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid payment ID"})
 		return
 	}
 
-	userID, err := middleware.GetUserID(r.Context())
+	p, err := h.paymentService.ResumePayment(r.Context(), paymentID, r.URL.Query().Get("token"))
 	if err != nil {
-		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		utils.RespondJSON(w, paymentErrorStatus(err), map[string]string{"error": err.Error()})
 		return
 	}
 
-	if err := h.paymentService.DeletePaymentMethod(r.Context(), userID, id); err != nil {
-		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
-	}
+	utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"payment_id":     p.ID,
+		"status":         p.Status,
+		"failure_reason": p.FailureReason,
+	})
+}
 
-	utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+// GET /admin/payments/providers
+func (h *PaymentHandler) ProviderStats(w http.ResponseWriter, r *http.Request) {
+	utils.RespondJSON(w, http.StatusOK, h.paymentService.ProviderStats())
 }
 
-func (h *PaymentHandler) ProcessPayment(w http.ResponseWriter, r *http.Request) {
-	var payment models.Payment
-	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
+// POST /payments/{id}/refunds
+// Тело можно не передавать: тогда возвращается весь остаток платежа.
+func (h *PaymentHandler) CreateRefund(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid payment ID"})
+		return
+	}
+
+	var req RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
 		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid input"})
 		return
 	}
@@ -100,22 +131,22 @@ func (h *PaymentHandler) ProcessPayment(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	payment.UserID = userID
-
-	result, err := h.paymentService.ProcessPayment(r.Context(), payment)
+	refund, err := h.paymentService.CreateRefund(r.Context(), userID, paymentID, req)
 	if err != nil {
-		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		utils.RespondJSON(w, paymentErrorStatus(err), map[string]string{"error": err.Error()})
 		return
 	}
 
-	utils.RespondJSON(w, http.StatusOK, result)
+	code := http.StatusCreated
+	if refund.Status == models.RefundStatusPending {
+		code = http.StatusAccepted
+	}
+	utils.RespondJSON(w, code, refund)
 }
 
-// POST /payments/{id}/refund
-func (h *PaymentHandler) RefundPayment(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-	paymentID, err := strconv.ParseInt(idStr, 10, 64)
+// GET /payments/{id}/refunds
+func (h *PaymentHandler) ListRefunds(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
 		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid payment ID"})
 		return
@@ -127,21 +158,57 @@ func (h *PaymentHandler) RefundPayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	payment, err := h.paymentService.GetPaymentByID(r.Context(), paymentID)
+	refunds, err := h.paymentService.ListRefunds(r.Context(), userID, paymentID)
 	if err != nil {
-		utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": "payment not found"})
+		utils.RespondJSON(w, paymentErrorStatus(err), map[string]string{"error": err.Error()})
 		return
 	}
 
-	if payment.UserID != userID {
-		utils.RespondJSON(w, http.StatusForbidden, map[string]string{"error": "access denied"})
+	utils.RespondJSON(w, http.StatusOK, refunds)
+}
+
+// POST /webhooks/{provider}
+// Ответ не 2xx заставляет провайдера повторить доставку, поэтому 500
+// возвращается только на ошибки, которые могут пройти при повторе.
+func (h *PaymentHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		utils.RespondJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "payload too large"})
 		return
 	}
 
-	if err := h.paymentService.RefundPayment(r.Context(), paymentID); err != nil {
-		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
+	err = h.paymentService.HandleWebhook(r.Context(), mux.Vars(r)["provider"], r, body)
+	switch {
+	case err == nil:
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	case errors.Is(err, ErrUnknownProvider):
+		utils.RespondJSON(w, http.StatusNotFound, map[string]string{"error": "unknown provider"})
+	case errors.Is(err, providers.ErrInvalidSignature):
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid signature"})
+	default:
+		logger.Sugared().Errorf("webhook %s: %v", mux.Vars(r)["provider"], err)
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
 	}
+}
 
-	utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "refunded"})
+const maxWebhookBody = 1 << 20
+
+func paymentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrPaymentNotFound), errors.Is(err, ErrRefundNotFound), errors.Is(err, ErrPayoutNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrPaymentAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, ErrUnknownProvider), errors.Is(err, ErrInvalidPayoutDestination), errors.Is(err, providers.ErrDestinationUnsupported):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrPaymentNotRefundable), errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrPaymentStatusChanged),
+		errors.Is(err, repositories.ErrFeeQuotaChanged):
+		return http.StatusConflict
+	case errors.Is(err, ErrRefundExceedsPayment), errors.Is(err, ErrInsufficientFunds),
+		errors.Is(err, ErrPayoutLimitExceeded):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, providers.ErrNoRoute), errors.Is(err, providers.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
 }
@@ -0,0 +1,378 @@
+package payment
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/payment/providers"
+	"bank-api/internal/service"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+)
+
+var ErrInvalidPayoutDestination = errors.New("invalid payout destination")
+
+// через сколько незавершённая выплата перепроверяется у провайдера
+const payoutSyncAfter = 10 * time.Minute
+
+var walletNumberRe = regexp.MustCompile(`^\d{11,20}$`)
+
+// PayoutConfig — ограничения выплат; комиссию рассчитывает тариф
+type PayoutConfig struct {
+	MinAmount  float64
+	DailyLimit float64 // сумма выплат пользователя за день без комиссии; 0 — без лимита
+}
+
+// PayoutRequest — вывод средств. Получатель — сохранённая карта
+// (payment_method_id) или кошелёк ЮMoney (wallet).
+type PayoutRequest struct {
+	AccountID       int64   `json:"account_id"`
+	Amount          float64 `json:"amount"`
+	Currency        string  `json:"currency"`
+	PaymentMethodID int64   `json:"payment_method_id"`
+	Wallet          string  `json:"wallet"`
+	Description     string  `json:"description"`
+}
+
+// CreatePayout выводит средства со счёта. Сумма с комиссией по тарифу сразу
+// блокируется транзакцией payout_hold, вместе с ней расходуется бесплатная
+// квота; при успехе блокировка заменяется списанием payout и комиссией на
+// счёт доходов банка, при отказе снимается и квота возвращается. Если
+// провайдер ответил неопределённо, выплата остаётся pending и доводится
+// SyncPendingPayouts.
+func (s *PaymentService) CreatePayout(ctx context.Context, userID int64, req PayoutRequest) (*models.Payout, error) {
+	amount := math.Round(req.Amount*100) / 100
+	if amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	if amount < s.cfg.Payouts.MinAmount {
+		return nil, fmt.Errorf("amount must be at least %.2f", s.cfg.Payouts.MinAmount)
+	}
+	owned, err := s.accountRepo.IsAccountOwnedByUser(ctx, req.AccountID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, ErrPaymentAccessDenied
+	}
+	if req.Currency == "" {
+		req.Currency = "RUB"
+	}
+
+	payout := &models.Payout{
+		UserID:    userID,
+		AccountID: req.AccountID,
+		Amount:    amount,
+		Currency:  req.Currency,
+		Status:    models.PayoutStatusPending,
+	}
+	if err := s.payoutDestination(ctx, payout, req); err != nil {
+		return nil, err
+	}
+	provider, err := s.provider(payout.Provider)
+	if err != nil {
+		return nil, err
+	}
+	quote, err := s.transactionService.QuoteFee(ctx, models.FeeRequest{
+		UserID:    userID,
+		Operation: models.FeeOperationPayout,
+		Channel:   payout.DestinationType,
+		Currency:  payout.Currency,
+		Amount:    payout.Amount,
+	})
+	if err != nil {
+		return nil, err
+	}
+	payout.Fee = quote.Fee
+
+	if err := s.repo.CreatePayout(ctx, payout, s.cfg.Payouts.DailyLimit); err != nil {
+		return nil, err
+	}
+
+	// квоту могла израсходовать параллельная операция — тогда выплата не
+	// создаётся, клиент повторит её с новой комиссией
+	hold := &models.Transaction{
+		FromAccount: payout.AccountID,
+		Amount:      payout.Amount + payout.Fee,
+		Type:        "payout_hold",
+		Timestamp:   time.Now(),
+		Description: fmt.Sprintf("Payout %d to %s: hold", payout.ID, payout.DestinationLabel),
+	}
+	if err := s.repo.PostPayoutHold(ctx, payout.ID, quote.Quota, hold); err != nil {
+		if _, ferr := s.repo.FailPayout(ctx, payout.ID, "ledger_error", nil); ferr != nil {
+			logger.Sugared().Errorf("payout %d: failed to mark as failed: %v", payout.ID, ferr)
+		}
+		return nil, err
+	}
+	s.transactionService.Posted(ctx, hold)
+	holdID := hold.ID
+	payout.HoldTransactionID = &holdID
+
+	if err := s.sendPayout(ctx, provider, payout, req.Description, false); err != nil {
+		return nil, err
+	}
+	return payout, nil
+}
+
+// payoutDestination выбирает получателя и провайдера выплаты
+func (s *PaymentService) payoutDestination(ctx context.Context, payout *models.Payout, req PayoutRequest) error {
+	switch {
+	case req.PaymentMethodID != 0 && req.Wallet == "":
+		method, err := s.methods.GetPaymentMethod(ctx, payout.UserID, req.PaymentMethodID)
+		if errors.Is(err, service.ErrPaymentMethodNotFound) {
+			return fmt.Errorf("%w: payment method not found", ErrInvalidPayoutDestination)
+		}
+		if err != nil {
+			return err
+		}
+		if method.Expired {
+			return fmt.Errorf("%w: %v", ErrInvalidPayoutDestination, service.ErrPaymentMethodExpired)
+		}
+		if method.Type != "card" || method.Provider == "" {
+			return fmt.Errorf("%w: payouts are only possible to saved cards", ErrInvalidPayoutDestination)
+		}
+		payout.Provider = method.Provider
+		payout.DestinationType = models.PayoutDestinationCard
+		payout.Destination = method.Token
+		payout.DestinationLabel = fmt.Sprintf("%s •••• %s", method.Brand, method.Last4)
+		payout.PaymentMethodID = &method.ID
+	case req.Wallet != "" && req.PaymentMethodID == 0:
+		if !walletNumberRe.MatchString(req.Wallet) {
+			return fmt.Errorf("%w: wallet must be 11 to 20 digits", ErrInvalidPayoutDestination)
+		}
+		payout.Provider = "yookassa"
+		payout.DestinationType = models.PayoutDestinationYooMoney
+		payout.Destination = req.Wallet
+		payout.DestinationLabel = "ЮMoney " + req.Wallet[:4] + "****" + req.Wallet[len(req.Wallet)-4:]
+	default:
+		return fmt.Errorf("%w: pass either payment_method_id or wallet", ErrInvalidPayoutDestination)
+	}
+	return nil
+}
+
+// sendPayout отправляет выплату провайдеру. Если при первой отправке провайдер
+// недоступен или не поддерживает получателя, выплата не создана — блокировка
+// снимается. Прочие ошибки и любые ошибки повтора оставляют выплату pending:
+// повтор с тем же ключом идемпотентности второй выплаты не создаст.
+func (s *PaymentService) sendPayout(ctx context.Context, provider providers.PaymentProvider, payout *models.Payout, description string, retry bool) error {
+	result, err := provider.Payout(ctx, providers.PayoutRequest{
+		PayoutID:        payout.ID,
+		Amount:          payout.Amount,
+		Currency:        payout.Currency,
+		DestinationType: payout.DestinationType,
+		Destination:     payout.Destination,
+		Description:     description,
+		IdempotencyKey:  fmt.Sprintf("payout-%d", payout.ID),
+	})
+	outcome := providers.OutcomeAccepted
+	switch {
+	case providers.IsRetryable(err):
+		outcome = providers.OutcomeUnavailable
+	case err != nil:
+		outcome = providers.OutcomeError
+	case result.Status == models.PayoutStatusFailed:
+		outcome = providers.OutcomeDeclined
+	}
+	s.registry.Report(provider.Name(), outcome)
+
+	switch {
+	case !retry && (providers.IsRetryable(err) || errors.Is(err, providers.ErrDestinationUnsupported)):
+		reason := FailureProviderUnavailable
+		if errors.Is(err, providers.ErrDestinationUnsupported) {
+			reason = "destination_unsupported"
+		}
+		if ferr := s.releasePayout(ctx, payout, reason); ferr != nil {
+			logger.Sugared().Errorf("payout %d: %v", payout.ID, ferr)
+		}
+		return err
+	case retry && err != nil:
+		return err
+	case err != nil:
+		logger.Sugared().Warnf("payout %d: %s did not confirm the payout, will retry: %v", payout.ID, provider.Name(), err)
+		return nil
+	}
+	return s.applyPayout(ctx, payout, result)
+}
+
+func (s *PaymentService) GetPayout(ctx context.Context, userID, id int64) (*models.Payout, error) {
+	payout, err := s.repo.GetPayout(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if payout.UserID != userID {
+		return nil, ErrPayoutNotFound
+	}
+	return payout, nil
+}
+
+func (s *PaymentService) ListPayouts(ctx context.Context, userID int64) ([]models.Payout, error) {
+	return s.repo.ListPayoutsByUser(ctx, userID, 100)
+}
+
+// SyncPendingPayouts доводит зависшие выплаты: без ID провайдера отправляет
+// повторно с тем же ключом идемпотентности, остальные запрашивает у провайдера.
+func (s *PaymentService) SyncPendingPayouts(ctx context.Context) error {
+	payouts, err := s.repo.ListUnsettledPayouts(ctx, time.Now().Add(-payoutSyncAfter), 100)
+	if err != nil {
+		return err
+	}
+
+	for i := range payouts {
+		payout := &payouts[i]
+		if err := s.syncPayout(ctx, payout); err != nil {
+			logger.Sugared().Errorf("payout %d sync failed: %v", payout.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *PaymentService) syncPayout(ctx context.Context, payout *models.Payout) error {
+	if payout.HoldTransactionID == nil {
+		// блокировка не проведена — отправлять нечего
+		_, err := s.repo.FailPayout(ctx, payout.ID, "ledger_error", nil)
+		return err
+	}
+	provider, err := s.provider(payout.Provider)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.TouchPayout(ctx, payout.ID); err != nil {
+		return err
+	}
+
+	if payout.ProviderPayoutID == "" {
+		return s.sendPayout(ctx, provider, payout, "", true)
+	}
+	result, err := provider.GetPayout(ctx, payout.ProviderPayoutID)
+	if err != nil {
+		return err
+	}
+	return s.applyPayout(ctx, payout, result)
+}
+
+func (s *PaymentService) applyPayoutEvent(ctx context.Context, providerName string, result *providers.PayoutResult) error {
+	payout, err := s.repo.GetPayoutByProviderID(ctx, providerName, result.ID)
+	if err != nil {
+		return err
+	}
+	if payout == nil {
+		// ID провайдера ещё не сохранён — досинхронизирует SyncPendingPayouts
+		logger.Sugared().Warnf("%s webhook: payout %s not found", providerName, result.ID)
+		return nil
+	}
+	return s.applyPayout(ctx, payout, result)
+}
+
+// applyPayout применяет состояние выплаты у провайдера
+func (s *PaymentService) applyPayout(ctx context.Context, payout *models.Payout, result *providers.PayoutResult) error {
+	if result.ID != "" && payout.ProviderPayoutID == "" {
+		if err := s.repo.SetPayoutProviderID(ctx, payout.ID, result.ID); err != nil {
+			return err
+		}
+		payout.ProviderPayoutID = result.ID
+	}
+
+	switch result.Status {
+	case models.PayoutStatusSucceeded:
+		return s.capturePayout(ctx, payout)
+	case models.PayoutStatusFailed:
+		return s.releasePayout(ctx, payout, result.FailureReason)
+	case models.PayoutStatusProcessing:
+		if payout.Status == models.PayoutStatusPending {
+			payout.Status = models.PayoutStatusProcessing
+			return s.repo.MarkPayoutProcessing(ctx, payout.ID)
+		}
+	}
+	return nil
+}
+
+// capturePayout снимает блокировку и списывает выплату и комиссию одной
+// транзакцией БД; комиссия зачисляется на счёт доходов банка
+func (s *PaymentService) capturePayout(ctx context.Context, payout *models.Payout) error {
+	changed, err := s.repo.CompletePayout(ctx, payout.ID)
+	if err != nil || !changed {
+		return err
+	}
+	payout.Status = models.PayoutStatusSucceeded
+
+	var release *models.Transaction
+	if payout.HoldTransactionID != nil {
+		release = &models.Transaction{
+			ToAccount:   payout.AccountID,
+			Amount:      payout.Amount + payout.Fee,
+			Type:        "payout_hold_release",
+			Description: fmt.Sprintf("Payout %d to %s: hold released", payout.ID, payout.DestinationLabel),
+		}
+	}
+	capture := &models.Transaction{
+		FromAccount: payout.AccountID,
+		Amount:      payout.Amount,
+		Type:        "payout",
+		Description: fmt.Sprintf("Payout %d to %s via %s", payout.ID, payout.DestinationLabel, payout.Provider),
+	}
+	var fee *models.Transaction
+	if payout.Fee > 0 {
+		fee, err = s.transactionService.FeeTransaction(ctx, payout.AccountID, payout.Fee, fmt.Sprintf("Fee for payout %d", payout.ID))
+		if err != nil {
+			return fmt.Errorf("payout %d succeeded, but failed to charge fee: %w", payout.ID, err)
+		}
+	}
+
+	var txns []*models.Transaction
+	for _, txn := range []*models.Transaction{release, capture, fee} {
+		if txn != nil {
+			txns = append(txns, txn)
+		}
+	}
+	if err := s.transactionService.PostTransactions(ctx, nil, txns...); err != nil {
+		return fmt.Errorf("payout %d succeeded, but failed to debit account %d: %w", payout.ID, payout.AccountID, err)
+	}
+
+	id := func(txn *models.Transaction) *int64 {
+		if txn == nil {
+			return nil
+		}
+		return &txn.ID
+	}
+	payout.ReleaseTransactionID, payout.TransactionID, payout.FeeTransactionID = id(release), id(capture), id(fee)
+	if err := s.repo.SetPayoutTransactions(ctx, payout.ID, payout.ReleaseTransactionID, payout.TransactionID, payout.FeeTransactionID); err != nil {
+		logger.Sugared().Errorf("payout %d: failed to link transactions: %v", payout.ID, err)
+	}
+	return nil
+}
+
+// releasePayout отмечает выплату неудачной и снимает блокировку
+func (s *PaymentService) releasePayout(ctx context.Context, payout *models.Payout, reason string) error {
+	var release *models.Transaction
+	if payout.HoldTransactionID != nil {
+		release = &models.Transaction{
+			ToAccount:   payout.AccountID,
+			Amount:      payout.Amount + payout.Fee,
+			Type:        "payout_hold_release",
+			Timestamp:   time.Now(),
+			Description: fmt.Sprintf("Payout %d to %s failed: hold released", payout.ID, payout.DestinationLabel),
+		}
+	}
+	changed, err := s.repo.FailPayout(ctx, payout.ID, reason, release)
+	if err != nil {
+		return fmt.Errorf("payout %d: failed to mark as failed and release hold on account %d: %w", payout.ID, payout.AccountID, err)
+	}
+	if !changed {
+		return nil
+	}
+	payout.Status = models.PayoutStatusFailed
+	payout.FailureReason = reason
+
+	if release == nil {
+		return nil
+	}
+	s.transactionService.Posted(ctx, release)
+	payout.ReleaseTransactionID = &release.ID
+	if err := s.transactionService.ReleaseFeeQuota(ctx, *payout.HoldTransactionID); err != nil {
+		logger.Sugared().Errorf("payout %d: failed to release fee quota: %v", payout.ID, err)
+	}
+	return nil
+}
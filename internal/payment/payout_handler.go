@@ -0,0 +1,80 @@
+package payment
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"bank-api/internal/middleware"
+	"bank-api/internal/models"
+	"bank-api/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// POST /api/payouts
+func (h *PaymentHandler) CreatePayout(w http.ResponseWriter, r *http.Request) {
+	var req PayoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	payout, err := h.paymentService.CreatePayout(r.Context(), userID, req)
+	if err != nil {
+		utils.RespondJSON(w, paymentErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+
+	code := http.StatusCreated
+	if payout.Status == models.PayoutStatusPending || payout.Status == models.PayoutStatusProcessing {
+		code = http.StatusAccepted // итог придёт уведомлением провайдера
+	}
+	utils.RespondJSON(w, code, payout)
+}
+
+// GET /api/payouts
+func (h *PaymentHandler) ListPayouts(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	payouts, err := h.paymentService.ListPayouts(r.Context(), userID)
+	if err != nil {
+		utils.RespondJSON(w, paymentErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, payouts)
+}
+
+// GET /api/payouts/{id}
+func (h *PaymentHandler) GetPayout(w http.ResponseWriter, r *http.Request) {
+	payoutID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid payout ID"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	payout, err := h.paymentService.GetPayout(r.Context(), userID, payoutID)
+	if err != nil {
+		utils.RespondJSON(w, paymentErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, payout)
+}
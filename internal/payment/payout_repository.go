@@ -0,0 +1,237 @@
+package payment
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/repositories"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrPayoutNotFound      = errors.New("payout not found")
+	ErrPayoutLimitExceeded = errors.New("daily payout limit exceeded")
+)
+
+const payoutColumns = `
+	id, user_id, account_id, amount, fee, currency, provider, destination_type, destination, destination_label,
+	payment_method_id, status, COALESCE(provider_payout_id, '') AS provider_payout_id,
+	COALESCE(failure_reason, '') AS failure_reason, hold_transaction_id, release_transaction_id,
+	transaction_id, fee_transaction_id, created_at, updated_at, completed_at
+`
+
+// CreatePayout создаёт выплату в статусе pending. Строки пользователя и счёта
+// блокируются, чтобы параллельные выплаты не превысили ни доступный остаток,
+// ни дневной лимит (dailyLimit = 0 — без лимита): лимит общий для всех счетов
+// пользователя. Выплаты, блокировка по которым ещё не проведена, вычитаются
+// из остатка отдельно.
+func (r *PaymentRepository) CreatePayout(ctx context.Context, p *models.Payout, dailyLimit float64) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// пользователь блокируется раньше счёта — в том же порядке, что и при
+	// списании бесплатной квоты комиссий
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, p.UserID); err != nil {
+		return err
+	}
+
+	var available float64
+	err = tx.QueryRowContext(ctx, `
+		SELECT a.balance + COALESCE(o.limit_amount, 0)
+			- COALESCE((SELECT SUM(h.amount) FROM card_authorizations h WHERE h.account_id = a.id AND h.status = 'held'), 0)
+			- COALESCE((SELECT SUM(p.amount + p.fee) FROM payouts p
+				WHERE p.account_id = a.id AND p.status = 'pending' AND p.hold_transaction_id IS NULL), 0)
+		FROM accounts a
+		LEFT JOIN overdrafts o ON o.account_id = a.id AND o.status = 'active'
+		WHERE a.id = $1
+		FOR UPDATE OF a
+	`, p.AccountID).Scan(&available)
+	if err != nil {
+		return err
+	}
+	if available < p.Amount+p.Fee {
+		return ErrInsufficientFunds
+	}
+
+	if dailyLimit > 0 {
+		var paidToday float64
+		err = tx.QueryRowContext(ctx, `
+			SELECT COALESCE(SUM(amount), 0) FROM payouts
+			WHERE user_id = $1 AND status <> 'failed' AND created_at >= date_trunc('day', NOW())
+		`, p.UserID).Scan(&paidToday)
+		if err != nil {
+			return err
+		}
+		if paidToday+p.Amount > dailyLimit {
+			return ErrPayoutLimitExceeded
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO payouts (user_id, account_id, amount, fee, currency, provider, destination_type, destination,
+			destination_label, payment_method_id, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at, updated_at
+	`, p.UserID, p.AccountID, p.Amount, p.Fee, p.Currency, p.Provider, p.DestinationType, p.Destination,
+		p.DestinationLabel, p.PaymentMethodID, p.Status,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PaymentRepository) GetPayout(ctx context.Context, id int64) (*models.Payout, error) {
+	var p models.Payout
+	err := r.DB.GetContext(ctx, &p, `SELECT `+payoutColumns+` FROM payouts WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPayoutNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetPayoutByProviderID ищет выплату по идентификатору провайдера, nil — не найдена
+func (r *PaymentRepository) GetPayoutByProviderID(ctx context.Context, provider, providerPayoutID string) (*models.Payout, error) {
+	var p models.Payout
+	err := r.DB.GetContext(ctx, &p, `
+		SELECT `+payoutColumns+` FROM payouts WHERE provider = $1 AND provider_payout_id = $2
+	`, provider, providerPayoutID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *PaymentRepository) ListPayoutsByUser(ctx context.Context, userID int64, limit int) ([]models.Payout, error) {
+	var list []models.Payout
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT `+payoutColumns+` FROM payouts
+		WHERE user_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, userID, limit)
+	return list, err
+}
+
+// ListUnsettledPayouts возвращает выплаты без итогового статуса, не менявшиеся с before
+func (r *PaymentRepository) ListUnsettledPayouts(ctx context.Context, before time.Time, limit int) ([]models.Payout, error) {
+	var list []models.Payout
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT `+payoutColumns+` FROM payouts
+		WHERE status IN ('pending', 'processing') AND updated_at < $1
+		ORDER BY id
+		LIMIT $2
+	`, before, limit)
+	return list, err
+}
+
+// PostPayoutHold проводит блокировку hold (вместе с квотой claim) и привязывает
+// её к выплате одной транзакцией БД: выплаты с проведённой, но не
+// привязанной блокировкой не бывает
+func (r *PaymentRepository) PostPayoutHold(ctx context.Context, id int64, claim *models.FeeQuotaClaim, hold *models.Transaction) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := repositories.PostTransactionsTx(ctx, tx, claim, hold); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE payouts SET hold_transaction_id = $1, updated_at = NOW() WHERE id = $2
+	`, hold.ID, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PaymentRepository) SetPayoutProviderID(ctx context.Context, id int64, providerPayoutID string) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE payouts SET provider_payout_id = $1, updated_at = NOW() WHERE id = $2
+	`, providerPayoutID, id)
+	return err
+}
+
+// TouchPayout откладывает следующую проверку выплаты
+func (r *PaymentRepository) TouchPayout(ctx context.Context, id int64) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE payouts SET updated_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// MarkPayoutProcessing отмечает, что провайдер принял выплату
+func (r *PaymentRepository) MarkPayoutProcessing(ctx context.Context, id int64) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE payouts SET status = 'processing', updated_at = NOW() WHERE id = $1 AND status = 'pending'
+	`, id)
+	return err
+}
+
+// CompletePayout переводит выплату в succeeded. false — у выплаты уже итоговый статус.
+func (r *PaymentRepository) CompletePayout(ctx context.Context, id int64) (bool, error) {
+	result, err := r.DB.ExecContext(ctx, `
+		UPDATE payouts SET status = 'succeeded', completed_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status IN ('pending', 'processing')
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// FailPayout переводит выплату в failed. false — у выплаты уже итоговый статус.
+// Если передан release, снятие блокировки проводится в той же транзакции БД.
+func (r *PaymentRepository) FailPayout(ctx context.Context, id int64, reason string, release *models.Transaction) (bool, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE payouts SET status = 'failed', failure_reason = $1, completed_at = NOW(), updated_at = NOW()
+		WHERE id = $2 AND status IN ('pending', 'processing')
+	`, reason, id)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	if release != nil {
+		if err := repositories.PostTransactionsTx(ctx, tx, nil, release); err != nil {
+			return false, err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE payouts SET release_transaction_id = $1 WHERE id = $2
+		`, release.ID, id); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// SetPayoutTransactions сохраняет проводки завершения выплаты; nil не меняет поле
+func (r *PaymentRepository) SetPayoutTransactions(ctx context.Context, id int64, release, capture, fee *int64) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE payouts
+		SET release_transaction_id = COALESCE($1, release_transaction_id),
+			transaction_id = COALESCE($2, transaction_id),
+			fee_transaction_id = COALESCE($3, fee_transaction_id),
+			updated_at = NOW()
+		WHERE id = $4
+	`, release, capture, fee, id)
+	return err
+}
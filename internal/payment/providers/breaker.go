@@ -0,0 +1,76 @@
+package providers
+
+import (
+	"sync"
+	"time"
+)
+
+// Состояния предохранителя
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open" // таймаут истёк, следующий запрос пробный
+)
+
+// BreakerSettings — когда размыкать предохранитель провайдера
+type BreakerSettings struct {
+	FailureThreshold int           // подряд ошибок доступности до размыкания
+	OpenTimeout      time.Duration // сколько провайдер исключён из маршрутизации
+}
+
+// circuitBreaker считает подряд идущие ошибки доступности провайдера.
+// Отказы по картам ошибками не считаются: провайдер работает.
+type circuitBreaker struct {
+	settings BreakerSettings
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	opened    bool
+}
+
+func newCircuitBreaker(settings BreakerSettings) *circuitBreaker {
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = 5
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = 30 * time.Second
+	}
+	return &circuitBreaker{settings: settings}
+}
+
+func (b *circuitBreaker) State(now time.Time) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case !b.opened:
+		return BreakerClosed
+	case now.Before(b.openUntil):
+		return BreakerOpen
+	}
+	return BreakerHalfOpen
+}
+
+// Available — можно ли отправлять провайдеру запросы. В полуоткрытом
+// состоянии пропускаются все запросы: первый же ответ решает, замкнуть
+// предохранитель или снова разомкнуть.
+func (b *circuitBreaker) Available(now time.Time) bool {
+	return b.State(now) != BreakerOpen
+}
+
+func (b *circuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.opened = false
+}
+
+func (b *circuitBreaker) Failure(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.opened || b.failures >= b.settings.FailureThreshold {
+		b.opened = true
+		b.openUntil = now.Add(b.settings.OpenTimeout)
+	}
+}
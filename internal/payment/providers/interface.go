@@ -2,11 +2,203 @@ package providers
 
 import (
 	"bank-api/internal/models"
+	"bank-api/internal/security"
 	"context"
+	"crypto/hmac"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// ErrUnavailable — провайдер не принял запрос: соединение не установлено или
+// он явно ответил, что перегружен. Платёж у него точно не создан, поэтому
+// запрос можно отправить другому провайдеру.
+var ErrUnavailable = errors.New("payment provider unavailable")
+
+// ErrDestinationUnsupported — провайдер не выплачивает на такой получатель
+var ErrDestinationUnsupported = errors.New("payout destination not supported by provider")
+
+// ErrMethodRejected — провайдер не принял карту или не знает токен
+var ErrMethodRejected = errors.New("payment method rejected by provider")
+
+// ErrRejected — провайдер явно отказал в запросе (ответ 4xx или запрос не
+// прошёл проверку до отправки): операция у него не проведена
+var ErrRejected = errors.New("request rejected by provider")
+
+// IsRetryable сообщает, можно ли повторить запрос у другого провайдера
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrUnavailable)
+}
+
+// NotProcessed сообщает, что операция у провайдера точно не проведена: он
+// был недоступен или явно отказал. После остальных ошибок (таймаут, 5xx)
+// исход неизвестен — его сообщит уведомление или сверка.
+func NotProcessed(err error) bool {
+	return errors.Is(err, ErrUnavailable) || errors.Is(err, ErrRejected) ||
+		errors.Is(err, ErrMethodRejected) || errors.Is(err, ErrDestinationUnsupported)
+}
+
+// unavailableStatus — ответы, после которых запрос гарантированно не обработан.
+// 500 и таймауты сюда не входят: платёж мог быть создан.
+func unavailableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
+// connectFailed — запрос не ушёл к провайдеру
+func connectFailed(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
 type PaymentProvider interface {
 	Name() string
 	ProcessPayment(ctx context.Context, payment models.Payment) (*models.PaymentResult, error)
-	Refund(ctx context.Context, transactionID string, amount float64) error
+	GetPayment(ctx context.Context, providerPaymentID string) (*models.PaymentResult, error)
+	Refund(ctx context.Context, req RefundRequest) (*RefundResult, error)
+	GetRefund(ctx context.Context, providerRefundID string) (*RefundResult, error)
+	Payout(ctx context.Context, req PayoutRequest) (*PayoutResult, error)
+	GetPayout(ctx context.Context, providerPayoutID string) (*PayoutResult, error)
+}
+
+// PaymentCanceler — провайдер, у которого можно отменить платёж, ожидающий
+// подтверждения клиента
+type PaymentCanceler interface {
+	CancelPayment(ctx context.Context, providerPaymentID string) (*models.PaymentResult, error)
+}
+
+// ReturnURLs строит адреса возврата клиента после подтверждения платежа:
+// к базовому адресу из конфигурации добавляются ID платежа и подпись. Адрес
+// открывается без авторизации, поэтому без подписи по нему можно было бы
+// перебирать чужие платежи.
+type ReturnURLs struct {
+	base   string
+	secret []byte
+}
+
+// NewReturnURLs создаёт построитель адресов; пустой base — адрес возврата
+// провайдеру не передаётся
+func NewReturnURLs(base, secret string) *ReturnURLs {
+	return &ReturnURLs{base: base, secret: []byte(secret)}
+}
+
+// Enabled сообщает, задан ли адрес возврата
+func (u *ReturnURLs) Enabled() bool {
+	return u != nil && u.base != ""
+}
+
+// For возвращает подписанный адрес возврата для платежа
+func (u *ReturnURLs) For(paymentID int64) string {
+	parsed, err := url.Parse(u.base)
+	if err != nil {
+		return u.base
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/") + "/" + strconv.FormatInt(paymentID, 10)
+	query := parsed.Query()
+	query.Set("token", u.token(paymentID))
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// Verify проверяет подпись из адреса возврата
+func (u *ReturnURLs) Verify(paymentID int64, token string) bool {
+	if u == nil || len(u.secret) == 0 || token == "" {
+		return false
+	}
+	return hmac.Equal([]byte(u.token(paymentID)), []byte(token))
+}
+
+func (u *ReturnURLs) token(paymentID int64) string {
+	token, _ := security.GenerateHMAC("payment-return:"+strconv.FormatInt(paymentID, 10), u.secret)
+	return token
+}
+
+// RefundRequest — возврат у провайдера. С тем же IdempotencyKey провайдер
+// не создаст второй возврат, поэтому запрос можно безопасно повторить.
+type RefundRequest struct {
+	ProviderPaymentID string
+	Amount            float64
+	Currency          string
+	Reason            string
+	IdempotencyKey    string
+}
+
+// RefundResult — состояние возврата у провайдера
+type RefundResult struct {
+	ID                string
+	ProviderPaymentID string
+	Status            string // models.RefundStatus*
+	FailureReason     string
+}
+
+// PayoutRequest — выплата на внешнюю карту или кошелёк. С тем же
+// IdempotencyKey провайдер не создаст вторую выплату.
+type PayoutRequest struct {
+	PayoutID        int64
+	Amount          float64
+	Currency        string
+	DestinationType string // models.PayoutDestination*
+	Destination     string // токен карты или номер кошелька
+	Description     string
+	IdempotencyKey  string
+}
+
+// PayoutResult — состояние выплаты у провайдера
+type PayoutResult struct {
+	ID            string
+	Status        string // models.PayoutStatus*
+	FailureReason string
+}
+
+// WebhookEvent — уведомление провайдера, приведённое к статусам платежа
+type WebhookEvent struct {
+	ID                string // уникален в пределах провайдера, по нему отсекаются повторы
+	Type              string
+	ProviderPaymentID string
+	PaymentID         int64  // наш ID из метаданных, 0 — не передан
+	Status            string // пусто — событие не меняет статус
+	Amount            float64
+	Currency          string
+	FailureReason     string
+	PaymentMethodID   string        // способ оплаты, сохранённый для будущих списаний
+	Refund            *RefundResult // событие о возврате; Status при этом пуст
+	Payout            *PayoutResult // событие о выплате; Status при этом пуст
+}
+
+// WebhookVerifier — провайдер, принимающий уведомления на /webhooks/{provider}.
+// ParseWebhook проверяет подлинность запроса и возвращает ErrInvalidSignature,
+// если проверка не прошла.
+type WebhookVerifier interface {
+	ParseWebhook(r *http.Request, body []byte) (*WebhookEvent, error)
+}
+
+// CardDetails — реквизиты карты для токенизации. Банк их не хранит: они
+// уходят провайдеру, а в хранилище попадает только токен.
+type CardDetails struct {
+	Number   string `json:"number"`
+	ExpMonth int    `json:"exp_month"`
+	ExpYear  int    `json:"exp_year"`
+	CVC      string `json:"cvc"`
+}
+
+// MethodDetails — способ оплаты у провайдера
+type MethodDetails struct {
+	Token       string
+	Type        string // card, wallet, sbp
+	Brand       string
+	Last4       string
+	ExpMonth    int
+	ExpYear     int
+	Fingerprint string // одинаков у всех токенов одной карты; пусто — провайдер не сообщает
+}
+
+// Tokenizer — провайдер, который токенизирует карты и сообщает сведения о
+// токене. Без него токен сохраняется как есть, а сведения берутся от клиента.
+type Tokenizer interface {
+	Tokenize(ctx context.Context, card CardDetails) (*MethodDetails, error)
+	DescribeMethod(ctx context.Context, token string) (*MethodDetails, error)
 }
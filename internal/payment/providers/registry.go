@@ -1,45 +1,308 @@
 package providers
 
 import (
+	"bank-api/internal/models"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
+var (
+	ErrProviderNotFound = errors.New("payment provider not found")
+	ErrNoRoute          = errors.New("no payment provider available")
+)
+
+// Итог обращения к провайдеру
+const (
+	OutcomeAccepted    = "accepted"    // платёж принят, в т.ч. processing и requires_action
+	OutcomeDeclined    = "declined"    // отказ по карте: провайдер исправен
+	OutcomeUnavailable = "unavailable" // ErrUnavailable: можно идти к следующему провайдеру
+	OutcomeError       = "error"       // прочие ошибки: исход платежа неизвестен
+)
+
+// по скольким последним обращениям считается доля успешных
+const statsWindow = 100
+
+// Profile — что принимает провайдер и во что обходится платёж через него
+type Profile struct {
+	Currencies []string // пусто — любые
+	Methods    []string // пусто — любые
+	FeePercent float64
+	FeeFixed   float64
+}
+
+// Supports сообщает, принимает ли провайдер валюту и способ оплаты
+func (p Profile) Supports(currency, method string) bool {
+	return matches(p.Currencies, currency) && matches(p.Methods, method)
+}
+
+// Cost — комиссия провайдера за платёж
+func (p Profile) Cost(amount float64) float64 {
+	return amount*p.FeePercent/100 + p.FeeFixed
+}
+
+// RoutingRule — правило выбора провайдера. Правила проверяются по порядку,
+// применяется первое подошедшее.
+type RoutingRule struct {
+	Name       string
+	Currencies []string // пусто — любые
+	Methods    []string // пусто — любые
+	MinAmount  float64
+	MaxAmount  float64  // 0 — без верхней границы
+	Providers  []string // по приоритету; пусто — все подходящие, по стоимости
+}
+
+func (r RoutingRule) match(p models.Payment) bool {
+	return matches(r.Currencies, p.Currency) && matches(r.Methods, p.Method) &&
+		p.Amount >= r.MinAmount && (r.MaxAmount == 0 || p.Amount <= r.MaxAmount)
+}
+
+// Route — решение маршрутизации: провайдеры в порядке попыток
+type Route struct {
+	Rule      string // пусто — ни одно правило не подошло
+	Providers []PaymentProvider
+}
+
+// ProviderStats — состояние провайдера для мониторинга
+type ProviderStats struct {
+	Name        string   `json:"name"`
+	Breaker     string   `json:"breaker"`
+	Requests    int      `json:"requests"`
+	Accepted    int      `json:"accepted"`
+	Declined    int      `json:"declined"`
+	Unavailable int      `json:"unavailable"`
+	Errors      int      `json:"errors"`
+	SuccessRate float64  `json:"success_rate"`
+	Currencies  []string `json:"currencies,omitempty"`
+	Methods     []string `json:"methods,omitempty"`
+	FeePercent  float64  `json:"fee_percent"`
+	FeeFixed    float64  `json:"fee_fixed"`
+}
+
+type entry struct {
+	provider PaymentProvider
+	profile  Profile
+	breaker  *circuitBreaker
+
+	mu       sync.Mutex
+	outcomes []string // кольцевой буфер последних statsWindow исходов
+	next     int
+}
+
+func (e *entry) record(outcome string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.outcomes) < statsWindow {
+		e.outcomes = append(e.outcomes, outcome)
+		return
+	}
+	e.outcomes[e.next] = outcome
+	e.next = (e.next + 1) % statsWindow
+}
+
+func (e *entry) stats(now time.Time) ProviderStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := ProviderStats{
+		Name:        e.provider.Name(),
+		Breaker:     e.breaker.State(now),
+		Requests:    len(e.outcomes),
+		SuccessRate: 1,
+		Currencies:  e.profile.Currencies,
+		Methods:     e.profile.Methods,
+		FeePercent:  e.profile.FeePercent,
+		FeeFixed:    e.profile.FeeFixed,
+	}
+	for _, o := range e.outcomes {
+		switch o {
+		case OutcomeAccepted:
+			st.Accepted++
+		case OutcomeDeclined:
+			st.Declined++
+		case OutcomeUnavailable:
+			st.Unavailable++
+		default:
+			st.Errors++
+		}
+	}
+	if st.Requests > 0 {
+		st.SuccessRate = float64(st.Accepted) / float64(st.Requests)
+	}
+	return st
+}
+
+// Registry хранит провайдеров, выбирает их для платежа и следит за их
+// доступностью
 type Registry struct {
-	providers map[string]PaymentProvider
+	providers map[string]*entry
+	order     []string
+	rules     []RoutingRule
+	breaker   BreakerSettings
 	mu        sync.RWMutex
 }
 
-func NewRegistry() *Registry {
+func NewRegistry(breaker BreakerSettings, rules []RoutingRule) *Registry {
 	return &Registry{
-		providers: make(map[string]PaymentProvider),
+		providers: make(map[string]*entry),
+		rules:     rules,
+		breaker:   breaker,
 	}
 }
 
-func (r *Registry) Register(p PaymentProvider) {
+func (r *Registry) Register(p PaymentProvider, profile Profile) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.providers[p.Name()] = p
+	if _, ok := r.providers[p.Name()]; !ok {
+		r.order = append(r.order, p.Name())
+	}
+	r.providers[p.Name()] = &entry{
+		provider: p,
+		profile:  profile,
+		breaker:  newCircuitBreaker(r.breaker),
+	}
 }
 
 func (r *Registry) Get(name string) (PaymentProvider, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	provider, ok := r.providers[name]
+	e, ok := r.providers[name]
 	if !ok {
-		return nil, fmt.Errorf("payment provider not found: %s", name)
+		return nil, fmt.Errorf("%w: %s", ErrProviderNotFound, name)
 	}
-	return provider, nil
+	return e.provider, nil
 }
 
 func (r *Registry) GetAll() []PaymentProvider {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	providers := make([]PaymentProvider, 0, len(r.providers))
-	for _, p := range r.providers {
-		providers = append(providers, p)
+	providers := make([]PaymentProvider, 0, len(r.order))
+	for _, name := range r.order {
+		providers = append(providers, r.providers[name].provider)
 	}
 	return providers
 }
+
+// Route выбирает провайдеров для платежа. Провайдер, названный клиентом,
+// идёт первым, за ним — провайдеры первого подошедшего правила. Если правило
+// не подошло или не называет провайдеров, подходящие упорядочиваются по
+// комиссии, при равной — по доле успешных платежей. Провайдеры с разомкнутым
+// предохранителем и не принимающие валюту или способ оплаты пропускаются.
+func (r *Registry) Route(p models.Payment) (*Route, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if p.Provider != "" {
+		if _, ok := r.providers[p.Provider]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrProviderNotFound, p.Provider)
+		}
+	}
+
+	route := &Route{}
+	var names []string
+	for _, rule := range r.rules {
+		if rule.match(p) {
+			route.Rule = rule.Name
+			names = rule.Providers
+			break
+		}
+	}
+
+	now := time.Now()
+	usable := func(name string) *entry {
+		e, ok := r.providers[name]
+		if !ok || !e.profile.Supports(p.Currency, p.Method) || !e.breaker.Available(now) {
+			return nil
+		}
+		return e
+	}
+
+	var candidates []*entry
+	if len(names) > 0 {
+		for _, name := range names {
+			if e := usable(name); e != nil {
+				candidates = append(candidates, e)
+			}
+		}
+	} else {
+		for _, name := range r.order {
+			if e := usable(name); e != nil {
+				candidates = append(candidates, e)
+			}
+		}
+		rates := make(map[*entry]float64, len(candidates))
+		for _, e := range candidates {
+			rates[e] = e.stats(now).SuccessRate
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			ci, cj := candidates[i].profile.Cost(p.Amount), candidates[j].profile.Cost(p.Amount)
+			if ci != cj {
+				return ci < cj
+			}
+			return rates[candidates[i]] > rates[candidates[j]]
+		})
+	}
+
+	if preferred := usable(p.Provider); preferred != nil {
+		route.Providers = append(route.Providers, preferred.provider)
+	}
+	for _, e := range candidates {
+		if e.provider.Name() != p.Provider {
+			route.Providers = append(route.Providers, e.provider)
+		}
+	}
+
+	if len(route.Providers) == 0 {
+		return nil, fmt.Errorf("%w for %.2f %s (%s)", ErrNoRoute, p.Amount, p.Currency, p.Method)
+	}
+	return route, nil
+}
+
+// Report учитывает исход обращения к провайдеру. Предохранитель размыкают
+// только ошибки доступности: отказы по картам и ошибки запроса не значат,
+// что провайдер лежит.
+func (r *Registry) Report(name, outcome string) {
+	r.mu.RLock()
+	e, ok := r.providers[name]
+	r.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	e.record(outcome)
+	switch outcome {
+	case OutcomeUnavailable:
+		e.breaker.Failure(time.Now())
+	case OutcomeAccepted, OutcomeDeclined:
+		e.breaker.Success()
+	}
+}
+
+// Stats возвращает состояние провайдеров в порядке регистрации
+func (r *Registry) Stats() []ProviderStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	stats := make([]ProviderStats, 0, len(r.order))
+	for _, name := range r.order {
+		stats = append(stats, r.providers[name].stats(now))
+	}
+	return stats
+}
+
+func matches(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(a, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,43 +3,551 @@ package providers
 import (
 	"bank-api/internal/models"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// допустимое расхождение метки времени в Stripe-Signature
+const stripeSignatureTolerance = 5 * time.Minute
+
+// StripeProvider — адаптер к Stripe PaymentIntents API
 type StripeProvider struct {
-	APIKey string
+	client        *http.Client
+	baseURL       string
+	apiKey        string
+	webhookSecret string // whsec_..., пусто — уведомления не принимаются
+	returnURLs    *ReturnURLs
 }
 
-func NewStripeProvider(apiKey string) *StripeProvider {
+func NewStripeProvider(client *http.Client, baseURL, apiKey, webhookSecret string, returnURLs *ReturnURLs) *StripeProvider {
+	if baseURL == "" {
+		baseURL = "https://api.stripe.com"
+	}
 	return &StripeProvider{
-		APIKey: apiKey,
+		client:        client,
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		apiKey:        apiKey,
+		webhookSecret: webhookSecret,
+		returnURLs:    returnURLs,
+	}
+}
+
+type stripePaymentIntent struct {
+	ID               string `json:"id"`
+	Status           string `json:"status"`
+	PaymentMethod    string `json:"payment_method"`
+	SetupFutureUsage string `json:"setup_future_usage"`
+	NextAction       *struct {
+		RedirectToURL *struct {
+			URL string `json:"url"`
+		} `json:"redirect_to_url"`
+	} `json:"next_action"`
+	LastPaymentError   *stripeError `json:"last_payment_error"`
+	CancellationReason string       `json:"cancellation_reason"`
+}
+
+type stripeError struct {
+	Type          string               `json:"type"`
+	Code          string               `json:"code"`
+	DeclineCode   string               `json:"decline_code"`
+	Message       string               `json:"message"`
+	PaymentIntent *stripePaymentIntent `json:"payment_intent"`
+
+	status int // HTTP-статус ответа с ошибкой
+}
+
+func (e *stripeError) Error() string {
+	return fmt.Sprintf("stripe: %s: %s", e.Type, e.Message)
+}
+
+// Is: ответ 4xx — явный отказ, ответ 5xx — исход неизвестен
+func (e *stripeError) Is(target error) bool {
+	return target == ErrRejected && e.status >= 400 && e.status < 500
+}
+
+func (e *stripeError) reason() string {
+	switch {
+	case e.DeclineCode != "":
+		return e.DeclineCode
+	case e.Code != "":
+		return e.Code
 	}
+	return e.Message
 }
 
-func (y *StripeProvider) ProcessPayment(ctx context.Context, payment models.Payment) (*models.PaymentResult, error) {
+// stripeSavedMethod — способ оплаты платежа, если он сохранён для будущих списаний
+func stripeSavedMethod(status, paymentMethod, setupFutureUsage string) string {
+	if setupFutureUsage == "" || status != "succeeded" {
+		return ""
+	}
+	return paymentMethod
+}
+
+// ProcessPayment — POST /v1/payment_intents с confirm=true. Списание по
+// инициативе магазина идёт с off_session=true: 3-D Secure пройти некому,
+// и банк, потребовавший его, отклонит платёж.
+func (s *StripeProvider) ProcessPayment(ctx context.Context, payment models.Payment) (*models.PaymentResult, error) {
 	if payment.Amount <= 0 {
-		return nil, errors.New("invalid payment amount")
+		return nil, fmt.Errorf("%w: invalid payment amount", ErrRejected)
+	}
+	if payment.PaymentMethod == "" {
+		return nil, fmt.Errorf("stripe: %w: payment_method is required", ErrRejected)
+	}
+
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(minorUnits(payment.Amount), 10))
+	form.Set("currency", strings.ToLower(payment.Currency))
+	form.Set("payment_method", payment.PaymentMethod)
+	form.Set("confirm", "true")
+	form.Set("metadata[payment_id]", strconv.FormatInt(payment.ID, 10))
+	if payment.SavePaymentMethod {
+		form.Set("setup_future_usage", "off_session")
+	}
+	if payment.Initiator == models.PaymentInitiatorMerchant {
+		form.Set("off_session", "true")
+	} else if s.returnURLs.Enabled() {
+		form.Set("return_url", s.returnURLs.For(payment.ID))
 	}
 
-	// Имитируем успешный платеж
-	transactionID := fmt.Sprintf("stripe_txn_%d", payment.ID)
+	var intent stripePaymentIntent
+	err := s.call(ctx, http.MethodPost, "/v1/payment_intents", fmt.Sprintf("payment-%d", payment.ID), form, &intent)
 
-	return &models.PaymentResult{
-		TransactionID: transactionID,
-		Status:        "success",
-	}, nil
+	var declined *stripeError
+	if errors.As(err, &declined) && declined.Type == "card_error" && declined.PaymentIntent != nil {
+		// отказ по карте: PaymentIntent создан, но не прошёл
+		return &models.PaymentResult{
+			TransactionID: declined.PaymentIntent.ID,
+			Status:        models.PaymentStatusFailed,
+			Provider:      s.Name(),
+			Error:         declined.reason(),
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return intent.result(s.Name())
 }
 
-func (s *StripeProvider) Refund(ctx context.Context, paymentID string, amount float64) error {
-	// Тут тоже могла бы быть интеграция с Stripe API для возврата
-	if amount <= 0 {
-		return errors.New("invalid refund amount")
+func (pi *stripePaymentIntent) result(provider string) (*models.PaymentResult, error) {
+	result := &models.PaymentResult{
+		TransactionID:   pi.ID,
+		Provider:        provider,
+		PaymentMethodID: stripeSavedMethod(pi.Status, pi.PaymentMethod, pi.SetupFutureUsage),
 	}
-	fmt.Printf("Stripe: refunded %.2f for %s\n", amount, paymentID)
-	return nil
+	switch pi.Status {
+	case "succeeded":
+		result.Status = models.PaymentStatusSucceeded
+	case "processing":
+		result.Status = models.PaymentStatusProcessing
+	case "requires_action", "requires_confirmation":
+		result.Status = models.PaymentStatusRequiresAction
+		if pi.NextAction != nil && pi.NextAction.RedirectToURL != nil {
+			result.ConfirmationURL = pi.NextAction.RedirectToURL.URL
+		}
+	case "canceled":
+		result.Status = models.PaymentStatusCanceled
+		if pi.CancellationReason != "" {
+			result.Error = pi.CancellationReason
+		}
+	case "requires_payment_method":
+		result.Status = models.PaymentStatusFailed
+		if pi.LastPaymentError != nil {
+			result.Error = pi.LastPaymentError.reason()
+		}
+	default:
+		return nil, fmt.Errorf("stripe: unexpected payment intent status %q", pi.Status)
+	}
+	return result, nil
+}
+
+// GetPayment — GET /v1/payment_intents/{id}
+func (s *StripeProvider) GetPayment(ctx context.Context, providerPaymentID string) (*models.PaymentResult, error) {
+	var intent stripePaymentIntent
+	if err := s.call(ctx, http.MethodGet, "/v1/payment_intents/"+url.PathEscape(providerPaymentID), "", nil, &intent); err != nil {
+		return nil, err
+	}
+	return intent.result(s.Name())
+}
+
+// CancelPayment — POST /v1/payment_intents/{id}/cancel. Stripe отменяет только
+// платёж, ожидающий действий клиента; если клиент успел его подтвердить,
+// возвращается текущее состояние.
+func (s *StripeProvider) CancelPayment(ctx context.Context, providerPaymentID string) (*models.PaymentResult, error) {
+	form := url.Values{}
+	form.Set("cancellation_reason", "abandoned")
+
+	var intent stripePaymentIntent
+	err := s.call(ctx, http.MethodPost, "/v1/payment_intents/"+url.PathEscape(providerPaymentID)+"/cancel",
+		"cancel-"+providerPaymentID, form, &intent)
+	var stripeErr *stripeError
+	if errors.As(err, &stripeErr) && stripeErr.Code == "payment_intent_unexpected_state" {
+		return s.GetPayment(ctx, providerPaymentID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return intent.result(s.Name())
+}
+
+type stripePaymentMethod struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Card *struct {
+		Brand       string `json:"brand"`
+		Last4       string `json:"last4"`
+		ExpMonth    int    `json:"exp_month"`
+		ExpYear     int    `json:"exp_year"`
+		Fingerprint string `json:"fingerprint"`
+	} `json:"card"`
+}
+
+func (m *stripePaymentMethod) details() *MethodDetails {
+	d := &MethodDetails{Token: m.ID, Type: m.Type}
+	if m.Card != nil {
+		d.Brand = m.Card.Brand
+		d.Last4 = m.Card.Last4
+		d.ExpMonth = m.Card.ExpMonth
+		d.ExpYear = m.Card.ExpYear
+		d.Fingerprint = m.Card.Fingerprint
+	}
+	return d
+}
+
+// Tokenize — POST /v1/payment_methods
+func (s *StripeProvider) Tokenize(ctx context.Context, card CardDetails) (*MethodDetails, error) {
+	form := url.Values{}
+	form.Set("type", "card")
+	form.Set("card[number]", card.Number)
+	form.Set("card[exp_month]", strconv.Itoa(card.ExpMonth))
+	form.Set("card[exp_year]", strconv.Itoa(card.ExpYear))
+	if card.CVC != "" {
+		form.Set("card[cvc]", card.CVC)
+	}
+
+	var method stripePaymentMethod
+	if err := s.call(ctx, http.MethodPost, "/v1/payment_methods", "", form, &method); err != nil {
+		return nil, stripeMethodError(err)
+	}
+	return method.details(), nil
+}
+
+// DescribeMethod — GET /v1/payment_methods/{id}
+func (s *StripeProvider) DescribeMethod(ctx context.Context, token string) (*MethodDetails, error) {
+	var method stripePaymentMethod
+	if err := s.call(ctx, http.MethodGet, "/v1/payment_methods/"+url.PathEscape(token), "", nil, &method); err != nil {
+		return nil, stripeMethodError(err)
+	}
+	return method.details(), nil
+}
+
+// stripeMethodError отделяет отказ в карте или токене от сбоев API
+func stripeMethodError(err error) error {
+	var apiErr *stripeError
+	if errors.As(err, &apiErr) && (apiErr.Type == "card_error" || apiErr.Code == "resource_missing") {
+		return fmt.Errorf("%w: %s", ErrMethodRejected, apiErr.reason())
+	}
+	return err
+}
+
+type stripeRefund struct {
+	ID            string `json:"id"`
+	PaymentIntent string `json:"payment_intent"`
+	Status        string `json:"status"` // pending, requires_action, succeeded, failed, canceled
+	FailureReason string `json:"failure_reason"`
+}
+
+func (r *stripeRefund) result() *RefundResult {
+	result := &RefundResult{ID: r.ID, ProviderPaymentID: r.PaymentIntent, Status: models.RefundStatusPending}
+	switch r.Status {
+	case "succeeded":
+		result.Status = models.RefundStatusSucceeded
+	case "failed", "canceled":
+		result.Status = models.RefundStatusFailed
+		result.FailureReason = r.FailureReason
+		if result.FailureReason == "" {
+			result.FailureReason = r.Status
+		}
+	}
+	return result
+}
+
+// Refund — POST /v1/refunds
+func (s *StripeProvider) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("%w: invalid refund amount", ErrRejected)
+	}
+
+	form := url.Values{}
+	form.Set("payment_intent", req.ProviderPaymentID)
+	form.Set("amount", strconv.FormatInt(minorUnits(req.Amount), 10))
+	if req.Reason != "" {
+		form.Set("metadata[reason]", req.Reason)
+	}
+
+	var refund stripeRefund
+	if err := s.call(ctx, http.MethodPost, "/v1/refunds", req.IdempotencyKey, form, &refund); err != nil {
+		return nil, err
+	}
+	return refund.result(), nil
+}
+
+// GetRefund — GET /v1/refunds/{id}
+func (s *StripeProvider) GetRefund(ctx context.Context, providerRefundID string) (*RefundResult, error) {
+	var refund stripeRefund
+	if err := s.call(ctx, http.MethodGet, "/v1/refunds/"+url.PathEscape(providerRefundID), "", nil, &refund); err != nil {
+		return nil, err
+	}
+	return refund.result(), nil
+}
+
+type stripePayout struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"` // pending, in_transit, paid, failed, canceled
+	FailureCode string `json:"failure_code"`
+}
+
+func (p *stripePayout) result() *PayoutResult {
+	result := &PayoutResult{ID: p.ID, Status: models.PayoutStatusProcessing}
+	switch p.Status {
+	case "paid":
+		result.Status = models.PayoutStatusSucceeded
+	case "failed", "canceled":
+		result.Status = models.PayoutStatusFailed
+		result.FailureReason = p.FailureCode
+		if result.FailureReason == "" {
+			result.FailureReason = p.Status
+		}
+	}
+	return result
+}
+
+// Payout — POST /v1/payouts на сохранённую карту. Кошельки Stripe не поддерживает.
+func (s *StripeProvider) Payout(ctx context.Context, req PayoutRequest) (*PayoutResult, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("%w: invalid payout amount", ErrRejected)
+	}
+	if req.DestinationType != models.PayoutDestinationCard {
+		return nil, fmt.Errorf("stripe: %w: %s", ErrDestinationUnsupported, req.DestinationType)
+	}
+
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(minorUnits(req.Amount), 10))
+	form.Set("currency", strings.ToLower(req.Currency))
+	form.Set("destination", req.Destination)
+	form.Set("metadata[payout_id]", strconv.FormatInt(req.PayoutID, 10))
+	if req.Description != "" {
+		form.Set("description", req.Description)
+	}
+
+	var payout stripePayout
+	if err := s.call(ctx, http.MethodPost, "/v1/payouts", req.IdempotencyKey, form, &payout); err != nil {
+		return nil, err
+	}
+	return payout.result(), nil
+}
+
+// GetPayout — GET /v1/payouts/{id}
+func (s *StripeProvider) GetPayout(ctx context.Context, providerPayoutID string) (*PayoutResult, error) {
+	var payout stripePayout
+	if err := s.call(ctx, http.MethodGet, "/v1/payouts/"+url.PathEscape(providerPayoutID), "", nil, &payout); err != nil {
+		return nil, err
+	}
+	return payout.result(), nil
 }
 
 func (s *StripeProvider) Name() string {
 	return "stripe"
 }
+
+// call отправляет form-запрос к API. Ошибка API возвращается как *stripeError.
+func (s *StripeProvider) call(ctx context.Context, method, path, idempotencyKey string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		if connectFailed(err) {
+			return fmt.Errorf("stripe: %w: %v", ErrUnavailable, err)
+		}
+		return fmt.Errorf("stripe: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if unavailableStatus(resp.StatusCode) {
+		return fmt.Errorf("stripe: %w: status %d", ErrUnavailable, resp.StatusCode)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		var body struct {
+			Error *stripeError `json:"error"`
+		}
+		if json.Unmarshal(raw, &body) == nil && body.Error != nil {
+			body.Error.status = resp.StatusCode
+			return body.Error
+		}
+		if resp.StatusCode < 500 {
+			return fmt.Errorf("stripe: %w: %s failed with status %d", ErrRejected, path, resp.StatusCode)
+		}
+		return fmt.Errorf("stripe: %s failed with status %d", path, resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("stripe: unexpected response (status %d)", resp.StatusCode)
+	}
+	return nil
+}
+
+// minorUnits переводит сумму в копейки/центы
+func minorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+type stripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object struct {
+			ID               string            `json:"id"`
+			Object           string            `json:"object"`
+			Status           string            `json:"status"`
+			PaymentMethod    string            `json:"payment_method"`
+			SetupFutureUsage string            `json:"setup_future_usage"`
+			PaymentIntent    string            `json:"payment_intent"` // у возвратов
+			FailureReason    string            `json:"failure_reason"` // у возвратов
+			FailureCode      string            `json:"failure_code"`   // у выплат
+			Amount           int64             `json:"amount"`
+			Currency         string            `json:"currency"`
+			Metadata         map[string]string `json:"metadata"`
+			LastPaymentError *stripeError      `json:"last_payment_error"`
+		} `json:"object"`
+	} `json:"data"`
+}
+
+// ParseWebhook проверяет заголовок Stripe-Signature (HMAC-SHA256 от "t.body")
+// и разбирает событие payment_intent.*
+func (s *StripeProvider) ParseWebhook(r *http.Request, body []byte) (*WebhookEvent, error) {
+	if s.webhookSecret == "" {
+		return nil, ErrInvalidSignature
+	}
+	if err := verifyStripeSignature(r.Header.Get("Stripe-Signature"), body, s.webhookSecret, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var e stripeEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, fmt.Errorf("invalid stripe event: %w", err)
+	}
+	if e.ID == "" {
+		return nil, errors.New("invalid stripe event: missing id")
+	}
+
+	obj := e.Data.Object
+	event := &WebhookEvent{
+		ID:   e.ID,
+		Type: e.Type,
+	}
+	if obj.Object == "refund" {
+		refund := stripeRefund{ID: obj.ID, PaymentIntent: obj.PaymentIntent, Status: obj.Status, FailureReason: obj.FailureReason}
+		event.ProviderPaymentID = obj.PaymentIntent
+		event.Refund = refund.result()
+		return event, nil
+	}
+	if obj.Object == "payout" {
+		payout := stripePayout{ID: obj.ID, Status: obj.Status, FailureCode: obj.FailureCode}
+		event.Payout = payout.result()
+		return event, nil
+	}
+	if obj.Object != "payment_intent" {
+		return event, nil
+	}
+
+	event.ProviderPaymentID = obj.ID
+	event.PaymentMethodID = stripeSavedMethod(obj.Status, obj.PaymentMethod, obj.SetupFutureUsage)
+	event.Amount = float64(obj.Amount) / 100
+	event.Currency = strings.ToUpper(obj.Currency)
+	if id, err := strconv.ParseInt(obj.Metadata["payment_id"], 10, 64); err == nil {
+		event.PaymentID = id
+	}
+
+	switch e.Type {
+	case "payment_intent.succeeded":
+		event.Status = models.PaymentStatusSucceeded
+	case "payment_intent.processing":
+		event.Status = models.PaymentStatusProcessing
+	case "payment_intent.requires_action":
+		event.Status = models.PaymentStatusRequiresAction
+	case "payment_intent.payment_failed":
+		event.Status = models.PaymentStatusFailed
+		if obj.LastPaymentError != nil {
+			event.FailureReason = obj.LastPaymentError.reason()
+		}
+	case "payment_intent.canceled":
+		event.Status = models.PaymentStatusCanceled
+	}
+	return event, nil
+}
+
+// verifyStripeSignature проверяет заголовок вида "t=1492774577,v1=5257a8...,v1=..."
+func verifyStripeSignature(header string, body []byte, secret string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > stripeSignatureTolerance || d < -stripeSignatureTolerance {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	for _, sig := range signatures {
+		got, err := hex.DecodeString(sig)
+		if err == nil && hmac.Equal(got, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
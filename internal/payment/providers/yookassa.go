@@ -2,43 +2,482 @@ package providers
 
 import (
 	"bank-api/internal/models"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
-type YooMoneyProvider struct {
-	Token string
+// DefaultYooKassaWebhookIPs — адреса, с которых YooKassa отправляет уведомления
+var DefaultYooKassaWebhookIPs = []string{
+	"185.71.76.0/27",
+	"185.71.77.0/27",
+	"77.75.153.0/25",
+	"77.75.156.11",
+	"77.75.156.35",
+	"77.75.154.128/25",
+	"2a02:5180::/32",
 }
 
-func NewYooMoneyProvider(token string) *YooMoneyProvider {
-	return &YooMoneyProvider{
-		Token: token,
+// YooKassaProvider — адаптер к YooKassa API v3
+type YooKassaProvider struct {
+	client     *http.Client
+	baseURL    string
+	shopID     string
+	secretKey  string
+	returnURLs *ReturnURLs
+	allowedIPs []*net.IPNet
+}
+
+// NewYooKassaProvider создаёт провайдера; allowedIPs — адреса и подсети, с которых
+// принимаются уведомления, пусто — DefaultYooKassaWebhookIPs
+func NewYooKassaProvider(client *http.Client, baseURL, shopID, secretKey string, returnURLs *ReturnURLs, allowedIPs []string) (*YooKassaProvider, error) {
+	if baseURL == "" {
+		baseURL = "https://api.yookassa.ru/v3"
+	}
+	if len(allowedIPs) == 0 {
+		allowedIPs = DefaultYooKassaWebhookIPs
+	}
+	nets := make([]*net.IPNet, 0, len(allowedIPs))
+	for _, s := range allowedIPs {
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook ip %q: %w", s, err)
+		}
+		nets = append(nets, n)
 	}
+
+	return &YooKassaProvider{
+		client:     client,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		shopID:     shopID,
+		secretKey:  secretKey,
+		returnURLs: returnURLs,
+		allowedIPs: nets,
+	}, nil
+}
+
+type yookassaAmount struct {
+	Value    string `json:"value"`
+	Currency string `json:"currency"`
+}
+
+type yookassaCancellation struct {
+	Party  string `json:"party"`
+	Reason string `json:"reason"`
 }
 
-func (y *YooMoneyProvider) ProcessPayment(ctx context.Context, payment models.Payment) (*models.PaymentResult, error) {
+type yookassaPayment struct {
+	ID           string         `json:"id"`
+	Status       string         `json:"status"` // pending, waiting_for_capture, succeeded, canceled
+	Amount       yookassaAmount `json:"amount"`
+	Confirmation *struct {
+		Type            string `json:"type"`
+		ConfirmationURL string `json:"confirmation_url"`
+	} `json:"confirmation"`
+	PaymentMethod *struct {
+		ID    string `json:"id"`
+		Saved bool   `json:"saved"`
+	} `json:"payment_method"`
+	Metadata     map[string]string     `json:"metadata"`
+	Cancellation *yookassaCancellation `json:"cancellation_details"`
+}
+
+// savedMethod — способ оплаты платежа, если YooKassa сохранила его для автоплатежей
+func (p *yookassaPayment) savedMethod() string {
+	if p.PaymentMethod == nil || !p.PaymentMethod.Saved {
+		return ""
+	}
+	return p.PaymentMethod.ID
+}
+
+// yookassaError — тело ответа с ошибкой
+type yookassaError struct {
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+
+	status int // HTTP-статус ответа с ошибкой
+}
+
+func (e *yookassaError) Error() string {
+	return fmt.Sprintf("yookassa: %s: %s", e.Code, e.Description)
+}
+
+// Is: ответ 4xx — явный отказ, ответ 5xx — исход неизвестен
+func (e *yookassaError) Is(target error) bool {
+	return target == ErrRejected && e.status >= 400 && e.status < 500
+}
+
+// ProcessPayment — POST /payments с автоматическим списанием (capture).
+// Автоплатёж по инициативе магазина отправляется без confirmation.
+func (y *YooKassaProvider) ProcessPayment(ctx context.Context, payment models.Payment) (*models.PaymentResult, error) {
 	if payment.Amount <= 0 {
-		return nil, errors.New("invalid payment amount")
+		return nil, fmt.Errorf("%w: invalid payment amount", ErrRejected)
+	}
+	if payment.PaymentMethod == "" {
+		return nil, fmt.Errorf("yookassa: %w: payment_method is required", ErrRejected)
 	}
 
-	// Имитируем успешный платеж
-	transactionID := fmt.Sprintf("yoomoney_txn_%d", payment.ID)
+	req := map[string]interface{}{
+		"amount":            formatAmount(payment.Amount, payment.Currency),
+		"capture":           true,
+		"payment_method_id": payment.PaymentMethod,
+		"description":       fmt.Sprintf("Payment %d", payment.ID),
+		"metadata":          map[string]string{"payment_id": strconv.FormatInt(payment.ID, 10)},
+	}
+	if payment.SavePaymentMethod {
+		req["save_payment_method"] = true
+	}
+	if payment.Initiator != models.PaymentInitiatorMerchant && y.returnURLs.Enabled() {
+		req["confirmation"] = map[string]string{"type": "redirect", "return_url": y.returnURLs.For(payment.ID)}
+	}
 
-	return &models.PaymentResult{
-		TransactionID: transactionID,
-		Status:        "success",
-	}, nil
+	var p yookassaPayment
+	if err := y.call(ctx, http.MethodPost, "/payments", fmt.Sprintf("payment-%d", payment.ID), req, &p); err != nil {
+		return nil, err
+	}
+	return p.result(y.Name())
+}
+
+func (p *yookassaPayment) result(provider string) (*models.PaymentResult, error) {
+	result := &models.PaymentResult{
+		TransactionID:   p.ID,
+		Provider:        provider,
+		PaymentMethodID: p.savedMethod(),
+	}
+	switch p.Status {
+	case "succeeded":
+		result.Status = models.PaymentStatusSucceeded
+	case "waiting_for_capture":
+		result.Status = models.PaymentStatusProcessing
+	case "pending":
+		result.Status = models.PaymentStatusProcessing
+		if p.Confirmation != nil && p.Confirmation.ConfirmationURL != "" {
+			result.Status = models.PaymentStatusRequiresAction
+			result.ConfirmationURL = p.Confirmation.ConfirmationURL
+		}
+	case "canceled":
+		result.Status = models.PaymentStatusFailed
+		if c := p.Cancellation; c != nil {
+			result.Error = c.Reason
+			if c.Party == "merchant" {
+				result.Status = models.PaymentStatusCanceled
+			}
+		}
+	default:
+		return nil, fmt.Errorf("yookassa: unexpected payment status %q", p.Status)
+	}
+	return result, nil
+}
+
+// GetPayment — GET /payments/{id}. Отменить платёж, ожидающий подтверждения,
+// YooKassa не даёт: неподтверждённый платёж она отменяет сама
+// (expired_on_confirmation).
+func (y *YooKassaProvider) GetPayment(ctx context.Context, providerPaymentID string) (*models.PaymentResult, error) {
+	var p yookassaPayment
+	if err := y.call(ctx, http.MethodGet, "/payments/"+url.PathEscape(providerPaymentID), "", nil, &p); err != nil {
+		return nil, err
+	}
+	return p.result(y.Name())
+}
+
+type yookassaRefund struct {
+	ID           string                `json:"id"`
+	PaymentID    string                `json:"payment_id"`
+	Status       string                `json:"status"` // pending, succeeded, canceled
+	Cancellation *yookassaCancellation `json:"cancellation_details"`
+}
+
+func (r *yookassaRefund) result() *RefundResult {
+	result := &RefundResult{ID: r.ID, ProviderPaymentID: r.PaymentID, Status: models.RefundStatusPending}
+	switch r.Status {
+	case "succeeded":
+		result.Status = models.RefundStatusSucceeded
+	case "canceled":
+		result.Status = models.RefundStatusFailed
+		result.FailureReason = "canceled"
+		if r.Cancellation != nil {
+			result.FailureReason = r.Cancellation.Reason
+		}
+	}
+	return result
+}
+
+// Refund — POST /refunds
+func (y *YooKassaProvider) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("%w: invalid refund amount", ErrRejected)
+	}
+
+	body := map[string]interface{}{
+		"payment_id": req.ProviderPaymentID,
+		"amount":     formatAmount(req.Amount, req.Currency),
+	}
+	if req.Reason != "" {
+		body["description"] = req.Reason
+	}
+
+	var refund yookassaRefund
+	if err := y.call(ctx, http.MethodPost, "/refunds", req.IdempotencyKey, body, &refund); err != nil {
+		return nil, err
+	}
+	return refund.result(), nil
+}
+
+// GetRefund — GET /refunds/{id}
+func (y *YooKassaProvider) GetRefund(ctx context.Context, providerRefundID string) (*RefundResult, error) {
+	var refund yookassaRefund
+	if err := y.call(ctx, http.MethodGet, "/refunds/"+url.PathEscape(providerRefundID), "", nil, &refund); err != nil {
+		return nil, err
+	}
+	return refund.result(), nil
+}
+
+type yookassaPayout struct {
+	ID           string                `json:"id"`
+	Status       string                `json:"status"` // pending, succeeded, canceled
+	Cancellation *yookassaCancellation `json:"cancellation_details"`
 }
 
-func (y *YooMoneyProvider) Refund(ctx context.Context, paymentID string, amount float64) error {
-	if amount <= 0 {
-		return errors.New("invalid refund amount")
+func (p *yookassaPayout) result() *PayoutResult {
+	result := &PayoutResult{ID: p.ID, Status: models.PayoutStatusProcessing}
+	switch p.Status {
+	case "succeeded":
+		result.Status = models.PayoutStatusSucceeded
+	case "canceled":
+		result.Status = models.PayoutStatusFailed
+		result.FailureReason = "canceled"
+		if p.Cancellation != nil {
+			result.FailureReason = p.Cancellation.Reason
+		}
+	}
+	return result
+}
+
+// Payout — POST /payouts. На карту выплата идёт по её токену, на кошелёк
+// ЮMoney — по номеру кошелька.
+func (y *YooKassaProvider) Payout(ctx context.Context, req PayoutRequest) (*PayoutResult, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("%w: invalid payout amount", ErrRejected)
+	}
+
+	body := map[string]interface{}{
+		"amount":   formatAmount(req.Amount, req.Currency),
+		"metadata": map[string]string{"payout_id": strconv.FormatInt(req.PayoutID, 10)},
+	}
+	switch req.DestinationType {
+	case models.PayoutDestinationCard:
+		body["payout_token"] = req.Destination
+	case models.PayoutDestinationYooMoney:
+		body["payout_destination_data"] = map[string]string{"type": "yoo_money", "account_number": req.Destination}
+	default:
+		return nil, fmt.Errorf("yookassa: %w: %s", ErrDestinationUnsupported, req.DestinationType)
+	}
+	if req.Description != "" {
+		body["description"] = req.Description
+	}
+
+	var payout yookassaPayout
+	if err := y.call(ctx, http.MethodPost, "/payouts", req.IdempotencyKey, body, &payout); err != nil {
+		return nil, err
+	}
+	return payout.result(), nil
+}
+
+// GetPayout — GET /payouts/{id}
+func (y *YooKassaProvider) GetPayout(ctx context.Context, providerPayoutID string) (*PayoutResult, error) {
+	var payout yookassaPayout
+	if err := y.call(ctx, http.MethodGet, "/payouts/"+url.PathEscape(providerPayoutID), "", nil, &payout); err != nil {
+		return nil, err
+	}
+	return payout.result(), nil
+}
+
+func (y *YooKassaProvider) Name() string {
+	return "yookassa"
+}
+
+// call отправляет запрос с Basic-авторизацией shopId:secretKey.
+// Idempotence-Key обязателен для всех POST-запросов YooKassa.
+func (y *YooKassaProvider) call(ctx context.Context, method, path, idempotenceKey string, payload, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, y.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(y.shopID, y.secretKey)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotenceKey != "" {
+		req.Header.Set("Idempotence-Key", idempotenceKey)
+	}
+
+	resp, err := y.client.Do(req)
+	if err != nil {
+		if connectFailed(err) {
+			return fmt.Errorf("yookassa: %w: %v", ErrUnavailable, err)
+		}
+		return fmt.Errorf("yookassa: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if unavailableStatus(resp.StatusCode) {
+		return fmt.Errorf("yookassa: %w: status %d", ErrUnavailable, resp.StatusCode)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		var e yookassaError
+		if json.Unmarshal(raw, &e) == nil && e.Code != "" {
+			e.status = resp.StatusCode
+			return &e
+		}
+		if resp.StatusCode < 500 {
+			return fmt.Errorf("yookassa: %w: %s failed with status %d", ErrRejected, path, resp.StatusCode)
+		}
+		return fmt.Errorf("yookassa: %s failed with status %d", path, resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("yookassa: unexpected response (status %d)", resp.StatusCode)
 	}
-	fmt.Printf("YooMoney: refunded %.2f for %s\n", amount, paymentID)
 	return nil
 }
 
-func (y *YooMoneyProvider) Name() string {
-	return "yoomoney"
+func formatAmount(amount float64, currency string) yookassaAmount {
+	if currency == "" {
+		currency = "RUB"
+	}
+	return yookassaAmount{Value: strconv.FormatFloat(float64(minorUnits(amount))/100, 'f', 2, 64), Currency: currency}
+}
+
+type yookassaNotification struct {
+	Type   string          `json:"type"`
+	Event  string          `json:"event"`
+	Object json.RawMessage `json:"object"` // платёж или возврат — по префиксу event
+}
+
+// ParseWebhook принимает только запросы с адресов YooKassa. Подписи у
+// уведомлений нет, а за обратным прокси адрес отправителя подделать проще,
+// поэтому уведомление — только повод: объект запрашивается у YooKassa, и
+// применяется статус из её ответа, а не из тела уведомления.
+func (y *YooKassaProvider) ParseWebhook(r *http.Request, body []byte) (*WebhookEvent, error) {
+	if !y.allowed(r.RemoteAddr) {
+		return nil, ErrInvalidSignature
+	}
+
+	var n yookassaNotification
+	var object struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &n); err != nil {
+		return nil, fmt.Errorf("invalid yookassa notification: %w", err)
+	}
+	if len(n.Object) > 0 {
+		_ = json.Unmarshal(n.Object, &object)
+	}
+	if n.Type != "notification" || n.Event == "" || object.ID == "" {
+		return nil, errors.New("invalid yookassa notification: missing type, event or object id")
+	}
+
+	ctx := r.Context()
+	event := &WebhookEvent{Type: n.Event}
+	var status string
+	switch {
+	case strings.HasPrefix(n.Event, "refund."):
+		var refund yookassaRefund
+		if err := y.fetch(ctx, "/refunds/", object.ID, &refund); err != nil {
+			return nil, err
+		}
+		event.ProviderPaymentID = refund.PaymentID
+		event.Refund = refund.result()
+		status = refund.Status
+	case strings.HasPrefix(n.Event, "payout."):
+		var payout yookassaPayout
+		if err := y.fetch(ctx, "/payouts/", object.ID, &payout); err != nil {
+			return nil, err
+		}
+		event.Payout = payout.result()
+		status = payout.Status
+	case strings.HasPrefix(n.Event, "payment."):
+		var p yookassaPayment
+		if err := y.fetch(ctx, "/payments/", object.ID, &p); err != nil {
+			return nil, err
+		}
+		result, err := p.result(y.Name())
+		if err != nil {
+			return nil, err
+		}
+		event.ProviderPaymentID = p.ID
+		event.PaymentMethodID = p.savedMethod()
+		event.Currency = p.Amount.Currency
+		event.Amount, _ = strconv.ParseFloat(p.Amount.Value, 64)
+		if id, err := strconv.ParseInt(p.Metadata["payment_id"], 10, 64); err == nil {
+			event.PaymentID = id
+		}
+		event.Status = result.Status
+		event.FailureReason = result.Error
+		status = p.Status
+	}
+
+	// у уведомлений YooKassa нет собственного ID: повтором считается то же
+	// событие по объекту в том же статусе
+	event.ID = n.Event + ":" + object.ID + ":" + status
+	return event, nil
+}
+
+// fetch запрашивает объект из уведомления. Объекта, о котором YooKassa не
+// знает, не существует — уведомление поддельное.
+func (y *YooKassaProvider) fetch(ctx context.Context, path, id string, out interface{}) error {
+	err := y.call(ctx, http.MethodGet, path+url.PathEscape(id), "", nil, out)
+	var e *yookassaError
+	if errors.As(err, &e) && e.Code == "not_found" {
+		return fmt.Errorf("%w: yookassa object %s not found", ErrInvalidSignature, id)
+	}
+	return err
+}
+
+func (y *YooKassaProvider) allowed(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range y.allowedIPs {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
 }
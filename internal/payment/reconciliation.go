@@ -0,0 +1,346 @@
+package payment
+
+import (
+	"bank-api/internal/models"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrBreakResolved      = errors.New("reconciliation break is already resolved")
+	ErrBreakNotCreditable = errors.New("reconciliation break cannot be resolved by crediting")
+	ErrInvalidBreakAction = errors.New("action must be credit or accept")
+	ErrBreakNoteRequired  = errors.New("note is required to accept a break")
+)
+
+// через сколько успешный, но не зачисленный платёж считается расхождением:
+// зачисление идёт сразу после смены статуса
+const uncreditedAfter = 10 * time.Minute
+
+// ReconciliationConfig — где лежат отчёты провайдеров и сколько ждать расчёта
+type ReconciliationConfig struct {
+	Dir           string        // отчёты лежат в Dir/<провайдер>/*.csv; пусто — импорт выключен
+	SettlementLag time.Duration // за сколько операция попадает в отчёт провайдера
+}
+
+// ReconciliationSummary — итог прогона сверки
+type ReconciliationSummary struct {
+	FilesImported   int      `json:"files_imported"`
+	EntriesImported int      `json:"entries_imported"`
+	EntriesMatched  int      `json:"entries_matched"`
+	BreaksCreated   int      `json:"breaks_created"`
+	FailedFiles     []string `json:"failed_files,omitempty"`
+}
+
+// BreakResolution — решение оператора по расхождению: credit зачисляет
+// платёж, который провайдер провёл, а мы нет; accept закрывает расхождение
+// с обязательным комментарием без проводок.
+type BreakResolution struct {
+	Action string `json:"action"`
+	Note   string `json:"note"`
+}
+
+// ReconciliationService сверяет платежи, возвраты и выплаты с отчётами
+// провайдеров о расчётах. Расхождения заводятся один раз и разбираются
+// оператором.
+type ReconciliationService struct {
+	repo     *ReconciliationRepository
+	payments *PaymentService
+	cfg      ReconciliationConfig
+	mu       sync.Mutex // прогоны сверки не пересекаются
+}
+
+func NewReconciliationService(repo *ReconciliationRepository, payments *PaymentService, cfg ReconciliationConfig) *ReconciliationService {
+	if cfg.SettlementLag <= 0 {
+		cfg.SettlementLag = 72 * time.Hour
+	}
+	return &ReconciliationService{repo: repo, payments: payments, cfg: cfg}
+}
+
+// Run — прогон сверки по расписанию
+func (s *ReconciliationService) Run(ctx context.Context) error {
+	summary, err := s.Reconcile(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Sugared().Infof("reconciliation: %d files, %d entries imported, %d matched, %d new breaks",
+		summary.FilesImported, summary.EntriesImported, summary.EntriesMatched, summary.BreaksCreated)
+	return nil
+}
+
+// Reconcile импортирует новые отчёты, сверяет их строки с нашими
+// операциями и ищет операции, которых нет у провайдера или в журнале.
+func (s *ReconciliationService) Reconcile(ctx context.Context) (*ReconciliationSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary := &ReconciliationSummary{}
+	if err := s.importFiles(ctx, summary); err != nil {
+		return nil, err
+	}
+
+	for {
+		entries, err := s.repo.ListUnreconciledEntries(ctx, 500)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		for i := range entries {
+			matched, created, err := s.reconcileEntry(ctx, &entries[i])
+			if err != nil {
+				return nil, fmt.Errorf("settlement entry %d: %w", entries[i].ID, err)
+			}
+			if matched {
+				summary.EntriesMatched++
+			}
+			if created {
+				summary.BreaksCreated++
+			}
+		}
+	}
+
+	n, err := s.repo.FlagUncreditedPayments(ctx, time.Now().Add(-uncreditedAfter))
+	if err != nil {
+		return nil, err
+	}
+	summary.BreaksCreated += n
+
+	for _, provider := range settlementProviders() {
+		n, err := s.repo.FlagMissingAtProvider(ctx, provider, s.cfg.SettlementLag)
+		if err != nil {
+			return nil, err
+		}
+		summary.BreaksCreated += n
+	}
+	return summary, nil
+}
+
+// importFiles импортирует файлы из каталогов провайдеров. Файл с ошибкой
+// пропускается и попадает в summary.FailedFiles; после исправления он
+// импортируется при следующем прогоне.
+func (s *ReconciliationService) importFiles(ctx context.Context, summary *ReconciliationSummary) error {
+	if s.cfg.Dir == "" {
+		return nil
+	}
+	for _, provider := range settlementProviders() {
+		paths, err := filepath.Glob(filepath.Join(s.cfg.Dir, provider, "*.csv"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			file, imported, err := s.importFile(ctx, provider, path)
+			if err != nil {
+				logger.Sugared().Errorf("reconciliation: %s: %v", path, err)
+				summary.FailedFiles = append(summary.FailedFiles, path)
+				continue
+			}
+			if imported {
+				summary.FilesImported++
+				summary.EntriesImported += file.Entries
+			}
+		}
+	}
+	return nil
+}
+
+// importFile импортирует один файл отчёта. false — файл уже импортирован.
+func (s *ReconciliationService) importFile(ctx context.Context, provider, path string) (*models.SettlementFile, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	entries, err := parseSettlementFile(provider, data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	sum := sha256.Sum256(data)
+	file := &models.SettlementFile{Provider: provider, Name: filepath.Base(path), Checksum: hex.EncodeToString(sum[:])}
+	imported, err := s.repo.ImportSettlementFile(ctx, file, entries)
+	return file, imported, err
+}
+
+func settlementProviders() []string {
+	names := make([]string, 0, len(settlementFormats))
+	for name := range settlementFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reconcileEntry сверяет строку отчёта с нашей операцией: matched — всё
+// сошлось, created — заведено новое расхождение.
+func (s *ReconciliationService) reconcileEntry(ctx context.Context, entry *models.SettlementEntry) (matched, created bool, err error) {
+	rec, err := s.repo.FindRecord(ctx, entry.Provider, entry.Kind, entry.ProviderObjectID)
+	if err != nil {
+		return false, false, err
+	}
+	var internalID *int64
+	if rec != nil {
+		internalID = &rec.ID
+	}
+	brk := classifyEntry(entry, rec)
+	created, err = s.repo.ReconcileEntry(ctx, entry, internalID, brk)
+	return brk == nil, created, err
+}
+
+// classifyEntry сравнивает строку отчёта с нашей операцией; nil — сошлось
+func classifyEntry(entry *models.SettlementEntry, rec *settlementRecord) *models.ReconciliationBreak {
+	settled := entry.Amount
+	brk := &models.ReconciliationBreak{
+		Provider:         entry.Provider,
+		Kind:             entry.Kind,
+		ProviderObjectID: entry.ProviderObjectID,
+		SettledAmount:    &settled,
+		Currency:         entry.Currency,
+	}
+	if rec == nil {
+		brk.Type = models.BreakMissingInternal
+		brk.Details = fmt.Sprintf("no %s with this provider ID", entry.Kind)
+		return brk
+	}
+
+	amount := rec.Amount
+	brk.InternalAmount = &amount
+	switch {
+	case !settledStatus(entry.Kind, rec.Status):
+		brk.Type = models.BreakMissingInternal
+		brk.Details = fmt.Sprintf("settled by provider, but %s %d is %s", entry.Kind, rec.ID, rec.Status)
+	case !rec.Posted:
+		brk.Type = models.BreakMissingInternal
+		brk.Details = fmt.Sprintf("%s %d is %s, but was not posted to the account", entry.Kind, rec.ID, rec.Status)
+	case amountsDiffer(rec.Amount, entry.Amount) || rec.Currency != entry.Currency:
+		brk.Type = models.BreakAmountMismatch
+		brk.Details = fmt.Sprintf("%s %d: %.2f %s internally, %.2f %s settled",
+			entry.Kind, rec.ID, rec.Amount, rec.Currency, entry.Amount, entry.Currency)
+	default:
+		return nil
+	}
+	return brk
+}
+
+// settledStatus — статус нашей операции, с которым она должна быть в отчёте провайдера
+func settledStatus(kind, status string) bool {
+	if kind == models.SettlementKindPayment {
+		switch status {
+		case models.PaymentStatusSucceeded, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded:
+			return true
+		}
+		return false
+	}
+	// у возвратов и выплат один успешный статус
+	return status == models.RefundStatusSucceeded
+}
+
+// CreditSettled зачисляет на счёт платёж, который провайдер провёл, а мы не
+// зачислили. Незавершённый платёж сначала переводится в succeeded; платёж,
+// уже признанный неуспешным, зачислить нельзя.
+func (s *PaymentService) CreditSettled(ctx context.Context, paymentID int64) (*models.Payment, error) {
+	p, err := s.repo.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if p.TransactionID != "" {
+		return p, nil
+	}
+
+	switch p.Status {
+	case models.PaymentStatusSucceeded, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded:
+		s.credit(ctx, p)
+	default:
+		if !CanTransition(p.Status, models.PaymentStatusSucceeded) {
+			return nil, fmt.Errorf("%w: payment is %s", ErrBreakNotCreditable, p.Status)
+		}
+		if err := s.apply(ctx, p, models.PaymentStatusSucceeded, "confirmed by provider settlement report", SourceSystem); err != nil {
+			return nil, err
+		}
+	}
+	if p.TransactionID == "" {
+		return nil, fmt.Errorf("payment %d: failed to credit account %d", p.ID, p.AccountID)
+	}
+	return p, nil
+}
+
+func (s *ReconciliationService) ListBreaks(ctx context.Context, status, provider, breakType string) ([]models.ReconciliationBreak, error) {
+	return s.repo.ListBreaks(ctx, status, provider, breakType, 500)
+}
+
+func (s *ReconciliationService) GetBreak(ctx context.Context, id int64) (*models.ReconciliationBreak, error) {
+	return s.repo.GetBreak(ctx, id)
+}
+
+// ResolveBreak разбирает расхождение. Расхождение закрывается до
+// зачисления, чтобы два оператора не зачислили платёж дважды; если
+// зачислить не удалось, оно снова открывается.
+func (s *ReconciliationService) ResolveBreak(ctx context.Context, adminID, id int64, req BreakResolution) (*models.ReconciliationBreak, error) {
+	brk, err := s.repo.GetBreak(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if brk.Status != models.BreakStatusOpen {
+		return nil, ErrBreakResolved
+	}
+
+	var resolution string
+	switch req.Action {
+	case "credit":
+		if brk.Type != models.BreakMissingInternal || brk.Kind != models.SettlementKindPayment || brk.InternalID == nil {
+			return nil, fmt.Errorf("%w: only a payment missing from the ledger can be credited", ErrBreakNotCreditable)
+		}
+		resolution = models.BreakResolutionCredited
+	case "accept":
+		if req.Note == "" {
+			return nil, ErrBreakNoteRequired
+		}
+		resolution = models.BreakResolutionAccepted
+	default:
+		return nil, ErrInvalidBreakAction
+	}
+
+	ok, err := s.repo.ResolveBreak(ctx, id, resolution, req.Note, adminID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrBreakResolved
+	}
+
+	if resolution == models.BreakResolutionCredited {
+		p, err := s.payments.CreditSettled(ctx, *brk.InternalID)
+		if err != nil {
+			if rerr := s.repo.ReopenBreak(ctx, id); rerr != nil {
+				logger.Sugared().Errorf("reconciliation break %d: failed to reopen: %v", id, rerr)
+			}
+			return nil, err
+		}
+		transactionID, err := strconv.ParseInt(p.TransactionID, 10, 64)
+		if err == nil {
+			err = s.repo.SetBreakTransaction(ctx, id, transactionID)
+		}
+		if err != nil {
+			logger.Sugared().Errorf("reconciliation break %d: failed to link transaction %s: %v", id, p.TransactionID, err)
+		}
+	}
+	return s.repo.GetBreak(ctx, id)
+}
+
+// amountsDiffer — суммы расходятся больше чем на копейку округления
+func amountsDiffer(a, b float64) bool {
+	return math.Abs(a-b) >= 0.005
+}
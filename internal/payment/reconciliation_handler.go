@@ -0,0 +1,108 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bank-api/internal/middleware"
+	"bank-api/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type ReconciliationHandler struct {
+	reconciliationService *ReconciliationService
+}
+
+func NewReconciliationHandler(reconciliationService *ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{reconciliationService: reconciliationService}
+}
+
+// POST /admin/reconciliation/run
+// Запускает сверку, не дожидаясь расписания, например после выкладки нового отчёта.
+func (h *ReconciliationHandler) Run(w http.ResponseWriter, r *http.Request) {
+	summary, err := h.reconciliationService.Reconcile(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, reconciliationErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, summary)
+}
+
+// GET /admin/reconciliation/breaks?status=open&provider=stripe&type=amount_mismatch
+// По умолчанию возвращаются открытые расхождения; status=all — все.
+func (h *ReconciliationHandler) ListBreaks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	status := query.Get("status")
+	switch status {
+	case "":
+		status = "open"
+	case "all":
+		status = ""
+	}
+
+	breaks, err := h.reconciliationService.ListBreaks(r.Context(), status, query.Get("provider"), query.Get("type"))
+	if err != nil {
+		utils.RespondJSON(w, reconciliationErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, breaks)
+}
+
+// GET /admin/reconciliation/breaks/{id}
+func (h *ReconciliationHandler) GetBreak(w http.ResponseWriter, r *http.Request) {
+	breakID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid break ID"})
+		return
+	}
+
+	brk, err := h.reconciliationService.GetBreak(r.Context(), breakID)
+	if err != nil {
+		utils.RespondJSON(w, reconciliationErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, brk)
+}
+
+// POST /admin/reconciliation/breaks/{id}/resolve
+func (h *ReconciliationHandler) ResolveBreak(w http.ResponseWriter, r *http.Request) {
+	breakID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid break ID"})
+		return
+	}
+
+	adminID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var req BreakResolution
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid input"})
+		return
+	}
+
+	brk, err := h.reconciliationService.ResolveBreak(r.Context(), adminID, breakID, req)
+	if err != nil {
+		utils.RespondJSON(w, reconciliationErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, brk)
+}
+
+func reconciliationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrBreakNotFound), errors.Is(err, ErrPaymentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidBreakAction), errors.Is(err, ErrBreakNoteRequired):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrBreakResolved), errors.Is(err, ErrBreakNotCreditable), errors.Is(err, ErrInvalidTransition):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
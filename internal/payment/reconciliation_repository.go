@@ -0,0 +1,311 @@
+package payment
+
+import (
+	"bank-api/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrBreakNotFound = errors.New("reconciliation break not found")
+
+type ReconciliationRepository struct {
+	DB *sqlx.DB
+}
+
+func NewReconciliationRepository(db *sqlx.DB) *ReconciliationRepository {
+	return &ReconciliationRepository{DB: db}
+}
+
+const settlementEntryColumns = `
+	id, file_id, provider, kind, provider_object_id, amount, fee, currency, settled_at, internal_id, reconciled_at
+`
+
+const breakColumns = `
+	id, provider, kind, type, provider_object_id, entry_id, internal_id, internal_amount, settled_amount,
+	currency, details, status, COALESCE(resolution, '') AS resolution, COALESCE(note, '') AS note,
+	transaction_id, resolved_by, resolved_at, created_at, updated_at
+`
+
+// settlementRecord — наша операция, с которой сверяется строка отчёта
+type settlementRecord struct {
+	ID       int64   `db:"id"`
+	Amount   float64 `db:"amount"`
+	Currency string  `db:"currency"`
+	Status   string  `db:"status"`
+	Posted   bool    `db:"posted"` // операция проведена по счёту
+}
+
+// ImportSettlementFile сохраняет файл отчёта и его строки. Строки, уже
+// пришедшие в другом файле, пропускаются. false — файл с таким содержимым
+// уже импортирован.
+func (r *ReconciliationRepository) ImportSettlementFile(ctx context.Context, file *models.SettlementFile, entries []models.SettlementEntry) (bool, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO settlement_files (provider, name, checksum)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (provider, checksum) DO NOTHING
+		RETURNING id, imported_at
+	`, file.Provider, file.Name, file.Checksum).Scan(&file.ID, &file.ImportedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	file.Entries = 0
+	for _, e := range entries {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO settlement_entries (file_id, provider, kind, provider_object_id, amount, fee, currency, settled_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (provider, kind, provider_object_id) DO NOTHING
+		`, file.ID, e.Provider, e.Kind, e.ProviderObjectID, e.Amount, e.Fee, e.Currency, e.SettledAt)
+		if err != nil {
+			return false, err
+		}
+		n, _ := result.RowsAffected()
+		file.Entries += int(n)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE settlement_files SET entries = $1 WHERE id = $2`, file.Entries, file.ID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// ListUnreconciledEntries возвращает строки отчётов, ещё не сверенные с нашими данными
+func (r *ReconciliationRepository) ListUnreconciledEntries(ctx context.Context, limit int) ([]models.SettlementEntry, error) {
+	var list []models.SettlementEntry
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT `+settlementEntryColumns+` FROM settlement_entries
+		WHERE reconciled_at IS NULL
+		ORDER BY id
+		LIMIT $1
+	`, limit)
+	return list, err
+}
+
+// FindRecord ищет нашу операцию по её ID у провайдера, nil — не найдена
+func (r *ReconciliationRepository) FindRecord(ctx context.Context, provider, kind, providerObjectID string) (*settlementRecord, error) {
+	var query string
+	switch kind {
+	case models.SettlementKindPayment:
+		query = `
+			SELECT id, amount, currency, status, COALESCE(transaction_id, '') <> '' AS posted
+			FROM payments WHERE provider = $1 AND provider_payment_id = $2`
+	case models.SettlementKindRefund:
+		query = `
+			SELECT r.id, r.amount, r.currency, r.status, r.transaction_id IS NOT NULL AS posted
+			FROM refunds r JOIN payments p ON p.id = r.payment_id
+			WHERE p.provider = $1 AND r.provider_refund_id = $2`
+	case models.SettlementKindPayout:
+		query = `
+			SELECT id, amount, currency, status, transaction_id IS NOT NULL AS posted
+			FROM payouts WHERE provider = $1 AND provider_payout_id = $2`
+	default:
+		return nil, nil
+	}
+
+	var rec settlementRecord
+	err := r.DB.GetContext(ctx, &rec, query, provider, providerObjectID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// ReconcileEntry отмечает строку отчёта сверенной. Если brk не nil,
+// заводится расхождение; если у операции уже есть такое расхождение без
+// строки отчёта (найденное проверкой журнала), строка привязывается к нему.
+// Сошедшаяся строка закрывает расхождение missing_at_provider по той же
+// операции. true — заведено новое расхождение.
+func (r *ReconciliationRepository) ReconcileEntry(ctx context.Context, entry *models.SettlementEntry, internalID *int64, brk *models.ReconciliationBreak) (bool, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE settlement_entries SET internal_id = $1, reconciled_at = NOW() WHERE id = $2
+	`, internalID, entry.ID)
+	if err != nil {
+		return false, err
+	}
+
+	created := false
+	if brk != nil {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO reconciliation_breaks (provider, kind, type, provider_object_id, entry_id, internal_id,
+				internal_amount, settled_amount, currency, details)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT DO NOTHING
+		`, brk.Provider, brk.Kind, brk.Type, brk.ProviderObjectID, entry.ID, internalID,
+			brk.InternalAmount, brk.SettledAmount, brk.Currency, brk.Details)
+		if err != nil {
+			return false, err
+		}
+		n, _ := result.RowsAffected()
+		created = n > 0
+		if !created && internalID != nil {
+			_, err = tx.ExecContext(ctx, `
+				UPDATE reconciliation_breaks
+				SET entry_id = $1, provider_object_id = $2, settled_amount = $3, updated_at = NOW()
+				WHERE kind = $4 AND internal_id = $5 AND type = $6 AND entry_id IS NULL
+			`, entry.ID, entry.ProviderObjectID, entry.Amount, entry.Kind, *internalID, brk.Type)
+			if err != nil {
+				return false, err
+			}
+		}
+	} else if internalID != nil {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE reconciliation_breaks
+			SET status = 'resolved', resolution = 'matched', entry_id = $1, provider_object_id = $2,
+				settled_amount = $3, resolved_at = NOW(), updated_at = NOW()
+			WHERE kind = $4 AND internal_id = $5 AND type = 'missing_at_provider' AND status = 'open'
+		`, entry.ID, entry.ProviderObjectID, entry.Amount, entry.Kind, *internalID)
+		if err != nil {
+			return false, err
+		}
+	}
+	return created, tx.Commit()
+}
+
+// FlagUncreditedPayments заводит расхождения по платежам, успешным у
+// провайдера, но не зачисленным на счёт до before. Возвращает число новых.
+func (r *ReconciliationRepository) FlagUncreditedPayments(ctx context.Context, before time.Time) (int, error) {
+	result, err := r.DB.ExecContext(ctx, `
+		INSERT INTO reconciliation_breaks (provider, kind, type, provider_object_id, internal_id, internal_amount,
+			currency, details)
+		SELECT provider, 'payment', 'missing_internal', COALESCE(provider_payment_id, ''), id, amount,
+			currency, 'payment succeeded but was not credited to the account'
+		FROM payments
+		WHERE status IN ('succeeded', 'partially_refunded', 'refunded') AND COALESCE(transaction_id, '') = ''
+			AND COALESCE(status_changed_at, updated_at) < $1
+		ON CONFLICT DO NOTHING
+	`, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// FlagMissingAtProvider заводит расхождения по нашим успешным операциям
+// провайдера, которых нет в его отчётах. Проверяется только период, который
+// отчёты покрывают, без последних lag: операции этого хвоста могут прийти
+// в следующем отчёте. Возвращает число новых расхождений.
+func (r *ReconciliationRepository) FlagMissingAtProvider(ctx context.Context, provider string, lag time.Duration) (int, error) {
+	const window = `
+		(SELECT MIN(settled_at) AS from_at, MAX(settled_at) - make_interval(secs => $2) AS to_at
+		 FROM settlement_entries WHERE provider = $1) w`
+	queries := []string{`
+		INSERT INTO reconciliation_breaks (provider, kind, type, provider_object_id, internal_id, internal_amount,
+			currency, details)
+		SELECT p.provider, 'payment', 'missing_at_provider', COALESCE(p.provider_payment_id, ''), p.id, p.amount,
+			p.currency, 'succeeded payment is missing from provider settlement reports'
+		FROM payments p, ` + window + `
+		WHERE p.provider = $1 AND p.status IN ('succeeded', 'partially_refunded', 'refunded')
+			AND p.created_at >= w.from_at AND p.created_at < w.to_at
+			AND NOT EXISTS (SELECT 1 FROM settlement_entries e WHERE e.kind = 'payment' AND e.internal_id = p.id)
+		ON CONFLICT DO NOTHING`, `
+		INSERT INTO reconciliation_breaks (provider, kind, type, provider_object_id, internal_id, internal_amount,
+			currency, details)
+		SELECT p.provider, 'refund', 'missing_at_provider', COALESCE(r.provider_refund_id, ''), r.id, r.amount,
+			r.currency, 'succeeded refund is missing from provider settlement reports'
+		FROM refunds r JOIN payments p ON p.id = r.payment_id, ` + window + `
+		WHERE p.provider = $1 AND r.status = 'succeeded' AND r.provider_refund_id IS NOT NULL
+			AND r.created_at >= w.from_at AND r.created_at < w.to_at
+			AND NOT EXISTS (SELECT 1 FROM settlement_entries e WHERE e.kind = 'refund' AND e.internal_id = r.id)
+		ON CONFLICT DO NOTHING`, `
+		INSERT INTO reconciliation_breaks (provider, kind, type, provider_object_id, internal_id, internal_amount,
+			currency, details)
+		SELECT o.provider, 'payout', 'missing_at_provider', COALESCE(o.provider_payout_id, ''), o.id, o.amount,
+			o.currency, 'succeeded payout is missing from provider settlement reports'
+		FROM payouts o, ` + window + `
+		WHERE o.provider = $1 AND o.status = 'succeeded'
+			AND o.created_at >= w.from_at AND o.created_at < w.to_at
+			AND NOT EXISTS (SELECT 1 FROM settlement_entries e WHERE e.kind = 'payout' AND e.internal_id = o.id)
+		ON CONFLICT DO NOTHING`,
+	}
+
+	total := 0
+	for _, query := range queries {
+		result, err := r.DB.ExecContext(ctx, query, provider, lag.Seconds())
+		if err != nil {
+			return total, err
+		}
+		n, _ := result.RowsAffected()
+		total += int(n)
+	}
+	return total, nil
+}
+
+// ListBreaks возвращает расхождения, новые первыми; пустой фильтр не ограничивает выборку
+func (r *ReconciliationRepository) ListBreaks(ctx context.Context, status, provider, breakType string, limit int) ([]models.ReconciliationBreak, error) {
+	var list []models.ReconciliationBreak
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT `+breakColumns+` FROM reconciliation_breaks
+		WHERE ($1 = '' OR status = $1) AND ($2 = '' OR provider = $2) AND ($3 = '' OR type = $3)
+		ORDER BY id DESC
+		LIMIT $4
+	`, status, provider, breakType, limit)
+	return list, err
+}
+
+func (r *ReconciliationRepository) GetBreak(ctx context.Context, id int64) (*models.ReconciliationBreak, error) {
+	var b models.ReconciliationBreak
+	err := r.DB.GetContext(ctx, &b, `SELECT `+breakColumns+` FROM reconciliation_breaks WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBreakNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// ResolveBreak закрывает открытое расхождение. false — его уже закрыли.
+func (r *ReconciliationRepository) ResolveBreak(ctx context.Context, id int64, resolution, note string, resolvedBy int64) (bool, error) {
+	result, err := r.DB.ExecContext(ctx, `
+		UPDATE reconciliation_breaks
+		SET status = 'resolved', resolution = $1, note = NULLIF($2, ''), resolved_by = $3,
+			resolved_at = NOW(), updated_at = NOW()
+		WHERE id = $4 AND status = 'open'
+	`, resolution, note, resolvedBy, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ReopenBreak возвращает расхождение в работу, если разбор не удался
+func (r *ReconciliationRepository) ReopenBreak(ctx context.Context, id int64) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE reconciliation_breaks
+		SET status = 'open', resolution = NULL, note = NULL, resolved_by = NULL, resolved_at = NULL, updated_at = NOW()
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+// SetBreakTransaction связывает расхождение с проводкой, сделанной при разборе
+func (r *ReconciliationRepository) SetBreakTransaction(ctx context.Context, id, transactionID int64) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE reconciliation_breaks SET transaction_id = $1, updated_at = NOW() WHERE id = $2
+	`, transactionID, id)
+	return err
+}
@@ -0,0 +1,384 @@
+package payment
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/payment/providers"
+	"bank-api/internal/service"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrRecurringInactive        = errors.New("recurring payment is not active")
+	ErrRecurringChargeInFlight  = errors.New("previous charge is still in progress")
+	ErrPaymentMethodUnavailable = errors.New("payment method not found or inactive")
+)
+
+// на сколько откладывается подписка, списание по которой не удалось начать
+const recurringLease = time.Hour
+
+// отказы, после которых повторять списание бессмысленно: нужен новый способ оплаты
+var hardDeclines = map[string]bool{
+	"expired_card":            true,
+	"card_expired":            true,
+	"lost_card":               true,
+	"stolen_card":             true,
+	"authentication_required": true,
+	"3d_secure_failed":        true,
+	"permission_revoked":      true,
+}
+
+// RecurringConfig — повторы неудачных списаний
+type RecurringConfig struct {
+	MaxFailures    int             // неудач подряд до отключения подписки
+	RetryIntervals []time.Duration // паузы перед повторами; последняя повторяется
+}
+
+// RecurringRequest — создание регулярного списания
+type RecurringRequest struct {
+	AccountID       int64   `json:"account_id"`
+	PaymentMethodID int64   `json:"payment_method_id"`
+	Amount          float64 `json:"amount"`
+	Currency        string  `json:"currency"`
+	Period          string  `json:"period"` // day, week, month; пусто — только по запросу
+	Description     string  `json:"description"`
+	Provider        string  `json:"provider"` // для способов без провайдера; иначе — провайдер, выпустивший токен
+}
+
+// RecurringService — регулярные пополнения и подписки. Первый платёж клиент
+// проводит сам (с 3-D Secure, если нужно) и разрешает провайдеру сохранить
+// способ оплаты; дальнейшие списания банк инициирует без клиента через
+// PaymentService.ProcessPayment. Исходы списаний приходят в onPayment.
+type RecurringService struct {
+	repo          *RecurringRepository
+	payments      *PaymentService
+	methods       *service.PaymentMethodService
+	notifications *service.NotificationService
+	cfg           RecurringConfig
+}
+
+func NewRecurringService(
+	repo *RecurringRepository,
+	payments *PaymentService,
+	methods *service.PaymentMethodService,
+	notifications *service.NotificationService,
+	cfg RecurringConfig,
+) *RecurringService {
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = 4
+	}
+	if len(cfg.RetryIntervals) == 0 {
+		cfg.RetryIntervals = []time.Duration{24 * time.Hour, 72 * time.Hour, 120 * time.Hour}
+	}
+	s := &RecurringService{
+		repo:          repo,
+		payments:      payments,
+		methods:       methods,
+		notifications: notifications,
+		cfg:           cfg,
+	}
+	payments.AddHook(s.onPayment)
+	methods.AddDeleteHook(s.onMethodDeleted)
+	return s
+}
+
+// CreateRecurring создаёт подписку и проводит первый платёж, которым клиент
+// разрешает списания. Подписка включается, когда этот платёж пройдёт.
+func (s *RecurringService) CreateRecurring(ctx context.Context, userID int64, req RecurringRequest) (*models.RecurringPayment, *models.Payment, error) {
+	if req.Amount <= 0 {
+		return nil, nil, errors.New("amount must be positive")
+	}
+	switch req.Period {
+	case "", models.RecurringPeriodDay, models.RecurringPeriodWeek, models.RecurringPeriodMonth:
+	default:
+		return nil, nil, errors.New("period must be day, week or month")
+	}
+	if req.Currency == "" {
+		req.Currency = "RUB"
+	}
+
+	method, err := s.methods.GetPaymentMethod(ctx, userID, req.PaymentMethodID)
+	if errors.Is(err, service.ErrPaymentMethodNotFound) {
+		return nil, nil, ErrPaymentMethodUnavailable
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if method.Expired {
+		return nil, nil, fmt.Errorf("%w: expired", ErrPaymentMethodUnavailable)
+	}
+	// токен действует только у выпустившего его провайдера
+	provider := method.Provider
+	if provider == "" {
+		provider = req.Provider
+	}
+
+	rp := &models.RecurringPayment{
+		UserID:          userID,
+		AccountID:       req.AccountID,
+		PaymentMethodID: method.ID,
+		Amount:          req.Amount,
+		Currency:        req.Currency,
+		Period:          req.Period,
+		Description:     req.Description,
+		Status:          models.RecurringStatusPendingAuthorization,
+		MaxFailures:     s.cfg.MaxFailures,
+	}
+	if err := s.repo.CreateRecurring(ctx, rp); err != nil {
+		return nil, nil, err
+	}
+
+	p, err := s.payments.ProcessPayment(ctx, models.Payment{
+		UserID:            userID,
+		AccountID:         rp.AccountID,
+		Amount:            rp.Amount,
+		Currency:          rp.Currency,
+		Provider:          provider,
+		PaymentMethod:     method.Token,
+		SavePaymentMethod: true,
+		Initiator:         models.PaymentInitiatorCustomer,
+		RecurringID:       &rp.ID,
+	})
+	if err != nil {
+		// платёж мог не создаться вовсе — тогда onPayment не вызывался
+		if _, cerr := s.repo.Cancel(ctx, rp.ID, "authorization failed: "+err.Error()); cerr != nil {
+			logger.Sugared().Errorf("recurring %d: failed to cancel: %v", rp.ID, cerr)
+		}
+		return nil, nil, err
+	}
+
+	rp, err = s.repo.GetRecurring(ctx, rp.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rp, p, nil
+}
+
+func (s *RecurringService) GetRecurring(ctx context.Context, userID, id int64) (*models.RecurringPayment, error) {
+	rp, err := s.repo.GetRecurring(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rp.UserID != userID {
+		return nil, ErrRecurringNotFound
+	}
+	return rp, nil
+}
+
+func (s *RecurringService) ListRecurring(ctx context.Context, userID int64) ([]models.RecurringPayment, error) {
+	return s.repo.ListRecurringByUser(ctx, userID)
+}
+
+// CancelRecurring отключает списания по просьбе клиента
+func (s *RecurringService) CancelRecurring(ctx context.Context, userID, id int64) (*models.RecurringPayment, error) {
+	rp, err := s.GetRecurring(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.Cancel(ctx, rp.ID, "canceled by user"); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRecurring(ctx, rp.ID)
+}
+
+// ChargeNow списывает сумму подписки вне расписания, например пополнение по кнопке
+func (s *RecurringService) ChargeNow(ctx context.Context, userID, id int64) (*models.Payment, error) {
+	rp, err := s.GetRecurring(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if rp.Status != models.RecurringStatusActive && rp.Status != models.RecurringStatusPastDue {
+		return nil, ErrRecurringInactive
+	}
+	inFlight, err := s.repo.HasChargeInFlight(ctx, rp.ID)
+	if err != nil {
+		return nil, err
+	}
+	if inFlight {
+		return nil, ErrRecurringChargeInFlight
+	}
+	return s.charge(ctx, rp)
+}
+
+// RunDue проводит списания, срок которых наступил. Запускается планировщиком.
+func (s *RecurringService) RunDue(ctx context.Context) error {
+	due, err := s.repo.ClaimDue(ctx, 100, recurringLease)
+	if err != nil {
+		return err
+	}
+
+	for i := range due {
+		rp := &due[i]
+		if _, err := s.charge(ctx, rp); err != nil {
+			logger.Sugared().Warnf("recurring %d: charge failed: %v", rp.ID, err)
+		}
+	}
+	return nil
+}
+
+// charge отправляет списание без клиента. Исход обрабатывает onPayment;
+// если платёж не удалось даже создать, подписка повторится после lease.
+func (s *RecurringService) charge(ctx context.Context, rp *models.RecurringPayment) (*models.Payment, error) {
+	p, err := s.payments.ProcessPayment(ctx, models.Payment{
+		UserID:        rp.UserID,
+		AccountID:     rp.AccountID,
+		Amount:        rp.Amount,
+		Currency:      rp.Currency,
+		Provider:      rp.Provider,
+		PaymentMethod: rp.ProviderMethodID,
+		Initiator:     models.PaymentInitiatorMerchant,
+		RecurringID:   &rp.ID,
+	})
+	if errors.Is(err, providers.ErrNoRoute) || errors.Is(err, providers.ErrUnavailable) {
+		// провайдер недоступен — это не неудача клиента, попробуем позже
+		if rerr := s.repo.Reschedule(ctx, rp.ID, time.Now().Add(recurringLease)); rerr != nil {
+			logger.Sugared().Errorf("recurring %d: failed to reschedule: %v", rp.ID, rerr)
+		}
+	}
+	return p, err
+}
+
+// onPayment — хук PaymentService: включает подписку после разрешающего
+// платежа и ведёт учёт регулярных списаний
+func (s *RecurringService) onPayment(ctx context.Context, p *models.Payment) {
+	if p.RecurringID == nil {
+		return
+	}
+	var err error
+	switch {
+	case p.Initiator == models.PaymentInitiatorCustomer:
+		err = s.onAuthorization(ctx, *p.RecurringID, p)
+	case p.Status == models.PaymentStatusSucceeded:
+		err = s.onChargeSucceeded(ctx, *p.RecurringID, p)
+	case p.Status == models.PaymentStatusFailed || p.Status == models.PaymentStatusCanceled:
+		err = s.onChargeFailed(ctx, *p.RecurringID, p)
+	}
+	if err != nil {
+		logger.Sugared().Errorf("recurring %d: payment %d %s: %v", *p.RecurringID, p.ID, p.Status, err)
+	}
+}
+
+func (s *RecurringService) onAuthorization(ctx context.Context, id int64, p *models.Payment) error {
+	switch p.Status {
+	case models.PaymentStatusSucceeded:
+		if p.SavedPaymentMethodID == "" {
+			s.cancel(ctx, id, p.UserID, fmt.Sprintf("%s did not save the payment method", p.Provider))
+			return nil
+		}
+		rp, err := s.repo.GetRecurring(ctx, id)
+		if err != nil {
+			return err
+		}
+		activated, err := s.repo.Activate(ctx, id, p.Provider, p.SavedPaymentMethodID, p.ID, s.nextCharge(rp.Period, time.Now()))
+		if err != nil || !activated {
+			return err
+		}
+		s.notifications.Notify(ctx, p.UserID, "recurring_activated",
+			fmt.Sprintf("Регулярное пополнение на %.2f %s подключено", rp.Amount, rp.Currency))
+	case models.PaymentStatusFailed, models.PaymentStatusCanceled:
+		s.cancel(ctx, id, p.UserID, "authorization failed: "+p.FailureReason)
+	}
+	return nil
+}
+
+func (s *RecurringService) onChargeSucceeded(ctx context.Context, id int64, p *models.Payment) error {
+	rp, err := s.repo.GetRecurring(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.ChargeSucceeded(ctx, id, p.ID, s.nextCharge(rp.Period, time.Now())); err != nil {
+		return err
+	}
+	s.notifications.Notify(ctx, p.UserID, "recurring_charge_succeeded",
+		fmt.Sprintf("Списано %.2f %s по регулярному пополнению", p.Amount, p.Currency))
+	return nil
+}
+
+// onChargeFailed назначает повтор (dunning); после MaxFailures неудач подряд
+// или отказа, который повтором не исправить, подписка отключается
+func (s *RecurringService) onChargeFailed(ctx context.Context, id int64, p *models.Payment) error {
+	rp, err := s.repo.GetRecurring(ctx, id)
+	if err != nil {
+		return err
+	}
+	if p.FailureReason == FailureProviderUnavailable {
+		// сбой на стороне провайдера — не вина клиента, неудачей не считаем
+		return s.repo.Reschedule(ctx, id, time.Now().Add(recurringLease))
+	}
+	if hardDeclines[p.FailureReason] {
+		s.cancel(ctx, id, p.UserID, "payment method declined: "+p.FailureReason)
+		return nil
+	}
+
+	// списание только по запросу повторяет сам клиент
+	var retryAt *time.Time
+	if rp.Period != "" {
+		t := time.Now().Add(s.retryInterval(rp.FailedAttempts))
+		retryAt = &t
+	}
+	attempts, err := s.repo.ChargeFailed(ctx, id, p.ID, retryAt)
+	if err != nil || attempts == 0 {
+		return err
+	}
+	if attempts >= rp.MaxFailures {
+		s.cancel(ctx, id, p.UserID, fmt.Sprintf("%d failed charges in a row", attempts))
+		return nil
+	}
+
+	message := fmt.Sprintf("Не удалось списать %.2f %s по регулярному пополнению (%s)", p.Amount, p.Currency, p.FailureReason)
+	if retryAt != nil {
+		message += ". Повторим " + retryAt.Format("02.01.2006 15:04")
+	}
+	s.notifications.Notify(ctx, p.UserID, "recurring_charge_failed", message)
+	return nil
+}
+
+// onMethodDeleted — хук хранилища: списывать с удалённого способа оплаты нельзя
+func (s *RecurringService) onMethodDeleted(ctx context.Context, method *models.PaymentMethod) {
+	list, err := s.repo.ListActiveByPaymentMethod(ctx, method.ID)
+	if err != nil {
+		logger.Sugared().Errorf("payment method %d deleted: failed to list recurring payments: %v", method.ID, err)
+		return
+	}
+	for _, rp := range list {
+		s.cancel(ctx, rp.ID, rp.UserID, "payment method deleted")
+	}
+}
+
+func (s *RecurringService) cancel(ctx context.Context, id, userID int64, reason string) {
+	canceled, err := s.repo.Cancel(ctx, id, reason)
+	if err != nil {
+		logger.Sugared().Errorf("recurring %d: failed to cancel: %v", id, err)
+		return
+	}
+	if canceled {
+		s.notifications.Notify(ctx, userID, "recurring_canceled",
+			fmt.Sprintf("Регулярное пополнение %d отключено: %s", id, reason))
+	}
+}
+
+// nextCharge — время следующего списания по расписанию, nil — только по запросу
+func (s *RecurringService) nextCharge(period string, from time.Time) *time.Time {
+	var next time.Time
+	switch period {
+	case models.RecurringPeriodDay:
+		next = from.AddDate(0, 0, 1)
+	case models.RecurringPeriodWeek:
+		next = from.AddDate(0, 0, 7)
+	case models.RecurringPeriodMonth:
+		next = from.AddDate(0, 1, 0)
+	default:
+		return nil
+	}
+	return &next
+}
+
+func (s *RecurringService) retryInterval(failedBefore int) time.Duration {
+	if failedBefore >= len(s.cfg.RetryIntervals) {
+		return s.cfg.RetryIntervals[len(s.cfg.RetryIntervals)-1]
+	}
+	return s.cfg.RetryIntervals[failedBefore]
+}
@@ -0,0 +1,146 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bank-api/internal/middleware"
+	"bank-api/internal/models"
+	"bank-api/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type RecurringHandler struct {
+	recurringService *RecurringService
+}
+
+func NewRecurringHandler(recurringService *RecurringService) *RecurringHandler {
+	return &RecurringHandler{recurringService: recurringService}
+}
+
+// POST /recurring
+// Сразу проводит первый платёж; если он ждёт 3-D Secure, ответ 202.
+func (h *RecurringHandler) CreateRecurring(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var req RecurringRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid input"})
+		return
+	}
+
+	rp, payment, err := h.recurringService.CreateRecurring(r.Context(), userID, req)
+	if err != nil {
+		utils.RespondJSON(w, recurringErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+
+	code := http.StatusCreated
+	if rp.Status == models.RecurringStatusPendingAuthorization {
+		code = http.StatusAccepted
+	}
+	utils.RespondJSON(w, code, map[string]interface{}{
+		"recurring": rp,
+		"payment":   payment,
+	})
+}
+
+// GET /recurring
+func (h *RecurringHandler) ListRecurring(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	list, err := h.recurringService.ListRecurring(r.Context(), userID)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, list)
+}
+
+// GET /recurring/{id}
+func (h *RecurringHandler) GetRecurring(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := recurringRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	rp, err := h.recurringService.GetRecurring(r.Context(), userID, id)
+	if err != nil {
+		utils.RespondJSON(w, recurringErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, rp)
+}
+
+// POST /recurring/{id}/charge — списание вне расписания
+func (h *RecurringHandler) ChargeNow(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := recurringRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	payment, err := h.recurringService.ChargeNow(r.Context(), userID, id)
+	if err != nil {
+		utils.RespondJSON(w, recurringErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+
+	code := http.StatusOK
+	if payment.Status != models.PaymentStatusSucceeded {
+		code = http.StatusAccepted
+	}
+	utils.RespondJSON(w, code, payment)
+}
+
+// DELETE /recurring/{id}
+func (h *RecurringHandler) CancelRecurring(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := recurringRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	rp, err := h.recurringService.CancelRecurring(r.Context(), userID, id)
+	if err != nil {
+		utils.RespondJSON(w, recurringErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, rp)
+}
+
+func recurringRequestIDs(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return 0, 0, false
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid recurring payment ID"})
+		return 0, 0, false
+	}
+	return userID, id, true
+}
+
+func recurringErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrRecurringNotFound), errors.Is(err, ErrPaymentMethodUnavailable):
+		return http.StatusNotFound
+	case errors.Is(err, ErrRecurringInactive), errors.Is(err, ErrRecurringChargeInFlight):
+		return http.StatusConflict
+	}
+	return paymentErrorStatus(err)
+}
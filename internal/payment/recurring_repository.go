@@ -0,0 +1,169 @@
+package payment
+
+import (
+	"bank-api/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrRecurringNotFound = errors.New("recurring payment not found")
+
+type RecurringRepository struct {
+	DB *sqlx.DB
+}
+
+func NewRecurringRepository(db *sqlx.DB) *RecurringRepository {
+	return &RecurringRepository{DB: db}
+}
+
+const recurringColumns = `
+	id, user_id, account_id, payment_method_id, COALESCE(provider, '') AS provider,
+	COALESCE(provider_method_id, '') AS provider_method_id, amount, currency, period, description, status,
+	next_charge_at, failed_attempts, max_failures, last_payment_id, COALESCE(cancel_reason, '') AS cancel_reason,
+	created_at, updated_at, canceled_at
+`
+
+// активное списание по подписке ещё не завершилось — новое не начинаем
+const recurringChargeInFlight = `
+	EXISTS (SELECT 1 FROM payments p WHERE p.recurring_id = recurring_payments.id
+		AND p.status IN ('created', 'processing', 'requires_action'))
+`
+
+func (r *RecurringRepository) CreateRecurring(ctx context.Context, rp *models.RecurringPayment) error {
+	return r.DB.QueryRowContext(ctx, `
+		INSERT INTO recurring_payments (user_id, account_id, payment_method_id, amount, currency, period,
+			description, status, max_failures)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at
+	`, rp.UserID, rp.AccountID, rp.PaymentMethodID, rp.Amount, rp.Currency, rp.Period,
+		rp.Description, rp.Status, rp.MaxFailures,
+	).Scan(&rp.ID, &rp.CreatedAt, &rp.UpdatedAt)
+}
+
+func (r *RecurringRepository) GetRecurring(ctx context.Context, id int64) (*models.RecurringPayment, error) {
+	var rp models.RecurringPayment
+	err := r.DB.GetContext(ctx, &rp, `SELECT `+recurringColumns+` FROM recurring_payments WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRecurringNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rp, nil
+}
+
+func (r *RecurringRepository) ListRecurringByUser(ctx context.Context, userID int64) ([]models.RecurringPayment, error) {
+	var list []models.RecurringPayment
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT `+recurringColumns+`
+		FROM recurring_payments
+		WHERE user_id = $1
+		ORDER BY id DESC
+	`, userID)
+	return list, err
+}
+
+// ListActiveByPaymentMethod возвращает неотключённые подписки на способ оплаты
+func (r *RecurringRepository) ListActiveByPaymentMethod(ctx context.Context, paymentMethodID int64) ([]models.RecurringPayment, error) {
+	var list []models.RecurringPayment
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT `+recurringColumns+`
+		FROM recurring_payments
+		WHERE payment_method_id = $1 AND status <> 'canceled'
+	`, paymentMethodID)
+	return list, err
+}
+
+// Activate включает списания после успешного разрешающего платежа
+func (r *RecurringRepository) Activate(ctx context.Context, id int64, provider, providerMethodID string, paymentID int64, next *time.Time) (bool, error) {
+	result, err := r.DB.ExecContext(ctx, `
+		UPDATE recurring_payments
+		SET status = 'active', provider = $1, provider_method_id = $2, last_payment_id = $3,
+			next_charge_at = $4, updated_at = NOW()
+		WHERE id = $5 AND status = 'pending_authorization'
+	`, provider, providerMethodID, paymentID, next, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ChargeSucceeded сбрасывает счётчик неудач и назначает следующее списание
+func (r *RecurringRepository) ChargeSucceeded(ctx context.Context, id, paymentID int64, next *time.Time) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE recurring_payments
+		SET status = 'active', failed_attempts = 0, last_payment_id = $1, next_charge_at = $2, updated_at = NOW()
+		WHERE id = $3 AND status IN ('active', 'past_due')
+	`, paymentID, next, id)
+	return err
+}
+
+// ChargeFailed учитывает неудачное списание и назначает повтор (nil — без
+// повтора). Возвращает число неудач подряд; 0 — подписка уже не активна.
+func (r *RecurringRepository) ChargeFailed(ctx context.Context, id, paymentID int64, retryAt *time.Time) (int, error) {
+	var attempts int
+	err := r.DB.QueryRowContext(ctx, `
+		UPDATE recurring_payments
+		SET status = 'past_due', failed_attempts = failed_attempts + 1, last_payment_id = $1,
+			next_charge_at = $2, updated_at = NOW()
+		WHERE id = $3 AND status IN ('active', 'past_due')
+		RETURNING failed_attempts
+	`, paymentID, retryAt, id).Scan(&attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return attempts, err
+}
+
+// Reschedule переносит следующее списание, не считая попытку неудачной
+func (r *RecurringRepository) Reschedule(ctx context.Context, id int64, next time.Time) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE recurring_payments SET next_charge_at = $1, updated_at = NOW()
+		WHERE id = $2 AND status IN ('active', 'past_due')
+	`, next, id)
+	return err
+}
+
+// Cancel отключает списания. false — уже отключены.
+func (r *RecurringRepository) Cancel(ctx context.Context, id int64, reason string) (bool, error) {
+	result, err := r.DB.ExecContext(ctx, `
+		UPDATE recurring_payments
+		SET status = 'canceled', cancel_reason = $1, next_charge_at = NULL, canceled_at = NOW(), updated_at = NOW()
+		WHERE id = $2 AND status <> 'canceled'
+	`, reason, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ClaimDue выбирает подписки, которым пора списание, и откладывает их на
+// lease: если списание не дойдёт до ChargeSucceeded/ChargeFailed, оно
+// повторится после lease. Подписки с незавершённым платежом пропускаются.
+func (r *RecurringRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.RecurringPayment, error) {
+	var list []models.RecurringPayment
+	err := r.DB.SelectContext(ctx, &list, `
+		UPDATE recurring_payments SET next_charge_at = NOW() + $1 * INTERVAL '1 second', updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM recurring_payments
+			WHERE status IN ('active', 'past_due') AND next_charge_at <= NOW() AND NOT `+recurringChargeInFlight+`
+			ORDER BY next_charge_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+recurringColumns, int64(lease/time.Second), limit)
+	return list, err
+}
+
+// HasChargeInFlight сообщает, есть ли у подписки незавершённый платёж
+func (r *RecurringRepository) HasChargeInFlight(ctx context.Context, id int64) (bool, error) {
+	var inFlight bool
+	err := r.DB.GetContext(ctx, &inFlight, `SELECT `+recurringChargeInFlight+` FROM recurring_payments WHERE id = $1`, id)
+	return inFlight, err
+}
@@ -0,0 +1,242 @@
+package payment
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/payment/providers"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// через сколько pending-возврат перепроверяется у провайдера
+const refundSyncAfter = 10 * time.Minute
+
+// RefundRequest — запрос на возврат. Amount = 0 — весь невозвращённый остаток.
+type RefundRequest struct {
+	Amount float64 `json:"amount"`
+	Reason string  `json:"reason"`
+}
+
+// CreateRefund создаёт возврат по платежу владельца. Сумма сразу списывается
+// со счёта отдельной транзакцией; если провайдер возврат отклонит, она
+// зачисляется обратно. Результат провайдера может прийти позже уведомлением.
+// Если ответа провайдера нет (таймаут, 5xx), возврат остаётся pending до
+// уведомления или SyncPendingRefunds — вернуть деньги можно, только когда
+// провайдер точно возврат не провёл.
+func (s *PaymentService) CreateRefund(ctx context.Context, userID, paymentID int64, req RefundRequest) (*models.Refund, error) {
+	p, err := s.repo.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if p.UserID != userID {
+		return nil, ErrPaymentAccessDenied
+	}
+	provider, err := s.provider(p.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if req.Amount < 0 {
+		return nil, errors.New("amount must be positive")
+	}
+
+	requested := math.Round(req.Amount*100) / 100
+	amount := requested
+	if amount == 0 {
+		amount = p.Amount - p.RefundedAmount
+	}
+	available, err := s.accountRepo.GetAvailableBalance(ctx, p.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if available < amount {
+		return nil, ErrInsufficientFunds
+	}
+
+	refund := &models.Refund{
+		PaymentID:   p.ID,
+		Amount:      requested,
+		Currency:    p.Currency,
+		Reason:      req.Reason,
+		Status:      models.RefundStatusPending,
+		RequestedBy: &userID,
+	}
+	if err := s.repo.CreateRefund(ctx, refund); err != nil {
+		return nil, err
+	}
+
+	transactionID, err := s.transactionService.PostDebit(ctx, p.AccountID, refund.Amount, "refund",
+		fmt.Sprintf("Refund %d for payment %d via %s", refund.ID, p.ID, p.Provider))
+	if err != nil {
+		if _, ferr := s.repo.FailRefund(ctx, refund.ID, "ledger_error"); ferr != nil {
+			logger.Sugared().Errorf("refund %d: failed to mark as failed: %v", refund.ID, ferr)
+		}
+		return nil, err
+	}
+	refund.TransactionID = &transactionID
+	if err := s.repo.SetRefundTransaction(ctx, refund.ID, transactionID); err != nil {
+		logger.Sugared().Errorf("refund %d: failed to link transaction %d: %v", refund.ID, transactionID, err)
+	}
+
+	result, err := provider.Refund(ctx, refundRequest(p, refund))
+	if err != nil && !providers.NotProcessed(err) {
+		logger.Sugared().Warnf("refund %d: outcome at %s unknown, left pending: %v", refund.ID, p.Provider, err)
+		return refund, nil
+	}
+	if err != nil {
+		if ferr := s.failRefund(ctx, p, refund, err.Error()); ferr != nil {
+			logger.Sugared().Errorf("refund %d: %v", refund.ID, ferr)
+		}
+		return nil, err
+	}
+
+	if err := s.applyRefund(ctx, p, refund, result, SourceProvider); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// ListRefunds возвращает возвраты по платежу владельца
+func (s *PaymentService) ListRefunds(ctx context.Context, userID, paymentID int64) ([]models.Refund, error) {
+	p, err := s.repo.GetPaymentByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if p.UserID != userID {
+		return nil, ErrPaymentAccessDenied
+	}
+	return s.repo.ListRefunds(ctx, paymentID)
+}
+
+// SyncPendingRefunds запрашивает у провайдеров состояние давно зависших
+// возвратов. Возврат без ID провайдера отправляется повторно с тем же ключом
+// идемпотентности — второго возврата провайдер не создаст.
+func (s *PaymentService) SyncPendingRefunds(ctx context.Context) error {
+	refunds, err := s.repo.ListPendingRefunds(ctx, time.Now().Add(-refundSyncAfter), 100)
+	if err != nil {
+		return err
+	}
+
+	for i := range refunds {
+		refund := &refunds[i]
+		if err := s.syncRefund(ctx, refund); err != nil {
+			logger.Sugared().Errorf("refund %d sync failed: %v", refund.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *PaymentService) syncRefund(ctx context.Context, refund *models.Refund) error {
+	p, err := s.repo.GetPaymentByID(ctx, refund.PaymentID)
+	if err != nil {
+		return err
+	}
+	provider, err := s.provider(p.Provider)
+	if err != nil {
+		return err
+	}
+
+	var result *providers.RefundResult
+	if refund.ProviderRefundID == "" {
+		result, err = provider.Refund(ctx, refundRequest(p, refund))
+		// повтор с тем же ключом идемпотентности явно отклонён — возврата у провайдера нет
+		if err != nil && providers.NotProcessed(err) && !errors.Is(err, providers.ErrUnavailable) {
+			return s.failRefund(ctx, p, refund, err.Error())
+		}
+	} else {
+		result, err = provider.GetRefund(ctx, refund.ProviderRefundID)
+	}
+	if err != nil {
+		return err
+	}
+	return s.applyRefund(ctx, p, refund, result, SourceSystem)
+}
+
+func (s *PaymentService) applyRefundEvent(ctx context.Context, providerName string, result *providers.RefundResult) error {
+	refund, err := s.repo.GetRefundByProviderID(ctx, providerName, result.ID)
+	if err != nil {
+		return err
+	}
+	if refund == nil {
+		// возврат создан не через нас или ID ещё не сохранён — досинхронизирует SyncPendingRefunds
+		logger.Sugared().Warnf("%s webhook: refund %s not found", providerName, result.ID)
+		return nil
+	}
+	p, err := s.repo.GetPaymentByID(ctx, refund.PaymentID)
+	if err != nil {
+		return err
+	}
+	return s.applyRefund(ctx, p, refund, result, SourceWebhook)
+}
+
+// applyRefund применяет состояние возврата у провайдера
+func (s *PaymentService) applyRefund(ctx context.Context, p *models.Payment, refund *models.Refund, result *providers.RefundResult, source string) error {
+	if result.ID != "" && refund.ProviderRefundID == "" {
+		if err := s.repo.SetRefundProviderID(ctx, refund.ID, result.ID); err != nil {
+			return err
+		}
+		refund.ProviderRefundID = result.ID
+	}
+
+	switch result.Status {
+	case models.RefundStatusSucceeded:
+		return s.completeRefund(ctx, p, refund, source)
+	case models.RefundStatusFailed:
+		return s.failRefund(ctx, p, refund, result.FailureReason)
+	}
+	return nil
+}
+
+func (s *PaymentService) completeRefund(ctx context.Context, p *models.Payment, refund *models.Refund, source string) error {
+	changed, err := s.repo.CompleteRefund(ctx, refund.ID)
+	if err != nil || !changed {
+		return err
+	}
+	refund.Status = models.RefundStatusSucceeded
+
+	// refunded_amount изменился — перечитываем платёж
+	fresh, err := s.repo.GetPaymentByID(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+	*p = *fresh
+
+	to := models.PaymentStatusPartiallyRefunded
+	if p.RefundedAmount >= p.Amount-0.005 {
+		to = models.PaymentStatusRefunded
+	}
+	return s.apply(ctx, p, to, fmt.Sprintf("refund %d", refund.ID), source)
+}
+
+// failRefund отмечает возврат отклонённым и возвращает списанную сумму на счёт
+func (s *PaymentService) failRefund(ctx context.Context, p *models.Payment, refund *models.Refund, reason string) error {
+	changed, err := s.repo.FailRefund(ctx, refund.ID, reason)
+	if err != nil || !changed {
+		return err
+	}
+	refund.Status = models.RefundStatusFailed
+	refund.FailureReason = reason
+
+	if refund.TransactionID == nil {
+		return nil
+	}
+	transactionID, err := s.transactionService.PostCredit(ctx, p.AccountID, refund.Amount, "refund_reversal",
+		fmt.Sprintf("Refund %d for payment %d declined by %s", refund.ID, p.ID, p.Provider))
+	if err != nil {
+		return fmt.Errorf("refund %d declined, but failed to return funds to account %d: %w", refund.ID, p.AccountID, err)
+	}
+	refund.ReversalTransactionID = &transactionID
+	return s.repo.SetRefundReversal(ctx, refund.ID, transactionID)
+}
+
+func refundRequest(p *models.Payment, refund *models.Refund) providers.RefundRequest {
+	return providers.RefundRequest{
+		ProviderPaymentID: p.ProviderPaymentID,
+		Amount:            refund.Amount,
+		Currency:          refund.Currency,
+		Reason:            refund.Reason,
+		IdempotencyKey:    fmt.Sprintf("refund-%d", refund.ID),
+	}
+}
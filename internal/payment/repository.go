@@ -4,7 +4,9 @@ import (
 	"bank-api/internal/models"
 	"context"
 	"database/sql"
-	"fmt"
+	"errors"
+	"math"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -17,92 +19,370 @@ func NewPaymentRepository(db *sqlx.DB) *PaymentRepository {
 	return &PaymentRepository{DB: db}
 }
 
+var (
+	ErrPaymentNotFound      = errors.New("payment not found")
+	ErrPaymentStatusChanged = errors.New("payment status changed concurrently")
+	ErrPaymentNotRefundable = errors.New("payment cannot be refunded")
+	ErrRefundExceedsPayment = errors.New("refund exceeds the unrefunded amount")
+	ErrRefundNotFound       = errors.New("refund not found")
+)
+
+const paymentColumns = `
+	id, user_id, account_id, amount, currency, method, provider, status,
+	COALESCE(provider_payment_id, '') AS provider_payment_id, COALESCE(failure_reason, '') AS failure_reason,
+	COALESCE(transaction_id, '') AS transaction_id, refunded_amount, COALESCE(routing_rule, '') AS routing_rule,
+	initiator, recurring_id, COALESCE(saved_payment_method_id, '') AS saved_payment_method_id,
+	COALESCE(confirmation_url, '') AS confirmation_url, action_expires_at, status_changed_at, created_at, updated_at
+`
+
 // Создание нового платежа
 func (r *PaymentRepository) CreatePayment(ctx context.Context, p *models.Payment) error {
-	query := `INSERT INTO payments (user_id, provider, amount, currency, status, created_at)
-	          VALUES ($1, $2, $3, $4, $5, NOW()) RETURNING id`
-	return r.DB.QueryRowContext(ctx, query, p.UserID, p.Provider, p.Amount, p.Currency, p.Status).Scan(&p.ID)
+	query := `INSERT INTO payments (user_id, account_id, provider, method, amount, currency, status, routing_rule,
+	              initiator, recurring_id, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, NOW(), NOW()) RETURNING id, created_at, updated_at`
+	return r.DB.QueryRowContext(ctx, query, p.UserID, p.AccountID, p.Provider, p.Method, p.Amount, p.Currency, p.Status, p.RoutingRule,
+		p.Initiator, p.RecurringID).
+		Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
 }
 
-// Обновление статуса
-func (r *PaymentRepository) UpdatePaymentStatus(ctx context.Context, id int64, status string) error {
-	query := `UPDATE payments SET status = $1 WHERE id = $2`
-	_, err := r.DB.ExecContext(ctx, query, status, id)
+// SetSavedPaymentMethod сохраняет способ оплаты, который провайдер запомнил для будущих списаний
+func (r *PaymentRepository) SetSavedPaymentMethod(ctx context.Context, id int64, providerMethodID string) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE payments SET saved_payment_method_id = $1, updated_at = NOW() WHERE id = $2
+	`, providerMethodID, id)
 	return err
 }
 
-// Завершить платеж
-func (r *PaymentRepository) MarkAsCompleted(ctx context.Context, id int64, transactionID string) error {
-	query := `UPDATE payments SET status = 'completed', transaction_id = $1 WHERE id = $2`
-	_, err := r.DB.ExecContext(ctx, query, transactionID, id)
+// SetProvider переключает платёж на другого провайдера, пока тот не ответил
+func (r *PaymentRepository) SetProvider(ctx context.Context, id int64, provider string) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE payments SET provider = $1, updated_at = NOW() WHERE id = $2 AND provider_payment_id IS NULL
+	`, provider, id)
 	return err
 }
 
-// Получить платеж по ID
-func (r *PaymentRepository) GetPaymentByID(ctx context.Context, id int64) (*models.Payment, error) {
-	query := `SELECT id, user_id, provider, amount, currency, status, transaction_id, created_at, refunded_at
-	          FROM payments WHERE id = $1`
+// AddRoutingAttempt записывает обращение к провайдеру
+func (r *PaymentRepository) AddRoutingAttempt(ctx context.Context, a *models.PaymentRoutingAttempt) error {
+	return r.DB.QueryRowContext(ctx, `
+		INSERT INTO payment_routing_attempts (payment_id, attempt, provider, outcome, error, latency_ms)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		RETURNING id, created_at
+	`, a.PaymentID, a.Attempt, a.Provider, a.Outcome, a.Error, a.LatencyMS).Scan(&a.ID, &a.CreatedAt)
+}
 
-	row := r.DB.QueryRowContext(ctx, query, id)
+func (r *PaymentRepository) ListRoutingAttempts(ctx context.Context, paymentID int64) ([]models.PaymentRoutingAttempt, error) {
+	var list []models.PaymentRoutingAttempt
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT id, payment_id, attempt, provider, outcome, COALESCE(error, '') AS error, latency_ms, created_at
+		FROM payment_routing_attempts
+		WHERE payment_id = $1
+		ORDER BY attempt
+	`, paymentID)
+	return list, err
+}
 
-	var p models.Payment
-	err := row.Scan(
-		&p.ID,
-		&p.UserID,
-		&p.Provider,
-		&p.Amount,
-		&p.Currency,
-		&p.Status,
-		&p.TransactionID,
-		&p.CreatedAt,
-		&p.IsRefunded,
-	)
+// SetProviderPaymentID сохраняет идентификатор платежа у провайдера
+func (r *PaymentRepository) SetProviderPaymentID(ctx context.Context, id int64, providerPaymentID string) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE payments SET provider_payment_id = NULLIF($1, ''), updated_at = NOW() WHERE id = $2
+	`, providerPaymentID, id)
+	return err
+}
+
+// SetConfirmation сохраняет ссылку на подтверждение платежа и срок ожидания клиента
+func (r *PaymentRepository) SetConfirmation(ctx context.Context, id int64, url string, expiresAt time.Time) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE payments SET confirmation_url = NULLIF($1, ''), action_expires_at = $2, updated_at = NOW() WHERE id = $3
+	`, url, expiresAt, id)
+	return err
+}
+
+// Transition меняет статус, если он всё ещё равен from, и пишет запись в журнал
+func (r *PaymentRepository) Transition(ctx context.Context, change *models.PaymentStatusChange) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE payments SET status = $1, failure_reason = CASE WHEN $1 = 'failed' THEN $2 ELSE failure_reason END,
+			confirmation_url = CASE WHEN $1 = 'requires_action' THEN confirmation_url END,
+			action_expires_at = CASE WHEN $1 = 'requires_action' THEN action_expires_at END,
+			status_changed_at = NOW(), updated_at = NOW()
+		WHERE id = $3 AND status = $4
+	`, change.ToStatus, change.Reason, change.PaymentID, change.FromStatus)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrPaymentStatusChanged
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO payment_status_history (payment_id, from_status, to_status, reason, source)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, change.PaymentID, change.FromStatus, change.ToStatus, change.Reason, change.Source).
+		Scan(&change.ID, &change.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetTransaction связывает платёж с транзакцией зачисления
+func (r *PaymentRepository) SetTransaction(ctx context.Context, id int64, transactionID string) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE payments SET transaction_id = $1, updated_at = NOW() WHERE id = $2
+	`, transactionID, id)
+	return err
+}
 
+// Получить платеж по ID
+func (r *PaymentRepository) GetPaymentByID(ctx context.Context, id int64) (*models.Payment, error) {
+	var p models.Payment
+	err := r.DB.GetContext(ctx, &p, `SELECT `+paymentColumns+` FROM payments WHERE id = $1`, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("payment not found")
+			return nil, ErrPaymentNotFound
 		}
 		return nil, err
 	}
+	return &p, nil
+}
 
+// GetByProviderPaymentID ищет платёж по идентификатору провайдера, nil — не найден
+func (r *PaymentRepository) GetByProviderPaymentID(ctx context.Context, provider, providerPaymentID string) (*models.Payment, error) {
+	var p models.Payment
+	err := r.DB.GetContext(ctx, &p, `
+		SELECT `+paymentColumns+` FROM payments WHERE provider = $1 AND provider_payment_id = $2
+	`, provider, providerPaymentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 	return &p, nil
 }
 
-// Пометить как возвращённый
-func (r *PaymentRepository) MarkAsRefunded(ctx context.Context, id int64) error {
-	query := `UPDATE payments SET status = 'refunded', refunded_at = NOW() WHERE id = $1`
-	_, err := r.DB.ExecContext(ctx, query, id)
+func (r *PaymentRepository) ListStatusHistory(ctx context.Context, paymentID int64) ([]models.PaymentStatusChange, error) {
+	var list []models.PaymentStatusChange
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT id, payment_id, from_status, to_status, reason, source, created_at
+		FROM payment_status_history
+		WHERE payment_id = $1
+		ORDER BY id
+	`, paymentID)
+	return list, err
+}
+
+// SaveWebhookEvent сохраняет уведомление провайдера. false — событие уже
+// было обработано раньше.
+func (r *PaymentRepository) SaveWebhookEvent(ctx context.Context, provider, eventID, eventType, providerPaymentID string, payload []byte) (int64, bool, error) {
+	var id int64
+	var processed bool
+	err := r.DB.QueryRowContext(ctx, `
+		INSERT INTO payment_webhook_events (provider, event_id, event_type, provider_payment_id, payload)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (provider, event_id) DO UPDATE SET event_type = EXCLUDED.event_type
+		RETURNING id, processed_at IS NOT NULL
+	`, provider, eventID, eventType, providerPaymentID, string(payload)).Scan(&id, &processed)
+	return id, !processed, err
+}
+
+func (r *PaymentRepository) MarkWebhookProcessed(ctx context.Context, id int64) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE payment_webhook_events SET processed_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+const refundColumns = `
+	r.id, r.payment_id, r.amount, r.currency, r.reason, r.status,
+	COALESCE(r.provider_refund_id, '') AS provider_refund_id, COALESCE(r.failure_reason, '') AS failure_reason,
+	r.transaction_id, r.reversal_transaction_id, r.requested_by, r.created_at, r.updated_at
+`
+
+// CreateRefund создаёт возврат в статусе pending. Строка платежа блокируется,
+// чтобы параллельные возвраты не превысили сумму платежа. Amount = 0 — весь остаток.
+func (r *PaymentRepository) CreateRefund(ctx context.Context, refund *models.Refund) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var amount, refunded, pending float64
+	var status string
+	err = tx.QueryRowContext(ctx, `
+		SELECT amount, refunded_amount, status FROM payments WHERE id = $1 FOR UPDATE
+	`, refund.PaymentID).Scan(&amount, &refunded, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrPaymentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status != models.PaymentStatusSucceeded && status != models.PaymentStatusPartiallyRefunded {
+		return ErrPaymentNotRefundable
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE payment_id = $1 AND status = 'pending'
+	`, refund.PaymentID).Scan(&pending)
+	if err != nil {
+		return err
+	}
+
+	remaining := math.Round((amount-refunded-pending)*100) / 100
+	if refund.Amount == 0 {
+		refund.Amount = remaining
+	}
+	if refund.Amount <= 0 || refund.Amount > remaining {
+		return ErrRefundExceedsPayment
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO refunds (payment_id, amount, currency, reason, status, requested_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`, refund.PaymentID, refund.Amount, refund.Currency, refund.Reason, refund.Status, refund.RequestedBy).
+		Scan(&refund.ID, &refund.CreatedAt, &refund.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PaymentRepository) SetRefundTransaction(ctx context.Context, id, transactionID int64) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE refunds SET transaction_id = $1, updated_at = NOW() WHERE id = $2
+	`, transactionID, id)
+	return err
+}
+
+func (r *PaymentRepository) SetRefundReversal(ctx context.Context, id, transactionID int64) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE refunds SET reversal_transaction_id = $1, updated_at = NOW() WHERE id = $2
+	`, transactionID, id)
+	return err
+}
+
+func (r *PaymentRepository) SetRefundProviderID(ctx context.Context, id int64, providerRefundID string) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE refunds SET provider_refund_id = NULLIF($1, ''), updated_at = NOW() WHERE id = $2
+	`, providerRefundID, id)
 	return err
 }
 
-func (r *PaymentRepository) CreatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
-	query := `
-		INSERT INTO payment_methods (user_id, type, token, created_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id
-	`
-	return r.DB.QueryRowContext(ctx, query, method.UserID, method.Type, method.Token, method.CreatedAt).
-		Scan(&method.ID)
+// CompleteRefund переводит возврат из pending в succeeded и увеличивает
+// refunded_amount платежа. false — возврат уже не в pending.
+func (r *PaymentRepository) CompleteRefund(ctx context.Context, id int64) (bool, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var paymentID int64
+	var amount float64
+	err = tx.QueryRowContext(ctx, `
+		UPDATE refunds SET status = 'succeeded', updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING payment_id, amount
+	`, id).Scan(&paymentID, &amount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE payments SET refunded_amount = refunded_amount + $1, updated_at = NOW() WHERE id = $2
+	`, amount, paymentID)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
 }
 
-func (r *PaymentRepository) GetPaymentMethodsByUser(ctx context.Context, userID int64) ([]models.PaymentMethod, error) {
-	var methods []models.PaymentMethod
-	query := `SELECT id, user_id, type, token, created_at FROM payment_methods WHERE user_id = $1 ORDER BY created_at DESC`
-	err := r.DB.SelectContext(ctx, &methods, query, userID)
-	return methods, err
+// FailRefund переводит возврат из pending в failed. false — возврат уже не в pending.
+func (r *PaymentRepository) FailRefund(ctx context.Context, id int64, reason string) (bool, error) {
+	result, err := r.DB.ExecContext(ctx, `
+		UPDATE refunds SET status = 'failed', failure_reason = $1, updated_at = NOW()
+		WHERE id = $2 AND status = 'pending'
+	`, reason, id)
+	if err != nil {
+		return false, err
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
 }
 
-func (r *PaymentRepository) GetPaymentMethodByID(ctx context.Context, id int64) (*models.PaymentMethod, error) {
-	var method models.PaymentMethod
-	query := `SELECT id, user_id, provider, card_last4, created_at FROM payment_methods WHERE id = $1`
-	err := r.DB.GetContext(ctx, &method, query, id)
+func (r *PaymentRepository) GetRefund(ctx context.Context, id int64) (*models.Refund, error) {
+	var refund models.Refund
+	err := r.DB.GetContext(ctx, &refund, `SELECT `+refundColumns+` FROM refunds r WHERE r.id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRefundNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &method, nil
+	return &refund, nil
 }
 
-func (r *PaymentRepository) DeletePaymentMethod(ctx context.Context, id int64) error {
-	_, err := r.DB.ExecContext(ctx, `DELETE FROM payment_methods WHERE id = $1`, id)
-	return err
+// GetRefundByProviderID ищет возврат по идентификатору провайдера, nil — не найден
+func (r *PaymentRepository) GetRefundByProviderID(ctx context.Context, provider, providerRefundID string) (*models.Refund, error) {
+	var refund models.Refund
+	err := r.DB.GetContext(ctx, &refund, `
+		SELECT `+refundColumns+`
+		FROM refunds r
+		JOIN payments p ON p.id = r.payment_id
+		WHERE p.provider = $1 AND r.provider_refund_id = $2
+	`, provider, providerRefundID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (r *PaymentRepository) ListRefunds(ctx context.Context, paymentID int64) ([]models.Refund, error) {
+	var list []models.Refund
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT `+refundColumns+` FROM refunds r WHERE r.payment_id = $1 ORDER BY r.id
+	`, paymentID)
+	return list, err
+}
+
+// ListPendingRefunds — возвраты, по которым провайдер давно не сообщал результат
+func (r *PaymentRepository) ListPendingRefunds(ctx context.Context, before time.Time, limit int) ([]models.Refund, error) {
+	var list []models.Refund
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT `+refundColumns+`
+		FROM refunds r
+		WHERE r.status = 'pending' AND r.updated_at < $1
+		ORDER BY r.id
+		LIMIT $2
+	`, before, limit)
+	return list, err
+}
+
+// ListExpiredActions — платежи, которые клиент не подтвердил вовремя. Платежи
+// без срока (перешли в requires_action по уведомлению) ждут до before.
+func (r *PaymentRepository) ListExpiredActions(ctx context.Context, before time.Time, limit int) ([]models.Payment, error) {
+	var list []models.Payment
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE status = 'requires_action'
+			AND (action_expires_at < NOW() OR (action_expires_at IS NULL AND status_changed_at < $1))
+		ORDER BY id
+		LIMIT $2
+	`, before, limit)
+	return list, err
 }
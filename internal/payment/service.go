@@ -3,144 +3,421 @@ package payment
 import (
 	"bank-api/internal/models"
 	"bank-api/internal/payment/providers"
+	"bank-api/internal/repositories"
 	"bank-api/internal/service"
+	"bank-api/pkg/utils/logger"
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
+var (
+	ErrUnknownProvider     = errors.New("payment provider not found")
+	ErrPaymentAccessDenied = errors.New("access denied")
+	ErrInvalidTransition   = errors.New("invalid payment status transition")
+	ErrInsufficientFunds   = errors.New("insufficient funds")
+)
+
+// причина неудачи платежа, который не принял ни один провайдер маршрута;
+// подробности — в payment_routing_attempts
+const FailureProviderUnavailable = "provider_unavailable"
+
+// причина отмены платежа, который клиент не подтвердил за PaymentConfig.ActionTimeout
+const FailureActionAbandoned = "authentication_abandoned"
+
+// PaymentConfig — настройки платёжного сервиса
+type PaymentConfig struct {
+	ActionTimeout time.Duration         // сколько ждать подтверждения клиента (3-D Secure) до отмены платежа
+	ReturnURLs    *providers.ReturnURLs // подписанные адреса возврата клиента после подтверждения
+	Payouts       PayoutConfig
+}
+
+// PaymentHook вызывается после каждой смены статуса платежа
+type PaymentHook func(ctx context.Context, p *models.Payment)
+
 type PaymentService struct {
 	repo               *PaymentRepository
-	providers          map[string]providers.PaymentProvider
+	accountRepo        *repositories.AccountRepository
+	registry           *providers.Registry
 	transactionService *service.TransactionService
+	methods            *service.PaymentMethodService
+	cfg                PaymentConfig
+	hooks              []PaymentHook
 }
 
-func NewPaymentService(repo *PaymentRepository, provider []providers.PaymentProvider, transactionService *service.TransactionService) *PaymentService {
-	providerMap := make(map[string]providers.PaymentProvider)
-	for _, p := range provider {
-		providerMap[p.Name()] = p
+func NewPaymentService(repo *PaymentRepository, accountRepo *repositories.AccountRepository, registry *providers.Registry,
+	transactionService *service.TransactionService, methods *service.PaymentMethodService, cfg PaymentConfig) *PaymentService {
+	if cfg.ActionTimeout <= 0 {
+		cfg.ActionTimeout = 30 * time.Minute
 	}
-
 	return &PaymentService{
 		repo:               repo,
-		providers:          providerMap,
+		accountRepo:        accountRepo,
+		registry:           registry,
 		transactionService: transactionService,
+		methods:            methods,
+		cfg:                cfg,
 	}
 }
 
-// ProcessPayment — обработка нового платежа
-func (s *PaymentService) ProcessPayment(ctx context.Context, payment models.Payment) (*models.PaymentResult, error) {
-	provider, ok := s.providers[payment.Provider]
-	if !ok {
-		return nil, fmt.Errorf("payment provider '%s' not found", payment.Provider)
+// AddHook регистрирует обработчик смены статуса платежа
+func (s *PaymentService) AddHook(hook PaymentHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+func (s *PaymentService) provider(name string) (providers.PaymentProvider, error) {
+	provider, err := s.registry.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
 	}
+	return provider, nil
+}
 
-	// Запись платежа в базу (со статусом pending)
-	payment.Status = "pending"
-	if err := s.repo.CreatePayment(ctx, &payment); err != nil {
-		return nil, err
+// ProcessPayment — создание платежа и отправка провайдеру. Провайдер
+// выбирается маршрутизацией; если он недоступен, платёж уходит следующему.
+// Провайдер может ответить не окончательным статусом — тогда платёж
+// доводится уведомлениями. Списание по инициативе магазина идёт только через
+// провайдера, у которого сохранён способ оплаты.
+func (s *PaymentService) ProcessPayment(ctx context.Context, payment models.Payment) (*models.Payment, error) {
+	if payment.Amount <= 0 {
+		return nil, errors.New("amount must be positive")
 	}
 
-	// Отправка на оплату провайдеру
-	result, err := provider.ProcessPayment(ctx, payment)
+	ownerID, err := s.accountRepo.GetAccountOwner(ctx, payment.AccountID)
 	if err != nil {
-		// Ошибка провайдера — обновим статус
-		_ = s.repo.UpdatePaymentStatus(ctx, payment.ID, "failed")
 		return nil, err
 	}
+	if ownerID != payment.UserID {
+		return nil, ErrPaymentAccessDenied
+	}
 
-	txn := models.Transaction{
-		FromAccount: 0, // внешний источник
-		ToAccount:   payment.AccountID,
-		Amount:      payment.Amount,
-		Type:        "deposit",
-		Timestamp:   time.Now(),
-		Description: fmt.Sprintf("External payment via %s", payment.Provider),
+	if payment.Method == "" {
+		payment.Method = "card"
+	}
+	if payment.Currency == "" {
+		payment.Currency = "RUB"
+	}
+	if payment.Initiator == "" {
+		payment.Initiator = models.PaymentInitiatorCustomer
+	}
+
+	route, err := s.registry.Route(payment)
+	if errors.Is(err, providers.ErrProviderNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, payment.Provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if payment.Initiator == models.PaymentInitiatorMerchant {
+		if route.Providers[0].Name() != payment.Provider {
+			return nil, fmt.Errorf("%w: %s", providers.ErrUnavailable, payment.Provider)
+		}
+		route.Providers = route.Providers[:1]
 	}
 
-	transactionID, err := s.transactionService.CreateTransaction(ctx, &txn)
+	// Запись платежа в базу (со статусом created)
+	payment.Provider = route.Providers[0].Name()
+	payment.RoutingRule = route.Rule
+	payment.Status = models.PaymentStatusCreated
+	if err := s.repo.CreatePayment(ctx, &payment); err != nil {
+		return nil, err
+	}
 
+	result, err := s.send(ctx, &payment, route)
+	if err != nil && !providers.NotProcessed(err) {
+		// таймаут или 5xx: провайдер мог списать деньги, поэтому платёж ждёт
+		// уведомления или сверки, а не отклоняется — из failed в succeeded
+		// перехода нет
+		logger.Sugared().Warnf("payment %d: outcome at %s is unknown: %v", payment.ID, payment.Provider, err)
+		if terr := s.apply(ctx, &payment, models.PaymentStatusProcessing, err.Error(), SourceProvider); terr != nil {
+			return nil, terr
+		}
+		return &payment, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("payment successful, but failed to record transaction: %w", err)
+		// провайдер недоступен или явно отказал — платёж у него не создан
+		reason := err.Error()
+		if providers.IsRetryable(err) {
+			reason = FailureProviderUnavailable
+		}
+		if terr := s.apply(ctx, &payment, models.PaymentStatusFailed, reason, SourceProvider); terr != nil {
+			logger.Sugared().Errorf("payment %d: failed to mark as failed: %v", payment.ID, terr)
+		}
+		return nil, err
 	}
 
-	// Обновим статус и свяжем транзакцию
-	if err := s.repo.MarkAsCompleted(ctx, payment.ID, fmt.Sprintf("%d", transactionID)); err != nil {
+	if result.TransactionID != "" {
+		if err := s.repo.SetProviderPaymentID(ctx, payment.ID, result.TransactionID); err != nil {
+			return nil, err
+		}
+		payment.ProviderPaymentID = result.TransactionID
+	}
+	if result.PaymentMethodID != "" {
+		if err := s.repo.SetSavedPaymentMethod(ctx, payment.ID, result.PaymentMethodID); err != nil {
+			return nil, err
+		}
+		payment.SavedPaymentMethodID = result.PaymentMethodID
+	}
+	if result.Status == models.PaymentStatusRequiresAction {
+		expiresAt := time.Now().Add(s.cfg.ActionTimeout)
+		if err := s.repo.SetConfirmation(ctx, payment.ID, result.ConfirmationURL, expiresAt); err != nil {
+			return nil, err
+		}
+		payment.ConfirmationURL = result.ConfirmationURL
+		payment.ActionExpiresAt = &expiresAt
+	}
+
+	if err := s.apply(ctx, &payment, result.Status, result.Error, SourceProvider); err != nil {
 		return nil, err
 	}
 
-	payment.TransactionID = fmt.Sprintf("%d", transactionID)
-	result.TransactionID = payment.TransactionID
+	return &payment, nil
+}
+
+// send отправляет платёж провайдерам маршрута по очереди. К следующему
+// переходим, только если текущий платёж точно не принял (ErrUnavailable):
+// иначе деньги могли бы списаться дважды.
+func (s *PaymentService) send(ctx context.Context, payment *models.Payment, route *providers.Route) (*models.PaymentResult, error) {
+	var lastErr error
+	for i, provider := range route.Providers {
+		if i > 0 {
+			if err := s.repo.SetProvider(ctx, payment.ID, provider.Name()); err != nil {
+				return nil, err
+			}
+			payment.Provider = provider.Name()
+		}
+
+		started := time.Now()
+		result, err := provider.ProcessPayment(ctx, *payment)
+		attempt := &models.PaymentRoutingAttempt{
+			PaymentID: payment.ID,
+			Attempt:   i + 1,
+			Provider:  provider.Name(),
+			Outcome:   providers.OutcomeAccepted,
+			LatencyMS: time.Since(started).Milliseconds(),
+		}
+		switch {
+		case providers.IsRetryable(err):
+			attempt.Outcome = providers.OutcomeUnavailable
+		case err != nil:
+			attempt.Outcome = providers.OutcomeError
+		case result.Status == models.PaymentStatusFailed:
+			attempt.Outcome = providers.OutcomeDeclined
+		}
+		if err != nil {
+			attempt.Error = err.Error()
+		} else if result.Error != "" {
+			attempt.Error = result.Error
+		}
 
-	return result, nil
+		s.registry.Report(provider.Name(), attempt.Outcome)
+		if aerr := s.repo.AddRoutingAttempt(ctx, attempt); aerr != nil {
+			logger.Sugared().Errorf("payment %d: failed to record routing attempt: %v", payment.ID, aerr)
+		}
+
+		if !providers.IsRetryable(err) {
+			return result, err
+		}
+		logger.Sugared().Warnf("payment %d: %s unavailable, failing over: %v", payment.ID, provider.Name(), err)
+		lastErr = err
+	}
+	return nil, lastErr
 }
 
-// RefundPayment — возврат средств
-func (s *PaymentService) RefundPayment(ctx context.Context, paymentID int64) error {
-	payment, err := s.repo.GetPaymentByID(ctx, paymentID)
-	if err != nil {
+// apply переводит платёж в статус to. Переход выполняется условным UPDATE,
+// поэтому из параллельных запросов и уведомлений его совершит только один.
+// Счёт пополняется ровно при переходе в succeeded.
+func (s *PaymentService) apply(ctx context.Context, p *models.Payment, to, reason, source string) error {
+	if to == "" || to == p.Status {
+		return nil
+	}
+	if !CanTransition(p.Status, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, p.Status, to)
+	}
+
+	change := &models.PaymentStatusChange{
+		PaymentID:  p.ID,
+		FromStatus: p.Status,
+		ToStatus:   to,
+		Reason:     reason,
+		Source:     source,
+	}
+	if err := s.repo.Transition(ctx, change); err != nil {
 		return err
 	}
+	p.Status = to
+	if to == models.PaymentStatusFailed {
+		p.FailureReason = reason
+	}
+	if to != models.PaymentStatusRequiresAction {
+		p.ConfirmationURL, p.ActionExpiresAt = "", nil
+	}
 
-	if payment.IsRefunded {
-		return errors.New("payment already refunded")
+	if to == models.PaymentStatusSucceeded {
+		s.credit(ctx, p)
+	}
+	for _, hook := range s.hooks {
+		hook(ctx, p)
+	}
+	return nil
+}
+
+// credit зачисляет успешный платёж на счёт; комиссия за пополнение по тарифу
+// списывается в той же транзакции БД. Статус к этому моменту уже succeeded,
+// поэтому ошибка только логируется: платёж без transaction_id находится сверкой.
+func (s *PaymentService) credit(ctx context.Context, p *models.Payment) {
+	txn := &models.Transaction{
+		ToAccount:   p.AccountID,
+		Amount:      p.Amount,
+		Type:        "deposit",
+		Description: fmt.Sprintf("External payment %d via %s", p.ID, p.Provider),
+	}
+	_, err := s.transactionService.PostWithFee(ctx, models.FeeRequest{
+		UserID:    p.UserID,
+		Operation: models.FeeOperationPayment,
+		Channel:   p.Method,
+		Currency:  p.Currency,
+		Amount:    p.Amount,
+	}, p.AccountID, txn, nil)
+	if err != nil {
+		logger.Sugared().Errorf("payment %d succeeded, but failed to credit account %d: %v", p.ID, p.AccountID, err)
+		return
 	}
 
-	provider, ok := s.providers[payment.Provider]
+	// Свяжем транзакцию
+	p.TransactionID = strconv.FormatInt(txn.ID, 10)
+	if err := s.repo.SetTransaction(ctx, p.ID, p.TransactionID); err != nil {
+		logger.Sugared().Errorf("payment %d: failed to link transaction %s: %v", p.ID, p.TransactionID, err)
+	}
+}
+
+// HandleWebhook проверяет и обрабатывает уведомление провайдера. Повторно
+// доставленное событие не обрабатывается; при ошибке событие остаётся
+// необработанным, и провайдер пришлёт его снова.
+func (s *PaymentService) HandleWebhook(ctx context.Context, providerName string, r *http.Request, body []byte) error {
+	provider, err := s.provider(providerName)
+	if err != nil {
+		return err
+	}
+	verifier, ok := provider.(providers.WebhookVerifier)
 	if !ok {
-		return fmt.Errorf("payment provider '%s' not found", payment.Provider)
+		return ErrUnknownProvider
 	}
 
-	if err := provider.Refund(ctx, payment.TransactionID, payment.Amount); err != nil {
+	event, err := verifier.ParseWebhook(r, body)
+	if err != nil {
 		return err
 	}
 
-	// Обратная транзакция
-	txn := models.Transaction{
-		FromAccount: payment.AccountID,
-		ToAccount:   0,
-		Amount:      payment.Amount,
-		Type:        "refund",
-		IsReversal:  true,
-		Timestamp:   time.Now(),
-		Description: fmt.Sprintf("Refund to %s for payment %d", payment.Provider, payment.ID),
+	eventID, fresh, err := s.repo.SaveWebhookEvent(ctx, providerName, event.ID, event.Type, event.ProviderPaymentID, body)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return nil
 	}
 
-	_, err = s.transactionService.CreateTransaction(ctx, &txn)
+	switch {
+	case event.Refund != nil:
+		err = s.applyRefundEvent(ctx, providerName, event.Refund)
+	case event.Payout != nil:
+		err = s.applyPayoutEvent(ctx, providerName, event.Payout)
+	case event.Status != "":
+		err = s.applyEvent(ctx, providerName, event)
+	}
 	if err != nil {
-		return fmt.Errorf("refund successful, but failed to record reversal: %w", err)
+		return err
 	}
 
-	return s.repo.MarkAsRefunded(ctx, paymentID)
+	return s.repo.MarkWebhookProcessed(ctx, eventID)
 }
 
-func (s *PaymentService) AddPaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
-	// простая валидация
-	if method.UserID == 0 || method.Type == "" || method.Token == "" {
-		return errors.New("invalid payment method")
+func (s *PaymentService) applyEvent(ctx context.Context, providerName string, event *providers.WebhookEvent) error {
+	p, err := s.repo.GetByProviderPaymentID(ctx, providerName, event.ProviderPaymentID)
+	if err != nil {
+		return err
+	}
+	if p == nil && event.PaymentID != 0 {
+		// уведомление пришло раньше, чем мы сохранили ID провайдера
+		p, err = s.repo.GetPaymentByID(ctx, event.PaymentID)
+		if errors.Is(err, ErrPaymentNotFound) {
+			p = nil
+		} else if err != nil {
+			return err
+		}
+		if p != nil && (p.Provider != providerName || p.ProviderPaymentID != "") {
+			p = nil
+		}
+		if p != nil {
+			if err := s.repo.SetProviderPaymentID(ctx, p.ID, event.ProviderPaymentID); err != nil {
+				return err
+			}
+			p.ProviderPaymentID = event.ProviderPaymentID
+		}
+	}
+	if p == nil {
+		logger.Sugared().Warnf("%s webhook %s: payment %s not found", providerName, event.ID, event.ProviderPaymentID)
+		return nil
 	}
 
-	method.CreatedAt = time.Now()
-	return s.repo.CreatePaymentMethod(ctx, method)
+	if event.Status == models.PaymentStatusSucceeded && event.Amount != 0 &&
+		(math.Abs(event.Amount-p.Amount) >= 0.005 || (event.Currency != "" && !strings.EqualFold(event.Currency, p.Currency))) {
+		logger.Sugared().Errorf("%s webhook %s: payment %d amount mismatch: got %.2f %s, expected %.2f %s",
+			providerName, event.ID, p.ID, event.Amount, event.Currency, p.Amount, p.Currency)
+		return nil
+	}
+
+	if event.PaymentMethodID != "" && p.SavedPaymentMethodID == "" {
+		if err := s.repo.SetSavedPaymentMethod(ctx, p.ID, event.PaymentMethodID); err != nil {
+			return err
+		}
+		p.SavedPaymentMethodID = event.PaymentMethodID
+	}
+
+	err = s.apply(ctx, p, event.Status, event.FailureReason, SourceWebhook)
+	if errors.Is(err, ErrInvalidTransition) {
+		// уведомления приходят не по порядку: устаревшее событие просто пропускаем
+		logger.Sugared().Infof("%s webhook %s: payment %d: %v, ignored", providerName, event.ID, p.ID, err)
+		return nil
+	}
+	return err
 }
 
-func (s *PaymentService) GetPaymentMethods(ctx context.Context, userID int64) ([]models.PaymentMethod, error) {
-	return s.repo.GetPaymentMethodsByUser(ctx, userID)
+// PaymentDetails — платёж с историей статусов и обращениями к провайдерам
+type PaymentDetails struct {
+	Payment *models.Payment                `json:"payment"`
+	History []models.PaymentStatusChange   `json:"history"`
+	Routing []models.PaymentRoutingAttempt `json:"routing"`
 }
 
-func (s *PaymentService) DeletePaymentMethod(ctx context.Context, userID int64, methodID int64) error {
-	// Проверим, существует ли метод и принадлежит ли он пользователю
-	method, err := s.repo.GetPaymentMethodByID(ctx, methodID)
+// GetPayment возвращает платёж владельца вместе с историей статусов и маршрутизации
+func (s *PaymentService) GetPayment(ctx context.Context, userID, paymentID int64) (*PaymentDetails, error) {
+	p, err := s.repo.GetPaymentByID(ctx, paymentID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	if method.UserID != userID {
-		return errors.New("unauthorized: payment method does not belong to the user")
+	if p.UserID != userID {
+		return nil, ErrPaymentAccessDenied
+	}
+	history, err := s.repo.ListStatusHistory(ctx, paymentID)
+	if err != nil {
+		return nil, err
 	}
+	routing, err := s.repo.ListRoutingAttempts(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	return &PaymentDetails{Payment: p, History: history, Routing: routing}, nil
+}
 
-	return s.repo.DeletePaymentMethod(ctx, methodID)
+// ProviderStats возвращает состояние провайдеров: предохранители и долю успешных платежей
+func (s *PaymentService) ProviderStats() []providers.ProviderStats {
+	return s.registry.Stats()
 }
 
 func (s *PaymentService) GetPaymentByID(ctx context.Context, paymentID int64) (*models.Payment, error) {
@@ -0,0 +1,223 @@
+package payment
+
+import (
+	"bank-api/internal/models"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrSettlementFormat = errors.New("unrecognized settlement file format")
+
+// settlementFormat — колонки отчёта провайдера. У колонки может быть
+// несколько названий: выгрузки из личного кабинета и отчёты API называют их
+// по-разному. Названия сравниваются без учёта регистра.
+type settlementFormat struct {
+	objectID        map[string][]string // вид операции -> колонки с её ID у провайдера, по приоритету
+	kind            []string            // пусто — все строки относятся к defaultKind
+	kinds           map[string]string   // тип строки -> вид операции; строки других типов пропускаются
+	defaultKind     string
+	amount          []string
+	fee             []string
+	currency        []string // пусто — defaultCurrency
+	date            []string
+	defaultCurrency string
+}
+
+// Форматы отчётов по провайдерам: Stripe — CSV балансовых транзакций
+// (Balance → Export или отчёт balance_change_from_activity.itemized),
+// YooKassa — реестр операций из личного кабинета.
+var settlementFormats = map[string]settlementFormat{
+	"stripe": {
+		objectID: map[string][]string{
+			// ID строки — txn_..., платёж у нас хранится по PaymentIntent
+			models.SettlementKindPayment: {"payment_intent_id", "payment_intent", "source_id", "source"},
+			models.SettlementKindRefund:  {"source_id", "source"},
+			models.SettlementKindPayout:  {"source_id", "source"},
+		},
+		kind: []string{"reporting_category", "type"},
+		kinds: map[string]string{
+			"charge":         models.SettlementKindPayment,
+			"payment":        models.SettlementKindPayment,
+			"refund":         models.SettlementKindRefund,
+			"payment_refund": models.SettlementKindRefund,
+			"payout":         models.SettlementKindPayout,
+		},
+		amount:   []string{"gross", "amount"},
+		fee:      []string{"fee"},
+		currency: []string{"currency"},
+		date:     []string{"created_utc", "created (utc)", "created"},
+	},
+	"yookassa": {
+		objectID: map[string][]string{
+			models.SettlementKindPayment: {"идентификатор платежа", "идентификатор операции", "id"},
+			models.SettlementKindRefund:  {"идентификатор возврата", "идентификатор операции", "id"},
+			models.SettlementKindPayout:  {"идентификатор выплаты", "идентификатор операции", "id"},
+		},
+		kind: []string{"тип операции", "type"},
+		kinds: map[string]string{
+			"платёж":  models.SettlementKindPayment,
+			"платеж":  models.SettlementKindPayment,
+			"оплата":  models.SettlementKindPayment,
+			"payment": models.SettlementKindPayment,
+			"возврат": models.SettlementKindRefund,
+			"refund":  models.SettlementKindRefund,
+			"выплата": models.SettlementKindPayout,
+			"payout":  models.SettlementKindPayout,
+		},
+		defaultKind:     models.SettlementKindPayment,
+		amount:          []string{"сумма операции", "сумма платежа", "сумма", "amount"},
+		fee:             []string{"комиссия", "сумма комиссии", "fee"},
+		currency:        []string{"валюта", "currency"},
+		date:            []string{"дата операции", "дата платежа", "дата", "created_at"},
+		defaultCurrency: "RUB",
+	},
+}
+
+var settlementDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"02.01.2006",
+}
+
+// parseSettlementFile разбирает CSV-отчёт провайдера. Разделитель (запятая
+// или точка с запятой) определяется по заголовку. Строки чужих типов
+// (комиссии, корректировки) пропускаются; ошибка в любой нужной строке
+// отклоняет весь файл, чтобы он не импортировался частично.
+func parseSettlementFile(provider string, data []byte) ([]models.SettlementEntry, error) {
+	format, ok := settlementFormats[provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+	}
+
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	header := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		header = data[:i]
+	}
+	reader := csv.NewReader(bytes.NewReader(data))
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	names, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSettlementFormat, err)
+	}
+	columns := make(map[string]int, len(names))
+	for i, name := range names {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	column := func(aliases []string) int {
+		for _, alias := range aliases {
+			if i, ok := columns[alias]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+
+	kindCol, amountCol, feeCol := column(format.kind), column(format.amount), column(format.fee)
+	currencyCol, dateCol := column(format.currency), column(format.date)
+	switch {
+	case kindCol < 0 && format.defaultKind == "":
+		return nil, fmt.Errorf("%w: no type column", ErrSettlementFormat)
+	case amountCol < 0:
+		return nil, fmt.Errorf("%w: no amount column", ErrSettlementFormat)
+	case dateCol < 0:
+		return nil, fmt.Errorf("%w: no date column", ErrSettlementFormat)
+	case currencyCol < 0 && format.defaultCurrency == "":
+		return nil, fmt.Errorf("%w: no currency column", ErrSettlementFormat)
+	}
+
+	var entries []models.SettlementEntry
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSettlementFormat, err)
+		}
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+
+		kind := format.defaultKind
+		if kindCol >= 0 {
+			kind = format.kinds[strings.ToLower(field(kindCol))]
+		}
+		if kind == "" {
+			continue
+		}
+
+		entry := models.SettlementEntry{Provider: provider, Kind: kind, Currency: format.defaultCurrency}
+		for _, alias := range format.objectID[kind] {
+			if i, ok := columns[alias]; ok && field(i) != "" {
+				entry.ProviderObjectID = field(i)
+				break
+			}
+		}
+		if entry.ProviderObjectID == "" {
+			return nil, fmt.Errorf("%w: line %d: no %s ID", ErrSettlementFormat, line, kind)
+		}
+		if entry.Amount, err = parseSettlementAmount(field(amountCol)); err != nil {
+			return nil, fmt.Errorf("%w: line %d: amount: %v", ErrSettlementFormat, line, err)
+		}
+		if feeCol >= 0 && field(feeCol) != "" {
+			if entry.Fee, err = parseSettlementAmount(field(feeCol)); err != nil {
+				return nil, fmt.Errorf("%w: line %d: fee: %v", ErrSettlementFormat, line, err)
+			}
+		}
+		if currencyCol >= 0 && field(currencyCol) != "" {
+			entry.Currency = strings.ToUpper(field(currencyCol))
+		}
+		if entry.SettledAt, err = parseSettlementDate(field(dateCol)); err != nil {
+			return nil, fmt.Errorf("%w: line %d: date: %v", ErrSettlementFormat, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// parseSettlementAmount разбирает сумму в рублях или долларах: «1 000,50»,
+// «-1000.50». Знак отбрасывается: вид операции задаёт её направление.
+func parseSettlementAmount(s string) (float64, error) {
+	s = strings.NewReplacer(" ", "", "\u00a0", "", ",", ".").Replace(s)
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return math.Round(math.Abs(v)*100) / 100, nil
+}
+
+// parseSettlementDate разбирает дату отчёта; дата без зоны считается UTC
+func parseSettlementDate(s string) (time.Time, error) {
+	if unix, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(unix, 0).UTC(), nil
+	}
+	for _, layout := range settlementDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported date %q", s)
+}
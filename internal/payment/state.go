@@ -0,0 +1,52 @@
+package payment
+
+import "bank-api/internal/models"
+
+// Источники смены статуса
+const (
+	SourceAPI      = "api"
+	SourceProvider = "provider"
+	SourceWebhook  = "webhook"
+	SourceSystem   = "system"
+)
+
+// transitions — допустимые переходы жизненного цикла платежа
+var transitions = map[string][]string{
+	models.PaymentStatusCreated: {
+		models.PaymentStatusRequiresAction, models.PaymentStatusProcessing,
+		models.PaymentStatusSucceeded, models.PaymentStatusFailed, models.PaymentStatusCanceled,
+	},
+	models.PaymentStatusRequiresAction: {
+		models.PaymentStatusProcessing, models.PaymentStatusSucceeded,
+		models.PaymentStatusFailed, models.PaymentStatusCanceled,
+	},
+	models.PaymentStatusProcessing: {
+		models.PaymentStatusRequiresAction, models.PaymentStatusSucceeded,
+		models.PaymentStatusFailed, models.PaymentStatusCanceled,
+	},
+	models.PaymentStatusSucceeded: {
+		models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded,
+	},
+	models.PaymentStatusPartiallyRefunded: {
+		models.PaymentStatusRefunded,
+	},
+}
+
+// CanTransition сообщает, разрешён ли переход from -> to
+func CanTransition(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal — статус, из которого платёж уже не может стать успешным
+func IsFinal(status string) bool {
+	switch status {
+	case models.PaymentStatusFailed, models.PaymentStatusCanceled, models.PaymentStatusRefunded:
+		return true
+	}
+	return false
+}
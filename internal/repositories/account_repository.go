@@ -17,20 +17,26 @@ func NewAccountRepository(db *sqlx.DB) *AccountRepository {
 	return &AccountRepository{DB: db}
 }
 
-// CreateAccount inserts a new account for a given user ID
+// CreateAccount inserts a new current account for a given user ID
 func (r *AccountRepository) CreateAccount(ctx context.Context, userID int64) (*models.Account, error) {
+	return r.CreateAccountOfKind(ctx, userID, "current")
+}
+
+// CreateAccountOfKind inserts a new account of the given kind (current, deposit, ...)
+func (r *AccountRepository) CreateAccountOfKind(ctx context.Context, userID int64, kind string) (*models.Account, error) {
 	account := &models.Account{
 		UserID:  userID,
 		Balance: 0,
+		Kind:    kind,
 	}
 
 	query := `
-		INSERT INTO accounts (user_id, balance)
-		VALUES ($1, $2)
+		INSERT INTO accounts (user_id, balance, kind)
+		VALUES ($1, $2, $3)
 		RETURNING id, created_at
 	`
 
-	err := r.DB.QueryRowContext(ctx, query, account.UserID, account.Balance).
+	err := r.DB.QueryRowContext(ctx, query, account.UserID, account.Balance, account.Kind).
 		Scan(&account.ID, &account.CreatedAt)
 
 	if err != nil {
@@ -41,7 +47,7 @@ func (r *AccountRepository) CreateAccount(ctx context.Context, userID int64) (*m
 
 func (r *AccountRepository) HasAccountByUserID(ctx context.Context, userID int64) (bool, error) {
 	var exists bool
-	query := `SELECT EXISTS (SELECT 1 FROM accounts WHERE user_id = $1)`
+	query := `SELECT EXISTS (SELECT 1 FROM accounts WHERE user_id = $1 AND kind = 'current')`
 	err := r.DB.QueryRowContext(ctx, query, userID).Scan(&exists)
 	return exists, err
 }
@@ -63,3 +69,48 @@ func (r *AccountRepository) IsAccountOwnedByUser(ctx context.Context, accountID
 	err := r.DB.GetContext(ctx, &count, `SELECT COUNT(*) FROM accounts WHERE id = $1 AND user_id = $2`, accountID, userID)
 	return count > 0, err
 }
+
+func (r *AccountRepository) GetAccountKind(ctx context.Context, accountID int64) (string, error) {
+	var kind string
+	err := r.DB.QueryRowContext(ctx, `SELECT kind FROM accounts WHERE id = $1`, accountID).Scan(&kind)
+	if err == sql.ErrNoRows {
+		return "", errors.New("account not found")
+	}
+	return kind, err
+}
+
+// GetAvailableBalance returns balance plus active overdraft limit minus card holds
+func (r *AccountRepository) GetAvailableBalance(ctx context.Context, accountID int64) (float64, error) {
+	var available float64
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT a.balance + COALESCE(o.limit_amount, 0) - COALESCE((
+			SELECT SUM(h.amount) FROM card_authorizations h WHERE h.account_id = a.id AND h.status = 'held'
+		), 0)
+		FROM accounts a
+		LEFT JOIN overdrafts o ON o.account_id = a.id AND o.status = 'active'
+		WHERE a.id = $1
+	`, accountID).Scan(&available)
+
+	if err == sql.ErrNoRows {
+		return 0, errors.New("account not found")
+	}
+	return available, err
+}
+
+// GetHeldAmount returns sum of card authorization holds not yet captured
+func (r *AccountRepository) GetHeldAmount(ctx context.Context, accountID int64) (float64, error) {
+	var held float64
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount), 0) FROM card_authorizations WHERE account_id = $1 AND status = 'held'
+	`, accountID).Scan(&held)
+	return held, err
+}
+
+func (r *AccountRepository) GetAccountOwner(ctx context.Context, accountID int64) (int64, error) {
+	var userID int64
+	err := r.DB.QueryRowContext(ctx, `SELECT user_id FROM accounts WHERE id = $1`, accountID).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, errors.New("account not found")
+	}
+	return userID, err
+}
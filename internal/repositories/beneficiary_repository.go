@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"bank-api/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type BeneficiaryRepository struct {
+	DB *sqlx.DB
+}
+
+func NewBeneficiaryRepository(db *sqlx.DB) *BeneficiaryRepository {
+	return &BeneficiaryRepository{DB: db}
+}
+
+const beneficiaryColumns = `
+	id, user_id, name, COALESCE(account_id, 0) AS account_id, COALESCE(phone, '') AS phone,
+	COALESCE(bank_name, '') AS bank_name, COALESCE(nickname, '') AS nickname,
+	is_favourite, status, created_at, updated_at
+`
+
+// CreateBeneficiary сохраняет получателя; codeHash заполняется, только если нужно подтверждение
+func (r *BeneficiaryRepository) CreateBeneficiary(ctx context.Context, b *models.Beneficiary, codeHash string, expiresAt time.Time) error {
+	var hash interface{}
+	var expires interface{}
+	if codeHash != "" {
+		hash = codeHash
+		expires = expiresAt
+	}
+
+	query := `
+		INSERT INTO beneficiaries (user_id, name, account_id, phone, bank_name, nickname, is_favourite, status,
+			confirmation_code_hash, confirmation_expires_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at
+	`
+	return r.DB.QueryRowContext(ctx, query,
+		b.UserID, b.Name, nullInt64(b.AccountID), b.Phone, b.BankName, b.Nickname, b.IsFavourite, b.Status,
+		hash, expires,
+	).Scan(&b.ID, &b.CreatedAt, &b.UpdatedAt)
+}
+
+func (r *BeneficiaryRepository) GetBeneficiaryByID(ctx context.Context, id int64) (*models.Beneficiary, error) {
+	var b models.Beneficiary
+	err := r.DB.GetContext(ctx, &b, `SELECT `+beneficiaryColumns+` FROM beneficiaries WHERE id = $1`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &b, nil
+}
+
+// ListBeneficiariesByUser возвращает адресную книгу пользователя, избранные — первыми
+func (r *BeneficiaryRepository) ListBeneficiariesByUser(ctx context.Context, userID int64) ([]models.Beneficiary, error) {
+	var list []models.Beneficiary
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT `+beneficiaryColumns+`
+		FROM beneficiaries
+		WHERE user_id = $1
+		ORDER BY is_favourite DESC, name
+	`, userID)
+	return list, err
+}
+
+func (r *BeneficiaryRepository) UpdateBeneficiary(ctx context.Context, b *models.Beneficiary) error {
+	query := `
+		UPDATE beneficiaries
+		SET name = $1, bank_name = NULLIF($2, ''), nickname = NULLIF($3, ''), is_favourite = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING updated_at
+	`
+	err := r.DB.QueryRowContext(ctx, query, b.Name, b.BankName, b.Nickname, b.IsFavourite, b.ID).Scan(&b.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("beneficiary not found")
+	}
+	return err
+}
+
+// ClaimConfirmationAttempt засчитывает попытку ввода кода и возвращает хэш
+// кода, срок его действия и число оставшихся попыток. Попытка списывается до
+// проверки кода, поэтому параллельные запросы не обойдут лимит maxAttempts;
+// когда попытки исчерпаны или кода нет, возвращается пустой хэш.
+func (r *BeneficiaryRepository) ClaimConfirmationAttempt(ctx context.Context, id int64, maxAttempts int) (string, time.Time, int, error) {
+	var hash sql.NullString
+	var expiresAt sql.NullTime
+	var attempts int
+	err := r.DB.QueryRowContext(ctx, `
+		UPDATE beneficiaries SET confirmation_attempts = confirmation_attempts + 1, updated_at = NOW()
+		WHERE id = $1 AND confirmation_code_hash IS NOT NULL AND confirmation_attempts < $2
+		RETURNING confirmation_code_hash, confirmation_expires_at, confirmation_attempts
+	`, id, maxAttempts).Scan(&hash, &expiresAt, &attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", time.Time{}, 0, nil
+	}
+	if err != nil {
+		return "", time.Time{}, 0, err
+	}
+	return hash.String, expiresAt.Time, maxAttempts - attempts, nil
+}
+
+// InvalidateConfirmation стирает код подтверждения, чтобы его больше нельзя было ввести
+func (r *BeneficiaryRepository) InvalidateConfirmation(ctx context.Context, id int64) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE beneficiaries SET confirmation_code_hash = NULL, confirmation_expires_at = NULL, updated_at = NOW()
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+// Activate переводит получателя в статус active и стирает код подтверждения
+func (r *BeneficiaryRepository) Activate(ctx context.Context, id int64) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE beneficiaries
+		SET status = 'active', confirmation_code_hash = NULL, confirmation_expires_at = NULL,
+			confirmation_attempts = 0, updated_at = NOW()
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+func (r *BeneficiaryRepository) DeleteBeneficiary(ctx context.Context, id int64) error {
+	result, err := r.DB.ExecContext(ctx, `DELETE FROM beneficiaries WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return errors.New("beneficiary not found")
+	}
+	return nil
+}
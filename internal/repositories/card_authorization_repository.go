@@ -0,0 +1,233 @@
+package repositories
+
+import (
+	"bank-api/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var ErrDuplicateRRN = errors.New("authorization with this RRN already exists")
+
+type CardAuthorizationRepository struct {
+	DB *sqlx.DB
+}
+
+func NewCardAuthorizationRepository(db *sqlx.DB) *CardAuthorizationRepository {
+	return &CardAuthorizationRepository{DB: db}
+}
+
+const authorizationColumns = `id, card_id, account_id, message_type, rrn, stan, terminal_id, merchant_id, merchant, mcc,
+	amount, captured_amount, auth_code, response_code, decline_reason, channel, entry_mode, country, status,
+	transaction_id, expires_at, created_at, updated_at`
+
+type execQuerier interface {
+	QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row
+}
+
+func insertAuthorization(ctx context.Context, q execQuerier, a *models.CardAuthorization) error {
+	err := q.QueryRowxContext(ctx, `
+		INSERT INTO card_authorizations (card_id, account_id, message_type, rrn, stan, terminal_id, merchant_id,
+			merchant, mcc, amount, auth_code, response_code, decline_reason, channel, entry_mode, country,
+			status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		RETURNING id, created_at, updated_at
+	`, a.CardID, a.AccountID, a.MessageType, a.RRN, a.STAN, a.TerminalID, a.MerchantID,
+		a.Merchant, a.MCC, a.Amount, a.AuthCode, a.ResponseCode, a.DeclineReason, a.Channel, a.EntryMode, a.Country,
+		a.Status, a.ExpiresAt).
+		Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateRRN
+	}
+	return err
+}
+
+// CreateAuthorization сохраняет авторизацию без блокировки средств (отказы)
+func (r *CardAuthorizationRepository) CreateAuthorization(ctx context.Context, a *models.CardAuthorization) error {
+	return insertAuthorization(ctx, r.DB, a)
+}
+
+// PlaceHold сохраняет одобренную авторизацию, если доступного остатка
+// (с учётом овердрафта и уже действующих hold) хватает и не превышены лимиты
+// карты. Счёт блокируется на время проверки, поэтому параллельные авторизации
+// не превысят ни остаток, ни лимиты. Возвращает причину отказа или "".
+func (r *CardAuthorizationRepository) PlaceHold(ctx context.Context, a *models.CardAuthorization, rules models.HoldRules) (string, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var available float64
+	err = tx.QueryRowContext(ctx, `
+		SELECT a.balance + COALESCE(o.limit_amount, 0)
+		FROM accounts a
+		LEFT JOIN overdrafts o ON o.account_id = a.id AND o.status = 'active'
+		WHERE a.id = $1
+		FOR UPDATE OF a
+	`, a.AccountID).Scan(&available)
+	if err != nil {
+		return "", err
+	}
+
+	var held float64
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount), 0) FROM card_authorizations WHERE account_id = $1 AND status = 'held'
+	`, a.AccountID).Scan(&held)
+	if err != nil {
+		return "", err
+	}
+	if available-held < a.Amount {
+		return models.DeclineInsufficientFunds, nil
+	}
+
+	for _, limit := range rules.Limits {
+		var spent float64
+		err = tx.QueryRowContext(ctx, `
+			SELECT COALESCE(SUM(COALESCE(captured_amount, amount)), 0)
+			FROM card_authorizations
+			WHERE card_id = $1 AND status IN ('held', 'captured') AND created_at >= $2
+				AND ($4 OR (channel = 'atm') = $3)
+		`, a.CardID, limit.Since, limit.ATM, limit.Any).Scan(&spent)
+		if err != nil {
+			return "", err
+		}
+		if spent+a.Amount > limit.Limit {
+			return limit.Reason, nil
+		}
+	}
+
+	// строка счёта заблокирована, поэтому операции по карте проверяются по очереди
+	if rules.SingleUse {
+		var used bool
+		err = tx.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM card_authorizations WHERE card_id = $1 AND status IN ('held', 'captured'))
+		`, a.CardID).Scan(&used)
+		if err != nil {
+			return "", err
+		}
+		if used {
+			return models.DeclineSingleUseUsed, nil
+		}
+	}
+	if rules.Merchant != "" {
+		// первая операция привязывает карту к продавцу
+		var locked string
+		err = tx.QueryRowContext(ctx, `
+			UPDATE cards SET locked_merchant = COALESCE(locked_merchant, $1) WHERE id = $2 RETURNING locked_merchant
+		`, rules.Merchant, a.CardID).Scan(&locked)
+		if err != nil {
+			return "", err
+		}
+		if locked != rules.Merchant {
+			return models.DeclineMerchantLocked, nil
+		}
+	}
+
+	if err := insertAuthorization(ctx, tx, a); err != nil {
+		return "", err
+	}
+	return "", tx.Commit()
+}
+
+// GetByRRN возвращает авторизацию по RRN или nil
+func (r *CardAuthorizationRepository) GetByRRN(ctx context.Context, rrn string) (*models.CardAuthorization, error) {
+	var a models.CardAuthorization
+	err := r.DB.GetContext(ctx, &a, `SELECT `+authorizationColumns+` FROM card_authorizations WHERE rrn = $1`, rrn)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// MarkCaptured переводит hold в списание; false — авторизация уже не в статусе held
+func (r *CardAuthorizationRepository) MarkCaptured(ctx context.Context, id int64, amount float64) (bool, error) {
+	result, err := r.DB.ExecContext(ctx, `
+		UPDATE card_authorizations SET status = 'captured', captured_amount = $1, updated_at = NOW()
+		WHERE id = $2 AND status = 'held'
+	`, amount, id)
+	if err != nil {
+		return false, err
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// RevertCapture возвращает hold, если провести списание не удалось
+func (r *CardAuthorizationRepository) RevertCapture(ctx context.Context, id int64) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE card_authorizations SET status = 'held', captured_amount = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'captured' AND transaction_id IS NULL
+	`, id)
+	return err
+}
+
+func (r *CardAuthorizationRepository) SetTransaction(ctx context.Context, id, transactionID int64) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE card_authorizations SET transaction_id = $1, updated_at = NOW() WHERE id = $2
+	`, transactionID, id)
+	return err
+}
+
+// MarkReversed отменяет авторизацию, если она всё ещё в статусе from. Если
+// передан refund, возврат списанной суммы проводится в той же транзакции БД:
+// отмена и зачисление проходят вместе или не проходят вовсе.
+func (r *CardAuthorizationRepository) MarkReversed(ctx context.Context, id int64, from string, refund *models.Transaction) (bool, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE card_authorizations SET status = 'reversed', updated_at = NOW()
+		WHERE id = $1 AND status = $2
+	`, id, from)
+	if err != nil {
+		return false, err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return false, nil
+	}
+
+	if refund != nil {
+		if err := postTransaction(ctx, tx, refund); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// ExpireHolds снимает hold, срок которых истёк до now
+func (r *CardAuthorizationRepository) ExpireHolds(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.DB.ExecContext(ctx, `
+		UPDATE card_authorizations SET status = 'expired', updated_at = NOW()
+		WHERE status = 'held' AND expires_at < $1
+	`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ListByCard возвращает последние авторизации по карте
+func (r *CardAuthorizationRepository) ListByCard(ctx context.Context, cardID int64, limit int) ([]models.CardAuthorization, error) {
+	var list []models.CardAuthorization
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT `+authorizationColumns+`
+		FROM card_authorizations
+		WHERE card_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, cardID, limit)
+	return list, err
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"bank-api/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type CardControlRepository struct {
+	DB *sqlx.DB
+}
+
+func NewCardControlRepository(db *sqlx.DB) *CardControlRepository {
+	return &CardControlRepository{DB: db}
+}
+
+// GetControls возвращает ограничения карты или nil, если они не заданы
+func (r *CardControlRepository) GetControls(ctx context.Context, cardID int64) (*models.CardControls, error) {
+	var c models.CardControls
+	err := r.DB.GetContext(ctx, &c, `
+		SELECT card_id, daily_limit, monthly_limit, per_transaction_limit, atm_daily_limit, atm_monthly_limit,
+			blocked_categories, blocked_mcc_codes, online_enabled, contactless_enabled, magstripe_enabled,
+			international_enabled, updated_at
+		FROM card_controls
+		WHERE card_id = $1
+	`, cardID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *CardControlRepository) SaveControls(ctx context.Context, c *models.CardControls) error {
+	return r.DB.QueryRowContext(ctx, `
+		INSERT INTO card_controls (card_id, daily_limit, monthly_limit, per_transaction_limit, atm_daily_limit,
+			atm_monthly_limit, blocked_categories, blocked_mcc_codes, online_enabled, contactless_enabled,
+			magstripe_enabled, international_enabled, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW())
+		ON CONFLICT (card_id) DO UPDATE SET
+			daily_limit = EXCLUDED.daily_limit,
+			monthly_limit = EXCLUDED.monthly_limit,
+			per_transaction_limit = EXCLUDED.per_transaction_limit,
+			atm_daily_limit = EXCLUDED.atm_daily_limit,
+			atm_monthly_limit = EXCLUDED.atm_monthly_limit,
+			blocked_categories = EXCLUDED.blocked_categories,
+			blocked_mcc_codes = EXCLUDED.blocked_mcc_codes,
+			online_enabled = EXCLUDED.online_enabled,
+			contactless_enabled = EXCLUDED.contactless_enabled,
+			magstripe_enabled = EXCLUDED.magstripe_enabled,
+			international_enabled = EXCLUDED.international_enabled,
+			updated_at = NOW()
+		RETURNING updated_at
+	`, c.CardID, c.DailyLimit, c.MonthlyLimit, c.PerTransactionLimit, c.ATMDailyLimit, c.ATMMonthlyLimit,
+		c.BlockedCategories, c.BlockedMCCCodes, c.OnlineEnabled, c.ContactlessEnabled, c.MagstripeEnabled,
+		c.InternationalEnabled).Scan(&c.UpdatedAt)
+}
@@ -6,129 +6,351 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type CardRepository struct {
 	DB *sqlx.DB
 }
 
-func (r *CardRepository) CreateCard(ctx context.Context, card *models.Card) error {
-	query := `
-		INSERT INTO cards (account_id, encrypted_data, hmac, cvv, created_at)
-		VALUES ($1, $2, $3, $4, NOW())
-		RETURNING id, created_at
-	`
-	err := r.DB.QueryRowContext(
-		ctx,
-		query,
-		card.AccountID,
-		card.EncryptedData,
-		card.HMAC,
-		card.CVV,
-	).Scan(&card.ID, &card.CreatedAt)
-	return err
+// NextCardID резервирует ID карты до вставки (он нужен для шифрования)
+func (r *CardRepository) NextCardID(ctx context.Context) (int64, error) {
+	var id int64
+	err := r.DB.QueryRowContext(ctx, `SELECT nextval(pg_get_serial_sequence('cards', 'id'))`).Scan(&id)
+	return id, err
 }
 
-func (r *CardRepository) GetCardByID(ctx context.Context, cardID int64) (*models.Card, error) {
-	query := `
-		SELECT id, account_id, encrypted_data, hmac, cvv, created_at
-		FROM cards
-		WHERE id = $1
-	`
+var ErrDuplicatePAN = errors.New("card number already issued")
+
+const cardColumns = `
+	id, account_id, encrypted_data, hmac, COALESCE(cvv, '') AS cvv, COALESCE(wrapped_dek, '') AS wrapped_dek,
+	key_id, COALESCE(pan_hash, '') AS pan_hash, COALESCE(product, '') AS product, COALESCE(brand, '') AS brand,
+	status, COALESCE(status_reason, '') AS status_reason, status_changed_at, expires_at, replaces_card_id,
+	form_factor, virtual_type, spend_cap, COALESCE(locked_merchant, '') AS locked_merchant,
+	pin_offset IS NOT NULL AS pin_set, created_at
+`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCard(row rowScanner) (*models.Card, error) {
 	var card models.Card
-	err := r.DB.QueryRowContext(ctx, query, cardID).Scan(
+	err := row.Scan(
 		&card.ID,
 		&card.AccountID,
 		&card.EncryptedData,
 		&card.HMAC,
 		&card.CVV,
+		&card.WrappedDEK,
+		&card.KeyID,
+		&card.PANHash,
+		&card.Product,
+		&card.Brand,
+		&card.Status,
+		&card.StatusReason,
+		&card.StatusChangedAt,
+		&card.ExpiresAt,
+		&card.ReplacesCardID,
+		&card.FormFactor,
+		&card.VirtualType,
+		&card.SpendCap,
+		&card.LockedMerchant,
+		&card.PINSet,
 		&card.CreatedAt,
 	)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil // карта не найдена
-		}
 		return nil, err
 	}
 	return &card, nil
 }
 
-func (r *CardRepository) GetCardsByAccountID(ctx context.Context, accountID int64) ([]*models.Card, error) {
-	query := `
-		SELECT id, account_id, encrypted_data, hmac, cvv, created_at
-		FROM cards
-		WHERE account_id = $1
-	`
-	rows, err := r.DB.QueryContext(ctx, query, accountID)
+func (r *CardRepository) queryCards(ctx context.Context, query string, args ...interface{}) ([]*models.Card, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
 	defer rows.Close()
 
 	var cards []*models.Card
 	for rows.Next() {
-		var card models.Card
-		if err := rows.Scan(
-			&card.ID,
-			&card.AccountID,
-			&card.EncryptedData,
-			&card.HMAC,
-			&card.CVV,
-			&card.CreatedAt,
-		); err != nil {
-			return nil, err
+		card, err := scanCard(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan card: %w", err)
 		}
-		cards = append(cards, &card)
+		cards = append(cards, card)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %w", err)
 	}
 	return cards, nil
 }
 
-func (r *CardRepository) GetCardsByUser(ctx context.Context, userID int64) ([]*models.Card, error) {
-	rows, err := r.DB.QueryContext(ctx, `SELECT id, account_id, encrypted_data, hmac, cvv, created_at FROM cards WHERE account_id IN (SELECT id FROM accounts WHERE user_id = $1)`, userID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %w", err)
+func (r *CardRepository) CreateCard(ctx context.Context, card *models.Card) error {
+	query := `
+		INSERT INTO cards (id, account_id, encrypted_data, hmac, cvv, wrapped_dek, key_id, pan_hash, product, brand,
+			status, expires_at, replaces_card_id, form_factor, virtual_type, spend_cap, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, NOW())
+		RETURNING id, created_at
+	`
+	err := r.DB.QueryRowContext(
+		ctx,
+		query,
+		card.ID,
+		card.AccountID,
+		card.EncryptedData,
+		card.HMAC,
+		card.CVV,
+		card.WrappedDEK,
+		card.KeyID,
+		card.PANHash,
+		card.Product,
+		card.Brand,
+		card.Status,
+		card.ExpiresAt,
+		card.ReplacesCardID,
+		card.FormFactor,
+		card.VirtualType,
+		card.SpendCap,
+	).Scan(&card.ID, &card.CreatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "idx_cards_pan_hash" {
+		return ErrDuplicatePAN
 	}
-	defer rows.Close()
+	return err
+}
 
-	var cards []*models.Card
-	for rows.Next() {
-		var card models.Card
-		if err := rows.Scan(&card.ID, &card.AccountID, &card.EncryptedData, &card.HMAC, &card.CVV, &card.CreatedAt); err != nil {
-			return nil, fmt.Errorf("failed to scan card: %w", err)
+// PANHashExists проверяет, выпускался ли уже номер с одним из хешей
+func (r *CardRepository) PANHashExists(ctx context.Context, panHashes []string) (bool, error) {
+	var exists bool
+	err := r.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM cards WHERE pan_hash = ANY($1))`, pq.Array(panHashes)).Scan(&exists)
+	return exists, err
+}
+
+func (r *CardRepository) GetCardByID(ctx context.Context, cardID int64) (*models.Card, error) {
+	card, err := scanCard(r.DB.QueryRowContext(ctx, `SELECT `+cardColumns+` FROM cards WHERE id = $1`, cardID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // карта не найдена
 		}
-		cards = append(cards, &card)
+		return nil, err
 	}
+	return card, nil
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("failed to iterate rows: %w", err)
+// GetCardByPANHash ищет карту по хешам номера под текущим и прежними ключами,
+// nil — не найдена
+func (r *CardRepository) GetCardByPANHash(ctx context.Context, panHashes []string) (*models.Card, error) {
+	card, err := scanCard(r.DB.QueryRowContext(ctx,
+		`SELECT `+cardColumns+` FROM cards WHERE pan_hash = ANY($1) LIMIT 1`, pq.Array(panHashes)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
 	}
+	return card, err
+}
 
-	return cards, nil
+func (r *CardRepository) GetCardsByAccountID(ctx context.Context, accountID int64) ([]*models.Card, error) {
+	return r.queryCards(ctx, `SELECT `+cardColumns+` FROM cards WHERE account_id = $1`, accountID)
+}
+
+func (r *CardRepository) GetCardsByUser(ctx context.Context, userID int64) ([]*models.Card, error) {
+	return r.queryCards(ctx, `
+		SELECT `+cardColumns+`
+		FROM cards
+		WHERE account_id IN (SELECT id FROM accounts WHERE user_id = $1)
+	`, userID)
+}
+
+var ErrCardStatusChanged = errors.New("card status changed concurrently")
+
+// GetCardOwner возвращает владельца счёта, к которому привязана карта
+func (r *CardRepository) GetCardOwner(ctx context.Context, cardID int64) (int64, error) {
+	var userID int64
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT a.user_id FROM cards c JOIN accounts a ON a.id = c.account_id WHERE c.id = $1
+	`, cardID).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return userID, err
 }
 
-func (r *CardRepository) DeleteCard(ctx context.Context, cardID int64) error {
-	result, err := r.DB.ExecContext(ctx, `DELETE FROM cards WHERE id = $1`, cardID)
+// ChangeStatus меняет статус, если он всё ещё равен from, и пишет запись в журнал
+func (r *CardRepository) ChangeStatus(ctx context.Context, change *models.CardStatusChange) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE cards SET status = $1, status_reason = $2, status_changed_at = NOW()
+		WHERE id = $3 AND status = $4
+	`, change.ToStatus, change.Reason, change.CardID, change.FromStatus)
 	if err != nil {
 		return err
 	}
 	rows, _ := result.RowsAffected()
 	if rows == 0 {
-		return errors.New("card not found")
+		return ErrCardStatusChanged
 	}
-	return nil
-}
 
-func (r *CardRepository) SetCardStatus(ctx context.Context, cardID int64, status string) error {
-	query := `UPDATE cards SET status = $1 WHERE id = $2`
-	result, err := r.DB.ExecContext(ctx, query, status, cardID)
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO card_status_history (card_id, from_status, to_status, reason, actor_type, actor_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, change.CardID, change.FromStatus, change.ToStatus, change.Reason, change.ActorType, change.ActorID).
+		Scan(&change.ID, &change.CreatedAt)
 	if err != nil {
 		return err
 	}
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
-		return errors.New("card not found")
+	return tx.Commit()
+}
+
+func (r *CardRepository) ListStatusHistory(ctx context.Context, cardID int64) ([]models.CardStatusChange, error) {
+	var list []models.CardStatusChange
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT id, card_id, from_status, to_status, reason, actor_type, actor_id, created_at
+		FROM card_status_history
+		WHERE card_id = $1
+		ORDER BY id
+	`, cardID)
+	return list, err
+}
+
+// LastStatusChange возвращает последнюю смену статуса карты или nil
+func (r *CardRepository) LastStatusChange(ctx context.Context, cardID int64) (*models.CardStatusChange, error) {
+	var change models.CardStatusChange
+	err := r.DB.GetContext(ctx, &change, `
+		SELECT id, card_id, from_status, to_status, reason, actor_type, actor_id, created_at
+		FROM card_status_history
+		WHERE card_id = $1
+		ORDER BY id DESC
+		LIMIT 1
+	`, cardID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return &change, nil
+}
+
+// ListExpiredCards возвращает действующие карты, срок действия которых истёк до date.
+// Заблокированные навсегда карты не истекают — их можно только закрыть.
+func (r *CardRepository) ListExpiredCards(ctx context.Context, date time.Time) ([]*models.Card, error) {
+	return r.queryCards(ctx, `
+		SELECT `+cardColumns+`
+		FROM cards
+		WHERE expires_at < $1 AND status IN ('inactive', 'active', 'temp_blocked')
+		ORDER BY id
+	`, date)
+}
+
+// ListCardsForRenewal возвращает действующие физические карты, истекающие не позже until,
+// для которых ещё не выпущена замена. Виртуальные карты не перевыпускаются.
+func (r *CardRepository) ListCardsForRenewal(ctx context.Context, until time.Time) ([]*models.Card, error) {
+	return r.queryCards(ctx, `
+		SELECT `+cardColumns+`
+		FROM cards c
+		WHERE c.expires_at <= $1 AND c.status IN ('active', 'temp_blocked') AND c.form_factor = 'physical'
+			AND NOT EXISTS (SELECT 1 FROM cards n WHERE n.replaces_card_id = c.id)
+		ORDER BY c.id
+	`, until)
+}
+
+// GetReplacement возвращает карту, выпущенную на замену cardID, или nil
+func (r *CardRepository) GetReplacement(ctx context.Context, cardID int64) (*models.Card, error) {
+	card, err := scanCard(r.DB.QueryRowContext(ctx, `
+		SELECT `+cardColumns+` FROM cards WHERE replaces_card_id = $1 ORDER BY id DESC LIMIT 1
+	`, cardID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return card, err
+}
+
+// ListCardsWithoutExpiry — карты, выпущенные до появления колонки expires_at
+func (r *CardRepository) ListCardsWithoutExpiry(ctx context.Context, afterID int64, limit int) ([]*models.Card, error) {
+	return r.queryCards(ctx, `
+		SELECT `+cardColumns+`
+		FROM cards
+		WHERE id > $1 AND expires_at IS NULL
+		ORDER BY id
+		LIMIT $2
+	`, afterID, limit)
+}
+
+func (r *CardRepository) SetExpiry(ctx context.Context, cardID int64, expiresAt time.Time) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE cards SET expires_at = $1 WHERE id = $2`, expiresAt, cardID)
+	return err
+}
+
+// ListCardsToReencrypt возвращает следующую пачку карт после afterID, зашифрованных
+// не ключом keyID или ещё без хеша номера (выпущенных до его появления)
+func (r *CardRepository) ListCardsToReencrypt(ctx context.Context, keyID string, afterID int64, limit int) ([]*models.Card, error) {
+	return r.queryCards(ctx, `
+		SELECT `+cardColumns+`
+		FROM cards
+		WHERE id > $1 AND (key_id <> $2 OR pan_hash IS NULL)
+		ORDER BY id
+		LIMIT $3
+	`, afterID, keyID, limit)
+}
+
+// UpdateCardEncryption сохраняет перешифрованные данные, если запись не менялась с момента чтения
+func (r *CardRepository) UpdateCardEncryption(ctx context.Context, card *models.Card, previousData string) (bool, error) {
+	result, err := r.DB.ExecContext(ctx, `
+		UPDATE cards SET encrypted_data = $1, hmac = $2, cvv = NULLIF($3, ''), wrapped_dek = $4, key_id = $5,
+			pan_hash = NULLIF($6, '')
+		WHERE id = $7 AND encrypted_data = $8
+	`, card.EncryptedData, card.HMAC, card.CVV, card.WrappedDEK, card.KeyID, card.PANHash, card.ID, previousData)
+	if err != nil {
+		return false, err
+	}
+	rows, _ := result.RowsAffected()
+	return rows == 1, nil
+}
+
+// CountCardsByKey возвращает количество карт, зашифрованных ключом keyID
+func (r *CardRepository) CountCardsByKey(ctx context.Context, keyID string) (int, error) {
+	var count int
+	err := r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM cards WHERE key_id = $1`, keyID).Scan(&count)
+	return count, err
+}
+
+// GetPIN возвращает смещение PIN ("" — PIN не установлен) и число неверных попыток
+func (r *CardRepository) GetPIN(ctx context.Context, cardID int64) (offset string, tries int, err error) {
+	err = r.DB.QueryRowContext(ctx, `
+		SELECT COALESCE(pin_offset, ''), pin_tries FROM cards WHERE id = $1
+	`, cardID).Scan(&offset, &tries)
+	return offset, tries, err
+}
+
+// SetPIN сохраняет смещение PIN и сбрасывает счётчик попыток
+func (r *CardRepository) SetPIN(ctx context.Context, cardID int64, offset string) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE cards SET pin_offset = $1, pin_tries = 0, pin_set_at = NOW() WHERE id = $2
+	`, offset, cardID)
+	return err
+}
+
+// IncrementPINTries увеличивает счётчик неверных PIN и возвращает новое значение
+func (r *CardRepository) IncrementPINTries(ctx context.Context, cardID int64) (int, error) {
+	var tries int
+	err := r.DB.QueryRowContext(ctx, `
+		UPDATE cards SET pin_tries = pin_tries + 1 WHERE id = $1 RETURNING pin_tries
+	`, cardID).Scan(&tries)
+	return tries, err
+}
+
+func (r *CardRepository) ResetPINTries(ctx context.Context, cardID int64) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE cards SET pin_tries = 0 WHERE id = $1 AND pin_tries > 0`, cardID)
+	return err
 }
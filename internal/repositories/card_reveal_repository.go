@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"bank-api/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type CardRevealRepository struct {
+	DB *sqlx.DB
+}
+
+func NewCardRevealRepository(db *sqlx.DB) *CardRevealRepository {
+	return &CardRevealRepository{DB: db}
+}
+
+func (r *CardRevealRepository) Create(ctx context.Context, reveal *models.CardReveal) error {
+	return r.DB.QueryRowContext(ctx, `
+		INSERT INTO card_reveals (card_id, user_id, token_hash, outcome, ip, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, reveal.CardID, reveal.UserID, reveal.TokenHash, reveal.Outcome, reveal.IP, reveal.UserAgent, reveal.ExpiresAt).
+		Scan(&reveal.ID, &reveal.CreatedAt)
+}
+
+// Consume отмечает токен пользователя использованным. nil — токена нет, он истёк или уже использован.
+func (r *CardRevealRepository) Consume(ctx context.Context, tokenHash string, userID int64, ip string) (*models.CardReveal, error) {
+	var reveal models.CardReveal
+	err := r.DB.GetContext(ctx, &reveal, `
+		UPDATE card_reveals SET revealed_at = NOW(), revealed_ip = $2
+		WHERE token_hash = $1 AND user_id = $3 AND revealed_at IS NULL AND expires_at > NOW()
+		RETURNING id, card_id, user_id, token_hash, outcome, ip, user_agent, expires_at, revealed_at, revealed_ip, created_at
+	`, tokenHash, ip, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &reveal, nil
+}
+
+// CountSince — сколько запросов на показ реквизитов сделал пользователь с момента since
+func (r *CardRevealRepository) CountSince(ctx context.Context, userID int64, since time.Time) (int, error) {
+	var count int
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM card_reveals WHERE user_id = $1 AND created_at >= $2
+	`, userID, since).Scan(&count)
+	return count, err
+}
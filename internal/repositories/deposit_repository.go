@@ -0,0 +1,183 @@
+package repositories
+
+import (
+	"bank-api/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type DepositRepository struct {
+	DB *sqlx.DB
+}
+
+func NewDepositRepository(db *sqlx.DB) *DepositRepository {
+	return &DepositRepository{DB: db}
+}
+
+const depositColumns = `
+	d.id, d.user_id, d.product_id, d.account_id, d.linked_account_id, a.balance, d.rate,
+	d.accrued_interest, d.status, d.opened_at, d.maturity_date, d.last_accrual_date, d.closed_at
+`
+
+func (r *DepositRepository) ListActiveProducts(ctx context.Context) ([]models.DepositProduct, error) {
+	var products []models.DepositProduct
+	err := r.DB.SelectContext(ctx, &products, `SELECT * FROM deposit_products WHERE is_active = TRUE ORDER BY id`)
+	return products, err
+}
+
+func (r *DepositRepository) GetProductByID(ctx context.Context, id int64) (*models.DepositProduct, error) {
+	var p models.DepositProduct
+	err := r.DB.GetContext(ctx, &p, `SELECT * FROM deposit_products WHERE id = $1`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *DepositRepository) CreateDeposit(ctx context.Context, d *models.Deposit) error {
+	query := `
+		INSERT INTO deposits (user_id, product_id, account_id, linked_account_id, rate, status, maturity_date)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, opened_at
+	`
+	return r.DB.QueryRowContext(ctx, query,
+		d.UserID, d.ProductID, d.AccountID, d.LinkedAccountID, d.Rate, d.Status, d.MaturityDate,
+	).Scan(&d.ID, &d.OpenedAt)
+}
+
+func (r *DepositRepository) GetDepositByID(ctx context.Context, id int64) (*models.Deposit, error) {
+	var d models.Deposit
+	err := r.DB.GetContext(ctx, &d, `
+		SELECT `+depositColumns+`
+		FROM deposits d JOIN accounts a ON a.id = d.account_id
+		WHERE d.id = $1
+	`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *DepositRepository) ListDepositsByUser(ctx context.Context, userID int64) ([]models.Deposit, error) {
+	var deposits []models.Deposit
+	err := r.DB.SelectContext(ctx, &deposits, `
+		SELECT `+depositColumns+`
+		FROM deposits d JOIN accounts a ON a.id = d.account_id
+		WHERE d.user_id = $1
+		ORDER BY d.opened_at DESC
+	`, userID)
+	return deposits, err
+}
+
+// ListOpenDeposits возвращает все открытые вклады для ночного начисления
+func (r *DepositRepository) ListOpenDeposits(ctx context.Context) ([]models.Deposit, error) {
+	var deposits []models.Deposit
+	err := r.DB.SelectContext(ctx, &deposits, `
+		SELECT `+depositColumns+`
+		FROM deposits d JOIN accounts a ON a.id = d.account_id
+		WHERE d.status = 'open'
+		ORDER BY d.id
+	`)
+	return deposits, err
+}
+
+// AddAccrual записывает дневное начисление и увеличивает накопленные проценты.
+// Повторный вызов за ту же дату ничего не меняет и возвращает false.
+func (r *DepositRepository) AddAccrual(ctx context.Context, a *models.DepositAccrual) (bool, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO deposit_accruals (deposit_id, accrual_date, balance, rate, amount)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (deposit_id, accrual_date) DO NOTHING
+	`, a.DepositID, a.AccrualDate, a.Balance, a.Rate, a.Amount)
+	if err != nil {
+		return false, err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE deposits
+		SET accrued_interest = accrued_interest + $1, last_accrual_date = $2
+		WHERE id = $3
+	`, a.Amount, a.AccrualDate, a.DepositID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// PostInterest выплачивает накопленные проценты одной транзакцией БД: проводка
+// txn и уменьшение накопленных процентов проходят вместе или не проходят вовсе.
+// Сумма (целые копейки) берётся из заблокированной строки вклада, поэтому
+// параллельные выплаты не возьмут проценты дважды; меньше копейки — ничего не
+// проводится и возвращается false.
+func (r *DepositRepository) PostInterest(ctx context.Context, id int64, txn *models.Transaction) (bool, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var accrued float64
+	err = tx.QueryRowContext(ctx, `SELECT accrued_interest FROM deposits WHERE id = $1 FOR UPDATE`, id).Scan(&accrued)
+	if err != nil {
+		return false, err
+	}
+
+	txn.Amount = math.Floor(accrued*100) / 100
+	if txn.Amount < 0.01 {
+		return false, nil
+	}
+	if err := postTransaction(ctx, tx, txn); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE deposits SET accrued_interest = GREATEST(accrued_interest - $1, 0) WHERE id = $2`,
+		txn.Amount, id,
+	); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (r *DepositRepository) UpdateRate(ctx context.Context, id int64, rate float64) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE deposits SET rate = $1 WHERE id = $2`, rate, id)
+	return err
+}
+
+// SumAccruals возвращает сумму начислений и сумму, пересчитанную по ставке rate
+func (r *DepositRepository) SumAccruals(ctx context.Context, id int64, rate float64) (earned, atRate float64, err error) {
+	err = r.DB.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount), 0), COALESCE(SUM(balance * $2 / 365 / 100), 0)
+		FROM deposit_accruals
+		WHERE deposit_id = $1
+	`, id, rate).Scan(&earned, &atRate)
+	return earned, atRate, err
+}
+
+func (r *DepositRepository) SetStatus(ctx context.Context, id int64, status string) error {
+	query := `UPDATE deposits SET status = $1 WHERE id = $2`
+	if status != "open" {
+		query = `UPDATE deposits SET status = $1, closed_at = NOW(), accrued_interest = 0 WHERE id = $2`
+	}
+	_, err := r.DB.ExecContext(ctx, query, status, id)
+	return err
+}
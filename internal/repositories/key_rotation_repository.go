@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"bank-api/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type KeyRotationRepository struct {
+	DB *sqlx.DB
+}
+
+func NewKeyRotationRepository(db *sqlx.DB) *KeyRotationRepository {
+	return &KeyRotationRepository{DB: db}
+}
+
+// GetRunningJob возвращает незавершённую задачу перешифрования на ключ targetKeyID
+func (r *KeyRotationRepository) GetRunningJob(ctx context.Context, targetKeyID string) (*models.ReencryptionJob, error) {
+	var job models.ReencryptionJob
+	err := r.DB.GetContext(ctx, &job, `
+		SELECT id, target_key_id, last_card_id, processed, failed, status, started_at, updated_at, completed_at
+		FROM reencryption_jobs
+		WHERE target_key_id = $1 AND status = 'running'
+		ORDER BY id DESC
+		LIMIT 1
+	`, targetKeyID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *KeyRotationRepository) CreateJob(ctx context.Context, job *models.ReencryptionJob) error {
+	return r.DB.QueryRowContext(ctx, `
+		INSERT INTO reencryption_jobs (target_key_id) VALUES ($1)
+		RETURNING id, last_card_id, processed, failed, status, started_at, updated_at
+	`, job.TargetKeyID).Scan(&job.ID, &job.LastCardID, &job.Processed, &job.Failed, &job.Status, &job.StartedAt, &job.UpdatedAt)
+}
+
+// SaveProgress фиксирует обработанную пачку
+func (r *KeyRotationRepository) SaveProgress(ctx context.Context, job *models.ReencryptionJob) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE reencryption_jobs
+		SET last_card_id = $1, processed = $2, failed = $3, updated_at = NOW()
+		WHERE id = $4
+	`, job.LastCardID, job.Processed, job.Failed, job.ID)
+	return err
+}
+
+func (r *KeyRotationRepository) CompleteJob(ctx context.Context, jobID int64) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE reencryption_jobs SET status = 'completed', completed_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+	`, jobID)
+	return err
+}
+
+func (r *KeyRotationRepository) RetireKey(ctx context.Context, keyID string) error {
+	_, err := r.DB.ExecContext(ctx, `
+		INSERT INTO retired_encryption_keys (key_id) VALUES ($1)
+		ON CONFLICT DO NOTHING
+	`, keyID)
+	return err
+}
+
+func (r *KeyRotationRepository) ListRetiredKeys(ctx context.Context) ([]string, error) {
+	var ids []string
+	err := r.DB.SelectContext(ctx, &ids, `SELECT key_id FROM retired_encryption_keys ORDER BY key_id`)
+	return ids, err
+}
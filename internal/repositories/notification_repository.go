@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"bank-api/internal/models"
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type NotificationRepository struct {
+	DB *sqlx.DB
+}
+
+func NewNotificationRepository(db *sqlx.DB) *NotificationRepository {
+	return &NotificationRepository{DB: db}
+}
+
+func (r *NotificationRepository) CreateNotification(ctx context.Context, n *models.Notification) error {
+	return r.DB.QueryRowContext(ctx, `
+		INSERT INTO notifications (user_id, kind, message)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, n.UserID, n.Kind, n.Message).Scan(&n.ID, &n.CreatedAt)
+}
+
+// ListNotificationsByUser возвращает последние уведомления, новые первыми
+func (r *NotificationRepository) ListNotificationsByUser(ctx context.Context, userID int64, unreadOnly bool, limit int) ([]models.Notification, error) {
+	var list []models.Notification
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT id, user_id, kind, message, read_at, created_at
+		FROM notifications
+		WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY id DESC
+		LIMIT $3
+	`, userID, unreadOnly, limit)
+	return list, err
+}
+
+// MarkRead отмечает уведомление прочитанным. false — у пользователя такого нет.
+func (r *NotificationRepository) MarkRead(ctx context.Context, userID, id int64) (bool, error) {
+	result, err := r.DB.ExecContext(ctx, `
+		UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id = $1 AND user_id = $2
+	`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
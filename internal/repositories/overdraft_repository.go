@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"bank-api/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type OverdraftRepository struct {
+	DB *sqlx.DB
+}
+
+func NewOverdraftRepository(db *sqlx.DB) *OverdraftRepository {
+	return &OverdraftRepository{DB: db}
+}
+
+const overdraftColumns = `
+	o.id, o.account_id, o.limit_amount, o.interest_rate, o.accrued_interest, o.status, o.granted_by,
+	o.last_accrual_date, o.interest_charged_on, a.balance, o.created_at, o.updated_at
+`
+
+// Upsert выдаёт овердрафт или меняет его условия
+func (r *OverdraftRepository) Upsert(ctx context.Context, o *models.Overdraft) error {
+	query := `
+		INSERT INTO overdrafts (account_id, limit_amount, interest_rate, status, granted_by)
+		VALUES ($1, $2, $3, 'active', $4)
+		ON CONFLICT (account_id) DO UPDATE
+		SET limit_amount = EXCLUDED.limit_amount, interest_rate = EXCLUDED.interest_rate,
+			status = 'active', granted_by = EXCLUDED.granted_by, updated_at = NOW()
+		RETURNING id, accrued_interest, status, created_at, updated_at
+	`
+	return r.DB.QueryRowContext(ctx, query, o.AccountID, o.LimitAmount, o.InterestRate, o.GrantedBy).
+		Scan(&o.ID, &o.AccruedInterest, &o.Status, &o.CreatedAt, &o.UpdatedAt)
+}
+
+func (r *OverdraftRepository) GetByAccountID(ctx context.Context, accountID int64) (*models.Overdraft, error) {
+	var o models.Overdraft
+	err := r.DB.GetContext(ctx, &o, `
+		SELECT `+overdraftColumns+`
+		FROM overdrafts o JOIN accounts a ON a.id = o.account_id
+		WHERE o.account_id = $1
+	`, accountID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &o, nil
+}
+
+// Revoke отзывает лимит; уже возникший долг остаётся и гасится поступлениями
+func (r *OverdraftRepository) Revoke(ctx context.Context, accountID int64) error {
+	result, err := r.DB.ExecContext(ctx, `
+		UPDATE overdrafts SET status = 'revoked', limit_amount = 0, updated_at = NOW()
+		WHERE account_id = $1
+	`, accountID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return errors.New("overdraft not found")
+	}
+	return nil
+}
+
+// AccrueDaily начисляет проценты на отрицательные остатки за дни с прошлого
+// начисления, поэтому пропущенные запуски догоняются. Дата начисления
+// сдвигается и у счетов без долга, чтобы при уходе в минус проценты не
+// начислились за дни, когда остаток был положительным. Возвращает число
+// счетов, по которым начислены проценты.
+func (r *OverdraftRepository) AccrueDaily(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.DB.QueryRowContext(ctx, `
+		WITH accrued AS (
+			UPDATE overdrafts o
+			SET accrued_interest = o.accrued_interest + CASE WHEN a.balance < 0
+					THEN (-a.balance) * o.interest_rate / 365 / 100 * (CURRENT_DATE - COALESCE(o.last_accrual_date, CURRENT_DATE - 1))
+					ELSE 0 END,
+				last_accrual_date = CURRENT_DATE
+			FROM accounts a
+			WHERE a.id = o.account_id
+				AND (o.last_accrual_date IS NULL OR o.last_accrual_date < CURRENT_DATE)
+			RETURNING a.balance < 0 AS charged
+		)
+		SELECT COUNT(*) FROM accrued WHERE charged
+	`).Scan(&count)
+	return count, err
+}
+
+// ListDueForCharge возвращает овердрафты с неуплаченными процентами, которые
+// с прошлого списания (или с выдачи) ещё не списывались в текущем месяце
+func (r *OverdraftRepository) ListDueForCharge(ctx context.Context) ([]models.Overdraft, error) {
+	var list []models.Overdraft
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT `+overdraftColumns+`
+		FROM overdrafts o JOIN accounts a ON a.id = o.account_id
+		WHERE o.accrued_interest >= 0.01
+			AND COALESCE(o.interest_charged_on, o.created_at::date) < date_trunc('month', CURRENT_DATE)
+		ORDER BY o.id
+	`)
+	return list, err
+}
+
+// ChargeInterest списывает накопленные проценты одной транзакцией БД:
+// проводка txn и уменьшение накопленных процентов проходят вместе или не
+// проходят вовсе. Сумма берётся из заблокированной строки овердрафта, поэтому
+// параллельные списания не возьмут проценты дважды; меньше копейки — ничего
+// не проводится и возвращается false. periodEnd отмечает списание за
+// расчётный период.
+func (r *OverdraftRepository) ChargeInterest(ctx context.Context, txn *models.Transaction, periodEnd bool) (bool, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var accrued float64
+	err = tx.QueryRowContext(ctx, `
+		SELECT accrued_interest FROM overdrafts WHERE account_id = $1 FOR UPDATE
+	`, txn.FromAccount).Scan(&accrued)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	txn.Amount = math.Round(accrued*100) / 100
+	if txn.Amount < 0.01 {
+		return false, nil
+	}
+	if err := postTransaction(ctx, tx, txn); err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE overdrafts
+		SET accrued_interest = GREATEST(accrued_interest - $1, 0),
+			interest_charged_on = CASE WHEN $2 THEN CURRENT_DATE ELSE interest_charged_on END,
+			updated_at = NOW()
+		WHERE account_id = $3
+	`, txn.Amount, periodEnd, txn.FromAccount)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
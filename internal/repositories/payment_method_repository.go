@@ -0,0 +1,255 @@
+package repositories
+
+import (
+	"bank-api/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrPaymentMethodNotFound = errors.New("payment method not found")
+
+type PaymentMethodRepository struct {
+	DB *sqlx.DB
+}
+
+func NewPaymentMethodRepository(db *sqlx.DB) *PaymentMethodRepository {
+	return &PaymentMethodRepository{DB: db}
+}
+
+const paymentMethodColumns = `
+	id, user_id, type, COALESCE(provider, '') AS provider, token, COALESCE(brand, '') AS brand,
+	COALESCE(last4, '') AS last4, COALESCE(exp_month, 0) AS exp_month, COALESCE(exp_year, 0) AS exp_year,
+	fingerprint, is_default, is_active, COALESCE(description, '') AS description, expiry_notified_at,
+	created_at, updated_at, deleted_at
+`
+
+// срок действия способа оплаты истёк
+const paymentMethodExpired = `
+	(exp_year IS NOT NULL AND make_date(exp_year, exp_month, 1) + INTERVAL '1 month' <= NOW())
+`
+
+// Create сохраняет способ оплаты. Первый способ пользователя становится
+// основным. false — у пользователя уже есть способ с тем же отпечатком.
+func (r *PaymentMethodRepository) Create(ctx context.Context, method *models.PaymentMethod) (bool, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if method.IsDefault {
+		if err := clearDefault(ctx, tx, method.UserID); err != nil {
+			return false, err
+		}
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO payment_methods (user_id, type, provider, token, brand, last4, exp_month, exp_year,
+			fingerprint, is_default, is_active, description)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, 0), NULLIF($8, 0), $9,
+			$10 OR NOT EXISTS (SELECT 1 FROM payment_methods WHERE user_id = $1 AND is_default AND deleted_at IS NULL),
+			TRUE, $11)
+		ON CONFLICT (user_id, fingerprint) WHERE deleted_at IS NULL DO NOTHING
+		RETURNING id, is_default, is_active, created_at, updated_at
+	`, method.UserID, method.Type, method.Provider, method.Token, method.Brand, method.Last4,
+		method.ExpMonth, method.ExpYear, method.Fingerprint, method.IsDefault, method.Description,
+	).Scan(&method.ID, &method.IsDefault, &method.IsActive, &method.CreatedAt, &method.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// GetByFingerprint возвращает неудалённый способ оплаты пользователя с этим отпечатком
+func (r *PaymentMethodRepository) GetByFingerprint(ctx context.Context, userID int64, fingerprint string) (*models.PaymentMethod, error) {
+	var method models.PaymentMethod
+	err := r.DB.GetContext(ctx, &method, `
+		SELECT `+paymentMethodColumns+` FROM payment_methods
+		WHERE user_id = $1 AND fingerprint = $2 AND deleted_at IS NULL
+	`, userID, fingerprint)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPaymentMethodNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &method, nil
+}
+
+// GetByUserID возвращает неудалённые способы пользователя, основной — первым
+func (r *PaymentMethodRepository) GetByUserID(ctx context.Context, userID int64) ([]models.PaymentMethod, error) {
+	var methods []models.PaymentMethod
+	err := r.DB.SelectContext(ctx, &methods, `
+		SELECT `+paymentMethodColumns+` FROM payment_methods
+		WHERE user_id = $1 AND deleted_at IS NULL
+		ORDER BY is_default DESC, created_at DESC, id DESC
+	`, userID)
+	return methods, err
+}
+
+// GetByID возвращает способ оплаты, в том числе удалённый
+func (r *PaymentMethodRepository) GetByID(ctx context.Context, id int64) (*models.PaymentMethod, error) {
+	var method models.PaymentMethod
+	err := r.DB.GetContext(ctx, &method, `SELECT `+paymentMethodColumns+` FROM payment_methods WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPaymentMethodNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &method, nil
+}
+
+// UpdateDetails обновляет сведения о карте, например срок после перевыпуска.
+// Если срок изменился, о его окончании снова напомним.
+func (r *PaymentMethodRepository) UpdateDetails(ctx context.Context, method *models.PaymentMethod) error {
+	return r.DB.QueryRowContext(ctx, `
+		UPDATE payment_methods
+		SET brand = NULLIF($1, ''), last4 = NULLIF($2, ''),
+			expiry_notified_at = CASE
+				WHEN exp_month IS DISTINCT FROM NULLIF($3, 0) OR exp_year IS DISTINCT FROM NULLIF($4, 0) THEN NULL
+				ELSE expiry_notified_at END,
+			exp_month = NULLIF($3, 0), exp_year = NULLIF($4, 0),
+			description = COALESCE(NULLIF($5, ''), description), updated_at = NOW()
+		WHERE id = $6 AND deleted_at IS NULL
+		RETURNING updated_at
+	`, method.Brand, method.Last4, method.ExpMonth, method.ExpYear, method.Description, method.ID).
+		Scan(&method.UpdatedAt)
+}
+
+// SetDefault делает способ основным. false — способа нет или он удалён.
+func (r *PaymentMethodRepository) SetDefault(ctx context.Context, userID, id int64) (bool, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if err := clearDefault(ctx, tx, userID); err != nil {
+		return false, err
+	}
+	result, err := tx.ExecContext(ctx, `
+		UPDATE payment_methods SET is_default = TRUE, updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+	`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	return true, tx.Commit()
+}
+
+// SoftDelete помечает способ удалённым; запись остаётся для истории платежей
+// и подписок. Если он был основным, основным становится последний
+// добавленный действующий. false — способа нет или он уже удалён.
+func (r *PaymentMethodRepository) SoftDelete(ctx context.Context, userID, id int64) (bool, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var wasDefault bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT is_default FROM payment_methods
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+		FOR UPDATE
+	`, id, userID).Scan(&wasDefault)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE payment_methods
+		SET deleted_at = NOW(), is_active = FALSE, is_default = FALSE, updated_at = NOW()
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	if wasDefault {
+		if err := promoteDefault(ctx, tx, userID); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// ListExpiring возвращает неудалённые способы, срок которых истекает до
+// before и о котором пользователь ещё не предупреждён
+func (r *PaymentMethodRepository) ListExpiring(ctx context.Context, before time.Time, limit int) ([]models.PaymentMethod, error) {
+	var methods []models.PaymentMethod
+	err := r.DB.SelectContext(ctx, &methods, `
+		SELECT `+paymentMethodColumns+` FROM payment_methods
+		WHERE deleted_at IS NULL AND expiry_notified_at IS NULL AND exp_year IS NOT NULL
+			AND make_date(exp_year, exp_month, 1) + INTERVAL '1 month' <= $1
+		ORDER BY exp_year, exp_month, id
+		LIMIT $2
+	`, before, limit)
+	return methods, err
+}
+
+func (r *PaymentMethodRepository) MarkExpiryNotified(ctx context.Context, id int64) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE payment_methods SET expiry_notified_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// ReassignExpiredDefaults снимает признак основного с истёкших способов и
+// передаёт его последнему добавленному действующему. Возвращает число
+// затронутых пользователей.
+func (r *PaymentMethodRepository) ReassignExpiredDefaults(ctx context.Context) (int, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userIDs []int64
+	err = tx.SelectContext(ctx, &userIDs, `
+		UPDATE payment_methods SET is_default = FALSE, updated_at = NOW()
+		WHERE is_default AND deleted_at IS NULL AND `+paymentMethodExpired+`
+		RETURNING user_id
+	`)
+	if err != nil {
+		return 0, err
+	}
+	for _, userID := range userIDs {
+		if err := promoteDefault(ctx, tx, userID); err != nil {
+			return 0, err
+		}
+	}
+	return len(userIDs), tx.Commit()
+}
+
+func clearDefault(ctx context.Context, tx *sqlx.Tx, userID int64) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE payment_methods SET is_default = FALSE, updated_at = NOW()
+		WHERE user_id = $1 AND is_default AND deleted_at IS NULL
+	`, userID)
+	return err
+}
+
+// promoteDefault назначает основным последний добавленный действующий способ
+func promoteDefault(ctx context.Context, tx *sqlx.Tx, userID int64) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE payment_methods SET is_default = TRUE, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM payment_methods
+			WHERE user_id = $1 AND deleted_at IS NULL AND NOT `+paymentMethodExpired+`
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		)
+	`, userID)
+	return err
+}
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"bank-api/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrIncomeAccountNotFound = errors.New("bank income account not found")
+	ErrFeeQuotaChanged       = errors.New("free fee quota was used by another operation")
+)
+
+type PricingRepository struct {
+	DB *sqlx.DB
+}
+
+func NewPricingRepository(db *sqlx.DB) *PricingRepository {
+	return &PricingRepository{DB: db}
+}
+
+// IncomeAccountID возвращает счёт доходов банка, на который зачисляются комиссии
+func (r *PricingRepository) IncomeAccountID(ctx context.Context) (int64, error) {
+	var id int64
+	err := r.DB.QueryRowContext(ctx, `SELECT id FROM accounts WHERE kind = 'income' ORDER BY id LIMIT 1`).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrIncomeAccountNotFound
+	}
+	return id, err
+}
+
+// QuotaUsage — сколько операций и какая сумма уже прошли по квоте правила за период
+func (r *PricingRepository) QuotaUsage(ctx context.Context, userID int64, rule, period string) (int, float64, error) {
+	var operations int
+	var amount float64
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(operations), 0), COALESCE(SUM(amount), 0)
+		FROM fee_quota_usage
+		WHERE user_id = $1 AND rule = $2 AND period = $3
+	`, userID, rule, period).Scan(&operations, &amount)
+	return operations, amount, err
+}
+
+// ReleaseQuota возвращает квоту, израсходованную операцией, которая не состоялась
+func (r *PricingRepository) ReleaseQuota(ctx context.Context, transactionID int64) error {
+	_, err := r.DB.ExecContext(ctx, `DELETE FROM fee_quota_usage WHERE transaction_id = $1`, transactionID)
+	return err
+}
+
+// claimFeeQuota расходует квоту в начале транзакции операции. Строка
+// пользователя блокируется, поэтому параллельные операции не израсходуют
+// квоту дважды; если её уже не хватает, возвращается ErrFeeQuotaChanged.
+func claimFeeQuota(ctx context.Context, tx *sqlx.Tx, claim *models.FeeQuotaClaim) (int64, error) {
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, claim.UserID); err != nil {
+		return 0, err
+	}
+
+	var operations int
+	var amount float64
+	err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(operations), 0), COALESCE(SUM(amount), 0)
+		FROM fee_quota_usage
+		WHERE user_id = $1 AND rule = $2 AND period = $3
+	`, claim.UserID, claim.Rule, claim.Period).Scan(&operations, &amount)
+	if err != nil {
+		return 0, err
+	}
+	if claim.Operations > 0 && operations+claim.Operations > claim.FreeOperations {
+		return 0, ErrFeeQuotaChanged
+	}
+	if claim.Amount > 0 && amount+claim.Amount > claim.FreeAmount+0.005 {
+		return 0, ErrFeeQuotaChanged
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO fee_quota_usage (user_id, rule, period, operations, amount)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, claim.UserID, claim.Rule, claim.Period, claim.Operations, claim.Amount).Scan(&id)
+	return id, err
+}
@@ -0,0 +1,195 @@
+package repositories
+
+import (
+	"bank-api/internal/models"
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type RewardRepository struct {
+	DB *sqlx.DB
+}
+
+func NewRewardRepository(db *sqlx.DB) *RewardRepository {
+	return &RewardRepository{DB: db}
+}
+
+const rewardCampaignColumns = `
+	c.id, c.name, c.category, COALESCE(c.mcc_codes, '') AS mcc_codes, COALESCE(c.merchant, '') AS merchant,
+	COALESCE(c.transaction_type, '') AS transaction_type, c.percent, c.monthly_cap, c.requires_opt_in,
+	c.starts_at, c.ends_at, c.is_active, c.created_at
+`
+
+func (r *RewardRepository) CreateCampaign(ctx context.Context, c *models.RewardCampaign) error {
+	query := `
+		INSERT INTO reward_campaigns (name, category, mcc_codes, merchant, transaction_type, percent,
+			monthly_cap, requires_opt_in, starts_at, ends_at, is_active)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6, $7, $8, $9, $10, TRUE)
+		RETURNING id, is_active, created_at
+	`
+	return r.DB.QueryRowContext(ctx, query,
+		c.Name, c.Category, c.MCCCodes, c.Merchant, c.TransactionType, c.Percent,
+		c.MonthlyCap, c.RequiresOptIn, c.StartsAt, c.EndsAt,
+	).Scan(&c.ID, &c.IsActive, &c.CreatedAt)
+}
+
+// ListActiveCampaigns возвращает действующие кампании; opted_in заполняется для userID за период
+func (r *RewardRepository) ListActiveCampaigns(ctx context.Context, userID int64, period time.Time) ([]models.RewardCampaign, error) {
+	var list []models.RewardCampaign
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT `+rewardCampaignColumns+`,
+			EXISTS (
+				SELECT 1 FROM reward_opt_ins o
+				WHERE o.campaign_id = c.id AND o.user_id = $1 AND o.period = $2
+			) AS opted_in
+		FROM reward_campaigns c
+		WHERE c.is_active = TRUE AND c.starts_at <= NOW() AND (c.ends_at IS NULL OR c.ends_at > NOW())
+		ORDER BY c.id
+	`, userID, period)
+	return list, err
+}
+
+func (r *RewardRepository) DeactivateCampaign(ctx context.Context, id int64) error {
+	result, err := r.DB.ExecContext(ctx, `UPDATE reward_campaigns SET is_active = FALSE WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return errors.New("campaign not found")
+	}
+	return nil
+}
+
+func (r *RewardRepository) OptIn(ctx context.Context, userID, campaignID int64, period time.Time) error {
+	_, err := r.DB.ExecContext(ctx, `
+		INSERT INTO reward_opt_ins (user_id, campaign_id, period) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`, userID, campaignID, period)
+	return err
+}
+
+func (r *RewardRepository) OptOut(ctx context.Context, userID, campaignID int64, period time.Time) error {
+	_, err := r.DB.ExecContext(ctx,
+		`DELETE FROM reward_opt_ins WHERE user_id = $1 AND campaign_id = $2 AND period = $3`,
+		userID, campaignID, period,
+	)
+	return err
+}
+
+func (r *RewardRepository) CountOptIns(ctx context.Context, userID int64, period time.Time) (int, error) {
+	var count int
+	err := r.DB.GetContext(ctx, &count,
+		`SELECT COUNT(*) FROM reward_opt_ins WHERE user_id = $1 AND period = $2`, userID, period)
+	return count, err
+}
+
+// SumAccrued возвращает кэшбэк, уже начисленный пользователю по кампании за период
+func (r *RewardRepository) SumAccrued(ctx context.Context, userID, campaignID int64, period time.Time) (float64, error) {
+	var total float64
+	err := r.DB.GetContext(ctx, &total, `
+		SELECT COALESCE(SUM(amount), 0) FROM cashback_accruals
+		WHERE user_id = $1 AND campaign_id = $2 AND period = $3
+	`, userID, campaignID, period)
+	return total, err
+}
+
+// CreateAccrual сохраняет начисление; повтор по той же транзакции и кампании игнорируется
+func (r *RewardRepository) CreateAccrual(ctx context.Context, a *models.CashbackAccrual) error {
+	_, err := r.DB.ExecContext(ctx, `
+		INSERT INTO cashback_accruals (user_id, account_id, campaign_id, transaction_id, category, amount, status, period)
+		VALUES ($1, $2, $3, $4, $5, $6, 'pending', $7)
+		ON CONFLICT (transaction_id, campaign_id) DO NOTHING
+	`, a.UserID, a.AccountID, a.CampaignID, a.TransactionID, a.Category, a.Amount, a.Period)
+	return err
+}
+
+// PendingTotal — сумма ожидающего выплаты кэшбэка по счёту
+type PendingTotal struct {
+	AccountID int64   `db:"account_id"`
+	Amount    float64 `db:"amount"`
+}
+
+// ListPendingTotals группирует невыплаченный кэшбэк за периоды до before по счетам
+func (r *RewardRepository) ListPendingTotals(ctx context.Context, before time.Time) ([]PendingTotal, error) {
+	var totals []PendingTotal
+	err := r.DB.SelectContext(ctx, &totals, `
+		SELECT account_id, SUM(amount) AS amount
+		FROM cashback_accruals
+		WHERE status = 'pending' AND period < $1
+		GROUP BY account_id
+		ORDER BY account_id
+	`, before)
+	return totals, err
+}
+
+// PayPending выплачивает невыплаченный кэшбэк счёта за периоды до before одной
+// транзакцией БД: проводка txn и отметка начислений выплаченными проходят
+// вместе или не проходят вовсе. Начисления блокируются, поэтому параллельный
+// запуск не выплатит их второй раз; меньше копейки — ничего не проводится и
+// возвращается false.
+func (r *RewardRepository) PayPending(ctx context.Context, accountID int64, before time.Time, txn *models.Transaction) (bool, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var pending []struct {
+		ID     int64   `db:"id"`
+		Amount float64 `db:"amount"`
+	}
+	err = tx.SelectContext(ctx, &pending, `
+		SELECT id, amount FROM cashback_accruals
+		WHERE account_id = $1 AND status = 'pending' AND period < $2
+		FOR UPDATE
+	`, accountID, before)
+	if err != nil {
+		return false, err
+	}
+
+	ids := make([]int64, len(pending))
+	total := 0.0
+	for i, a := range pending {
+		ids[i] = a.ID
+		total += a.Amount
+	}
+	txn.Amount = math.Round(total*100) / 100
+	if txn.Amount < 0.01 {
+		return false, nil
+	}
+	if err := postTransaction(ctx, tx, txn); err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE cashback_accruals
+		SET status = 'paid', paid_at = NOW(), payout_transaction_id = $1
+		WHERE id = ANY($2)
+	`, txn.ID, pq.Array(ids))
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Summary возвращает начисленный, ожидающий и выплаченный кэшбэк по категориям за период
+func (r *RewardRepository) Summary(ctx context.Context, userID int64, period time.Time) ([]models.CashbackSummary, error) {
+	var list []models.CashbackSummary
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT category,
+			SUM(amount) AS accrued,
+			COALESCE(SUM(amount) FILTER (WHERE status = 'pending'), 0) AS pending,
+			COALESCE(SUM(amount) FILTER (WHERE status = 'paid'), 0) AS paid
+		FROM cashback_accruals
+		WHERE user_id = $1 AND period = $2
+		GROUP BY category
+		ORDER BY category
+	`, userID, period)
+	return list, err
+}
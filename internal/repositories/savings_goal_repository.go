@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"bank-api/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SavingsGoalRepository struct {
+	DB *sqlx.DB
+}
+
+func NewSavingsGoalRepository(db *sqlx.DB) *SavingsGoalRepository {
+	return &SavingsGoalRepository{DB: db}
+}
+
+const savingsGoalColumns = `
+	g.id, g.user_id, g.account_id, g.source_account_id, g.name, g.target_amount, g.target_date,
+	g.status, a.balance, g.created_at, g.closed_at
+`
+
+func (r *SavingsGoalRepository) CreateGoal(ctx context.Context, g *models.SavingsGoal) error {
+	query := `
+		INSERT INTO savings_goals (user_id, account_id, source_account_id, name, target_amount, target_date, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	return r.DB.QueryRowContext(ctx, query,
+		g.UserID, g.AccountID, g.SourceAccountID, g.Name, g.TargetAmount, g.TargetDate, g.Status,
+	).Scan(&g.ID, &g.CreatedAt)
+}
+
+func (r *SavingsGoalRepository) GetGoalByID(ctx context.Context, id int64) (*models.SavingsGoal, error) {
+	var g models.SavingsGoal
+	err := r.DB.GetContext(ctx, &g, `
+		SELECT `+savingsGoalColumns+`
+		FROM savings_goals g JOIN accounts a ON a.id = g.account_id
+		WHERE g.id = $1
+	`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (r *SavingsGoalRepository) ListGoalsByUser(ctx context.Context, userID int64) ([]models.SavingsGoal, error) {
+	var goals []models.SavingsGoal
+	err := r.DB.SelectContext(ctx, &goals, `
+		SELECT `+savingsGoalColumns+`
+		FROM savings_goals g JOIN accounts a ON a.id = g.account_id
+		WHERE g.user_id = $1
+		ORDER BY g.status, g.created_at DESC
+	`, userID)
+	return goals, err
+}
+
+func (r *SavingsGoalRepository) CloseGoal(ctx context.Context, id int64) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE savings_goals SET status = 'closed', closed_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+func (r *SavingsGoalRepository) CreateRule(ctx context.Context, rule *models.SavingsRule) error {
+	query := `
+		INSERT INTO savings_rules (goal_id, type, round_to, percent, amount, weekday, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	return r.DB.QueryRowContext(ctx, query,
+		rule.GoalID, rule.Type, rule.RoundTo, rule.Percent, rule.Amount, rule.Weekday, rule.IsActive,
+	).Scan(&rule.ID, &rule.CreatedAt)
+}
+
+func (r *SavingsGoalRepository) ListRulesByGoal(ctx context.Context, goalID int64) ([]models.SavingsRule, error) {
+	var rules []models.SavingsRule
+	err := r.DB.SelectContext(ctx, &rules, `SELECT * FROM savings_rules WHERE goal_id = $1 ORDER BY id`, goalID)
+	return rules, err
+}
+
+func (r *SavingsGoalRepository) DeleteRule(ctx context.Context, goalID, ruleID int64) error {
+	result, err := r.DB.ExecContext(ctx, `DELETE FROM savings_rules WHERE id = $1 AND goal_id = $2`, ruleID, goalID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return errors.New("savings rule not found")
+	}
+	return nil
+}
+
+// ListActiveRulesForAccount возвращает активные правила заданного типа
+// для целей, которые пополняются с указанного счёта
+func (r *SavingsGoalRepository) ListActiveRulesForAccount(ctx context.Context, accountID int64, ruleType string) ([]models.SavingsRule, error) {
+	var rules []models.SavingsRule
+	err := r.DB.SelectContext(ctx, &rules, `
+		SELECT sr.*
+		FROM savings_rules sr JOIN savings_goals g ON g.id = sr.goal_id
+		WHERE g.source_account_id = $1 AND g.status = 'active' AND sr.is_active = TRUE AND sr.type = $2
+		ORDER BY sr.id
+	`, accountID, ruleType)
+	return rules, err
+}
+
+// ListDueWeeklySweeps возвращает еженедельные правила на заданный день недели, которые сегодня ещё не выполнялись
+func (r *SavingsGoalRepository) ListDueWeeklySweeps(ctx context.Context, weekday int) ([]models.SavingsRule, error) {
+	var rules []models.SavingsRule
+	err := r.DB.SelectContext(ctx, &rules, `
+		SELECT sr.*
+		FROM savings_rules sr JOIN savings_goals g ON g.id = sr.goal_id
+		WHERE sr.type = 'weekly_sweep' AND sr.is_active = TRUE AND g.status = 'active'
+			AND sr.weekday = $1 AND (sr.last_run_at IS NULL OR sr.last_run_at < CURRENT_DATE)
+		ORDER BY sr.id
+	`, weekday)
+	return rules, err
+}
+
+func (r *SavingsGoalRepository) MarkRuleRun(ctx context.Context, ruleID int64) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE savings_rules SET last_run_at = NOW() WHERE id = $1`, ruleID)
+	return err
+}
@@ -18,11 +18,12 @@ type TransactionRepository struct {
 func (r *TransactionRepository) CreateTransaction(ctx context.Context, t *models.Transaction) (int64, error) {
 	var id int64
 	err := r.DB.QueryRowContext(ctx, `
-		INSERT INTO transactions (from_account, to_account, amount, type, timestamp, description, is_reversal)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO transactions (from_account, to_account, amount, type, timestamp, description, is_reversal, mcc, merchant)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''))
 		RETURNING id
 	`,
-		t.FromAccount, t.ToAccount, t.Amount, t.Type, t.Timestamp, t.Description, t.IsReversal,
+		nullInt64(t.FromAccount), nullInt64(t.ToAccount), t.Amount, t.Type, t.Timestamp, t.Description, t.IsReversal,
+		t.MCC, t.Merchant,
 	).Scan(&id)
 
 	if err != nil {
@@ -128,6 +129,80 @@ func (r *TransactionRepository) UpdateBalancesTx(ctx context.Context, fromID int
 
 	return tx.Commit()
 }
+
+// PostTransactions проводит транзакции одной транзакцией БД: по каждой
+// списывает Amount с FromAccount, зачисляет на ToAccount и пишет её в журнал.
+// Так операция и комиссия за неё проводятся вместе или не проводятся вовсе.
+// Квота бесплатных операций (claim) расходуется там же, до проводок, и
+// привязывается к первой транзакции.
+func (r *TransactionRepository) PostTransactions(ctx context.Context, claim *models.FeeQuotaClaim, txns ...*models.Transaction) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := PostTransactionsTx(ctx, tx, claim, txns...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PostTransactionsTx проводит транзакции в уже открытой транзакции БД tx —
+// для репозиториев, которые меняют свои данные вместе с проводками
+func PostTransactionsTx(ctx context.Context, tx *sqlx.Tx, claim *models.FeeQuotaClaim, txns ...*models.Transaction) error {
+	var usageID int64
+	if claim != nil {
+		var err error
+		if usageID, err = claimFeeQuota(ctx, tx, claim); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range txns {
+		if err := postTransaction(ctx, tx, t); err != nil {
+			return err
+		}
+	}
+
+	if usageID != 0 && len(txns) > 0 {
+		_, err := tx.ExecContext(ctx, `UPDATE fee_quota_usage SET transaction_id = $1 WHERE id = $2`, txns[0].ID, usageID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postTransaction списывает Amount с FromAccount, зачисляет на ToAccount и
+// пишет транзакцию в журнал в рамках транзакции БД tx
+func postTransaction(ctx context.Context, tx *sqlx.Tx, t *models.Transaction) error {
+	for _, change := range []struct {
+		accountID int64
+		delta     float64
+	}{{t.FromAccount, -t.Amount}, {t.ToAccount, t.Amount}} {
+		if change.accountID == 0 {
+			continue
+		}
+		res, err := tx.ExecContext(ctx, `UPDATE accounts SET balance = balance + $1 WHERE id = $2`, change.delta, change.accountID)
+		if err != nil {
+			return err
+		}
+		if rows, _ := res.RowsAffected(); rows == 0 {
+			return fmt.Errorf("account %d not found", change.accountID)
+		}
+	}
+
+	return tx.QueryRowContext(ctx, `
+		INSERT INTO transactions (from_account, to_account, amount, type, timestamp, description, is_reversal, mcc, merchant)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''))
+		RETURNING id
+	`,
+		nullInt64(t.FromAccount), nullInt64(t.ToAccount), t.Amount, t.Type, t.Timestamp, t.Description, t.IsReversal,
+		t.MCC, t.Merchant,
+	).Scan(&t.ID)
+}
+
 func (r *TransactionRepository) GetTransactionByID(ctx context.Context, id int64) (*models.Transaction, error) {
 	row := r.DB.QueryRowContext(ctx, `SELECT id, from_account, to_account, amount, type, timestamp, description FROM transactions WHERE id = $1`, id)
 
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"bank-api/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type TransferTemplateRepository struct {
+	DB *sqlx.DB
+}
+
+func NewTransferTemplateRepository(db *sqlx.DB) *TransferTemplateRepository {
+	return &TransferTemplateRepository{DB: db}
+}
+
+const transferTemplateColumns = `
+	id, user_id, beneficiary_id, from_account_id, name, default_amount,
+	COALESCE(description, '') AS description, created_at, updated_at
+`
+
+func (r *TransferTemplateRepository) CreateTemplate(ctx context.Context, t *models.TransferTemplate) error {
+	query := `
+		INSERT INTO transfer_templates (user_id, beneficiary_id, from_account_id, name, default_amount, description)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`
+	return r.DB.QueryRowContext(ctx, query,
+		t.UserID, t.BeneficiaryID, t.FromAccountID, t.Name, t.DefaultAmount, t.Description,
+	).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+}
+
+func (r *TransferTemplateRepository) GetTemplateByID(ctx context.Context, id int64) (*models.TransferTemplate, error) {
+	var t models.TransferTemplate
+	err := r.DB.GetContext(ctx, &t, `SELECT `+transferTemplateColumns+` FROM transfer_templates WHERE id = $1`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *TransferTemplateRepository) ListTemplatesByUser(ctx context.Context, userID int64) ([]models.TransferTemplate, error) {
+	var list []models.TransferTemplate
+	err := r.DB.SelectContext(ctx, &list, `
+		SELECT `+transferTemplateColumns+`
+		FROM transfer_templates
+		WHERE user_id = $1
+		ORDER BY name
+	`, userID)
+	return list, err
+}
+
+func (r *TransferTemplateRepository) UpdateTemplate(ctx context.Context, t *models.TransferTemplate) error {
+	query := `
+		UPDATE transfer_templates
+		SET beneficiary_id = $1, from_account_id = $2, name = $3, default_amount = $4, description = $5, updated_at = NOW()
+		WHERE id = $6
+		RETURNING updated_at
+	`
+	err := r.DB.QueryRowContext(ctx, query,
+		t.BeneficiaryID, t.FromAccountID, t.Name, t.DefaultAmount, t.Description, t.ID,
+	).Scan(&t.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("transfer template not found")
+	}
+	return err
+}
+
+func (r *TransferTemplateRepository) DeleteTemplate(ctx context.Context, id int64) error {
+	result, err := r.DB.ExecContext(ctx, `DELETE FROM transfer_templates WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return errors.New("transfer template not found")
+	}
+	return nil
+}
@@ -41,11 +41,50 @@ func (r *UserRepository) IsEmailOrUsernameTaken(ctx context.Context, email, user
 
 func (r *UserRepository) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
 	user := &models.User{}
-	query := `SELECT id, username, email, created_at FROM users WHERE id = $1`
+	query := `SELECT id, username, email, tariff, created_at FROM users WHERE id = $1`
 	err := r.DB.QueryRowContext(ctx, query, id).
-		Scan(&user.ID, &user.UserName, &user.Email, &user.CreatedAt)
+		Scan(&user.ID, &user.UserName, &user.Email, &user.Tariff, &user.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	return user, err
 }
+
+func (r *UserRepository) GetUserRole(ctx context.Context, id int64) (string, error) {
+	var role string
+	err := r.DB.QueryRowContext(ctx, `SELECT role FROM users WHERE id = $1`, id).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// GetPasswordHash возвращает хеш пароля пользователя, "" — пользователь не найден
+func (r *UserRepository) GetPasswordHash(ctx context.Context, id int64) (string, error) {
+	var hash string
+	err := r.DB.QueryRowContext(ctx, `SELECT password FROM users WHERE id = $1`, id).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return hash, err
+}
+
+// GetTariff возвращает тарифный план пользователя, "" — пользователь не найден
+func (r *UserRepository) GetTariff(ctx context.Context, id int64) (string, error) {
+	var tariff string
+	err := r.DB.QueryRowContext(ctx, `SELECT tariff FROM users WHERE id = $1`, id).Scan(&tariff)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return tariff, err
+}
+
+// SetTariff меняет тарифный план пользователя; false — пользователь не найден
+func (r *UserRepository) SetTariff(ctx context.Context, id int64, tariff string) (bool, error) {
+	res, err := r.DB.ExecContext(ctx, `UPDATE users SET tariff = $1 WHERE id = $2`, tariff, id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
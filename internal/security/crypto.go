@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
 )
 
-// EncryptAES шифрует строку с помощью AES-256 в режиме CBC и PKCS7 padding
+// EncryptAES шифрует строку с помощью AES-256 в режиме CBC и PKCS7 padding.
+//
+// Deprecated: нулевой IV делает шифротекст детерминированным. Новые данные
+// шифруются через Seal (AES-GCM); функция оставлена для совместимости.
 func EncryptAES(plaintext string, key []byte) (string, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -25,7 +29,8 @@ func EncryptAES(plaintext string, key []byte) (string, error) {
 	return encodeBase64(encrypted), nil
 }
 
-// DecryptAES расшифровывает строку, зашифрованную AES-256 + CBC + PKCS7
+// DecryptAES расшифровывает строку, зашифрованную AES-256 + CBC + PKCS7.
+// Используется только для чтения старых записей cards.encrypted_data.
 func DecryptAES(encoded string, key []byte) (string, error) {
 	data, err := decodeBase64(encoded)
 	if err != nil {
@@ -37,7 +42,7 @@ func DecryptAES(encoded string, key []byte) (string, error) {
 		return "", err
 	}
 	blockSize := block.BlockSize()
-	if len(data)%blockSize != 0 {
+	if len(data) == 0 || len(data)%blockSize != 0 {
 		return "", errors.New("invalid encrypted data length")
 	}
 
@@ -63,11 +68,16 @@ func pkcs7Pad(data []byte, blockSize int) []byte {
 
 func pkcs7Unpad(data []byte) ([]byte, error) {
 	length := len(data)
-	if length == 0 {
+	if length == 0 || length%aes.BlockSize != 0 {
 		return nil, errors.New("invalid padding size")
 	}
 	padding := int(data[length-1])
-	if padding > length {
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, errors.New("invalid padding")
+	}
+	// все байты дополнения должны быть равны его длине
+	expected := bytes.Repeat([]byte{byte(padding)}, padding)
+	if subtle.ConstantTimeCompare(data[length-padding:], expected) != 1 {
 		return nil, errors.New("invalid padding")
 	}
 	return data[:length-padding], nil
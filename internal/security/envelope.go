@@ -0,0 +1,130 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Формат конверта: "v2:<keyID>:<base64(nonce || ciphertext || tag)>".
+// Шифрование — AES-256-GCM со случайным 96-битным nonce.
+const envelopePrefix = "v2:"
+
+const KeySize = 32
+
+var (
+	ErrInvalidEnvelope = errors.New("invalid envelope format")
+	ErrUnknownKey      = errors.New("unknown encryption key")
+)
+
+// KeyLookup возвращает ключ по его идентификатору из конверта
+type KeyLookup func(keyID string) ([]byte, error)
+
+// ValidateKey проверяет, что ключ пригоден для AES-256
+func ValidateKey(key []byte) error {
+	if len(key) != KeySize {
+		return fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return nil
+}
+
+// ParseKey декодирует ключ из base64 и проверяет его длину
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not valid base64: %w", err)
+	}
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// IsEnvelope отличает новый формат от старых CBC-шифротекстов (чистый base64)
+func IsEnvelope(data string) bool {
+	return strings.HasPrefix(data, envelopePrefix)
+}
+
+// EnvelopeKeyID возвращает идентификатор ключа, которым зашифрован конверт
+func EnvelopeKeyID(data string) (string, error) {
+	keyID, _, err := splitEnvelope(data)
+	return keyID, err
+}
+
+// Seal шифрует plaintext ключом keyID. aad не шифруется, но участвует в
+// проверке целостности: расшифровать можно только с теми же aad.
+func Seal(plaintext string, keyID string, key []byte, aad []byte) (string, error) {
+	if keyID == "" || strings.Contains(keyID, ":") {
+		return "", errors.New("invalid key ID")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), aad)
+	return envelopePrefix + keyID + ":" + encodeBase64(sealed), nil
+}
+
+// Open расшифровывает конверт, выбирая ключ по его идентификатору
+func Open(data string, lookup KeyLookup, aad []byte) (string, error) {
+	keyID, payload, err := splitEnvelope(data)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := lookup(keyID)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := decodeBase64(payload)
+	if err != nil {
+		return "", ErrInvalidEnvelope
+	}
+	if len(raw) < gcm.NonceSize()+gcm.Overhead() {
+		return "", ErrInvalidEnvelope
+	}
+
+	nonce, ciphertext := raw[:gcm.NonceSize()], raw[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return "", errors.New("envelope authentication failed")
+	}
+	return string(plaintext), nil
+}
+
+func splitEnvelope(data string) (keyID, payload string, err error) {
+	if !IsEnvelope(data) {
+		return "", "", ErrInvalidEnvelope
+	}
+	parts := strings.SplitN(strings.TrimPrefix(data, envelopePrefix), ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", ErrInvalidEnvelope
+	}
+	return parts[0], parts[1], nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,191 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	// LegacyKeyID — условный идентификатор старых CBC-записей без префикса
+	LegacyKeyID = "legacy"
+	// DataKeyID — идентификатор в конверте, зашифрованном собственным ключом записи (DEK)
+	DataKeyID = "dek"
+)
+
+// Keyring хранит статические ключи шифрования из конфига. Новые записи
+// шифруются ключами данных из KMS, а кольцо нужно для чтения старых записей,
+// пока они не перешифрованы, и для проверки подписей.
+type Keyring struct {
+	mu       sync.RWMutex
+	keys     map[string][]byte
+	legacy   []byte   // ключ старого формата AES-CBC
+	hmacKeys [][]byte // первый — текущий, остальные принимаются при проверке
+}
+
+// NewKeyring создаёт кольцо; previousHMACKeys — прежние ключи подписи, которые
+// ещё принимаются при проверке. Текущий ключ подписи задаётся через SetSigningKey.
+func NewKeyring(keys map[string][]byte, legacy []byte, previousHMACKeys [][]byte) (*Keyring, error) {
+	for id, key := range keys {
+		if id == "" || id == LegacyKeyID || id == DataKeyID {
+			return nil, fmt.Errorf("invalid key ID %q", id)
+		}
+		if err := ValidateKey(key); err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+	}
+	if len(legacy) > 0 {
+		if err := ValidateKey(legacy); err != nil {
+			return nil, fmt.Errorf("legacy key: %w", err)
+		}
+	}
+
+	return &Keyring{
+		keys:     keys,
+		legacy:   legacy,
+		hmacKeys: append([][]byte{nil}, previousHMACKeys...),
+	}, nil
+}
+
+// SetSigningKey задаёт текущий ключ подписи (его выдаёт KMS)
+func (k *Keyring) SetSigningKey(key []byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.hmacKeys[0] = key
+}
+
+// Lookup реализует KeyLookup
+func (k *Keyring) Lookup(keyID string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	return key, nil
+}
+
+// KeyIDs возвращает идентификаторы статических ключей кольца
+func (k *Keyring) KeyIDs() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	var ids []string
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	if len(k.legacy) > 0 {
+		ids = append(ids, LegacyKeyID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Retire убирает ключ из кольца
+func (k *Keyring) Retire(keyID string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if keyID == LegacyKeyID {
+		k.legacy = nil
+		return
+	}
+	delete(k.keys, keyID)
+}
+
+// Decrypt расшифровывает конверт любым ключом кольца, а записи без префикса —
+// старым CBC-ключом
+func (k *Keyring) Decrypt(data string, aad []byte) (string, error) {
+	if IsEnvelope(data) {
+		return Open(data, k.Lookup, aad)
+	}
+
+	k.mu.RLock()
+	legacy := k.legacy
+	k.mu.RUnlock()
+	if len(legacy) == 0 {
+		return "", errors.New("legacy ciphertext found but no legacy key configured")
+	}
+	return DecryptAES(data, legacy)
+}
+
+// KeyIDOf возвращает идентификатор ключа, которым зашифрованы данные
+func KeyIDOf(data string) string {
+	if !IsEnvelope(data) {
+		return LegacyKeyID
+	}
+	id, err := EnvelopeKeyID(data)
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// Sign подписывает данные текущим HMAC-ключом
+func (k *Keyring) Sign(data string) (string, error) {
+	k.mu.RLock()
+	key := k.hmacKeys[0]
+	k.mu.RUnlock()
+	if len(key) == 0 {
+		return "", errors.New("signing key is not set")
+	}
+	return GenerateHMAC(data, key)
+}
+
+// PANHash — ключевой хеш номера карты для индекса уникальности. Ключ выводится
+// из текущего ключа подписи, поэтому хеши пересчитываются при перешифровании.
+func (k *Keyring) PANHash(pan string) (string, error) {
+	k.mu.RLock()
+	key := k.hmacKeys[0]
+	k.mu.RUnlock()
+	if len(key) == 0 {
+		return "", errors.New("signing key is not set")
+	}
+	return panHash(pan, key)
+}
+
+// PANHashes возвращает хеши номера под всеми принимаемыми ключами подписи,
+// первым — под текущим. По ним ищутся карты, которые ещё не перешифрованы
+// после смены ключа.
+func (k *Keyring) PANHashes(pan string) ([]string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if len(k.hmacKeys[0]) == 0 {
+		return nil, errors.New("signing key is not set")
+	}
+	var hashes []string
+	for _, key := range k.hmacKeys {
+		if len(key) == 0 {
+			continue
+		}
+		hash, err := panHash(pan, key)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+func panHash(pan string, signingKey []byte) (string, error) {
+	indexKey, err := GenerateHMAC("pan-index", signingKey)
+	if err != nil {
+		return "", err
+	}
+	return GenerateHMAC(pan, []byte(indexKey))
+}
+
+// Verify проверяет подпись текущим и предыдущими HMAC-ключами.
+// current=false означает, что запись стоит переподписать.
+func (k *Keyring) Verify(data, signature string) (valid, current bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for i, key := range k.hmacKeys {
+		if len(key) == 0 {
+			continue
+		}
+		if ok, _ := VerifyHMAC(data, signature, key); ok {
+			return true, i == 0
+		}
+	}
+	return false, false
+}
@@ -7,7 +7,10 @@ import (
 	"errors"
 )
 
-var ErrAccountAlreadyExists = errors.New("account already exists for this user")
+var (
+	ErrAccountAlreadyExists = errors.New("account already exists for this user")
+	ErrAccountNotFound      = errors.New("account not found")
+)
 
 type AccountService struct {
 	accountRepo *repositories.AccountRepository
@@ -34,3 +37,36 @@ func (s *AccountService) CreateAccount(ctx context.Context, userID int64) (*mode
 func (s *AccountService) GetBalance(ctx context.Context, accountID int64) (float64, error) {
 	return s.accountRepo.GetAccountBalance(ctx, accountID)
 }
+
+// GetBalanceDetails returns balance and amount available to spend including overdraft
+func (s *AccountService) GetBalanceDetails(ctx context.Context, userID, accountID int64) (*models.AccountBalance, error) {
+	owned, err := s.accountRepo.IsAccountOwnedByUser(ctx, accountID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, ErrAccountNotFound
+	}
+
+	balance, err := s.accountRepo.GetAccountBalance(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	available, err := s.accountRepo.GetAvailableBalance(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	held, err := s.accountRepo.GetHeldAmount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AccountBalance{
+		AccountID:      accountID,
+		Currency:       "RUB",
+		Balance:        balance,
+		OverdraftLimit: available + held - balance,
+		Held:           held,
+		Available:      available,
+	}, nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/repositories"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrBeneficiaryNotFound     = errors.New("beneficiary not found")
+	ErrBeneficiaryNotConfirmed = errors.New("beneficiary is not confirmed")
+	ErrInvalidConfirmationCode = errors.New("invalid or expired confirmation code")
+	ErrConfirmationAttempts    = errors.New("too many confirmation attempts, add the beneficiary again")
+)
+
+const (
+	defaultConfirmationTTL      = 10 * time.Minute
+	defaultConfirmationAttempts = 5
+)
+
+// ConfirmationSender доставляет пользователю одноразовый код подтверждения
+type ConfirmationSender interface {
+	SendConfirmationCode(ctx context.Context, userID int64, code string) error
+}
+
+// stubConfirmationSender — заглушка, пока нет отправки SMS/push. Код никуда
+// не уходит и не пишется в лог: по логу нельзя подтвердить чужого получателя.
+type stubConfirmationSender struct{}
+
+func (stubConfirmationSender) SendConfirmationCode(_ context.Context, userID int64, _ string) error {
+	logger.Sugared().Warnf("confirmation code for user %d was not delivered: no sender configured", userID)
+	return nil
+}
+
+type BeneficiaryService struct {
+	repo                *repositories.BeneficiaryRepository
+	sender              ConfirmationSender
+	requireConfirmation bool
+	confirmationTTL     time.Duration
+	maxAttempts         int
+}
+
+// NewBeneficiaryService создаёт сервис; sender == nil — коды не доставляются.
+// maxAttempts — сколько раз можно ввести код, прежде чем он станет недействительным.
+func NewBeneficiaryService(repo *repositories.BeneficiaryRepository, sender ConfirmationSender, requireConfirmation bool,
+	confirmationTTL time.Duration, maxAttempts int) *BeneficiaryService {
+	if confirmationTTL <= 0 {
+		confirmationTTL = defaultConfirmationTTL
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = defaultConfirmationAttempts
+	}
+	if sender == nil {
+		sender = stubConfirmationSender{}
+	}
+	return &BeneficiaryService{
+		repo:                repo,
+		sender:              sender,
+		requireConfirmation: requireConfirmation,
+		confirmationTTL:     confirmationTTL,
+		maxAttempts:         maxAttempts,
+	}
+}
+
+// AddBeneficiary добавляет получателя в адресную книгу.
+// Если включено подтверждение, получатель создаётся в статусе pending_confirmation
+// и пользователю отправляется одноразовый код.
+func (s *BeneficiaryService) AddBeneficiary(ctx context.Context, b *models.Beneficiary) error {
+	if b.UserID == 0 || b.Name == "" {
+		return errors.New("missing required beneficiary fields")
+	}
+	if b.AccountID == 0 && b.Phone == "" {
+		return errors.New("either account_id or phone is required")
+	}
+
+	if !s.requireConfirmation {
+		b.Status = "active"
+		return s.repo.CreateBeneficiary(ctx, b, "", time.Time{})
+	}
+
+	code, err := generateConfirmationCode()
+	if err != nil {
+		return fmt.Errorf("generate confirmation code: %w", err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	b.Status = "pending_confirmation"
+	if err := s.repo.CreateBeneficiary(ctx, b, string(hash), time.Now().Add(s.confirmationTTL)); err != nil {
+		return err
+	}
+
+	if err := s.sender.SendConfirmationCode(ctx, b.UserID, code); err != nil {
+		return fmt.Errorf("send confirmation code: %w", err)
+	}
+	return nil
+}
+
+// ConfirmBeneficiary проверяет одноразовый код и активирует получателя.
+// Каждая проверка расходует попытку; после последней неверной код стирается.
+func (s *BeneficiaryService) ConfirmBeneficiary(ctx context.Context, userID, id int64, code string) (*models.Beneficiary, error) {
+	b, err := s.GetBeneficiary(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if b.Status == "active" {
+		return b, nil
+	}
+
+	hash, expiresAt, remaining, err := s.repo.ClaimConfirmationAttempt(ctx, id, s.maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if hash == "" {
+		return nil, ErrConfirmationAttempts
+	}
+	if time.Now().After(expiresAt) {
+		return nil, ErrInvalidConfirmationCode
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)); err != nil {
+		if remaining == 0 {
+			if err := s.repo.InvalidateConfirmation(ctx, id); err != nil {
+				logger.Sugared().Errorf("beneficiary %d: failed to invalidate confirmation code: %v", id, err)
+			}
+		}
+		return nil, ErrInvalidConfirmationCode
+	}
+
+	if err := s.repo.Activate(ctx, id); err != nil {
+		return nil, err
+	}
+	b.Status = "active"
+	return b, nil
+}
+
+func (s *BeneficiaryService) GetBeneficiary(ctx context.Context, userID, id int64) (*models.Beneficiary, error) {
+	b, err := s.repo.GetBeneficiaryByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil || b.UserID != userID {
+		return nil, ErrBeneficiaryNotFound
+	}
+	return b, nil
+}
+
+func (s *BeneficiaryService) ListBeneficiaries(ctx context.Context, userID int64) ([]models.Beneficiary, error) {
+	return s.repo.ListBeneficiariesByUser(ctx, userID)
+}
+
+// UpdateBeneficiary меняет только описательные поля.
+// Реквизиты (счёт, телефон) не редактируются, чтобы не обходить подтверждение.
+func (s *BeneficiaryService) UpdateBeneficiary(ctx context.Context, userID int64, update *models.Beneficiary) (*models.Beneficiary, error) {
+	b, err := s.GetBeneficiary(ctx, userID, update.ID)
+	if err != nil {
+		return nil, err
+	}
+	if update.Name == "" {
+		return nil, errors.New("name is required")
+	}
+
+	b.Name = update.Name
+	b.BankName = update.BankName
+	b.Nickname = update.Nickname
+	b.IsFavourite = update.IsFavourite
+
+	if err := s.repo.UpdateBeneficiary(ctx, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *BeneficiaryService) DeleteBeneficiary(ctx context.Context, userID, id int64) error {
+	if _, err := s.GetBeneficiary(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteBeneficiary(ctx, id)
+}
+
+func generateConfirmationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
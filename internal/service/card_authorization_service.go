@@ -0,0 +1,429 @@
+package service
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/pan"
+	"bank-api/internal/repositories"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrAuthorizationNotFound = errors.New("authorization not found")
+	ErrAuthorizationNotHeld  = errors.New("authorization is not on hold")
+	ErrInvalidCaptureAmount  = errors.New("capture amount must not exceed authorized amount")
+)
+
+const defaultHoldTTL = 7 * 24 * time.Hour
+
+// Причины отказа для статусов карты
+var cardStatusDeclines = map[string]string{
+	models.CardStatusInactive:      models.DeclineCardInactive,
+	models.CardStatusTempBlocked:   models.DeclineCardFrozen,
+	models.CardStatusBlockedLost:   models.DeclineCardLost,
+	models.CardStatusBlockedStolen: models.DeclineCardStolen,
+	models.CardStatusExpired:       models.DeclineCardExpired,
+	models.CardStatusClosed:        models.DeclineInvalidCard,
+}
+
+// Коды ответа для причин отказа
+var declineResponses = map[string]string{
+	models.DeclineInvalidCard:           models.ResponseInvalidCard,
+	models.DeclineCardInactive:          models.ResponseInactiveCard,
+	models.DeclineCardFrozen:            models.ResponseRestrictedCard,
+	models.DeclineCardLost:              models.ResponseLostCard,
+	models.DeclineCardStolen:            models.ResponseStolenCard,
+	models.DeclineCardExpired:           models.ResponseExpiredCard,
+	models.DeclineCVVMismatch:           models.ResponseCVVMismatch,
+	models.DeclineInvalidAmount:         models.ResponseInvalidAmount,
+	models.DeclineInsufficientFunds:     models.ResponseInsufficientFunds,
+	models.DeclineSystemError:           models.ResponseSystemError,
+	models.DeclineTransactionLimit:      models.ResponseExceedsLimit,
+	models.DeclineDailyLimit:            models.ResponseExceedsLimit,
+	models.DeclineMonthlyLimit:          models.ResponseExceedsLimit,
+	models.DeclineATMDailyLimit:         models.ResponseExceedsLimit,
+	models.DeclineATMMonthlyLimit:       models.ResponseExceedsLimit,
+	models.DeclineMerchantBlocked:       models.ResponseNotPermitted,
+	models.DeclineOnlineDisabled:        models.ResponseNotPermitted,
+	models.DeclineContactlessDisabled:   models.ResponseNotPermitted,
+	models.DeclineMagstripeDisabled:     models.ResponseNotPermitted,
+	models.DeclineInternationalDisabled: models.ResponseRestrictedCard,
+	models.DeclineCardNotPresentOnly:    models.ResponseNotPermitted,
+	models.DeclineSpendCap:              models.ResponseExceedsLimit,
+	models.DeclineSingleUseUsed:         models.ResponseInvalidCard,
+	models.DeclineMerchantLocked:        models.ResponseNotPermitted,
+	models.DeclineIncorrectPIN:          models.ResponseIncorrectPIN,
+	models.DeclinePINTriesExceeded:      models.ResponsePINTriesExceeded,
+	models.DeclinePINNotSet:             models.ResponseIncorrectPIN,
+	models.DeclinePINRequired:           models.ResponseIncorrectPIN,
+}
+
+// mccATM — снятие наличных в банкомате
+const mccATM = "6011"
+
+// CardAuthorizationService — эмитентская часть авторизации по картам:
+// проверяет карту и её ограничения, блокирует средства и списывает их при клиринге
+type CardAuthorizationService struct {
+	cardService        *CardService
+	controlService     *CardControlService
+	pinService         *CardPINService
+	cardRepo           *repositories.CardRepository
+	repo               *repositories.CardAuthorizationRepository
+	transactionService *TransactionService
+	holdTTL            time.Duration
+}
+
+func NewCardAuthorizationService(
+	cardService *CardService,
+	controlService *CardControlService,
+	pinService *CardPINService,
+	cardRepo *repositories.CardRepository,
+	repo *repositories.CardAuthorizationRepository,
+	transactionService *TransactionService,
+	holdTTL time.Duration,
+) *CardAuthorizationService {
+	if holdTTL <= 0 {
+		holdTTL = defaultHoldTTL
+	}
+	return &CardAuthorizationService{
+		cardService:        cardService,
+		controlService:     controlService,
+		pinService:         pinService,
+		cardRepo:           cardRepo,
+		repo:               repo,
+		transactionService: transactionService,
+		holdTTL:            holdTTL,
+	}
+}
+
+// Authorize проверяет карту и блокирует сумму. Отказ — не ошибка: он
+// возвращается авторизацией с кодом ответа и причиной. Повтор запроса с тем
+// же RRN возвращает исходный ответ.
+func (s *CardAuthorizationService) Authorize(ctx context.Context, req *models.AuthorizationRequest) (*models.CardAuthorization, error) {
+	if req.RRN != "" {
+		existing, err := s.repo.GetByRRN(ctx, req.RRN)
+		if err != nil || existing != nil {
+			return existing, err
+		}
+	} else {
+		rrn, err := pan.RandomDigits(12)
+		if err != nil {
+			return nil, err
+		}
+		req.RRN = rrn
+	}
+	if req.MessageType == "" {
+		req.MessageType = "0100"
+		if req.Capture {
+			req.MessageType = "0200"
+		}
+	}
+	if req.MCC == mccATM {
+		req.Channel = models.ChannelATM
+	}
+	if req.Channel == "" {
+		req.Channel = models.ChannelPOS
+	}
+
+	auth := &models.CardAuthorization{
+		MessageType: req.MessageType,
+		RRN:         req.RRN,
+		STAN:        req.STAN,
+		TerminalID:  req.TerminalID,
+		MerchantID:  req.MerchantID,
+		Merchant:    req.Merchant,
+		MCC:         req.MCC,
+		Channel:     req.Channel,
+		EntryMode:   req.EntryMode,
+		Country:     req.Country,
+		Amount:      math.Round(req.Amount*100) / 100,
+	}
+
+	card, reason, rules, err := s.check(ctx, req, auth.Amount)
+	if err != nil {
+		logger.Sugared().Errorf("card authorization %s failed: %v", req.RRN, err)
+		reason = models.DeclineSystemError
+	}
+	if card != nil {
+		auth.CardID = &card.ID
+		auth.AccountID = &card.AccountID
+	}
+
+	if reason == "" {
+		reason, err = s.placeHold(ctx, auth, rules)
+		if err != nil {
+			return nil, err
+		}
+		if reason == "" {
+			if card.VirtualType == models.VirtualSingleUse {
+				s.closeSingleUse(ctx, card)
+			}
+			return s.completeSingleMessage(ctx, auth, req.Capture)
+		}
+	}
+
+	auth.ResponseCode = declineResponses[reason]
+	auth.DeclineReason = reason
+	auth.Status = models.AuthStatusDeclined
+	if err := s.repo.CreateAuthorization(ctx, auth); err != nil {
+		if errors.Is(err, repositories.ErrDuplicateRRN) {
+			return s.repo.GetByRRN(ctx, auth.RRN)
+		}
+		return nil, err
+	}
+	return auth, nil
+}
+
+// check проверяет карту и её ограничения. Возвращает причину отказа или ""
+// и правила, которые проверяются при блокировке средств.
+func (s *CardAuthorizationService) check(ctx context.Context, req *models.AuthorizationRequest, amount float64) (*models.Card, string, models.HoldRules, error) {
+	card, reason, err := s.checkCard(ctx, req)
+	if err != nil || reason != "" {
+		return card, reason, models.HoldRules{}, err
+	}
+	if amount <= 0 {
+		return card, models.DeclineInvalidAmount, models.HoldRules{}, nil
+	}
+	if req.Channel == models.ChannelATM && req.PINBlock == "" {
+		return card, models.DeclinePINRequired, models.HoldRules{}, nil
+	}
+
+	reason, rules, err := s.controlService.Evaluate(ctx, card, req, amount)
+	return card, reason, rules, err
+}
+
+// closeSingleUse закрывает одноразовую карту после первой одобренной операции.
+// Повторная операция, пришедшая до закрытия, отклоняется при блокировке средств.
+func (s *CardAuthorizationService) closeSingleUse(ctx context.Context, card *models.Card) {
+	err := s.cardRepo.ChangeStatus(ctx, &models.CardStatusChange{
+		CardID:     card.ID,
+		FromStatus: card.Status,
+		ToStatus:   models.CardStatusClosed,
+		Reason:     "single-use card used",
+		ActorType:  CardActorSystem,
+	})
+	if err != nil {
+		logger.Sugared().Errorf("failed to close single-use card %d: %v", card.ID, err)
+	}
+}
+
+// checkCard находит карту по номеру и проверяет реквизиты и статус
+func (s *CardAuthorizationService) checkCard(ctx context.Context, req *models.AuthorizationRequest) (*models.Card, string, error) {
+	number := pan.Normalize(req.PAN)
+	if !pan.Valid(number) {
+		return nil, models.DeclineInvalidCard, nil
+	}
+
+	panHashes, err := s.cardService.keyring.PANHashes(number)
+	if err != nil {
+		return nil, "", err
+	}
+	card, err := s.cardRepo.GetCardByPANHash(ctx, panHashes)
+	if err != nil {
+		return nil, "", err
+	}
+	if card == nil {
+		return nil, models.DeclineInvalidCard, nil
+	}
+
+	storedNumber, expiry, cvv, err := s.cardService.decryptAndVerify(ctx, card)
+	if err != nil {
+		return card, "", err
+	}
+	if storedNumber != number {
+		return card, models.DeclineInvalidCard, nil
+	}
+
+	if reason, blocked := cardStatusDeclines[card.Status]; blocked {
+		return card, reason, nil
+	}
+	if req.Expiry != expiry {
+		return card, models.DeclineCardExpired, nil
+	}
+	if card.ExpiresAt != nil && card.ExpiresAt.Before(time.Now().UTC().Truncate(24*time.Hour)) {
+		return card, models.DeclineCardExpired, nil
+	}
+	// CVV не передаётся в операциях с физической картой, но в интернете без
+	// него карта не принимается: иначе хватило бы номера и срока
+	if req.CVV == "" && req.Channel == models.ChannelEcommerce {
+		return card, models.DeclineCVVMismatch, nil
+	}
+	if req.CVV != "" && subtle.ConstantTimeCompare([]byte(req.CVV), []byte(cvv)) != 1 {
+		return card, models.DeclineCVVMismatch, nil
+	}
+	// PIN проверяется последним: неверная попытка расходует счётчик
+	if req.PINBlock != "" {
+		reason, err := s.pinService.verify(ctx, card, number, req.PINBlock, req.PINFormat)
+		return card, reason, err
+	}
+	return card, "", nil
+}
+
+func (s *CardAuthorizationService) placeHold(ctx context.Context, auth *models.CardAuthorization, rules models.HoldRules) (string, error) {
+	code, err := pan.RandomDigits(6)
+	if err != nil {
+		return "", err
+	}
+	expiresAt := time.Now().Add(s.holdTTL)
+
+	auth.AuthCode = code
+	auth.ResponseCode = models.ResponseApproved
+	auth.Status = models.AuthStatusHeld
+	auth.ExpiresAt = &expiresAt
+
+	reason, err := s.repo.PlaceHold(ctx, auth, rules)
+	if err != nil {
+		return "", err
+	}
+	if reason != "" {
+		auth.AuthCode = ""
+		auth.ExpiresAt = nil
+	}
+	return reason, nil
+}
+
+// completeSingleMessage сразу списывает одобренную одностадийную операцию (0200)
+func (s *CardAuthorizationService) completeSingleMessage(ctx context.Context, auth *models.CardAuthorization, capture bool) (*models.CardAuthorization, error) {
+	if !capture {
+		return auth, nil
+	}
+	if err := s.capture(ctx, auth, auth.Amount); err != nil {
+		// hold остаётся и будет списан повторным клирингом или снят по сроку
+		logger.Sugared().Errorf("capture of authorization %s failed: %v", auth.RRN, err)
+	}
+	return auth, nil
+}
+
+// Capture — клиринг: списывает заблокированную сумму транзакцией.
+// amount может быть меньше суммы авторизации (0 — вся сумма), остаток hold снимается.
+func (s *CardAuthorizationService) Capture(ctx context.Context, rrn string, amount float64) (*models.CardAuthorization, error) {
+	auth, err := s.repo.GetByRRN(ctx, rrn)
+	if err != nil {
+		return nil, err
+	}
+	if auth == nil || !auth.Approved() {
+		return nil, ErrAuthorizationNotFound
+	}
+	if auth.Status == models.AuthStatusCaptured {
+		return auth, nil
+	}
+	if auth.Status != models.AuthStatusHeld {
+		return nil, fmt.Errorf("%w: %s", ErrAuthorizationNotHeld, auth.Status)
+	}
+
+	amount = math.Round(amount*100) / 100
+	if amount == 0 {
+		amount = auth.Amount
+	}
+	if amount < 0 || amount > auth.Amount {
+		return nil, ErrInvalidCaptureAmount
+	}
+
+	if err := s.capture(ctx, auth, amount); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+func (s *CardAuthorizationService) capture(ctx context.Context, auth *models.CardAuthorization, amount float64) error {
+	captured, err := s.repo.MarkCaptured(ctx, auth.ID, amount)
+	if err != nil {
+		return err
+	}
+	if !captured {
+		return ErrAuthorizationNotHeld
+	}
+
+	txnType, description := "card_purchase", "Card purchase"
+	if auth.Channel == models.ChannelATM {
+		txnType, description = "atm_withdrawal", "ATM withdrawal"
+	}
+	if auth.Merchant != "" {
+		description += ": " + auth.Merchant
+	}
+	txnID, err := s.transactionService.PostCardOperation(ctx, *auth.AccountID, amount, txnType, auth.MCC, auth.Merchant, description)
+	if err != nil {
+		if revertErr := s.repo.RevertCapture(ctx, auth.ID); revertErr != nil {
+			logger.Sugared().Errorf("failed to restore hold %s: %v", auth.RRN, revertErr)
+		}
+		return err
+	}
+	if err := s.repo.SetTransaction(ctx, auth.ID, txnID); err != nil {
+		logger.Sugared().Errorf("failed to link transaction %d to authorization %s: %v", txnID, auth.RRN, err)
+	}
+
+	auth.Status = models.AuthStatusCaptured
+	auth.CapturedAmount = &amount
+	auth.TransactionID = &txnID
+	return nil
+}
+
+// Reverse отменяет авторизацию (0400): снимает hold, а если сумма уже
+// списана — возвращает её на счёт
+func (s *CardAuthorizationService) Reverse(ctx context.Context, rrn string) (*models.CardAuthorization, error) {
+	auth, err := s.repo.GetByRRN(ctx, rrn)
+	if err != nil {
+		return nil, err
+	}
+	if auth == nil || !auth.Approved() {
+		return nil, ErrAuthorizationNotFound
+	}
+
+	switch auth.Status {
+	case models.AuthStatusReversed:
+		return auth, nil
+	case models.AuthStatusHeld, models.AuthStatusCaptured:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrAuthorizationNotHeld, auth.Status)
+	}
+
+	var refund *models.Transaction
+	if auth.Status == models.AuthStatusCaptured && auth.CapturedAmount != nil {
+		refund = &models.Transaction{
+			ToAccount:   *auth.AccountID,
+			Amount:      *auth.CapturedAmount,
+			Type:        "reversal",
+			Timestamp:   time.Now(),
+			Description: "Card purchase reversal: " + auth.RRN,
+		}
+	}
+
+	reversed, err := s.repo.MarkReversed(ctx, auth.ID, auth.Status, refund)
+	if err != nil {
+		return nil, err
+	}
+	if !reversed {
+		return nil, ErrAuthorizationNotHeld
+	}
+	if refund != nil {
+		s.transactionService.Posted(ctx, refund)
+	}
+
+	auth.Status = models.AuthStatusReversed
+	return auth, nil
+}
+
+// ExpireHolds — фоновая задача: снимает hold, по которым не пришёл клиринг
+func (s *CardAuthorizationService) ExpireHolds(ctx context.Context) error {
+	count, err := s.repo.ExpireHolds(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		logger.Sugared().Infof("%d card holds expired", count)
+	}
+	return nil
+}
+
+// ListByCard возвращает последние авторизации по карте владельца
+func (s *CardAuthorizationService) ListByCard(ctx context.Context, userID, cardID int64) ([]models.CardAuthorization, error) {
+	if _, err := s.cardService.getOwnedCard(ctx, userID, cardID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListByCard(ctx, cardID, 100)
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/repositories"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// mccCategories — категории продавцов, которые владелец может заблокировать целиком
+var mccCategories = map[string][]string{
+	"gambling":       {"7800", "7801", "7802", "7995", "9406"},
+	"crypto":         {"6051"},
+	"adult":          {"5967", "7273"},
+	"money_transfer": {"4829", "6540"},
+}
+
+// CardControlService хранит ограничения карт и проверяет по ним операции
+type CardControlService struct {
+	repo        *repositories.CardControlRepository
+	cardService *CardService
+	homeCountry string // ISO 3166 numeric, операции в других странах — международные
+}
+
+func NewCardControlService(repo *repositories.CardControlRepository, cardService *CardService, homeCountry string) *CardControlService {
+	return &CardControlService{repo: repo, cardService: cardService, homeCountry: homeCountry}
+}
+
+// Get возвращает ограничения карты владельца; если они не заданы — значения по умолчанию
+func (s *CardControlService) Get(ctx context.Context, userID, cardID int64) (*models.CardControls, error) {
+	if _, err := s.cardService.getOwnedCard(ctx, userID, cardID); err != nil {
+		return nil, err
+	}
+	return s.controls(ctx, cardID)
+}
+
+func (s *CardControlService) controls(ctx context.Context, cardID int64) (*models.CardControls, error) {
+	c, err := s.repo.GetControls(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return models.DefaultCardControls(cardID), nil
+	}
+	return c, nil
+}
+
+// Update заменяет ограничения карты
+func (s *CardControlService) Update(ctx context.Context, userID, cardID int64, c *models.CardControls) error {
+	if _, err := s.cardService.getOwnedCard(ctx, userID, cardID); err != nil {
+		return err
+	}
+
+	for name, limit := range map[string]*float64{
+		"daily_limit":           c.DailyLimit,
+		"monthly_limit":         c.MonthlyLimit,
+		"per_transaction_limit": c.PerTransactionLimit,
+		"atm_daily_limit":       c.ATMDailyLimit,
+		"atm_monthly_limit":     c.ATMMonthlyLimit,
+	} {
+		if limit != nil && *limit < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	if c.DailyLimit != nil && c.MonthlyLimit != nil && *c.DailyLimit > *c.MonthlyLimit {
+		return fmt.Errorf("daily_limit must not exceed monthly_limit")
+	}
+	if c.ATMDailyLimit != nil && c.ATMMonthlyLimit != nil && *c.ATMDailyLimit > *c.ATMMonthlyLimit {
+		return fmt.Errorf("atm_daily_limit must not exceed atm_monthly_limit")
+	}
+
+	categories := splitList(c.BlockedCategories)
+	for _, category := range categories {
+		if _, ok := mccCategories[category]; !ok {
+			return fmt.Errorf("unknown merchant category %q", category)
+		}
+	}
+	codes := splitList(c.BlockedMCCCodes)
+	for _, code := range codes {
+		if len(code) != 4 || !isDigits(code) {
+			return fmt.Errorf("invalid MCC %q", code)
+		}
+	}
+
+	c.CardID = cardID
+	c.BlockedCategories = strings.Join(categories, ",")
+	c.BlockedMCCCodes = strings.Join(codes, ",")
+	return s.repo.SaveControls(ctx, c)
+}
+
+// Evaluate проверяет операцию по ограничениям карты. Возвращает причину
+// отказа или "" и правила, которые проверяются при блокировке средств.
+func (s *CardControlService) Evaluate(ctx context.Context, card *models.Card, req *models.AuthorizationRequest, amount float64) (string, models.HoldRules, error) {
+	var rules models.HoldRules
+	c, err := s.controls(ctx, card.ID)
+	if err != nil {
+		return "", rules, err
+	}
+
+	if c.PerTransactionLimit != nil && amount > *c.PerTransactionLimit {
+		return models.DeclineTransactionLimit, rules, nil
+	}
+	if req.MCC != "" && s.mccBlocked(c, req.MCC) {
+		return models.DeclineMerchantBlocked, rules, nil
+	}
+	if req.Channel == models.ChannelEcommerce && !c.OnlineEnabled {
+		return models.DeclineOnlineDisabled, rules, nil
+	}
+	if req.EntryMode == models.EntryContactless && !c.ContactlessEnabled {
+		return models.DeclineContactlessDisabled, rules, nil
+	}
+	if req.EntryMode == models.EntryMagstripe && !c.MagstripeEnabled {
+		return models.DeclineMagstripeDisabled, rules, nil
+	}
+	if req.Country != "" && s.homeCountry != "" && req.Country != s.homeCountry && !c.InternationalEnabled {
+		return models.DeclineInternationalDisabled, rules, nil
+	}
+	if card.IsVirtual() {
+		if reason := s.evaluateVirtual(card, req, &rules); reason != "" {
+			return reason, rules, nil
+		}
+	}
+
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	add := func(limit *float64, since time.Time, atm bool, reason string) {
+		if limit != nil {
+			rules.Limits = append(rules.Limits, models.SpendLimit{Since: since, ATM: atm, Limit: *limit, Reason: reason})
+		}
+	}
+	if req.Channel == models.ChannelATM {
+		add(c.ATMDailyLimit, day, true, models.DeclineATMDailyLimit)
+		add(c.ATMMonthlyLimit, month, true, models.DeclineATMMonthlyLimit)
+	} else {
+		add(c.DailyLimit, day, false, models.DeclineDailyLimit)
+		add(c.MonthlyLimit, month, false, models.DeclineMonthlyLimit)
+	}
+	return "", rules, nil
+}
+
+// evaluateVirtual — правила виртуальных карт: только онлайн, лимит за весь
+// срок действия, одна операция или один продавец
+func (s *CardControlService) evaluateVirtual(card *models.Card, req *models.AuthorizationRequest, rules *models.HoldRules) string {
+	if req.Channel != models.ChannelEcommerce {
+		return models.DeclineCardNotPresentOnly
+	}
+	if card.SpendCap != nil {
+		rules.Limits = append(rules.Limits, models.SpendLimit{
+			Since: card.CreatedAt, Any: true, Limit: *card.SpendCap, Reason: models.DeclineSpendCap,
+		})
+	}
+
+	switch card.VirtualType {
+	case models.VirtualSingleUse:
+		rules.SingleUse = true
+	case models.VirtualMerchantLocked:
+		merchant := req.MerchantID
+		if merchant == "" {
+			merchant = req.Merchant
+		}
+		if merchant == "" {
+			return models.DeclineMerchantLocked
+		}
+		if card.LockedMerchant != "" && card.LockedMerchant != merchant {
+			return models.DeclineMerchantLocked
+		}
+		rules.Merchant = merchant
+	}
+	return ""
+}
+
+func (s *CardControlService) mccBlocked(c *models.CardControls, mcc string) bool {
+	for _, code := range splitList(c.BlockedMCCCodes) {
+		if code == mcc {
+			return true
+		}
+	}
+	for _, category := range splitList(c.BlockedCategories) {
+		for _, code := range mccCategories[category] {
+			if code == mcc {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitList разбирает список через запятую: " gambling, crypto" -> [gambling crypto]
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/repositories"
+	"bank-api/internal/security"
+	"bank-api/pkg/utils/logger"
+	"context"
+)
+
+const defaultReencryptBatch = 500
+
+// CardKeyRotationService перешифровывает карты ключами данных под текущим
+// мастер-ключом KMS и выводит из оборота статические ключи, на которые больше
+// не ссылается ни одна запись
+type CardKeyRotationService struct {
+	cardService *CardService
+	cardRepo    *repositories.CardRepository
+	repo        *repositories.KeyRotationRepository
+	keyring     *security.Keyring
+	batchSize   int
+}
+
+func NewCardKeyRotationService(
+	cardService *CardService,
+	cardRepo *repositories.CardRepository,
+	repo *repositories.KeyRotationRepository,
+	keyring *security.Keyring,
+	batchSize int,
+) *CardKeyRotationService {
+	if batchSize <= 0 {
+		batchSize = defaultReencryptBatch
+	}
+	return &CardKeyRotationService{
+		cardService: cardService,
+		cardRepo:    cardRepo,
+		repo:        repo,
+		keyring:     keyring,
+		batchSize:   batchSize,
+	}
+}
+
+// ApplyRetired убирает из кольца ключи, выведенные при прошлых запусках.
+// Вызывается при старте, чтобы оставшийся в конфиге старый ключ не использовался.
+func (s *CardKeyRotationService) ApplyRetired(ctx context.Context) error {
+	retired, err := s.repo.ListRetiredKeys(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range retired {
+		s.keyring.Retire(id)
+	}
+	return nil
+}
+
+// Run перешифровывает карты пачками. Прогресс сохраняется после каждой пачки,
+// поэтому прерванный запуск продолжается с последней обработанной карты.
+func (s *CardKeyRotationService) Run(ctx context.Context) error {
+	target := s.cardService.CurrentKeyID()
+
+	job, err := s.repo.GetRunningJob(ctx, target)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		job = &models.ReencryptionJob{TargetKeyID: target}
+		if err := s.repo.CreateJob(ctx, job); err != nil {
+			return err
+		}
+	} else {
+		logger.Sugared().Infof("resuming re-encryption job %d after card %d", job.ID, job.LastCardID)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		cards, err := s.cardRepo.ListCardsToReencrypt(ctx, target, job.LastCardID, s.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(cards) == 0 {
+			break
+		}
+
+		for _, card := range cards {
+			s.reencryptCard(ctx, job, card)
+			job.LastCardID = card.ID
+		}
+
+		if err := s.repo.SaveProgress(ctx, job); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.CompleteJob(ctx, job.ID); err != nil {
+		return err
+	}
+	logger.Sugared().Infof("re-encryption job %d completed: %d cards re-encrypted, %d failed",
+		job.ID, job.Processed, job.Failed)
+
+	return s.retireUnused(ctx)
+}
+
+func (s *CardKeyRotationService) reencryptCard(ctx context.Context, job *models.ReencryptionJob, card *models.Card) {
+	previous := card.EncryptedData
+
+	changed, err := s.cardService.reencrypt(ctx, card)
+	if err != nil {
+		job.Failed++
+		logger.Sugared().Errorf("re-encryption of card %d failed: %v", card.ID, err)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	updated, err := s.cardRepo.UpdateCardEncryption(ctx, card, previous)
+	if err != nil {
+		job.Failed++
+		logger.Sugared().Errorf("failed to save re-encrypted card %d: %v", card.ID, err)
+		return
+	}
+	if !updated {
+		// карта изменилась параллельно — подхватим при следующем запуске
+		logger.Sugared().Warnf("card %d changed during re-encryption, skipped", card.ID)
+		return
+	}
+	job.Processed++
+}
+
+// retireUnused выводит из оборота статические ключи, которыми не зашифровано ни одной карты
+func (s *CardKeyRotationService) retireUnused(ctx context.Context) error {
+	for _, id := range s.keyring.KeyIDs() {
+		count, err := s.cardRepo.CountCardsByKey(ctx, id)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			logger.Sugared().Infof("key %q is still used by %d cards", id, count)
+			continue
+		}
+
+		if err := s.repo.RetireKey(ctx, id); err != nil {
+			return err
+		}
+		s.keyring.Retire(id)
+		logger.Sugared().Infof("encryption key %q retired and can be removed from config", id)
+	}
+	return nil
+}
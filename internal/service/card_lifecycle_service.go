@@ -0,0 +1,356 @@
+package service
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/repositories"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrCardTransitionNotAllowed = errors.New("card status transition not allowed")
+	ErrCardBlockedByBank        = errors.New("card was blocked by the bank, contact support")
+	ErrCardReplacementPending   = errors.New("replacement card already issued")
+)
+
+// Кто меняет статус карты
+const (
+	CardActorUser   = "user"
+	CardActorAdmin  = "admin"
+	CardActorSystem = "system"
+)
+
+// Причины перевыпуска
+const (
+	ReissueDamaged = "damaged"
+	ReissueLost    = "lost"
+	ReissueStolen  = "stolen"
+)
+
+const (
+	// за сколько дней до окончания срока выпускается новая карта
+	cardRenewalDays = 30
+	// размер пачки при заполнении expires_at у старых карт
+	expiryBackfillBatch = 500
+)
+
+// cardTransitions — разрешённые переходы статусов карты
+var cardTransitions = map[string][]string{
+	models.CardStatusInactive: {
+		models.CardStatusActive, models.CardStatusTempBlocked, models.CardStatusBlockedLost,
+		models.CardStatusBlockedStolen, models.CardStatusExpired, models.CardStatusClosed,
+	},
+	models.CardStatusActive: {
+		models.CardStatusTempBlocked, models.CardStatusBlockedLost, models.CardStatusBlockedStolen,
+		models.CardStatusExpired, models.CardStatusClosed,
+	},
+	models.CardStatusTempBlocked: {
+		models.CardStatusActive, models.CardStatusBlockedLost, models.CardStatusBlockedStolen,
+		models.CardStatusExpired, models.CardStatusClosed,
+	},
+	models.CardStatusBlockedLost:   {models.CardStatusClosed},
+	models.CardStatusBlockedStolen: {models.CardStatusClosed},
+	models.CardStatusExpired:       {models.CardStatusClosed},
+	models.CardStatusClosed:        {},
+}
+
+func canTransition(from, to string) bool {
+	for _, status := range cardTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CardLifecycleService управляет статусами карт: заморозка, блокировка,
+// активация, перевыпуск, закрытие, а также истечение срока и продление
+type CardLifecycleService struct {
+	cardService *CardService
+	repo        *repositories.CardRepository
+}
+
+func NewCardLifecycleService(cardService *CardService, repo *repositories.CardRepository) *CardLifecycleService {
+	return &CardLifecycleService{cardService: cardService, repo: repo}
+}
+
+// changeStatus проверяет переход и записывает его в журнал
+func (s *CardLifecycleService) changeStatus(
+	ctx context.Context,
+	card *models.Card,
+	to, reason, actorType string,
+	actorID *int64,
+) error {
+	if card.Status == to {
+		return nil
+	}
+	if !canTransition(card.Status, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrCardTransitionNotAllowed, card.Status, to)
+	}
+
+	change := &models.CardStatusChange{
+		CardID:     card.ID,
+		FromStatus: card.Status,
+		ToStatus:   to,
+		Reason:     reason,
+		ActorType:  actorType,
+		ActorID:    actorID,
+	}
+	if err := s.repo.ChangeStatus(ctx, change); err != nil {
+		return err
+	}
+
+	card.Status = to
+	card.StatusReason = reason
+	card.StatusChangedAt = &change.CreatedAt
+	return nil
+}
+
+// Freeze временно блокирует карту по просьбе владельца
+func (s *CardLifecycleService) Freeze(ctx context.Context, userID, cardID int64) (*models.Card, error) {
+	card, err := s.cardService.getOwnedCard(ctx, userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.changeStatus(ctx, card, models.CardStatusTempBlocked, "frozen by user", CardActorUser, &userID); err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+// Unfreeze снимает временную блокировку. Блокировку, поставленную банком,
+// владелец снять не может.
+func (s *CardLifecycleService) Unfreeze(ctx context.Context, userID, cardID int64) (*models.Card, error) {
+	card, err := s.cardService.getOwnedCard(ctx, userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status != models.CardStatusTempBlocked {
+		return nil, fmt.Errorf("%w: card is %s", ErrCardTransitionNotAllowed, card.Status)
+	}
+
+	last, err := s.repo.LastStatusChange(ctx, card.ID)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil && last.ToStatus == models.CardStatusTempBlocked && last.ActorType != CardActorUser {
+		return nil, ErrCardBlockedByBank
+	}
+
+	if err := s.changeStatus(ctx, card, models.CardStatusActive, "unfrozen by user", CardActorUser, &userID); err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+// Block блокирует карту навсегда при утере или краже
+func (s *CardLifecycleService) Block(ctx context.Context, userID, cardID int64, reason string) (*models.Card, error) {
+	status, err := blockStatus(reason)
+	if err != nil {
+		return nil, err
+	}
+	card, err := s.cardService.getOwnedCard(ctx, userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.changeStatus(ctx, card, status, "reported "+reason, CardActorUser, &userID); err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+func blockStatus(reason string) (string, error) {
+	switch reason {
+	case ReissueLost:
+		return models.CardStatusBlockedLost, nil
+	case ReissueStolen:
+		return models.CardStatusBlockedStolen, nil
+	}
+	return "", fmt.Errorf("unknown block reason %q", reason)
+}
+
+// Activate активирует выпущенную карту. Если она заменяет другую,
+// старая карта закрывается.
+func (s *CardLifecycleService) Activate(ctx context.Context, userID, cardID int64) (*models.Card, error) {
+	card, err := s.cardService.getOwnedCard(ctx, userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status != models.CardStatusInactive {
+		return nil, fmt.Errorf("%w: card is %s", ErrCardTransitionNotAllowed, card.Status)
+	}
+	if err := s.changeStatus(ctx, card, models.CardStatusActive, "activated", CardActorUser, &userID); err != nil {
+		return nil, err
+	}
+
+	if card.ReplacesCardID != nil {
+		old, err := s.repo.GetCardByID(ctx, *card.ReplacesCardID)
+		if err != nil {
+			return nil, err
+		}
+		if old != nil && old.Status != models.CardStatusClosed {
+			if err := s.changeStatus(ctx, old, models.CardStatusClosed, "replaced", CardActorSystem, nil); err != nil {
+				logger.Sugared().Errorf("failed to close card %d replaced by %d: %v", old.ID, card.ID, err)
+			}
+		}
+	}
+	return card, nil
+}
+
+// Close закрывает карту. Запись остаётся для истории операций.
+func (s *CardLifecycleService) Close(ctx context.Context, userID, cardID int64) (*models.Card, error) {
+	card, err := s.cardService.getOwnedCard(ctx, userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.changeStatus(ctx, card, models.CardStatusClosed, "closed by user", CardActorUser, &userID); err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+// Reissue выпускает карту на замену. При утере или краже старая карта
+// блокируется сразу, при повреждении — работает до активации новой.
+func (s *CardLifecycleService) Reissue(ctx context.Context, userID, cardID int64, reason string) (*models.Card, error) {
+	card, err := s.cardService.getOwnedCard(ctx, userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status == models.CardStatusClosed {
+		return nil, fmt.Errorf("%w: card is closed", ErrCardTransitionNotAllowed)
+	}
+	if card.IsVirtual() {
+		return nil, fmt.Errorf("%w: virtual cards are not reissued, issue a new one", ErrCardTransitionNotAllowed)
+	}
+
+	pending, err := s.repo.GetReplacement(ctx, card.ID)
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil && pending.Status == models.CardStatusInactive {
+		return nil, ErrCardReplacementPending
+	}
+
+	switch reason {
+	case ReissueDamaged:
+	case ReissueLost, ReissueStolen:
+		status, _ := blockStatus(reason)
+		if err := s.changeStatus(ctx, card, status, "reported "+reason, CardActorUser, &userID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown reissue reason %q", reason)
+	}
+
+	return s.cardService.issueCard(ctx, card.AccountID, card.Product, cardOptions{Status: models.CardStatusInactive, ReplacesCardID: &card.ID})
+}
+
+// History возвращает журнал смены статусов карты
+func (s *CardLifecycleService) History(ctx context.Context, userID, cardID int64) ([]models.CardStatusChange, error) {
+	if _, err := s.cardService.getOwnedCard(ctx, userID, cardID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListStatusHistory(ctx, cardID)
+}
+
+// SetStatus — смена статуса сотрудником банка
+func (s *CardLifecycleService) SetStatus(ctx context.Context, adminID, cardID int64, status, reason string) (*models.Card, error) {
+	if _, ok := cardTransitions[status]; !ok {
+		return nil, fmt.Errorf("unknown card status %q", status)
+	}
+	if reason == "" {
+		return nil, errors.New("reason is required")
+	}
+
+	card, err := s.repo.GetCardByID(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card == nil {
+		return nil, ErrCardNotFound
+	}
+	if err := s.changeStatus(ctx, card, status, reason, CardActorAdmin, &adminID); err != nil {
+		return nil, err
+	}
+	// разблокировка сотрудником снимает и блокировку PIN
+	if status == models.CardStatusActive {
+		if err := s.repo.ResetPINTries(ctx, card.ID); err != nil {
+			return nil, err
+		}
+	}
+	return card, nil
+}
+
+// ProcessExpiry — ежедневная задача: переводит просроченные карты в expired
+// и заранее выпускает новые карты тем, у кого срок подходит к концу
+func (s *CardLifecycleService) ProcessExpiry(ctx context.Context) error {
+	if err := s.backfillExpiry(ctx); err != nil {
+		return err
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	expired, err := s.repo.ListExpiredCards(ctx, today)
+	if err != nil {
+		return err
+	}
+	for _, card := range expired {
+		if err := s.changeStatus(ctx, card, models.CardStatusExpired, "expired", CardActorSystem, nil); err != nil {
+			logger.Sugared().Errorf("failed to expire card %d: %v", card.ID, err)
+		}
+	}
+
+	renewals, err := s.repo.ListCardsForRenewal(ctx, today.AddDate(0, 0, cardRenewalDays))
+	if err != nil {
+		return err
+	}
+	for _, card := range renewals {
+		renewed, err := s.cardService.issueCard(ctx, card.AccountID, card.Product, cardOptions{Status: models.CardStatusInactive, ReplacesCardID: &card.ID})
+		if err != nil {
+			logger.Sugared().Errorf("failed to issue renewal for card %d: %v", card.ID, err)
+			continue
+		}
+		logger.Sugared().Infof("card %d renewed with card %d", card.ID, renewed.ID)
+	}
+
+	if len(expired) > 0 || len(renewals) > 0 {
+		logger.Sugared().Infof("card expiry: %d cards expired, %d renewals issued", len(expired), len(renewals))
+	}
+	return nil
+}
+
+// backfillExpiry заполняет expires_at у карт, выпущенных до его появления:
+// срок хранится только в зашифрованных данных карты
+func (s *CardLifecycleService) backfillExpiry(ctx context.Context) error {
+	var afterID int64
+	for {
+		cards, err := s.repo.ListCardsWithoutExpiry(ctx, afterID, expiryBackfillBatch)
+		if err != nil {
+			return err
+		}
+		if len(cards) == 0 {
+			return nil
+		}
+
+		for _, card := range cards {
+			afterID = card.ID
+
+			_, expire, _, err := s.cardService.decryptAndVerify(ctx, card)
+			if err != nil {
+				logger.Sugared().Errorf("failed to read expiry of card %d: %v", card.ID, err)
+				continue
+			}
+			month, err := time.Parse("01/06", expire)
+			if err != nil {
+				logger.Sugared().Errorf("card %d has invalid expiry %q", card.ID, expire)
+				continue
+			}
+			if err := s.repo.SetExpiry(ctx, card.ID, lastDayOfMonth(month)); err != nil {
+				return err
+			}
+		}
+	}
+}
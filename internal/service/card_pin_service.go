@@ -0,0 +1,183 @@
+package service
+
+import (
+	"bank-api/internal/hsm"
+	"bank-api/internal/models"
+	"bank-api/internal/repositories"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrPINAlreadySet    = errors.New("PIN is already set, use change PIN")
+	ErrPINNotSet        = errors.New("PIN is not set")
+	ErrIncorrectPIN     = errors.New("incorrect PIN")
+	ErrPINTriesExceeded = errors.New("PIN tries exceeded, card is blocked")
+	ErrInvalidPINBlock  = errors.New("invalid PIN block")
+	ErrPINNotApplicable = errors.New("virtual cards have no PIN")
+	ErrUnknownPINFormat = errors.New("pin format must be iso0 or iso4")
+)
+
+const defaultPINMaxTries = 3
+
+// pinBlockFormats — форматы PIN-блока в API; по умолчанию формат 0
+var pinBlockFormats = map[string]int{"": hsm.FormatISO0, "iso0": hsm.FormatISO0, "iso4": hsm.FormatISO4}
+
+// PINBlock — PIN-блок ISO 9564 в hex, зашифрованный ключом зоны
+type PINBlock struct {
+	Block  string `json:"pin_block"`
+	Format string `json:"format"` // iso0 или iso4
+}
+
+// CardPINService устанавливает и проверяет PIN через HSM. В базе хранится
+// только смещение PIN; после maxTries неверных попыток подряд карта блокируется.
+type CardPINService struct {
+	cardService *CardService
+	repo        *repositories.CardRepository
+	hsm         hsm.HSM
+	maxTries    int
+}
+
+func NewCardPINService(cardService *CardService, repo *repositories.CardRepository, h hsm.HSM, maxTries int) *CardPINService {
+	if maxTries <= 0 {
+		maxTries = defaultPINMaxTries
+	}
+	return &CardPINService{cardService: cardService, repo: repo, hsm: h, maxTries: maxTries}
+}
+
+// SetPIN устанавливает первый PIN карты
+func (s *CardPINService) SetPIN(ctx context.Context, userID, cardID int64, pin PINBlock) error {
+	card, number, err := s.pinCard(ctx, userID, cardID)
+	if err != nil {
+		return err
+	}
+	if card.PINSet {
+		return ErrPINAlreadySet
+	}
+	return s.storePIN(ctx, card, number, pin)
+}
+
+// ChangePIN меняет PIN после проверки текущего. Неверный текущий PIN
+// расходует попытку, как и в авторизации.
+func (s *CardPINService) ChangePIN(ctx context.Context, userID, cardID int64, current, pin PINBlock) error {
+	card, number, err := s.pinCard(ctx, userID, cardID)
+	if err != nil {
+		return err
+	}
+
+	switch reason, err := s.verify(ctx, card, number, current.Block, current.Format); {
+	case err != nil:
+		return err
+	case reason == models.DeclinePINNotSet:
+		return ErrPINNotSet
+	case reason == models.DeclinePINTriesExceeded:
+		return ErrPINTriesExceeded
+	case reason != "":
+		return ErrIncorrectPIN
+	}
+	return s.storePIN(ctx, card, number, pin)
+}
+
+func (s *CardPINService) pinCard(ctx context.Context, userID, cardID int64) (*models.Card, string, error) {
+	card, err := s.cardService.getOwnedCard(ctx, userID, cardID)
+	if err != nil {
+		return nil, "", err
+	}
+	if card.IsVirtual() {
+		return nil, "", ErrPINNotApplicable
+	}
+	if card.Status != models.CardStatusActive && card.Status != models.CardStatusInactive {
+		return nil, "", fmt.Errorf("%w: card is %s", ErrCardTransitionNotAllowed, card.Status)
+	}
+
+	number, _, _, err := s.cardService.decryptAndVerify(ctx, card)
+	if err != nil {
+		return nil, "", err
+	}
+	return card, number, nil
+}
+
+func (s *CardPINService) storePIN(ctx context.Context, card *models.Card, number string, pin PINBlock) error {
+	block, format, err := decodePINBlock(pin.Block, pin.Format)
+	if err != nil {
+		return err
+	}
+	offset, err := s.hsm.GeneratePINOffset(block, format, number)
+	switch {
+	case errors.Is(err, hsm.ErrWeakPIN):
+		return errors.New("PIN is too simple")
+	case errors.Is(err, hsm.ErrInvalidPINBlock):
+		return ErrInvalidPINBlock
+	case err != nil:
+		return err
+	}
+	return s.repo.SetPIN(ctx, card.ID, offset)
+}
+
+// verify проверяет PIN из авторизации или смены PIN. Возвращает причину
+// отказа или "". Блок, который не расшифровывается, считается неверным PIN.
+func (s *CardPINService) verify(ctx context.Context, card *models.Card, number, pinBlock, pinFormat string) (string, error) {
+	offset, tries, err := s.repo.GetPIN(ctx, card.ID)
+	if err != nil {
+		return "", err
+	}
+	if offset == "" {
+		return models.DeclinePINNotSet, nil
+	}
+	if tries >= s.maxTries {
+		return models.DeclinePINTriesExceeded, nil
+	}
+
+	block, format, err := decodePINBlock(pinBlock, pinFormat)
+	if errors.Is(err, ErrUnknownPINFormat) {
+		return "", err
+	}
+	valid := false
+	if err == nil {
+		valid, err = s.hsm.VerifyPIN(block, format, number, offset)
+		if err != nil && !errors.Is(err, hsm.ErrInvalidPINBlock) {
+			return "", err
+		}
+	}
+	if valid {
+		if tries > 0 {
+			return "", s.repo.ResetPINTries(ctx, card.ID)
+		}
+		return "", nil
+	}
+
+	tries, err = s.repo.IncrementPINTries(ctx, card.ID)
+	if err != nil {
+		return "", err
+	}
+	if tries < s.maxTries {
+		return models.DeclineIncorrectPIN, nil
+	}
+	if card.Status == models.CardStatusActive {
+		err = s.repo.ChangeStatus(ctx, &models.CardStatusChange{
+			CardID:     card.ID,
+			FromStatus: card.Status,
+			ToStatus:   models.CardStatusTempBlocked,
+			Reason:     "PIN tries exceeded",
+			ActorType:  CardActorSystem,
+		})
+		if err != nil && !errors.Is(err, repositories.ErrCardStatusChanged) {
+			return "", err
+		}
+	}
+	return models.DeclinePINTriesExceeded, nil
+}
+
+func decodePINBlock(block, format string) ([]byte, int, error) {
+	f, ok := pinBlockFormats[format]
+	if !ok {
+		return nil, 0, ErrUnknownPINFormat
+	}
+	data, err := hex.DecodeString(block)
+	if err != nil {
+		return nil, 0, ErrInvalidPINBlock
+	}
+	return data, f, nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/repositories"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrRevealTokenInvalid = errors.New("reveal token is invalid or expired")
+	ErrRevealReauth       = errors.New("password is incorrect")
+	ErrRevealRateLimited  = errors.New("too many reveal requests, try again later")
+)
+
+const (
+	defaultRevealTTL     = time.Minute
+	defaultRevealPerHour = 5
+)
+
+// PasswordVerifier проверяет пароль пользователя для повторной аутентификации
+type PasswordVerifier func(ctx context.Context, userID int64, password string) error
+
+// CardRevealService выдаёт полные реквизиты карты: после повторного ввода
+// пароля выпускается одноразовый токен с коротким сроком жизни. Каждая
+// попытка, в том числе с неверным паролем, пишется в card_reveals.
+type CardRevealService struct {
+	cardService    *CardService
+	repo           *repositories.CardRevealRepository
+	verifyPassword PasswordVerifier
+	ttl            time.Duration
+	perHour        int // сколько запросов на показ пользователь может сделать за час
+}
+
+func NewCardRevealService(
+	cardService *CardService,
+	repo *repositories.CardRevealRepository,
+	verifyPassword PasswordVerifier,
+	ttl time.Duration,
+	perHour int,
+) *CardRevealService {
+	if ttl <= 0 {
+		ttl = defaultRevealTTL
+	}
+	if perHour <= 0 {
+		perHour = defaultRevealPerHour
+	}
+	return &CardRevealService{
+		cardService:    cardService,
+		repo:           repo,
+		verifyPassword: verifyPassword,
+		ttl:            ttl,
+		perHour:        perHour,
+	}
+}
+
+// RequestReveal проверяет пароль и выдаёт одноразовый токен для показа
+// реквизитов. В базе хранится только хеш токена.
+func (s *CardRevealService) RequestReveal(ctx context.Context, userID, cardID int64, password, ip, userAgent string) (string, *models.CardReveal, error) {
+	card, err := s.cardService.getOwnedCard(ctx, userID, cardID)
+	if err != nil {
+		return "", nil, err
+	}
+	if card.Status == models.CardStatusClosed || card.Status == models.CardStatusExpired {
+		return "", nil, fmt.Errorf("%w: card is %s", ErrCardTransitionNotAllowed, card.Status)
+	}
+
+	count, err := s.repo.CountSince(ctx, userID, time.Now().Add(-time.Hour))
+	if err != nil {
+		return "", nil, err
+	}
+	if count >= s.perHour {
+		return "", nil, ErrRevealRateLimited
+	}
+
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	reveal := &models.CardReveal{
+		CardID:    card.ID,
+		UserID:    userID,
+		IP:        ip,
+		UserAgent: userAgent,
+		Outcome:   models.RevealIssued,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+
+	if err := s.verifyPassword(ctx, userID, password); err != nil {
+		reveal.Outcome = models.RevealDenied
+		if err := s.repo.Create(ctx, reveal); err != nil {
+			return "", nil, err
+		}
+		return "", nil, ErrRevealReauth
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(raw)
+	hash := revealTokenHash(token)
+	reveal.TokenHash = &hash
+
+	if err := s.repo.Create(ctx, reveal); err != nil {
+		return "", nil, err
+	}
+	return token, reveal, nil
+}
+
+// Reveal возвращает номер, срок и CVV карты по токену. Токен действует один раз.
+func (s *CardRevealService) Reveal(ctx context.Context, userID int64, token, ip string) (*models.CardDetails, error) {
+	reveal, err := s.repo.Consume(ctx, revealTokenHash(token), userID, ip)
+	if err != nil {
+		return nil, err
+	}
+	if reveal == nil {
+		return nil, ErrRevealTokenInvalid
+	}
+
+	card, err := s.cardService.getOwnedCard(ctx, userID, reveal.CardID)
+	if err != nil {
+		return nil, err
+	}
+	number, expire, cvv, err := s.cardService.decryptAndVerify(ctx, card)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %w", err)
+	}
+	return &models.CardDetails{CardID: card.ID, CardNumber: number, ExpirationDate: expire, CVV: cvv}, nil
+}
+
+func revealTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,98 +1,211 @@
 package service
 
 import (
-	"bank-api/internal/config"
+	"bank-api/internal/hsm"
+	"bank-api/internal/kms"
 	"bank-api/internal/models"
+	"bank-api/internal/pan"
 	"bank-api/internal/repositories"
 	"bank-api/internal/security"
 	"context"
-	"crypto/rand"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// maxIssueAttempts — сколько раз перевыпускать номер при совпадении с уже выданным
+const maxIssueAttempts = 5
+
 // Service for work with card
 type CardService struct {
-	repo      *repositories.CardRepository
-	secretKey []byte
-	hmacKey   []byte
+	repo           *repositories.CardRepository
+	accountRepo    *repositories.AccountRepository
+	kms            kms.KeyManager
+	keyring        *security.Keyring // старые статические ключи и HMAC
+	issuer         *pan.Issuer
+	hsm            hsm.HSM // CVV2 вычисляется ключом CVK, а не хранится
+	defaultProduct string
 }
 
 // NewCardService created new service for cards
-func NewCardService(repo *repositories.CardRepository, secretKey, hmacKey []byte) *CardService {
+func NewCardService(
+	repo *repositories.CardRepository,
+	accountRepo *repositories.AccountRepository,
+	keyManager kms.KeyManager,
+	keyring *security.Keyring,
+	issuer *pan.Issuer,
+	h hsm.HSM,
+	defaultProduct string,
+) *CardService {
 	return &CardService{
-		repo:      repo,
-		secretKey: secretKey,
-		hmacKey:   hmacKey,
+		repo:           repo,
+		accountRepo:    accountRepo,
+		kms:            keyManager,
+		keyring:        keyring,
+		issuer:         issuer,
+		hsm:            h,
+		defaultProduct: defaultProduct,
+	}
+}
+
+// CurrentKeyID — значение cards.key_id для карт, чей DEK обёрнут текущим мастер-ключом
+func (s *CardService) CurrentKeyID() string {
+	return "kms:" + s.kms.KeyID()
+}
+
+// CreateCard generate new card for choosen account. Пустой product — продукт по умолчанию.
+func (s *CardService) CreateCard(ctx context.Context, userID, accountID int64, product string) (*models.Card, error) {
+	owned, err := s.accountRepo.IsAccountOwnedByUser(ctx, accountID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, ErrCardNotFound
+	}
+	kind, err := s.accountRepo.GetAccountKind(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if kind != "current" {
+		return nil, errors.New("cards can be issued only to current accounts")
+	}
+
+	return s.issueCard(ctx, accountID, product, cardOptions{Status: models.CardStatusActive})
+}
+
+// cardOptions — параметры выпуска карты
+type cardOptions struct {
+	Status         string
+	ReplacesCardID *int64     // карта, которую заменяет новая
+	VirtualType    string     // пусто — физическая карта
+	SpendCap       *float64   // лимит за весь срок действия
+	ExpiresAt      *time.Time // пусто — стандартный срок 3 года
+}
+
+// issueCard выпускает карту с уникальным номером
+func (s *CardService) issueCard(ctx context.Context, accountID int64, product string, opts cardOptions) (*models.Card, error) {
+	if product == "" {
+		product = s.defaultProduct
+	}
+	binRange, ok := s.issuer.Product(product)
+	if !ok {
+		return nil, fmt.Errorf("unknown card product %q", product)
 	}
+
+	for attempt := 0; attempt < maxIssueAttempts; attempt++ {
+		cardNumber, panHash, err := s.issueNumber(ctx, product)
+		if err != nil {
+			return nil, err
+		}
+
+		card, err := s.createCard(ctx, accountID, binRange, cardNumber, panHash, opts)
+		if errors.Is(err, repositories.ErrDuplicatePAN) {
+			continue // номер успели выдать параллельно
+		}
+		return card, err
+	}
+	return nil, errors.New("failed to issue a unique card number")
 }
 
-// CreateCard generate new card for choosen account
-func (s *CardService) CreateCard(ctx context.Context, accountID int64) (*models.Card, error) {
-	cardNumber := generateCardNumber()
-	cvv := generateCVV()
+// issueNumber выпускает номер, которого ещё нет среди выданных
+func (s *CardService) issueNumber(ctx context.Context, product string) (number, panHash string, err error) {
+	for attempt := 0; attempt < maxIssueAttempts; attempt++ {
+		number, err = s.issuer.Generate(product)
+		if err != nil {
+			return "", "", err
+		}
+		// номер мог быть выдан до смены ключа подписи, поэтому проверяются
+		// хеши под всеми принимаемыми ключами; первый — под текущим
+		hashes, err := s.keyring.PANHashes(number)
+		if err != nil {
+			return "", "", err
+		}
+		exists, err := s.repo.PANHashExists(ctx, hashes)
+		if err != nil {
+			return "", "", err
+		}
+		if !exists {
+			return number, hashes[0], nil
+		}
+	}
+	return "", "", errors.New("failed to issue a unique card number")
+}
+
+func (s *CardService) createCard(
+	ctx context.Context,
+	accountID int64,
+	product pan.BINRange,
+	cardNumber, panHash string,
+	opts cardOptions,
+) (*models.Card, error) {
 	expirationDate := time.Now().AddDate(3, 0, 0)
+	expiresAt := lastDayOfMonth(expirationDate)
+	// виртуальная карта действует до заданной даты, на реквизитах — её месяц
+	if opts.ExpiresAt != nil {
+		expirationDate = *opts.ExpiresAt
+		expiresAt = *opts.ExpiresAt
+	}
+	formFactor := models.CardFormPhysical
+	if opts.VirtualType != "" {
+		formFactor = models.CardFormVirtual
+	}
+
+	// ID нужен заранее: он входит в associated data шифротекста
+	cardID, err := s.repo.NextCardID(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	// Формируем строку, которую будем шифровать и подписывать
 	plainData := fmt.Sprintf("%s|%s", cardNumber, expirationDate.Format("01/06"))
 
+	// Для каждой карты — свой ключ данных, в БД хранится только обёрнутый
+	dek, err := s.kms.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("generate data key: %w", err)
+	}
+
 	// Шифруем
-	encryptedData, err := security.EncryptAES(plainData, s.secretKey)
+	encryptedData, err := sealCardField(plainData, dek.Plaintext, cardID)
 	if err != nil {
 		return nil, fmt.Errorf("encrypt card data: %w", err)
 	}
 
 	// Подписываем
-	signature, err := security.GenerateHMAC(plainData, s.hmacKey)
+	signature, err := s.keyring.Sign(plainData)
 	if err != nil {
 		return nil, fmt.Errorf("generate HMAC: %w", err)
 	}
 
-	// Шифруем CVV
-	encryptedCVV, err := security.EncryptAES(cvv, s.secretKey)
-	if err != nil {
-		return nil, fmt.Errorf("encrypt CVV: %w", err)
-	}
-
 	card := &models.Card{
-		AccountID:     accountID,
-		EncryptedData: encryptedData,
-		HMAC:          signature,
-		CVV:           encryptedCVV,
-		CreatedAt:     time.Now(),
+		ID:             cardID,
+		AccountID:      accountID,
+		EncryptedData:  encryptedData,
+		HMAC:           signature,
+		WrappedDEK:     dek.Wrapped,
+		KeyID:          s.CurrentKeyID(),
+		PANHash:        panHash,
+		Product:        product.Product,
+		Brand:          product.Brand,
+		Status:         opts.Status,
+		ExpiresAt:      &expiresAt,
+		ReplacesCardID: opts.ReplacesCardID,
+		FormFactor:     formFactor,
+		VirtualType:    opts.VirtualType,
+		SpendCap:       opts.SpendCap,
+		CreatedAt:      time.Now(),
 	}
 
-	err = s.repo.CreateCard(ctx, card)
-	if err != nil {
+	if err := s.repo.CreateCard(ctx, card); err != nil {
 		return nil, err
 	}
-
+	setCardDetails(card, cardNumber, expirationDate.Format("01/06"))
 	return card, nil
 }
 
-// Generate card number
-func generateCardNumber() string {
-	return fmt.Sprintf("4%012d", randInt64(100000000000, 999999999999))
-}
-
-func generateCVV() string {
-	return fmt.Sprintf("%03d", randInt64(100, 999))
-}
-
-func randInt64(min, max int64) int64 {
-	return min + randInt63n(max-min+1)
-}
-
-func randInt63n(max int64) int64 {
-	b := make([]byte, 8)
-	_, _ = rand.Read(b)
-	return int64(binary.LittleEndian.Uint64(b)) % max
-}
-
 func (s *CardService) GetCardsByAccountID(ctx context.Context, accountID int64) ([]*models.Card, error) {
 	cards, err := s.repo.GetCardsByAccountID(ctx, accountID)
 	if err != nil {
@@ -100,16 +213,14 @@ func (s *CardService) GetCardsByAccountID(ctx context.Context, accountID int64)
 	}
 
 	for _, card := range cards {
-		number, expiry, cvv, err := s.decryptAndVerify(card)
+		number, expiry, _, err := s.decryptAndVerify(ctx, card)
 		if err != nil {
 			// log
 			continue
 		}
 
 		// Добавляем в карту, чтобы вернуть на фронт
-		card.CardNumber = number
-		card.ExpirationDate, _ = time.Parse("01/06", expiry)
-		card.CVV = cvv
+		setCardDetails(card, number, expiry)
 	}
 
 	return cards, nil
@@ -124,17 +235,15 @@ func (s *CardService) GetCardsByUser(ctx context.Context, userID int64) ([]*mode
 
 	// Расшифровываем данные карт и проверяем HMAC (если нужно)
 	for i, card := range cards {
-		number, expire, cvv, err := s.decryptAndVerify(card)
+		number, expire, _, err := s.decryptAndVerify(ctx, card)
 		if err != nil {
 			// Можно залогировать ошибку и продолжить, если важно продолжить обработку других карт
 			log.Printf("error decrypting card %d: %v", card.ID, err)
 			continue
 		}
 
-		// Обновляем расшифрованные данные карты
-		cards[i].CardNumber = number
-		cards[i].ExpirationDate, _ = time.Parse("01/06", expire)
-		cards[i].CVV = cvv
+		// Маскированный номер и срок — полный номер только через reveal
+		setCardDetails(cards[i], number, expire)
 	}
 
 	return cards, nil
@@ -145,29 +254,32 @@ func (s *CardService) GetCardByID(ctx context.Context, ID int64) (*models.Card,
 	if err != nil {
 		return nil, err
 	}
-	number, expire, cvv, err := s.decryptAndVerify(card)
+	number, expire, _, err := s.decryptAndVerify(ctx, card)
 	if err != nil {
 		// log
 	}
-	card.CardNumber = number
-	card.ExpirationDate, _ = time.Parse("01/06", expire)
-	card.CVV = cvv
+	setCardDetails(card, number, expire)
 	return card, nil
 
 }
 
+// setCardDetails заполняет данные для ответа: маскированный номер и срок.
+// Полный номер и CVV выдаются только через reveal.
+func setCardDetails(card *models.Card, number, expire string) {
+	card.ExpirationDate, _ = time.Parse("01/06", expire)
+	card.MaskedPAN = pan.Mask(number)
+	card.CVV = ""
+}
+
 var ErrCardNotFound = errors.New("card not found")
 
 func (s *CardService) GetDecryptedCardByID(ctx context.Context, userID, cardID int64) (*models.CardResponse, error) {
-	card, err := s.repo.GetCardByID(ctx, cardID)
+	card, err := s.getOwnedCard(ctx, userID, cardID)
 	if err != nil {
 		return nil, err
 	}
-	if card == nil || card.AccountID != userID {
-		return nil, ErrCardNotFound
-	}
 
-	number, expire, _, err := s.decryptAndVerify(card)
+	number, expire, _, err := s.decryptAndVerify(ctx, card)
 	if err != nil {
 		return nil, fmt.Errorf("decryption failed: %w", err)
 	}
@@ -175,42 +287,160 @@ func (s *CardService) GetDecryptedCardByID(ctx context.Context, userID, cardID i
 	return &models.CardResponse{
 		ID:             card.ID,
 		AccountID:      card.AccountID,
-		CardNumber:     number,
+		MaskedPAN:      pan.Mask(number),
+		Brand:          card.Brand,
+		Status:         card.Status,
 		ExpirationDate: expire,
 		CreatedAt:      card.CreatedAt,
 	}, nil
 }
 
-func (s *CardService) BlockCard(ctx context.Context, cardID int64) error {
-	return s.repo.SetCardStatus(ctx, cardID, "blocked") // например, если ты добавишь поле `Status` в модель
+// getOwnedCard возвращает карту, если она привязана к счёту пользователя
+func (s *CardService) getOwnedCard(ctx context.Context, userID, cardID int64) (*models.Card, error) {
+	ownerID, err := s.repo.GetCardOwner(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if ownerID == 0 || ownerID != userID {
+		return nil, ErrCardNotFound
+	}
+
+	card, err := s.repo.GetCardByID(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card == nil {
+		return nil, ErrCardNotFound
+	}
+	return card, nil
+}
+
+// lastDayOfMonth — карта с датой MM/YY действует до конца этого месяца
+func lastDayOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
 }
 
-func (s *CardService) DeleteCard(ctx context.Context, cardID int64) error {
-	return s.repo.DeleteCard(ctx, cardID)
+// cardAAD привязывает шифротекст к карте: строку нельзя подставить в другую запись
+func cardAAD(cardID int64) []byte {
+	return []byte("card:" + strconv.FormatInt(cardID, 10))
+}
+
+func sealCardField(plaintext string, dek []byte, cardID int64) (string, error) {
+	return security.Seal(plaintext, security.DataKeyID, dek, cardAAD(cardID))
+}
+
+// cardDecrypter возвращает функцию расшифровки полей карты. Поля под DEK
+// расшифровываются ключом записи (он разворачивается в KMS не больше одного
+// раза), старые записи — статическими ключами кольца.
+func (s *CardService) cardDecrypter(ctx context.Context, card *models.Card) func(data string) (string, error) {
+	var dek []byte
+	return func(data string) (string, error) {
+		if security.KeyIDOf(data) != security.DataKeyID {
+			return s.keyring.Decrypt(data, cardAAD(card.ID))
+		}
+		if dek == nil {
+			if card.WrappedDEK == "" {
+				return "", errors.New("card has no data key")
+			}
+			key, err := s.kms.Decrypt(ctx, card.WrappedDEK)
+			if err != nil {
+				return "", fmt.Errorf("unwrap data key: %w", err)
+			}
+			dek = key
+		}
+		return security.Open(data, func(string) ([]byte, error) { return dek, nil }, cardAAD(card.ID))
+	}
 }
 
-func (s *CardService) decryptAndVerify(card *models.Card) (number, expire, cvv string, err error) {
-	key := []byte(config.AppConfig.Encryption.Secret)
+func (s *CardService) decryptAndVerify(ctx context.Context, card *models.Card) (number, expire, cvv string, err error) {
+	decrypt := s.cardDecrypter(ctx, card)
 
-	plaintext, err := security.DecryptAES(card.EncryptedData, key)
+	plaintext, err := decrypt(card.EncryptedData)
 	if err != nil {
 		return "", "", "", fmt.Errorf("decrypt error: %w", err)
 	}
 
-	expectedMAC := card.HMAC
-	valid, _ := security.VerifyHMAC(plaintext, expectedMAC, key)
-	if !valid {
+	if valid, _ := s.keyring.Verify(plaintext, card.HMAC); !valid {
 		return "", "", "", errors.New("HMAC verification failed")
 	}
 
-	// Пример: "4111111111111111|12/27|123"
+	// Пример: "4111111111111111|12/27"
 	parts := strings.Split(plaintext, "|")
-	if len(parts) != 3 {
+	if len(parts) != 2 {
 		return "", "", "", errors.New("invalid decrypted format")
 	}
 
 	number = parts[0]
 	expire = parts[1]
-	cvv = parts[2]
+
+	// CVV карт, выпущенных до перехода на CVK, хранится зашифрованным
+	if card.CVV != "" {
+		cvv, err = decrypt(card.CVV)
+		if err != nil {
+			return "", "", "", fmt.Errorf("decrypt CVV: %w", err)
+		}
+		return
+	}
+	cvv, err = s.generateCVV(number, expire)
 	return
 }
+
+// generateCVV вычисляет CVV2 по номеру и сроку MM/YY
+func (s *CardService) generateCVV(number, expire string) (string, error) {
+	if len(expire) != 5 {
+		return "", errors.New("invalid expiry format")
+	}
+	cvv, err := s.hsm.GenerateCVV(number, expire[3:]+expire[:2], hsm.ServiceCodeCVV2)
+	if err != nil {
+		return "", fmt.Errorf("generate CVV: %w", err)
+	}
+	return cvv, nil
+}
+
+// reencrypt перешифровывает карту новым ключом данных под текущим мастер-ключом
+// и переподписывает HMAC. Возвращает false, если запись уже в актуальном виде.
+func (s *CardService) reencrypt(ctx context.Context, card *models.Card) (bool, error) {
+	decrypt := s.cardDecrypter(ctx, card)
+
+	plaintext, err := decrypt(card.EncryptedData)
+	if err != nil {
+		return false, fmt.Errorf("decrypt error: %w", err)
+	}
+	valid, current := s.keyring.Verify(plaintext, card.HMAC)
+	if !valid {
+		return false, errors.New("HMAC verification failed")
+	}
+	if card.KeyID == s.CurrentKeyID() && current && card.PANHash != "" {
+		return false, nil
+	}
+
+	var cvv string
+	if card.CVV != "" {
+		if cvv, err = decrypt(card.CVV); err != nil {
+			return false, fmt.Errorf("decrypt CVV: %w", err)
+		}
+	}
+
+	dek, err := s.kms.GenerateDataKey(ctx)
+	if err != nil {
+		return false, fmt.Errorf("generate data key: %w", err)
+	}
+	if card.EncryptedData, err = sealCardField(plaintext, dek.Plaintext, card.ID); err != nil {
+		return false, err
+	}
+	if cvv != "" {
+		if card.CVV, err = sealCardField(cvv, dek.Plaintext, card.ID); err != nil {
+			return false, err
+		}
+	}
+	if card.HMAC, err = s.keyring.Sign(plaintext); err != nil {
+		return false, err
+	}
+	// ключ индекса выводится из ключа подписи, поэтому хеш номера тоже обновляется
+	if card.PANHash, err = s.keyring.PANHash(strings.SplitN(plaintext, "|", 2)[0]); err != nil {
+		return false, err
+	}
+	card.WrappedDEK = dek.Wrapped
+	card.KeyID = s.CurrentKeyID()
+	return true, nil
+}
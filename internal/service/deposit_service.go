@@ -0,0 +1,392 @@
+package service
+
+import (
+	"bank-api/internal/cbr"
+	"bank-api/internal/models"
+	"bank-api/internal/repositories"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrDepositNotFound    = errors.New("deposit not found")
+	ErrDepositNotOpen     = errors.New("deposit is not open")
+	ErrProductNotFound    = errors.New("deposit product not found")
+	ErrOperationDenied    = errors.New("operation is not allowed by deposit product")
+	ErrBelowMinimumAmount = errors.New("amount is below product minimum")
+)
+
+type DepositService struct {
+	repo               *repositories.DepositRepository
+	accountRepo        *repositories.AccountRepository
+	cbService          *cbr.CBRService
+	transactionService *TransactionService
+}
+
+func NewDepositService(
+	repo *repositories.DepositRepository,
+	accountRepo *repositories.AccountRepository,
+	cb *cbr.CBRService,
+	transactionService *TransactionService,
+) *DepositService {
+	return &DepositService{
+		repo:               repo,
+		accountRepo:        accountRepo,
+		cbService:          cb,
+		transactionService: transactionService,
+	}
+}
+
+func (s *DepositService) ListProducts(ctx context.Context) ([]models.DepositProduct, error) {
+	return s.repo.ListActiveProducts(ctx)
+}
+
+// OpenDeposit открывает вклад: создаёт счёт вклада и переводит на него сумму с текущего счёта
+func (s *DepositService) OpenDeposit(ctx context.Context, userID, productID, linkedAccountID int64, amount float64) (*models.Deposit, error) {
+	product, err := s.repo.GetProductByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if product == nil || !product.IsActive {
+		return nil, ErrProductNotFound
+	}
+	if amount <= 0 || amount < product.MinAmount {
+		return nil, ErrBelowMinimumAmount
+	}
+
+	if err := s.checkLinkedAccount(ctx, userID, linkedAccountID); err != nil {
+		return nil, err
+	}
+
+	rate, err := s.resolveRate(ctx, product, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := s.accountRepo.CreateAccountOfKind(ctx, userID, "deposit")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create deposit account: %w", err)
+	}
+
+	if _, err := s.transactionService.MoveFunds(ctx, linkedAccountID, account.ID, amount,
+		"deposit_transfer", fmt.Sprintf("Opening deposit on account %d", account.ID)); err != nil {
+		return nil, err
+	}
+
+	deposit := &models.Deposit{
+		UserID:          userID,
+		ProductID:       product.ID,
+		AccountID:       account.ID,
+		LinkedAccountID: linkedAccountID,
+		Balance:         amount,
+		Rate:            rate,
+		Status:          "open",
+	}
+	if product.TermMonths > 0 {
+		maturity := truncateToDay(time.Now()).AddDate(0, product.TermMonths, 0)
+		deposit.MaturityDate = &maturity
+	}
+
+	if err := s.repo.CreateDeposit(ctx, deposit); err != nil {
+		// Возвращаем деньги, чтобы они не зависли на счёте без вклада
+		_, _ = s.transactionService.MoveFunds(ctx, account.ID, linkedAccountID, amount,
+			"deposit_transfer", "Opening deposit failed, funds returned")
+		return nil, fmt.Errorf("failed to create deposit: %w", err)
+	}
+
+	return deposit, nil
+}
+
+func (s *DepositService) GetDeposit(ctx context.Context, userID, id int64) (*models.Deposit, error) {
+	d, err := s.repo.GetDepositByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil || d.UserID != userID {
+		return nil, ErrDepositNotFound
+	}
+	return d, nil
+}
+
+func (s *DepositService) ListDeposits(ctx context.Context, userID int64) ([]models.Deposit, error) {
+	return s.repo.ListDepositsByUser(ctx, userID)
+}
+
+// TopUp пополняет вклад с привязанного счёта, если продукт это разрешает
+func (s *DepositService) TopUp(ctx context.Context, userID, id int64, amount float64) error {
+	d, product, err := s.getOpenDeposit(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !product.AllowTopUp {
+		return ErrOperationDenied
+	}
+
+	_, err = s.transactionService.MoveFunds(ctx, d.LinkedAccountID, d.AccountID, amount,
+		"deposit_transfer", fmt.Sprintf("Top-up of deposit %d", d.ID))
+	return err
+}
+
+// Withdraw частично снимает средства со вклада на привязанный счёт
+func (s *DepositService) Withdraw(ctx context.Context, userID, id int64, amount float64) error {
+	d, product, err := s.getOpenDeposit(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !product.AllowPartialWithdrawal {
+		return ErrOperationDenied
+	}
+	if d.Balance-amount < product.MinAmount {
+		return ErrBelowMinimumAmount
+	}
+
+	_, err = s.transactionService.MoveFunds(ctx, d.AccountID, d.LinkedAccountID, amount,
+		"deposit_transfer", fmt.Sprintf("Partial withdrawal from deposit %d", d.ID))
+	return err
+}
+
+// CloseDeposit закрывает вклад и возвращает средства на привязанный счёт.
+// При досрочном закрытии срочного вклада проценты пересчитываются по ставке "до востребования".
+func (s *DepositService) CloseDeposit(ctx context.Context, userID, id int64) error {
+	d, product, err := s.getOpenDeposit(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+
+	early := d.MaturityDate != nil && time.Now().Before(*d.MaturityDate)
+	if early {
+		if err := s.recalculateOnDemand(ctx, d, product); err != nil {
+			return err
+		}
+	} else if err := s.postInterest(ctx, d, product); err != nil {
+		return err
+	}
+
+	return s.settle(ctx, d, "closed")
+}
+
+// AccrueInterest — ночная задача: начисляет проценты за каждый прошедший день,
+// капитализирует или выплачивает их в конце месяца/срока и закрывает истёкшие вклады.
+func (s *DepositService) AccrueInterest(ctx context.Context) error {
+	deposits, err := s.repo.ListOpenDeposits(ctx)
+	if err != nil {
+		return err
+	}
+
+	yesterday := truncateToDay(time.Now()).AddDate(0, 0, -1)
+	products := make(map[int64]*models.DepositProduct)
+	keyRate := 0.0
+	var keyRateErr error
+
+	for i := range deposits {
+		d := &deposits[i]
+
+		product, ok := products[d.ProductID]
+		if !ok {
+			product, err = s.repo.GetProductByID(ctx, d.ProductID)
+			if err != nil || product == nil {
+				return fmt.Errorf("failed to load product %d: %w", d.ProductID, err)
+			}
+			products[d.ProductID] = product
+		}
+
+		// Плавающая ставка пересматривается при каждом запуске. Без ключевой
+		// ставки такие вклады пропускаются: их дни догонит следующий запуск.
+		if product.RateSource == "cbr_key_rate" {
+			if keyRate == 0 && keyRateErr == nil {
+				if keyRate, keyRateErr = s.cbService.GetKeyRate(ctx); keyRateErr != nil {
+					logger.Sugared().Errorf("failed to get key rate, floating-rate deposits skipped: %v", keyRateErr)
+				}
+			}
+			if keyRateErr != nil {
+				continue
+			}
+			rate, _ := s.resolveRate(ctx, product, keyRate)
+			if rate != d.Rate {
+				if err := s.repo.UpdateRate(ctx, d.ID, rate); err != nil {
+					return err
+				}
+				d.Rate = rate
+			}
+		}
+
+		if err := s.accrueDeposit(ctx, d, product, yesterday); err != nil {
+			logger.Sugared().Errorf("deposit %d accrual failed: %v", d.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *DepositService) accrueDeposit(ctx context.Context, d *models.Deposit, product *models.DepositProduct, until time.Time) error {
+	// Проценты начисляются со дня, следующего за днём открытия
+	day := truncateToDay(d.OpenedAt).AddDate(0, 0, 1)
+	if d.LastAccrualDate != nil {
+		day = d.LastAccrualDate.AddDate(0, 0, 1)
+	}
+
+	for ; !day.After(until); day = day.AddDate(0, 0, 1) {
+		accrual := &models.DepositAccrual{
+			DepositID:   d.ID,
+			AccrualDate: day,
+			Balance:     d.Balance,
+			Rate:        d.Rate,
+			Amount:      d.Balance * d.Rate / 365 / 100,
+		}
+		inserted, err := s.repo.AddAccrual(ctx, accrual)
+		if err != nil {
+			return err
+		}
+		if inserted {
+			d.AccruedInterest += accrual.Amount
+		}
+
+		matured := d.MaturityDate != nil && !day.Before(*d.MaturityDate)
+		monthEnd := day.AddDate(0, 0, 1).Day() == 1
+		if matured || (monthEnd && (product.InterestPeriod == "monthly" || d.MaturityDate == nil)) {
+			if err := s.postInterest(ctx, d, product); err != nil {
+				return err
+			}
+		}
+
+		if matured {
+			return s.settle(ctx, d, "matured")
+		}
+	}
+	return nil
+}
+
+// postInterest переносит накопленные проценты на счёт вклада или привязанный счёт
+func (s *DepositService) postInterest(ctx context.Context, d *models.Deposit, product *models.DepositProduct) error {
+	target := d.AccountID
+	if product.InterestMode == "payout" {
+		target = d.LinkedAccountID
+	}
+
+	txn := &models.Transaction{
+		ToAccount:   target,
+		Type:        "interest",
+		Timestamp:   time.Now(),
+		Description: fmt.Sprintf("Interest on deposit %d", d.ID),
+	}
+	posted, err := s.repo.PostInterest(ctx, d.ID, txn)
+	if err != nil || !posted {
+		return err
+	}
+	s.transactionService.Posted(ctx, txn)
+
+	d.AccruedInterest -= txn.Amount
+	if target == d.AccountID {
+		d.Balance += txn.Amount
+	}
+	return nil
+}
+
+// recalculateOnDemand пересчитывает весь доход по ставке до востребования
+// и доплачивает или удерживает разницу с уже выплаченными процентами
+func (s *DepositService) recalculateOnDemand(ctx context.Context, d *models.Deposit, product *models.DepositProduct) error {
+	earned, onDemand, err := s.repo.SumAccruals(ctx, d.ID, product.OnDemandRate)
+	if err != nil {
+		return err
+	}
+	paid := earned - d.AccruedInterest
+	delta := math.Round((onDemand-paid)*100) / 100
+
+	switch {
+	case delta > 0:
+		if _, err := s.transactionService.PostCredit(ctx, d.AccountID, delta, "interest",
+			fmt.Sprintf("Interest on early closed deposit %d", d.ID)); err != nil {
+			return err
+		}
+		d.Balance += delta
+	case delta < 0:
+		claw := math.Min(-delta, d.Balance)
+		if claw > 0 {
+			if _, err := s.transactionService.PostDebit(ctx, d.AccountID, claw, "interest_adjustment",
+				fmt.Sprintf("Interest recalculation on early closure of deposit %d", d.ID)); err != nil {
+				return err
+			}
+			d.Balance -= claw
+		}
+	}
+
+	d.AccruedInterest = 0
+	return nil
+}
+
+// settle переводит остаток вклада на привязанный счёт и меняет статус
+func (s *DepositService) settle(ctx context.Context, d *models.Deposit, status string) error {
+	balance, err := s.accountRepo.GetAccountBalance(ctx, d.AccountID)
+	if err != nil {
+		return err
+	}
+	if balance > 0 {
+		if _, err := s.transactionService.MoveFunds(ctx, d.AccountID, d.LinkedAccountID, balance,
+			"deposit_transfer", fmt.Sprintf("Closing deposit %d", d.ID)); err != nil {
+			return err
+		}
+	}
+	d.Balance = 0
+	d.Status = status
+	return s.repo.SetStatus(ctx, d.ID, status)
+}
+
+func (s *DepositService) getOpenDeposit(ctx context.Context, userID, id int64) (*models.Deposit, *models.DepositProduct, error) {
+	d, err := s.GetDeposit(ctx, userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d.Status != "open" {
+		return nil, nil, ErrDepositNotOpen
+	}
+	product, err := s.repo.GetProductByID(ctx, d.ProductID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if product == nil {
+		return nil, nil, ErrProductNotFound
+	}
+	return d, product, nil
+}
+
+func (s *DepositService) checkLinkedAccount(ctx context.Context, userID, accountID int64) error {
+	owned, err := s.accountRepo.IsAccountOwnedByUser(ctx, accountID, userID)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return errors.New("unauthorized: account does not belong to user")
+	}
+	kind, err := s.accountRepo.GetAccountKind(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	if kind != "current" {
+		return errors.New("deposit must be linked to a current account")
+	}
+	return nil
+}
+
+// resolveRate возвращает ставку по продукту; для привязки к ключевой ставке BaseRate — это спред.
+// keyRate можно передать заранее, чтобы не запрашивать ЦБ для каждого вклада.
+func (s *DepositService) resolveRate(ctx context.Context, product *models.DepositProduct, keyRate float64) (float64, error) {
+	if product.RateSource != "cbr_key_rate" {
+		return product.BaseRate, nil
+	}
+	if keyRate == 0 {
+		var err error
+		if keyRate, err = s.cbService.GetKeyRate(ctx); err != nil {
+			return 0, fmt.Errorf("failed to get key rate: %w", err)
+		}
+	}
+	return math.Max(keyRate+product.BaseRate, 0), nil
+}
+
+func truncateToDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/repositories"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"errors"
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// NotificationService складывает сообщения пользователю во входящие.
+// Отправки SMS/push пока нет — доставку можно добавить здесь.
+type NotificationService struct {
+	repo *repositories.NotificationRepository
+}
+
+func NewNotificationService(repo *repositories.NotificationRepository) *NotificationService {
+	return &NotificationService{repo: repo}
+}
+
+// Notify сохраняет уведомление. Ошибка только логируется: уведомление не
+// должно срывать операцию, о которой оно сообщает.
+func (s *NotificationService) Notify(ctx context.Context, userID int64, kind, message string) {
+	n := &models.Notification{UserID: userID, Kind: kind, Message: message}
+	if err := s.repo.CreateNotification(ctx, n); err != nil {
+		logger.Sugared().Errorf("notify user %d (%s): %v", userID, kind, err)
+		return
+	}
+	logger.Sugared().Infof("notification %d for user %d: %s", n.ID, userID, message)
+}
+
+func (s *NotificationService) ListNotifications(ctx context.Context, userID int64, unreadOnly bool) ([]models.Notification, error) {
+	return s.repo.ListNotificationsByUser(ctx, userID, unreadOnly, 100)
+}
+
+func (s *NotificationService) MarkRead(ctx context.Context, userID, id int64) error {
+	ok, err := s.repo.MarkRead(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/repositories"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrOverdraftNotFound = errors.New("overdraft not found")
+
+type OverdraftService struct {
+	repo               *repositories.OverdraftRepository
+	accountRepo        *repositories.AccountRepository
+	transactionService *TransactionService
+}
+
+func NewOverdraftService(
+	repo *repositories.OverdraftRepository,
+	accountRepo *repositories.AccountRepository,
+	transactionService *TransactionService,
+) *OverdraftService {
+	return &OverdraftService{
+		repo:               repo,
+		accountRepo:        accountRepo,
+		transactionService: transactionService,
+	}
+}
+
+// Grant выдаёт овердрафт на текущий счёт или меняет лимит и ставку
+func (s *OverdraftService) Grant(ctx context.Context, adminID, accountID int64, limit, rate float64) (*models.Overdraft, error) {
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+	if rate < 0 {
+		return nil, errors.New("interest rate must not be negative")
+	}
+
+	kind, err := s.accountRepo.GetAccountKind(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if kind != "current" {
+		return nil, errors.New("overdraft is available only for current accounts")
+	}
+
+	o := &models.Overdraft{
+		AccountID:    accountID,
+		LimitAmount:  limit,
+		InterestRate: rate,
+		GrantedBy:    &adminID,
+	}
+	if err := s.repo.Upsert(ctx, o); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByAccountID(ctx, accountID)
+}
+
+// Revoke отзывает лимит: новые списания в минус запрещены, текущий долг гасится поступлениями
+func (s *OverdraftService) Revoke(ctx context.Context, accountID int64) error {
+	return s.repo.Revoke(ctx, accountID)
+}
+
+func (s *OverdraftService) GetByAccount(ctx context.Context, accountID int64) (*models.Overdraft, error) {
+	o, err := s.repo.GetByAccountID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		return nil, ErrOverdraftNotFound
+	}
+	return o, nil
+}
+
+// AccrueDaily — ежедневная задача начисления процентов на отрицательный остаток
+func (s *OverdraftService) AccrueDaily(ctx context.Context) error {
+	count, err := s.repo.AccrueDaily(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Sugared().Infof("overdraft interest accrued for %d accounts", count)
+	return nil
+}
+
+// ChargeMonthly списывает накопленные проценты раз в месяц. Запускается
+// ежедневно и списывает по овердрафтам, у которых с прошлого списания сменился
+// месяц, поэтому пропущенный запуск догоняется на следующий день.
+func (s *OverdraftService) ChargeMonthly(ctx context.Context) error {
+	list, err := s.repo.ListDueForCharge(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range list {
+		if err := s.charge(ctx, o.AccountID, "Monthly overdraft interest", true); err != nil {
+			logger.Sugared().Errorf("overdraft interest charge for account %d failed: %v", o.AccountID, err)
+		}
+	}
+	return nil
+}
+
+// OnTransaction — хук TransactionService. Отрицательный остаток гасится поступлениями
+// сам по себе, т.к. они зачисляются на тот же счёт. Если поступление полностью закрыло
+// долг, хук сразу списывает и начисленные проценты, не дожидаясь конца месяца.
+func (s *OverdraftService) OnTransaction(ctx context.Context, txn *models.Transaction) {
+	if txn.ToAccount == 0 || txn.Type == "overdraft_interest" {
+		return
+	}
+
+	o, err := s.repo.GetByAccountID(ctx, txn.ToAccount)
+	if err != nil {
+		logger.Sugared().Errorf("failed to load overdraft for account %d: %v", txn.ToAccount, err)
+		return
+	}
+	if o == nil || o.AccruedInterest < 0.01 || o.Balance < o.AccruedInterest {
+		return
+	}
+
+	if err := s.charge(ctx, o.AccountID, "Overdraft interest on repayment", false); err != nil {
+		logger.Sugared().Errorf("overdraft repayment for account %d failed: %v", o.AccountID, err)
+	}
+}
+
+// charge списывает все накопленные проценты вместе с уменьшением долга по
+// процентам; periodEnd — списание за расчётный период
+func (s *OverdraftService) charge(ctx context.Context, accountID int64, description string, periodEnd bool) error {
+	txn := &models.Transaction{
+		FromAccount: accountID,
+		Type:        "overdraft_interest",
+		Timestamp:   time.Now(),
+		Description: fmt.Sprintf("%s for account %d", description, accountID),
+	}
+	_, err := s.repo.ChargeInterest(ctx, txn, periodEnd)
+	return err
+}
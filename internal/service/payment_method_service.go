@@ -0,0 +1,302 @@
+package service
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/payment/providers"
+	"bank-api/internal/repositories"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPaymentMethodNotFound   = repositories.ErrPaymentMethodNotFound
+	ErrInvalidPaymentMethod    = errors.New("invalid payment method")
+	ErrPaymentMethodExpired    = errors.New("payment method has expired")
+	ErrTokenizationUnsupported = errors.New("provider does not tokenize cards: pass a token")
+)
+
+// за сколько до окончания срока предупреждать пользователя
+const paymentMethodExpiryNotice = 30 * 24 * time.Hour
+
+var last4Re = regexp.MustCompile(`^\d{4}$`)
+
+// PaymentMethodHook вызывается после удаления способа оплаты
+type PaymentMethodHook func(ctx context.Context, method *models.PaymentMethod)
+
+// PaymentMethodInput — добавление способа оплаты. Передаётся либо токен
+// провайдера, либо реквизиты карты, которые токенизирует провайдер. Бренд,
+// last4 и срок нужны только для провайдеров, которые не сообщают их сами.
+type PaymentMethodInput struct {
+	Provider    string                 `json:"provider"`
+	Type        string                 `json:"type"`
+	Token       string                 `json:"token"`
+	Card        *providers.CardDetails `json:"card"`
+	Brand       string                 `json:"brand"`
+	Last4       string                 `json:"last4"`
+	ExpMonth    int                    `json:"exp_month"`
+	ExpYear     int                    `json:"exp_year"`
+	Description string                 `json:"description"`
+	MakeDefault bool                   `json:"make_default"`
+}
+
+// PaymentMethodService — хранилище способов оплаты: токенизация у
+// провайдера, отсев дублей по отпечатку, основной способ, слежение за сроком
+// действия и мягкое удаление
+type PaymentMethodService struct {
+	repo          *repositories.PaymentMethodRepository
+	registry      *providers.Registry
+	notifications *NotificationService
+	hooks         []PaymentMethodHook
+}
+
+func NewPaymentMethodService(
+	repo *repositories.PaymentMethodRepository,
+	registry *providers.Registry,
+	notifications *NotificationService,
+) *PaymentMethodService {
+	return &PaymentMethodService{repo: repo, registry: registry, notifications: notifications}
+}
+
+// AddDeleteHook регистрирует обработчик удаления способа оплаты
+func (s *PaymentMethodService) AddDeleteHook(hook PaymentMethodHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// AddPaymentMethod сохраняет способ оплаты. Если у пользователя уже есть
+// способ с тем же отпечатком, обновляет его сведения и возвращает его с
+// created = false.
+func (s *PaymentMethodService) AddPaymentMethod(ctx context.Context, userID int64, in PaymentMethodInput) (method *models.PaymentMethod, created bool, err error) {
+	in.Provider = strings.ToLower(strings.TrimSpace(in.Provider))
+	if in.Provider == "" {
+		return nil, false, fmt.Errorf("%w: provider is required", ErrInvalidPaymentMethod)
+	}
+	provider, err := s.registry.Get(in.Provider)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: unknown provider %s", ErrInvalidPaymentMethod, in.Provider)
+	}
+	if (in.Card == nil) == (in.Token == "") {
+		return nil, false, fmt.Errorf("%w: pass either token or card", ErrInvalidPaymentMethod)
+	}
+
+	details, err := s.describe(ctx, provider, in)
+	if errors.Is(err, providers.ErrMethodRejected) {
+		return nil, false, fmt.Errorf("%w: %v", ErrInvalidPaymentMethod, err)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	method = &models.PaymentMethod{
+		UserID:      userID,
+		Type:        firstNonEmpty(details.Type, in.Type, "card"),
+		Provider:    in.Provider,
+		Token:       details.Token,
+		Brand:       strings.ToLower(details.Brand),
+		Last4:       details.Last4,
+		ExpMonth:    details.ExpMonth,
+		ExpYear:     details.ExpYear,
+		Fingerprint: fingerprint(in.Provider, details),
+		IsDefault:   in.MakeDefault,
+		Description: in.Description,
+	}
+	if err := validateMethod(method); err != nil {
+		return nil, false, err
+	}
+	if method.IsExpired(time.Now()) {
+		return nil, false, ErrPaymentMethodExpired
+	}
+
+	created, err = s.repo.Create(ctx, method)
+	if err != nil {
+		return nil, false, err
+	}
+	if !created {
+		existing, err := s.repo.GetByFingerprint(ctx, userID, method.Fingerprint)
+		if err != nil {
+			return nil, false, err
+		}
+		existing.Brand, existing.Last4 = method.Brand, method.Last4
+		existing.ExpMonth, existing.ExpYear = method.ExpMonth, method.ExpYear
+		existing.Description = method.Description
+		if err := s.repo.UpdateDetails(ctx, existing); err != nil {
+			return nil, false, err
+		}
+		if in.MakeDefault && !existing.IsDefault {
+			if _, err := s.repo.SetDefault(ctx, userID, existing.ID); err != nil {
+				return nil, false, err
+			}
+		}
+		method, err = s.repo.GetByID(ctx, existing.ID)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	method.Expired = method.IsExpired(time.Now())
+	return method, created, nil
+}
+
+// describe токенизирует карту или получает сведения о токене у провайдера.
+// Если провайдер этого не умеет, сведения берутся из запроса.
+func (s *PaymentMethodService) describe(ctx context.Context, provider providers.PaymentProvider, in PaymentMethodInput) (*providers.MethodDetails, error) {
+	tokenizer, ok := provider.(providers.Tokenizer)
+	switch {
+	case in.Card != nil && !ok:
+		return nil, ErrTokenizationUnsupported
+	case in.Card != nil:
+		return tokenizer.Tokenize(ctx, *in.Card)
+	case ok:
+		return tokenizer.DescribeMethod(ctx, in.Token)
+	}
+	return &providers.MethodDetails{
+		Token:    in.Token,
+		Type:     in.Type,
+		Brand:    in.Brand,
+		Last4:    in.Last4,
+		ExpMonth: in.ExpMonth,
+		ExpYear:  in.ExpYear,
+	}, nil
+}
+
+// GetPaymentMethods возвращает неудалённые способы пользователя, основной — первым
+func (s *PaymentMethodService) GetPaymentMethods(ctx context.Context, userID int64) ([]models.PaymentMethod, error) {
+	methods, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range methods {
+		methods[i].Expired = methods[i].IsExpired(now)
+	}
+	return methods, nil
+}
+
+// GetPaymentMethod возвращает неудалённый способ оплаты пользователя
+func (s *PaymentMethodService) GetPaymentMethod(ctx context.Context, userID, id int64) (*models.PaymentMethod, error) {
+	method, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if method.UserID != userID || method.DeletedAt != nil {
+		return nil, ErrPaymentMethodNotFound
+	}
+	method.Expired = method.IsExpired(time.Now())
+	return method, nil
+}
+
+// SetDefault делает способ основным
+func (s *PaymentMethodService) SetDefault(ctx context.Context, userID, id int64) (*models.PaymentMethod, error) {
+	method, err := s.GetPaymentMethod(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if method.Expired {
+		return nil, ErrPaymentMethodExpired
+	}
+	ok, err := s.repo.SetDefault(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrPaymentMethodNotFound
+	}
+	return s.GetPaymentMethod(ctx, userID, id)
+}
+
+// DeletePaymentMethod мягко удаляет способ оплаты и сообщает об этом подписчикам
+func (s *PaymentMethodService) DeletePaymentMethod(ctx context.Context, userID, id int64) error {
+	ok, err := s.repo.SoftDelete(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPaymentMethodNotFound
+	}
+	method, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, hook := range s.hooks {
+		hook(ctx, method)
+	}
+	return nil
+}
+
+// ProcessExpiry предупреждает об окончании срока способов оплаты и снимает
+// признак основного с истёкших. Запускается планировщиком раз в день.
+func (s *PaymentMethodService) ProcessExpiry(ctx context.Context) error {
+	now := time.Now()
+	expiring, err := s.repo.ListExpiring(ctx, now.Add(paymentMethodExpiryNotice), 500)
+	if err != nil {
+		return err
+	}
+	for i := range expiring {
+		method := &expiring[i]
+		kind, message := "payment_method_expiring", fmt.Sprintf("Срок действия %s истекает %02d/%d. Добавьте новый способ оплаты",
+			methodLabel(method), method.ExpMonth, method.ExpYear)
+		if method.IsExpired(now) {
+			kind, message = "payment_method_expired", fmt.Sprintf("Срок действия %s истёк", methodLabel(method))
+		}
+		s.notifications.Notify(ctx, method.UserID, kind, message)
+		if err := s.repo.MarkExpiryNotified(ctx, method.ID); err != nil {
+			logger.Sugared().Errorf("payment method %d: failed to mark expiry notice: %v", method.ID, err)
+		}
+	}
+
+	reassigned, err := s.repo.ReassignExpiredDefaults(ctx)
+	if err != nil {
+		return err
+	}
+	if len(expiring) > 0 || reassigned > 0 {
+		logger.Sugared().Infof("payment method expiry: %d notices sent, %d default methods reassigned", len(expiring), reassigned)
+	}
+	return nil
+}
+
+// fingerprint — отпечаток провайдера, если он его сообщает, иначе хеш токена
+func fingerprint(provider string, details *providers.MethodDetails) string {
+	if details.Fingerprint != "" {
+		return provider + ":" + details.Fingerprint
+	}
+	sum := sha256.Sum256([]byte(provider + ":" + details.Token))
+	return hex.EncodeToString(sum[:])
+}
+
+func validateMethod(method *models.PaymentMethod) error {
+	if method.Token == "" {
+		return fmt.Errorf("%w: token is required", ErrInvalidPaymentMethod)
+	}
+	if method.Last4 != "" && !last4Re.MatchString(method.Last4) {
+		return fmt.Errorf("%w: last4 must be 4 digits", ErrInvalidPaymentMethod)
+	}
+	if (method.ExpMonth == 0) != (method.ExpYear == 0) {
+		return fmt.Errorf("%w: exp_month and exp_year go together", ErrInvalidPaymentMethod)
+	}
+	if method.ExpMonth != 0 && (method.ExpMonth < 1 || method.ExpMonth > 12 || method.ExpYear < 2000 || method.ExpYear > 2100) {
+		return fmt.Errorf("%w: invalid expiry date", ErrInvalidPaymentMethod)
+	}
+	return nil
+}
+
+// methodLabel — как назвать способ оплаты в уведомлении
+func methodLabel(method *models.PaymentMethod) string {
+	if method.Last4 != "" {
+		return fmt.Sprintf("карты %s •••• %s", method.Brand, method.Last4)
+	}
+	return fmt.Sprintf("способа оплаты %d", method.ID)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
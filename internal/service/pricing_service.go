@@ -0,0 +1,234 @@
+package service
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownFeeOperation = errors.New("unknown operation")
+	ErrUnknownTariff       = errors.New("unknown tariff")
+	ErrUserNotFound        = errors.New("user not found")
+)
+
+var feeOperations = map[string]bool{
+	models.FeeOperationTransfer:   true,
+	models.FeeOperationWithdrawal: true,
+	models.FeeOperationPayment:    true,
+	models.FeeOperationPayout:     true,
+}
+
+// FeeRule — правило комиссии: процент и фиксированная часть с ограничением
+// снизу и сверху. Пустые списки подходят к любому значению. Бесплатная квота
+// считается за календарный месяц: сначала бесплатными идут FreeOperations
+// операций, затем комиссия не берётся с первых FreeAmount суммы.
+type FeeRule struct {
+	Name           string
+	Operation      string
+	Channels       []string
+	Currencies     []string
+	Tariffs        []string
+	MinAmount      float64
+	MaxAmount      float64 // 0 — без верхней границы
+	Percent        float64
+	Fixed          float64
+	MinFee         float64
+	MaxFee         float64 // 0 — без ограничения
+	FreeOperations int
+	FreeAmount     float64
+}
+
+func (r FeeRule) match(req models.FeeRequest, tariff string) bool {
+	return r.Operation == req.Operation && matchesAny(r.Channels, req.Channel) &&
+		matchesAny(r.Currencies, req.Currency) && matchesAny(r.Tariffs, tariff) &&
+		req.Amount >= r.MinAmount && (r.MaxAmount == 0 || req.Amount <= r.MaxAmount)
+}
+
+func matchesAny(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(a, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// PricingService рассчитывает комиссии по правилам. Правила проверяются по
+// порядку, применяется первое подошедшее; если не подошло ни одно, операция
+// бесплатна.
+type PricingService struct {
+	repo     *repositories.PricingRepository
+	userRepo *repositories.UserRepository
+	rules    []FeeRule
+	tariffs  map[string]bool
+
+	mu              sync.Mutex
+	incomeAccountID int64
+}
+
+func NewPricingService(repo *repositories.PricingRepository, userRepo *repositories.UserRepository, tariffs []string, rules []FeeRule) (*PricingService, error) {
+	s := &PricingService{
+		repo:     repo,
+		userRepo: userRepo,
+		rules:    rules,
+		tariffs:  map[string]bool{models.TariffBasic: true},
+	}
+	for _, tariff := range tariffs {
+		s.tariffs[tariff] = true
+	}
+
+	names := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		switch {
+		case rule.Name == "":
+			return nil, fmt.Errorf("fee rule %d: name is required", i+1)
+		case names[rule.Name]:
+			return nil, fmt.Errorf("fee rule %q: duplicate name", rule.Name)
+		case !feeOperations[rule.Operation]:
+			return nil, fmt.Errorf("fee rule %q: %w %q", rule.Name, ErrUnknownFeeOperation, rule.Operation)
+		case rule.Percent < 0 || rule.Fixed < 0 || rule.MinFee < 0 || rule.MaxFee < 0:
+			return nil, fmt.Errorf("fee rule %q: fees must not be negative", rule.Name)
+		case rule.MaxFee > 0 && rule.MaxFee < rule.MinFee:
+			return nil, fmt.Errorf("fee rule %q: max_fee is less than min_fee", rule.Name)
+		case rule.FreeOperations < 0 || rule.FreeAmount < 0:
+			return nil, fmt.Errorf("fee rule %q: free quota must not be negative", rule.Name)
+		}
+		for _, tariff := range rule.Tariffs {
+			if !s.tariffs[tariff] {
+				return nil, fmt.Errorf("fee rule %q: %w %q", rule.Name, ErrUnknownTariff, tariff)
+			}
+		}
+		names[rule.Name] = true
+	}
+	return s, nil
+}
+
+// Quote рассчитывает комиссию за операцию с учётом тарифа клиента и
+// остатка бесплатной квоты. Квота расходуется только при проведении операции.
+func (s *PricingService) Quote(ctx context.Context, req models.FeeRequest) (*models.FeeQuote, error) {
+	if !feeOperations[req.Operation] {
+		return nil, fmt.Errorf("%w %q", ErrUnknownFeeOperation, req.Operation)
+	}
+	req.Amount = math.Round(req.Amount*100) / 100
+	if req.Amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	req.Currency = strings.ToUpper(req.Currency)
+	if req.Currency == "" {
+		req.Currency = "RUB"
+	}
+
+	tariff, err := s.userRepo.GetTariff(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if tariff == "" {
+		return nil, ErrUserNotFound
+	}
+
+	quote := &models.FeeQuote{FeeRequest: req, Tariff: tariff}
+	var rule *FeeRule
+	for i := range s.rules {
+		if s.rules[i].match(req, tariff) {
+			rule = &s.rules[i]
+			break
+		}
+	}
+	if rule == nil {
+		return quote, nil
+	}
+	quote.Rule = rule.Name
+
+	base := req.Amount
+	if rule.FreeOperations > 0 || rule.FreeAmount > 0 {
+		claim := &models.FeeQuotaClaim{
+			UserID:         req.UserID,
+			Rule:           rule.Name,
+			Period:         time.Now().Format("2006-01"),
+			FreeOperations: rule.FreeOperations,
+			FreeAmount:     rule.FreeAmount,
+		}
+		usedOperations, usedAmount, err := s.repo.QuotaUsage(ctx, req.UserID, claim.Rule, claim.Period)
+		if err != nil {
+			return nil, err
+		}
+
+		if rule.FreeOperations > 0 {
+			left := max(rule.FreeOperations-usedOperations, 0)
+			quote.FreeOperationsLeft = &left
+			if left > 0 {
+				claim.Operations = 1
+				quote.Quota = claim
+				return quote, nil
+			}
+		}
+		if rule.FreeAmount > 0 {
+			left := math.Max(math.Round((rule.FreeAmount-usedAmount)*100)/100, 0)
+			quote.FreeAmountLeft = &left
+			if covered := math.Min(left, base); covered > 0 {
+				claim.Amount = covered
+				quote.Quota = claim
+				base = math.Round((base-covered)*100) / 100
+			}
+		}
+	}
+	if base > 0 {
+		quote.Fee = rule.fee(base)
+	}
+	return quote, nil
+}
+
+// fee — комиссия с суммы по правилу, округлённая до копеек
+func (r FeeRule) fee(amount float64) float64 {
+	fee := amount*r.Percent/100 + r.Fixed
+	fee = math.Max(fee, r.MinFee)
+	if r.MaxFee > 0 {
+		fee = math.Min(fee, r.MaxFee)
+	}
+	return math.Round(fee*100) / 100
+}
+
+// IncomeAccountID — счёт доходов банка, на который зачисляются комиссии
+func (s *PricingService) IncomeAccountID(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.incomeAccountID == 0 {
+		id, err := s.repo.IncomeAccountID(ctx)
+		if err != nil {
+			return 0, err
+		}
+		s.incomeAccountID = id
+	}
+	return s.incomeAccountID, nil
+}
+
+// ReleaseQuota возвращает бесплатную квоту операции, которая не состоялась
+// (например, выплаты, отклонённой провайдером)
+func (s *PricingService) ReleaseQuota(ctx context.Context, transactionID int64) error {
+	return s.repo.ReleaseQuota(ctx, transactionID)
+}
+
+// SetTariff переводит клиента на тарифный план
+func (s *PricingService) SetTariff(ctx context.Context, userID int64, tariff string) error {
+	if !s.tariffs[tariff] {
+		return fmt.Errorf("%w %q", ErrUnknownTariff, tariff)
+	}
+	found, err := s.userRepo.SetTariff(ctx, userID, tariff)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrUserNotFound
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/repositories"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCampaignNotFound  = errors.New("reward campaign not found")
+	ErrOptInLimitReached = errors.New("opt-in category limit reached for this month")
+)
+
+// типы транзакций, за которые кэшбэк не начисляется никогда
+var nonRewardableTypes = map[string]bool{
+	"cashback":            true,
+	"atm_withdrawal":      true,
+	"pot_transfer":        true,
+	"deposit_transfer":    true,
+	"credit_payment":      true,
+	"interest":            true,
+	"interest_adjustment": true,
+	"overdraft_interest":  true,
+	"reversal":            true,
+}
+
+type RewardService struct {
+	repo               *repositories.RewardRepository
+	accountRepo        *repositories.AccountRepository
+	transactionService *TransactionService
+	maxOptIns          int
+}
+
+func NewRewardService(
+	repo *repositories.RewardRepository,
+	accountRepo *repositories.AccountRepository,
+	transactionService *TransactionService,
+	maxOptIns int,
+) *RewardService {
+	return &RewardService{
+		repo:               repo,
+		accountRepo:        accountRepo,
+		transactionService: transactionService,
+		maxOptIns:          maxOptIns,
+	}
+}
+
+// rewardPeriod — первый день месяца, к которому относится дата
+func rewardPeriod(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (s *RewardService) CreateCampaign(ctx context.Context, c *models.RewardCampaign) error {
+	if c.Name == "" || c.Category == "" {
+		return errors.New("name and category are required")
+	}
+	if c.Percent <= 0 || c.Percent > 100 {
+		return errors.New("percent must be between 0 and 100")
+	}
+	if c.MonthlyCap < 0 {
+		return errors.New("monthly cap must not be negative")
+	}
+	if c.MCCCodes == "" && c.Merchant == "" && c.TransactionType == "" {
+		return errors.New("campaign must match by MCC, merchant or transaction type")
+	}
+	if c.StartsAt.IsZero() {
+		c.StartsAt = time.Now()
+	}
+	if c.EndsAt != nil && !c.EndsAt.After(c.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+
+	// нормализуем список MCC: "5812, 5814" -> "5812,5814"
+	var codes []string
+	for _, code := range strings.Split(c.MCCCodes, ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	c.MCCCodes = strings.Join(codes, ",")
+
+	return s.repo.CreateCampaign(ctx, c)
+}
+
+func (s *RewardService) DeactivateCampaign(ctx context.Context, id int64) error {
+	return s.repo.DeactivateCampaign(ctx, id)
+}
+
+// ListCampaigns возвращает действующие кампании с отметкой, подключена ли категория в текущем месяце
+func (s *RewardService) ListCampaigns(ctx context.Context, userID int64) ([]models.RewardCampaign, error) {
+	return s.repo.ListActiveCampaigns(ctx, userID, rewardPeriod(time.Now()))
+}
+
+// OptIn подключает категорию на текущий месяц
+func (s *RewardService) OptIn(ctx context.Context, userID, campaignID int64) error {
+	period := rewardPeriod(time.Now())
+
+	campaign, err := s.findActiveCampaign(ctx, userID, campaignID, period)
+	if err != nil {
+		return err
+	}
+	if !campaign.RequiresOptIn {
+		return errors.New("campaign does not require opt-in")
+	}
+	if campaign.OptedIn {
+		return nil
+	}
+
+	if s.maxOptIns > 0 {
+		count, err := s.repo.CountOptIns(ctx, userID, period)
+		if err != nil {
+			return err
+		}
+		if count >= s.maxOptIns {
+			return ErrOptInLimitReached
+		}
+	}
+
+	return s.repo.OptIn(ctx, userID, campaignID, period)
+}
+
+// OptOut отключает категорию; уже начисленный кэшбэк сохраняется
+func (s *RewardService) OptOut(ctx context.Context, userID, campaignID int64) error {
+	period := rewardPeriod(time.Now())
+	if _, err := s.findActiveCampaign(ctx, userID, campaignID, period); err != nil {
+		return err
+	}
+	return s.repo.OptOut(ctx, userID, campaignID, period)
+}
+
+func (s *RewardService) findActiveCampaign(ctx context.Context, userID, campaignID int64, period time.Time) (*models.RewardCampaign, error) {
+	campaigns, err := s.repo.ListActiveCampaigns(ctx, userID, period)
+	if err != nil {
+		return nil, err
+	}
+	for i := range campaigns {
+		if campaigns[i].ID == campaignID {
+			return &campaigns[i], nil
+		}
+	}
+	return nil, ErrCampaignNotFound
+}
+
+// Summary возвращает кэшбэк по категориям за месяц, в который попадает month
+func (s *RewardService) Summary(ctx context.Context, userID int64, month time.Time) ([]models.CashbackSummary, error) {
+	list, err := s.repo.Summary(ctx, userID, rewardPeriod(month))
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []models.CashbackSummary{}
+	}
+	return list, nil
+}
+
+// OnTransaction — хук TransactionService. Начисляет кэшбэк в статусе pending
+// за списания с текущего счёта, подходящие под действующие кампании.
+func (s *RewardService) OnTransaction(ctx context.Context, txn *models.Transaction) {
+	if txn.FromAccount == 0 || nonRewardableTypes[txn.Type] {
+		return
+	}
+
+	kind, err := s.accountRepo.GetAccountKind(ctx, txn.FromAccount)
+	if err != nil || kind != "current" {
+		return
+	}
+
+	userID, err := s.accountRepo.GetAccountOwner(ctx, txn.FromAccount)
+	if err != nil {
+		logger.Sugared().Errorf("cashback: failed to load owner of account %d: %v", txn.FromAccount, err)
+		return
+	}
+
+	period := rewardPeriod(txn.Timestamp)
+	campaigns, err := s.repo.ListActiveCampaigns(ctx, userID, period)
+	if err != nil {
+		logger.Sugared().Errorf("cashback: failed to load campaigns: %v", err)
+		return
+	}
+
+	for _, c := range campaigns {
+		if !campaignMatches(c, txn) || (c.RequiresOptIn && !c.OptedIn) {
+			continue
+		}
+
+		amount := txn.Amount * c.Percent / 100
+		if c.MonthlyCap > 0 {
+			accrued, err := s.repo.SumAccrued(ctx, userID, c.ID, period)
+			if err != nil {
+				logger.Sugared().Errorf("cashback: failed to sum accruals for campaign %d: %v", c.ID, err)
+				continue
+			}
+			amount = math.Min(amount, c.MonthlyCap-accrued)
+		}
+		amount = math.Round(amount*100) / 100
+		if amount < 0.01 {
+			continue
+		}
+
+		accrual := &models.CashbackAccrual{
+			UserID:        userID,
+			AccountID:     txn.FromAccount,
+			CampaignID:    c.ID,
+			TransactionID: txn.ID,
+			Category:      c.Category,
+			Amount:        amount,
+			Period:        period,
+		}
+		if err := s.repo.CreateAccrual(ctx, accrual); err != nil {
+			logger.Sugared().Errorf("cashback: failed to accrue for transaction %d: %v", txn.ID, err)
+		}
+	}
+}
+
+// campaignMatches проверяет все заданные в кампании условия
+func campaignMatches(c models.RewardCampaign, txn *models.Transaction) bool {
+	if c.TransactionType != "" && c.TransactionType != txn.Type {
+		return false
+	}
+	if c.Merchant != "" && !strings.EqualFold(c.Merchant, txn.Merchant) {
+		return false
+	}
+	if c.MCCCodes != "" {
+		if txn.MCC == "" {
+			return false
+		}
+		for _, code := range strings.Split(c.MCCCodes, ",") {
+			if code == txn.MCC {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// SettleMonthly выплачивает накопленный за прошлые месяцы кэшбэк одной
+// транзакцией на счёт. Запускается ежедневно: всё, что не выплачено за месяцы
+// до текущего (в том числе из-за пропущенных запусков), выплачивается сразу.
+func (s *RewardService) SettleMonthly(ctx context.Context) error {
+	before := rewardPeriod(time.Now())
+	totals, err := s.repo.ListPendingTotals(ctx, before)
+	if err != nil {
+		return err
+	}
+
+	paid := 0
+	for _, t := range totals {
+		txn := &models.Transaction{
+			ToAccount:   t.AccountID,
+			Type:        "cashback",
+			Timestamp:   time.Now(),
+			Description: fmt.Sprintf("Cashback through %s", before.AddDate(0, -1, 0).Format("January 2006")),
+		}
+		posted, err := s.repo.PayPending(ctx, t.AccountID, before, txn)
+		if err != nil {
+			logger.Sugared().Errorf("cashback payout for account %d failed: %v", t.AccountID, err)
+			continue
+		}
+		if posted {
+			s.transactionService.Posted(ctx, txn)
+			paid++
+		}
+	}
+
+	if paid > 0 {
+		logger.Sugared().Infof("cashback settled for %d accounts", paid)
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/repositories"
+	"bank-api/pkg/utils/logger"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrGoalNotFound = errors.New("savings goal not found")
+	ErrGoalClosed   = errors.New("savings goal is closed")
+)
+
+type SavingsGoalService struct {
+	repo               *repositories.SavingsGoalRepository
+	accountRepo        *repositories.AccountRepository
+	transactionService *TransactionService
+}
+
+func NewSavingsGoalService(
+	repo *repositories.SavingsGoalRepository,
+	accountRepo *repositories.AccountRepository,
+	transactionService *TransactionService,
+) *SavingsGoalService {
+	return &SavingsGoalService{
+		repo:               repo,
+		accountRepo:        accountRepo,
+		transactionService: transactionService,
+	}
+}
+
+// CreateGoal создаёт цель и отдельный счёт-копилку под неё
+func (s *SavingsGoalService) CreateGoal(ctx context.Context, g *models.SavingsGoal) error {
+	if g.UserID == 0 || g.Name == "" || g.SourceAccountID == 0 || g.TargetAmount <= 0 {
+		return errors.New("missing required goal fields")
+	}
+
+	owned, err := s.accountRepo.IsAccountOwnedByUser(ctx, g.SourceAccountID, g.UserID)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return errors.New("unauthorized: account does not belong to user")
+	}
+
+	account, err := s.accountRepo.CreateAccountOfKind(ctx, g.UserID, "pot")
+	if err != nil {
+		return fmt.Errorf("failed to create pot account: %w", err)
+	}
+
+	g.AccountID = account.ID
+	g.Status = "active"
+	return s.repo.CreateGoal(ctx, g)
+}
+
+func (s *SavingsGoalService) GetGoal(ctx context.Context, userID, id int64) (*models.SavingsGoal, error) {
+	g, err := s.repo.GetGoalByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if g == nil || g.UserID != userID {
+		return nil, ErrGoalNotFound
+	}
+	return g, nil
+}
+
+// GetProgress возвращает цель вместе с прогрессом накопления
+func (s *SavingsGoalService) GetProgress(ctx context.Context, userID, id int64) (*models.SavingsGoalProgress, error) {
+	g, err := s.GetGoal(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return goalProgress(*g), nil
+}
+
+func (s *SavingsGoalService) ListGoals(ctx context.Context, userID int64) ([]*models.SavingsGoalProgress, error) {
+	goals, err := s.repo.ListGoalsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*models.SavingsGoalProgress, 0, len(goals))
+	for _, g := range goals {
+		result = append(result, goalProgress(g))
+	}
+	return result, nil
+}
+
+// MoveIn переводит деньги с основного счёта в копилку
+func (s *SavingsGoalService) MoveIn(ctx context.Context, userID, id int64, amount float64) error {
+	g, err := s.getActiveGoal(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.transactionService.MoveFunds(ctx, g.SourceAccountID, g.AccountID, amount,
+		"pot_transfer", fmt.Sprintf("Top-up of savings goal %q", g.Name))
+	return err
+}
+
+// MoveOut возвращает деньги из копилки на основной счёт
+func (s *SavingsGoalService) MoveOut(ctx context.Context, userID, id int64, amount float64) error {
+	g, err := s.getActiveGoal(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.transactionService.MoveFunds(ctx, g.AccountID, g.SourceAccountID, amount,
+		"pot_transfer", fmt.Sprintf("Withdrawal from savings goal %q", g.Name))
+	return err
+}
+
+// CloseGoal возвращает накопленное на основной счёт и закрывает цель
+func (s *SavingsGoalService) CloseGoal(ctx context.Context, userID, id int64) error {
+	g, err := s.getActiveGoal(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if g.Balance > 0 {
+		if _, err := s.transactionService.MoveFunds(ctx, g.AccountID, g.SourceAccountID, g.Balance,
+			"pot_transfer", fmt.Sprintf("Closing savings goal %q", g.Name)); err != nil {
+			return err
+		}
+	}
+	return s.repo.CloseGoal(ctx, g.ID)
+}
+
+func (s *SavingsGoalService) AddRule(ctx context.Context, userID int64, rule *models.SavingsRule) error {
+	if _, err := s.getActiveGoal(ctx, userID, rule.GoalID); err != nil {
+		return err
+	}
+
+	switch rule.Type {
+	case "round_up":
+		if rule.RoundTo != 10 && rule.RoundTo != 50 && rule.RoundTo != 100 {
+			return errors.New("round_to must be 10, 50 or 100")
+		}
+	case "income_percent":
+		if rule.Percent <= 0 || rule.Percent > 100 {
+			return errors.New("percent must be between 0 and 100")
+		}
+	case "weekly_sweep":
+		if rule.Amount <= 0 {
+			return errors.New("amount must be positive")
+		}
+		if rule.Weekday < 0 || rule.Weekday > 6 {
+			return errors.New("weekday must be between 0 (Sunday) and 6")
+		}
+	default:
+		return errors.New("unknown rule type")
+	}
+
+	rule.IsActive = true
+	return s.repo.CreateRule(ctx, rule)
+}
+
+func (s *SavingsGoalService) ListRules(ctx context.Context, userID, goalID int64) ([]models.SavingsRule, error) {
+	if _, err := s.GetGoal(ctx, userID, goalID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListRulesByGoal(ctx, goalID)
+}
+
+func (s *SavingsGoalService) DeleteRule(ctx context.Context, userID, goalID, ruleID int64) error {
+	if _, err := s.GetGoal(ctx, userID, goalID); err != nil {
+		return err
+	}
+	return s.repo.DeleteRule(ctx, goalID, ruleID)
+}
+
+// OnTransaction — хук TransactionService: округление покупок по карте
+// и процент от входящих пополнений уходят в копилки
+func (s *SavingsGoalService) OnTransaction(ctx context.Context, txn *models.Transaction) {
+	var (
+		accountID int64
+		ruleType  string
+	)
+	switch txn.Type {
+	case "card_purchase":
+		accountID, ruleType = txn.FromAccount, "round_up"
+	case "deposit":
+		accountID, ruleType = txn.ToAccount, "income_percent"
+	default:
+		return
+	}
+	if accountID == 0 {
+		return
+	}
+
+	rules, err := s.repo.ListActiveRulesForAccount(ctx, accountID, ruleType)
+	if err != nil {
+		logger.Sugared().Errorf("failed to load savings rules for account %d: %v", accountID, err)
+		return
+	}
+
+	amount := math.Abs(txn.Amount)
+	for _, rule := range rules {
+		var save float64
+		switch rule.Type {
+		case "round_up":
+			step := float64(rule.RoundTo)
+			save = math.Ceil(amount/step)*step - amount
+		case "income_percent":
+			save = amount * rule.Percent / 100
+		}
+		save = math.Round(save*100) / 100
+		if save <= 0 {
+			continue
+		}
+
+		if err := s.applyRule(ctx, rule, save, txn.ID); err != nil {
+			logger.Sugared().Warnf("savings rule %d skipped: %v", rule.ID, err)
+		}
+	}
+}
+
+// RunWeeklySweeps — ежедневная задача, выполняющая еженедельные переводы в копилки
+func (s *SavingsGoalService) RunWeeklySweeps(ctx context.Context) error {
+	rules, err := s.repo.ListDueWeeklySweeps(ctx, int(time.Now().Weekday()))
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if err := s.applyRule(ctx, rule, rule.Amount, 0); err != nil {
+			logger.Sugared().Warnf("weekly sweep %d skipped: %v", rule.ID, err)
+			continue
+		}
+		if err := s.repo.MarkRuleRun(ctx, rule.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SavingsGoalService) applyRule(ctx context.Context, rule models.SavingsRule, amount float64, sourceTxnID int64) error {
+	g, err := s.repo.GetGoalByID(ctx, rule.GoalID)
+	if err != nil {
+		return err
+	}
+	if g == nil || g.Status != "active" {
+		return ErrGoalClosed
+	}
+
+	description := fmt.Sprintf("Savings rule %s for goal %q", rule.Type, g.Name)
+	if sourceTxnID != 0 {
+		description = fmt.Sprintf("%s (transaction %d)", description, sourceTxnID)
+	}
+
+	_, err = s.transactionService.MoveFunds(ctx, g.SourceAccountID, g.AccountID, amount, "pot_transfer", description)
+	return err
+}
+
+func (s *SavingsGoalService) getActiveGoal(ctx context.Context, userID, id int64) (*models.SavingsGoal, error) {
+	g, err := s.GetGoal(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if g.Status != "active" {
+		return nil, ErrGoalClosed
+	}
+	return g, nil
+}
+
+func goalProgress(g models.SavingsGoal) *models.SavingsGoalProgress {
+	p := &models.SavingsGoalProgress{SavingsGoal: g}
+
+	p.Remaining = math.Max(g.TargetAmount-g.Balance, 0)
+	if g.TargetAmount > 0 {
+		p.Progress = math.Min(math.Round(g.Balance/g.TargetAmount*10000)/100, 100)
+	}
+
+	if g.TargetDate != nil && p.Remaining > 0 {
+		months := math.Ceil(time.Until(*g.TargetDate).Hours() / 24 / 30)
+		if months < 1 {
+			months = 1
+		}
+		p.MonthlyNeeded = math.Round(p.Remaining/months*100) / 100
+	}
+	return p
+}
@@ -10,13 +10,43 @@ import (
 	"time"
 )
 
+// TransactionHook вызывается после того, как транзакция проведена и записана
+type TransactionHook func(ctx context.Context, txn *models.Transaction)
+
 type TransactionService struct {
 	repo        repositories.TransactionRepository
 	accountRepo repositories.AccountRepository
+	pricing     *PricingService
+	hooks       []TransactionHook
+}
+
+func NewTransactionService(repo repositories.TransactionRepository, accountRepo repositories.AccountRepository, pricing *PricingService) *TransactionService {
+	return &TransactionService{repo: repo, accountRepo: accountRepo, pricing: pricing}
+}
+
+// AddHook регистрирует обработчик, который вызывается после каждой проведённой транзакции
+func (s *TransactionService) AddHook(hook TransactionHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// record сохраняет транзакцию и уведомляет подписчиков
+func (s *TransactionService) record(ctx context.Context, txn *models.Transaction) (int64, error) {
+	id, err := s.repo.CreateTransaction(ctx, txn)
+	if err != nil {
+		return 0, err
+	}
+	txn.ID = id
+
+	s.Posted(ctx, txn)
+	return id, nil
 }
 
-func NewTransactionService(repo repositories.TransactionRepository, accountRepo repositories.AccountRepository) *TransactionService {
-	return &TransactionService{repo: repo, accountRepo: accountRepo}
+// Posted уведомляет подписчиков о транзакции, которую другой репозиторий
+// провёл в своей транзакции БД вместе с изменением собственных данных
+func (s *TransactionService) Posted(ctx context.Context, txn *models.Transaction) {
+	for _, hook := range s.hooks {
+		hook(ctx, txn)
+	}
 }
 
 func (s *TransactionService) CreateTransaction(ctx context.Context, txn *models.Transaction) (int64, error) {
@@ -25,7 +55,7 @@ func (s *TransactionService) CreateTransaction(ctx context.Context, txn *models.
 	}
 
 	txn.Timestamp = time.Now()
-	return s.repo.CreateTransaction(ctx, txn)
+	return s.record(ctx, txn)
 }
 
 func (s *TransactionService) TransferBetweenAccounts(ctx context.Context, fromID, toID int64, amount float64, description string) (int64, error) {
@@ -33,11 +63,11 @@ func (s *TransactionService) TransferBetweenAccounts(ctx context.Context, fromID
 		return 0, errors.New("amount must be positive")
 	}
 
-	balance, err := s.accountRepo.GetAccountBalance(ctx, fromID)
+	available, err := s.accountRepo.GetAvailableBalance(ctx, fromID)
 	if err != nil {
 		return 0, err
 	}
-	if balance < amount {
+	if available < amount {
 		return 0, errors.New("insufficient funds")
 	}
 
@@ -54,7 +84,7 @@ func (s *TransactionService) TransferBetweenAccounts(ctx context.Context, fromID
 		Timestamp:   time.Now(),
 		Description: description,
 	}
-	return s.repo.CreateTransaction(ctx, transaction)
+	return s.record(ctx, transaction)
 }
 
 func (s *TransactionService) Deposit(ctx context.Context, toAccountID int64, amount float64, description string) (int64, error) {
@@ -75,9 +105,10 @@ func (s *TransactionService) Deposit(ctx context.Context, toAccountID int64, amo
 		Description: description,
 	}
 
-	return s.repo.CreateTransaction(ctx, transaction)
+	return s.record(ctx, transaction)
 }
 
+// Withdraw снимает средства со счёта; комиссия по тарифу списывается вместе со снятием
 func (s *TransactionService) Withdraw(ctx context.Context, fromAccountID int64, amount float64, description string) (int64, error) {
 	if amount <= 0 {
 		return 0, errors.New("amount must be greater than zero")
@@ -85,35 +116,32 @@ func (s *TransactionService) Withdraw(ctx context.Context, fromAccountID int64,
 	if err := s.authorizeAccountOwner(ctx, fromAccountID); err != nil {
 		return 0, err
 	}
-	// Проверка баланса до транзакции (необязательна, но даёт более явную ошибку до начала транзакции)
-	balance, err := s.accountRepo.GetAccountBalance(ctx, fromAccountID)
+	userID, err := middleware.GetUserID(ctx)
 	if err != nil {
-		return 0, err
-	}
-	if balance < amount {
-		return 0, errors.New("insufficient funds")
+		return 0, errors.New("unauthenticated")
 	}
 
 	// Снятие средств, toID = 0 (внешняя система или просто уходит из системы)
-	if err := s.repo.UpdateBalancesTx(ctx, fromAccountID, -amount, 0, 0); err != nil {
-		return 0, err
-	}
-
 	transaction := &models.Transaction{
 		FromAccount: fromAccountID,
-		Amount:      -amount,
+		Amount:      amount,
 		Type:        "withdraw",
 		Timestamp:   time.Now(),
 		Description: description,
 	}
-
-	return s.repo.CreateTransaction(ctx, transaction)
+	req := models.FeeRequest{UserID: userID, Operation: models.FeeOperationWithdrawal, Amount: amount}
+	if _, err := s.PostWithFee(ctx, req, fromAccountID, transaction, s.requireAvailable(ctx, fromAccountID, amount)); err != nil {
+		return 0, err
+	}
+	return transaction.ID, nil
 }
 
 func (s *TransactionService) GetTransactionHistory(ctx context.Context, accountID int64) ([]models.Transaction, error) {
 	return s.repo.GetTransactionsByAccountID(ctx, accountID)
 }
 
+// Transfer переводит средства со своего текущего счёта. Комиссия зависит от
+// канала: перевод между своими счетами (own) или другому клиенту (p2p).
 func (s *TransactionService) Transfer(ctx context.Context, fromID, toID int64, amount float64, description string) (int64, error) {
 
 	if err := s.authorizeAccountOwner(ctx, fromID); err != nil {
@@ -124,15 +152,12 @@ func (s *TransactionService) Transfer(ctx context.Context, fromID, toID int64, a
 		return 0, errors.New("amount must be positive")
 	}
 
-	balance, err := s.accountRepo.GetAccountBalance(ctx, fromID)
+	userID, err := middleware.GetUserID(ctx)
 	if err != nil {
-		return 0, err
+		return 0, errors.New("unauthenticated")
 	}
-	if balance < amount {
-		return 0, errors.New("insufficient funds")
-	}
-
-	if err := s.repo.UpdateBalancesTx(ctx, fromID, -amount, toID, amount); err != nil {
+	channel, err := s.transferChannel(ctx, userID, toID)
+	if err != nil {
 		return 0, err
 	}
 
@@ -144,7 +169,23 @@ func (s *TransactionService) Transfer(ctx context.Context, fromID, toID int64, a
 		Timestamp:   time.Now(),
 		Description: description,
 	}
-	return s.repo.CreateTransaction(ctx, tx)
+	req := models.FeeRequest{UserID: userID, Operation: models.FeeOperationTransfer, Channel: channel, Amount: amount}
+	if _, err := s.PostWithFee(ctx, req, fromID, tx, s.requireAvailable(ctx, fromID, amount)); err != nil {
+		return 0, err
+	}
+	return tx.ID, nil
+}
+
+// transferChannel — own, если счёт получателя принадлежит отправителю, иначе p2p
+func (s *TransactionService) transferChannel(ctx context.Context, userID, toID int64) (string, error) {
+	owner, err := s.accountRepo.GetAccountOwner(ctx, toID)
+	if err != nil {
+		return "", err
+	}
+	if owner == userID {
+		return models.FeeChannelOwn, nil
+	}
+	return models.FeeChannelP2P, nil
 }
 
 func (s *TransactionService) CreditPayment(ctx context.Context, fromAccountID int64, amount float64, description string) (int64, error) {
@@ -152,11 +193,11 @@ func (s *TransactionService) CreditPayment(ctx context.Context, fromAccountID in
 		return 0, errors.New("amount must be greater than zero")
 	}
 
-	balance, err := s.accountRepo.GetAccountBalance(ctx, fromAccountID)
+	available, err := s.accountRepo.GetAvailableBalance(ctx, fromAccountID)
 	if err != nil {
 		return 0, err
 	}
-	if balance < amount {
+	if available < amount {
 		return 0, errors.New("insufficient funds")
 	}
 
@@ -167,13 +208,13 @@ func (s *TransactionService) CreditPayment(ctx context.Context, fromAccountID in
 
 	transaction := &models.Transaction{
 		FromAccount: fromAccountID,
-		Amount:      -amount,
+		Amount:      amount,
 		Type:        "credit_payment",
 		Timestamp:   time.Now(),
 		Description: description,
 	}
 
-	return s.repo.CreateTransaction(ctx, transaction)
+	return s.record(ctx, transaction)
 }
 
 func (s *TransactionService) ReverseTransaction(ctx context.Context, transactionID int64, description string) (int64, error) {
@@ -227,20 +268,16 @@ func (s *TransactionService) ReverseTransaction(ctx context.Context, transaction
 		Description: fmt.Sprintf("Reversal of transaction %d: %s", transactionID, description),
 	}
 
-	return s.repo.CreateTransaction(ctx, reversal)
+	return s.record(ctx, reversal)
 }
 
 func (s *TransactionService) authorizeAccountOwner(ctx context.Context, accountID int64) error {
-	userIDRaw := ctx.Value(middleware.UserIDKey)
-	if userIDRaw == nil {
+	// JWTMiddleware кладёт userID строкой, поэтому читаем через GetUserID
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
 		return errors.New("unauthenticated")
 	}
 
-	userID, ok := userIDRaw.(int64)
-	if !ok {
-		return errors.New("invalid user id")
-	}
-
 	isOwner, err := s.accountRepo.IsAccountOwnedByUser(ctx, accountID, userID)
 	if err != nil {
 		return err
@@ -249,6 +286,15 @@ func (s *TransactionService) authorizeAccountOwner(ctx context.Context, accountI
 		return errors.New("unauthorized: account does not belong to user")
 	}
 
+	// Счета вкладов и т.п. управляются своими сервисами, напрямую с них не списываем
+	kind, err := s.accountRepo.GetAccountKind(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	if kind != "current" {
+		return errors.New("operation is not allowed for this account type")
+	}
+
 	return nil
 }
 
@@ -272,3 +318,198 @@ func (s *TransactionService) CreditToAccount(ctx context.Context, accountID int6
 
 	return s.CreateTransaction(ctx, txn)
 }
+
+// PostCredit зачисляет средства банка на счёт (проценты, кэшбэк и т.п.) с заданным типом транзакции
+func (s *TransactionService) PostCredit(ctx context.Context, accountID int64, amount float64, txnType, description string) (int64, error) {
+	if amount <= 0 {
+		return 0, errors.New("amount must be positive")
+	}
+
+	if err := s.repo.UpdateBalancesTx(ctx, 0, 0, accountID, amount); err != nil {
+		return 0, err
+	}
+
+	txn := &models.Transaction{
+		ToAccount:   accountID,
+		Amount:      amount,
+		Type:        txnType,
+		Timestamp:   time.Now(),
+		Description: description,
+	}
+	return s.record(ctx, txn)
+}
+
+// PostDebit списывает средства со счёта в пользу банка без проверки владельца и остатка
+func (s *TransactionService) PostDebit(ctx context.Context, accountID int64, amount float64, txnType, description string) (int64, error) {
+	if amount <= 0 {
+		return 0, errors.New("amount must be positive")
+	}
+
+	if err := s.repo.UpdateBalancesTx(ctx, accountID, -amount, 0, 0); err != nil {
+		return 0, err
+	}
+
+	txn := &models.Transaction{
+		FromAccount: accountID,
+		Amount:      amount,
+		Type:        txnType,
+		Timestamp:   time.Now(),
+		Description: description,
+	}
+	return s.record(ctx, txn)
+}
+
+// PostCardOperation списывает покупку или снятие наличных по карте. Средства уже
+// заблокированы авторизацией, поэтому остаток повторно не проверяется.
+func (s *TransactionService) PostCardOperation(ctx context.Context, accountID int64, amount float64, txnType, mcc, merchant, description string) (int64, error) {
+	if amount <= 0 {
+		return 0, errors.New("amount must be positive")
+	}
+
+	if err := s.repo.UpdateBalancesTx(ctx, accountID, -amount, 0, 0); err != nil {
+		return 0, err
+	}
+
+	txn := &models.Transaction{
+		FromAccount: accountID,
+		Amount:      amount,
+		Type:        txnType,
+		Timestamp:   time.Now(),
+		Description: description,
+		MCC:         mcc,
+		Merchant:    merchant,
+	}
+	return s.record(ctx, txn)
+}
+
+// MoveFunds переводит средства между счетами банка с заданным типом транзакции.
+// Владельца проверяет вызывающий сервис.
+func (s *TransactionService) MoveFunds(ctx context.Context, fromID, toID int64, amount float64, txnType, description string) (int64, error) {
+	if amount <= 0 {
+		return 0, errors.New("amount must be positive")
+	}
+
+	balance, err := s.accountRepo.GetAccountBalance(ctx, fromID)
+	if err != nil {
+		return 0, err
+	}
+	if balance < amount {
+		return 0, errors.New("insufficient funds")
+	}
+
+	if err := s.repo.UpdateBalancesTx(ctx, fromID, -amount, toID, amount); err != nil {
+		return 0, err
+	}
+
+	txn := &models.Transaction{
+		FromAccount: fromID,
+		ToAccount:   toID,
+		Amount:      amount,
+		Type:        txnType,
+		Timestamp:   time.Now(),
+		Description: description,
+	}
+	return s.record(ctx, txn)
+}
+
+// QuoteFee рассчитывает комиссию за операцию до её выполнения
+func (s *TransactionService) QuoteFee(ctx context.Context, req models.FeeRequest) (*models.FeeQuote, error) {
+	return s.pricing.Quote(ctx, req)
+}
+
+// PostWithFee проводит операцию txn вместе с комиссией по тарифу, которая
+// списывается со счёта feeAccountID на счёт доходов банка в той же
+// транзакции БД. check получает рассчитанную комиссию и может отклонить
+// операцию. Если бесплатную квоту, на которую рассчитывал расчёт, успела
+// израсходовать параллельная операция, комиссия пересчитывается.
+func (s *TransactionService) PostWithFee(ctx context.Context, req models.FeeRequest, feeAccountID int64, txn *models.Transaction,
+	check func(fee float64) error) (*models.FeeQuote, error) {
+	for attempt := 1; ; attempt++ {
+		quote, err := s.pricing.Quote(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if check != nil {
+			if err := check(quote.Fee); err != nil {
+				return nil, err
+			}
+		}
+
+		txns := []*models.Transaction{txn}
+		if quote.Fee > 0 {
+			fee, err := s.FeeTransaction(ctx, feeAccountID, quote.Fee, fmt.Sprintf("Fee for %s (%s)", quote.Operation, quote.Rule))
+			if err != nil {
+				return nil, err
+			}
+			txns = append(txns, fee)
+		}
+
+		err = s.PostTransactions(ctx, quote.Quota, txns...)
+		if errors.Is(err, repositories.ErrFeeQuotaChanged) && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return quote, nil
+	}
+}
+
+// FeeTransaction — списание комиссии со счёта на счёт доходов банка. Проводится
+// через PostTransactions вместе с операцией, за которую взимается.
+func (s *TransactionService) FeeTransaction(ctx context.Context, accountID int64, fee float64, description string) (*models.Transaction, error) {
+	incomeAccountID, err := s.pricing.IncomeAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Transaction{
+		FromAccount: accountID,
+		ToAccount:   incomeAccountID,
+		Amount:      fee,
+		Type:        "fee",
+		Timestamp:   time.Now(),
+		Description: description,
+	}, nil
+}
+
+// ReleaseFeeQuota возвращает бесплатную квоту, израсходованную транзакцией
+// операции, которая в итоге не состоялась
+func (s *TransactionService) ReleaseFeeQuota(ctx context.Context, transactionID int64) error {
+	return s.pricing.ReleaseQuota(ctx, transactionID)
+}
+
+// PostTransactions проводит транзакции одной транзакцией БД и уведомляет подписчиков
+func (s *TransactionService) PostTransactions(ctx context.Context, claim *models.FeeQuotaClaim, txns ...*models.Transaction) error {
+	for _, txn := range txns {
+		if txn.Amount <= 0 {
+			return errors.New("amount must be positive")
+		}
+		if txn.Timestamp.IsZero() {
+			txn.Timestamp = time.Now()
+		}
+	}
+	if err := s.repo.PostTransactions(ctx, claim, txns...); err != nil {
+		return err
+	}
+
+	for _, txn := range txns {
+		for _, hook := range s.hooks {
+			hook(ctx, txn)
+		}
+	}
+	return nil
+}
+
+// requireAvailable проверяет, что доступного остатка хватит на сумму с комиссией
+func (s *TransactionService) requireAvailable(ctx context.Context, accountID int64, amount float64) func(fee float64) error {
+	return func(fee float64) error {
+		available, err := s.accountRepo.GetAvailableBalance(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if available < amount+fee {
+			return errors.New("insufficient funds")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"bank-api/internal/models"
+	"bank-api/internal/repositories"
+	"context"
+	"errors"
+)
+
+var ErrTemplateNotFound = errors.New("transfer template not found")
+
+type TransferTemplateService struct {
+	repo               *repositories.TransferTemplateRepository
+	accountRepo        *repositories.AccountRepository
+	beneficiaryService *BeneficiaryService
+	transactionService *TransactionService
+}
+
+func NewTransferTemplateService(
+	repo *repositories.TransferTemplateRepository,
+	accountRepo *repositories.AccountRepository,
+	beneficiaryService *BeneficiaryService,
+	transactionService *TransactionService,
+) *TransferTemplateService {
+	return &TransferTemplateService{
+		repo:               repo,
+		accountRepo:        accountRepo,
+		beneficiaryService: beneficiaryService,
+		transactionService: transactionService,
+	}
+}
+
+func (s *TransferTemplateService) CreateTemplate(ctx context.Context, t *models.TransferTemplate) error {
+	if err := s.validate(ctx, t); err != nil {
+		return err
+	}
+	return s.repo.CreateTemplate(ctx, t)
+}
+
+func (s *TransferTemplateService) GetTemplate(ctx context.Context, userID, id int64) (*models.TransferTemplate, error) {
+	t, err := s.repo.GetTemplateByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || t.UserID != userID {
+		return nil, ErrTemplateNotFound
+	}
+	return t, nil
+}
+
+func (s *TransferTemplateService) ListTemplates(ctx context.Context, userID int64) ([]models.TransferTemplate, error) {
+	return s.repo.ListTemplatesByUser(ctx, userID)
+}
+
+func (s *TransferTemplateService) UpdateTemplate(ctx context.Context, t *models.TransferTemplate) error {
+	if _, err := s.GetTemplate(ctx, t.UserID, t.ID); err != nil {
+		return err
+	}
+	if err := s.validate(ctx, t); err != nil {
+		return err
+	}
+	return s.repo.UpdateTemplate(ctx, t)
+}
+
+func (s *TransferTemplateService) DeleteTemplate(ctx context.Context, userID, id int64) error {
+	if _, err := s.GetTemplate(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteTemplate(ctx, id)
+}
+
+// PayFromTemplate выполняет перевод по шаблону через обычный Transfer.
+// Если amount не задан, используется сумма по умолчанию из шаблона.
+func (s *TransferTemplateService) PayFromTemplate(ctx context.Context, userID, id int64, amount float64) (int64, error) {
+	t, err := s.GetTemplate(ctx, userID, id)
+	if err != nil {
+		return 0, err
+	}
+
+	b, err := s.beneficiaryService.GetBeneficiary(ctx, userID, t.BeneficiaryID)
+	if err != nil {
+		return 0, err
+	}
+	if b.Status != "active" {
+		return 0, ErrBeneficiaryNotConfirmed
+	}
+	if b.AccountID == 0 {
+		return 0, errors.New("beneficiary has no account for internal transfer")
+	}
+
+	if amount <= 0 {
+		amount = t.DefaultAmount
+	}
+
+	return s.transactionService.Transfer(ctx, t.FromAccountID, b.AccountID, amount, t.Description)
+}
+
+func (s *TransferTemplateService) validate(ctx context.Context, t *models.TransferTemplate) error {
+	if t.UserID == 0 || t.Name == "" || t.BeneficiaryID == 0 || t.FromAccountID == 0 {
+		return errors.New("missing required template fields")
+	}
+	if t.DefaultAmount < 0 {
+		return errors.New("default amount must not be negative")
+	}
+
+	if _, err := s.beneficiaryService.GetBeneficiary(ctx, t.UserID, t.BeneficiaryID); err != nil {
+		return err
+	}
+
+	owned, err := s.accountRepo.IsAccountOwnedByUser(ctx, t.FromAccountID, t.UserID)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return errors.New("unauthorized: account does not belong to user")
+	}
+	return nil
+}
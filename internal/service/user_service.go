@@ -60,6 +60,18 @@ func (s *UserService) AuthenticateUser(ctx context.Context, email, password stri
 	return generateJWT(user.ID)
 }
 
+// VerifyPassword — повторная аутентификация перед чувствительными операциями
+func (s *UserService) VerifyPassword(ctx context.Context, userID int64, password string) error {
+	hash, err := s.repo.GetPasswordHash(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if hash == "" || bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return errors.New("invalid credentials")
+	}
+	return nil
+}
+
 func (s *UserService) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
 	return s.repo.GetUserByID(ctx, id)
 }
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"bank-api/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// виртуальная карта живёт не дольше физической
+	maxVirtualCardDays     = 3 * 365
+	defaultVirtualCardDays = 30
+)
+
+// VirtualCardRequest — параметры выпуска виртуальной карты
+type VirtualCardRequest struct {
+	AccountID     int64   `json:"account_id"`
+	Type          string  `json:"type"`      // standard, single_use, merchant_locked
+	SpendCap      float64 `json:"spend_cap"` // лимит за весь срок действия
+	ExpiresInDays int     `json:"expires_in_days"`
+	Product       string  `json:"product"`
+}
+
+// VirtualCardService выпускает виртуальные карты. Реквизиты выдаёт CardRevealService.
+type VirtualCardService struct {
+	cardService *CardService
+}
+
+func NewVirtualCardService(cardService *CardService) *VirtualCardService {
+	return &VirtualCardService{cardService: cardService}
+}
+
+// Create выпускает виртуальную карту. Она активна сразу, номер в ответе маскирован.
+func (s *VirtualCardService) Create(ctx context.Context, userID int64, req VirtualCardRequest) (*models.Card, error) {
+	if req.Type == "" {
+		req.Type = models.VirtualStandard
+	}
+	switch req.Type {
+	case models.VirtualStandard, models.VirtualSingleUse, models.VirtualMerchantLocked:
+	default:
+		return nil, fmt.Errorf("unknown virtual card type %q", req.Type)
+	}
+	if req.SpendCap <= 0 {
+		return nil, errors.New("spend_cap must be positive")
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultVirtualCardDays
+	}
+	if req.ExpiresInDays < 1 || req.ExpiresInDays > maxVirtualCardDays {
+		return nil, fmt.Errorf("expires_in_days must be between 1 and %d", maxVirtualCardDays)
+	}
+
+	owned, err := s.cardService.accountRepo.IsAccountOwnedByUser(ctx, req.AccountID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, ErrCardNotFound
+	}
+	kind, err := s.cardService.accountRepo.GetAccountKind(ctx, req.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if kind != "current" {
+		return nil, errors.New("cards can be issued only to current accounts")
+	}
+
+	now := time.Now().UTC()
+	expiresAt := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, req.ExpiresInDays)
+	spendCap := req.SpendCap
+	return s.cardService.issueCard(ctx, req.AccountID, req.Product, cardOptions{
+		Status:      models.CardStatusActive,
+		VirtualType: req.Type,
+		SpendCap:    &spendCap,
+		ExpiresAt:   &expiresAt,
+	})
+}
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS beneficiaries (
+    id SERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    account_id BIGINT REFERENCES accounts(id) ON DELETE SET NULL,
+    phone VARCHAR(32),
+    bank_name VARCHAR(255),
+    nickname VARCHAR(100),
+    is_favourite BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(32) NOT NULL DEFAULT 'active',
+    confirmation_code_hash TEXT,
+    confirmation_expires_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (account_id IS NOT NULL OR phone IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_beneficiaries_user_id ON beneficiaries(user_id);
@@ -0,0 +1 @@
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS kind VARCHAR(32) NOT NULL DEFAULT 'current';
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS deposit_products (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    kind VARCHAR(32) NOT NULL,                         -- term, savings
+    rate_source VARCHAR(32) NOT NULL DEFAULT 'fixed',  -- fixed, cbr_key_rate
+    base_rate NUMERIC(5, 2) NOT NULL DEFAULT 0.00,     -- фиксированная ставка или спред к ключевой
+    on_demand_rate NUMERIC(5, 2) NOT NULL DEFAULT 0.01,
+    term_months INT NOT NULL DEFAULT 0,
+    interest_period VARCHAR(32) NOT NULL DEFAULT 'monthly', -- monthly, end_of_term
+    interest_mode VARCHAR(32) NOT NULL DEFAULT 'capitalize', -- capitalize, payout
+    allow_top_up BOOLEAN NOT NULL DEFAULT TRUE,
+    allow_partial_withdrawal BOOLEAN NOT NULL DEFAULT FALSE,
+    min_amount NUMERIC(14, 2) NOT NULL DEFAULT 0.00,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO deposit_products
+    (name, kind, rate_source, base_rate, on_demand_rate, term_months, interest_period, interest_mode,
+     allow_top_up, allow_partial_withdrawal, min_amount)
+VALUES
+    ('Накопительный счёт', 'savings', 'cbr_key_rate', -3.00, 0.01, 0, 'monthly', 'capitalize', TRUE, TRUE, 0.00),
+    ('Вклад на 6 месяцев', 'term', 'fixed', 16.00, 0.01, 6, 'end_of_term', 'capitalize', FALSE, FALSE, 10000.00),
+    ('Вклад на 12 месяцев с выплатой', 'term', 'fixed', 15.00, 0.01, 12, 'monthly', 'payout', TRUE, FALSE, 10000.00);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS deposits (
+    id SERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    product_id BIGINT NOT NULL REFERENCES deposit_products(id),
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,        -- счёт самого вклада
+    linked_account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE, -- текущий счёт клиента
+    rate NUMERIC(5, 2) NOT NULL,
+    accrued_interest NUMERIC(14, 6) NOT NULL DEFAULT 0,  -- начислено, но ещё не выплачено
+    status VARCHAR(32) NOT NULL DEFAULT 'open',          -- open, matured, closed
+    opened_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    maturity_date DATE,
+    last_accrual_date DATE,
+    closed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_deposits_user_id ON deposits(user_id);
+CREATE INDEX IF NOT EXISTS idx_deposits_status ON deposits(status);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS deposit_accruals (
+    id SERIAL PRIMARY KEY,
+    deposit_id BIGINT NOT NULL REFERENCES deposits(id) ON DELETE CASCADE,
+    accrual_date DATE NOT NULL,
+    balance NUMERIC(14, 2) NOT NULL,
+    rate NUMERIC(5, 2) NOT NULL,
+    amount NUMERIC(14, 6) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (deposit_id, accrual_date)
+);
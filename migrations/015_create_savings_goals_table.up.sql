@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS savings_goals (
+    id SERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,        -- счёт копилки
+    source_account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE, -- основной счёт
+    name VARCHAR(255) NOT NULL,
+    target_amount NUMERIC(14, 2) NOT NULL,
+    target_date DATE,
+    status VARCHAR(32) NOT NULL DEFAULT 'active', -- active, closed
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_savings_goals_user_id ON savings_goals(user_id);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS savings_rules (
+    id SERIAL PRIMARY KEY,
+    goal_id BIGINT NOT NULL REFERENCES savings_goals(id) ON DELETE CASCADE,
+    type VARCHAR(32) NOT NULL,           -- round_up, income_percent, weekly_sweep
+    round_to INT NOT NULL DEFAULT 0,     -- 10, 50, 100 для round_up
+    percent NUMERIC(5, 2) NOT NULL DEFAULT 0,
+    amount NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    weekday INT NOT NULL DEFAULT 1,      -- 0 = воскресенье, для weekly_sweep
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    last_run_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_savings_rules_goal_id ON savings_rules(goal_id);
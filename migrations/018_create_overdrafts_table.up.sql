@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS overdrafts (
+    id SERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL UNIQUE REFERENCES accounts(id) ON DELETE CASCADE,
+    limit_amount NUMERIC(14, 2) NOT NULL,
+    interest_rate NUMERIC(5, 2) NOT NULL,            -- годовая ставка на отрицательный остаток
+    accrued_interest NUMERIC(14, 6) NOT NULL DEFAULT 0,
+    status VARCHAR(32) NOT NULL DEFAULT 'active',    -- active, revoked
+    granted_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    last_accrual_date DATE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS merchant;
+ALTER TABLE transactions DROP COLUMN IF EXISTS mcc;
@@ -0,0 +1,2 @@
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS mcc VARCHAR(4);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS merchant VARCHAR(255);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS reward_campaigns (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    category VARCHAR(100) NOT NULL,       -- категория для отчёта: restaurants, taxi, ...
+    mcc_codes TEXT,                       -- список MCC через запятую
+    merchant VARCHAR(255),
+    transaction_type VARCHAR(32),
+    percent NUMERIC(5, 2) NOT NULL,
+    monthly_cap NUMERIC(14, 2) NOT NULL DEFAULT 0, -- 0 = без ограничения
+    requires_opt_in BOOLEAN NOT NULL DEFAULT FALSE,
+    starts_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    ends_at TIMESTAMP,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
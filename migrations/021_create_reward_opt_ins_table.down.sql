@@ -0,0 +1 @@
+DROP TABLE IF EXISTS reward_opt_ins;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS reward_opt_ins (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    campaign_id BIGINT NOT NULL REFERENCES reward_campaigns(id) ON DELETE CASCADE,
+    period DATE NOT NULL, -- первый день месяца
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, campaign_id, period)
+);
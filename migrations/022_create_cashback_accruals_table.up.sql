@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS cashback_accruals (
+    id SERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    campaign_id BIGINT NOT NULL REFERENCES reward_campaigns(id),
+    transaction_id BIGINT NOT NULL REFERENCES transactions(id),
+    category VARCHAR(100) NOT NULL,
+    amount NUMERIC(14, 2) NOT NULL,
+    status VARCHAR(32) NOT NULL DEFAULT 'pending', -- pending, paid
+    period DATE NOT NULL,
+    payout_transaction_id BIGINT REFERENCES transactions(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    paid_at TIMESTAMP,
+    UNIQUE (transaction_id, campaign_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_cashback_accruals_user_period ON cashback_accruals(user_id, period);
+CREATE INDEX IF NOT EXISTS idx_cashback_accruals_status ON cashback_accruals(status);
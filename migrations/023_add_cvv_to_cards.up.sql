@@ -0,0 +1,2 @@
+-- CVV шифруется тем же конвертом, что и encrypted_data
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS cvv TEXT;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS reencryption_jobs;
+DROP TABLE IF EXISTS retired_encryption_keys;
+DROP INDEX IF EXISTS idx_cards_key_id;
+ALTER TABLE cards DROP COLUMN IF EXISTS key_id;
@@ -0,0 +1,27 @@
+-- ключ, которым зашифрована карта; legacy — старый формат CBC
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS key_id VARCHAR(64);
+
+UPDATE cards SET key_id = split_part(encrypted_data, ':', 2) WHERE key_id IS NULL AND encrypted_data LIKE 'v2:%';
+UPDATE cards SET key_id = 'legacy' WHERE key_id IS NULL;
+
+ALTER TABLE cards ALTER COLUMN key_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_cards_key_id ON cards(key_id);
+
+-- выведенные из оборота ключи: на них больше не ссылается ни одна запись
+CREATE TABLE IF NOT EXISTS retired_encryption_keys (
+    key_id VARCHAR(64) PRIMARY KEY,
+    retired_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- прогресс перешифрования, чтобы задачу можно было продолжить после остановки
+CREATE TABLE IF NOT EXISTS reencryption_jobs (
+    id SERIAL PRIMARY KEY,
+    target_key_id VARCHAR(64) NOT NULL,
+    last_card_id BIGINT NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    status VARCHAR(32) NOT NULL DEFAULT 'running', -- running, completed
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP
+);
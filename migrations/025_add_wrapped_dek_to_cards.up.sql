@@ -0,0 +1,2 @@
+-- ключ данных карты, обёрнутый мастер-ключом KMS; у старых записей пусто
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS wrapped_dek TEXT;
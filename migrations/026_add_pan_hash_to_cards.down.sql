@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_cards_pan_hash;
+ALTER TABLE cards DROP COLUMN IF EXISTS brand;
+ALTER TABLE cards DROP COLUMN IF EXISTS product;
+ALTER TABLE cards DROP COLUMN IF EXISTS pan_hash;
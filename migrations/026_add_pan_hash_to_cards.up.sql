@@ -0,0 +1,6 @@
+-- ключевой хеш номера карты: уникальность без хранения номера в открытом виде
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS pan_hash VARCHAR(64);
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS product VARCHAR(64);
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS brand VARCHAR(32);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cards_pan_hash ON cards(pan_hash);
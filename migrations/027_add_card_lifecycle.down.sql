@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS card_status_history;
+DROP INDEX IF EXISTS idx_cards_replaces_card_id;
+DROP INDEX IF EXISTS idx_cards_status_expires_at;
+ALTER TABLE cards DROP COLUMN IF EXISTS replaces_card_id;
+ALTER TABLE cards DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE cards DROP COLUMN IF EXISTS status_changed_at;
+ALTER TABLE cards DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE cards DROP COLUMN IF EXISTS status;
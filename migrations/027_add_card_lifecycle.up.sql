@@ -0,0 +1,23 @@
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS status VARCHAR(32) NOT NULL DEFAULT 'active';
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS status_reason VARCHAR(255);
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP;
+-- последний день срока действия; у старых карт заполняется задачей card-expiry
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS expires_at DATE;
+-- карта, которую заменяет эта (перевыпуск или продление)
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS replaces_card_id BIGINT REFERENCES cards(id);
+
+CREATE INDEX IF NOT EXISTS idx_cards_status_expires_at ON cards(status, expires_at);
+CREATE INDEX IF NOT EXISTS idx_cards_replaces_card_id ON cards(replaces_card_id);
+
+CREATE TABLE IF NOT EXISTS card_status_history (
+    id SERIAL PRIMARY KEY,
+    card_id BIGINT NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+    from_status VARCHAR(32) NOT NULL,
+    to_status VARCHAR(32) NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    actor_type VARCHAR(16) NOT NULL, -- user, admin, system
+    actor_id BIGINT REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_card_status_history_card_id ON card_status_history(card_id);
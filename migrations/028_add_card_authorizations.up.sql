@@ -0,0 +1,26 @@
+-- авторизации по картам; одобренная авторизация держит средства (hold) до списания
+CREATE TABLE IF NOT EXISTS card_authorizations (
+    id SERIAL PRIMARY KEY,
+    card_id BIGINT REFERENCES cards(id),
+    account_id BIGINT REFERENCES accounts(id),
+    message_type VARCHAR(4) NOT NULL, -- MTI запроса: 0100, 0200
+    rrn VARCHAR(12) NOT NULL UNIQUE,
+    stan VARCHAR(6) NOT NULL DEFAULT '',
+    terminal_id VARCHAR(8) NOT NULL DEFAULT '',
+    merchant_id VARCHAR(15) NOT NULL DEFAULT '',
+    merchant VARCHAR(255) NOT NULL DEFAULT '',
+    mcc VARCHAR(4) NOT NULL DEFAULT '',
+    amount NUMERIC(14, 2) NOT NULL,
+    captured_amount NUMERIC(14, 2),
+    auth_code VARCHAR(6) NOT NULL DEFAULT '',
+    response_code VARCHAR(2) NOT NULL,
+    status VARCHAR(16) NOT NULL, -- declined, held, captured, reversed, expired
+    transaction_id BIGINT REFERENCES transactions(id),
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_card_authorizations_account_status ON card_authorizations(account_id, status);
+CREATE INDEX IF NOT EXISTS idx_card_authorizations_card_id ON card_authorizations(card_id);
+CREATE INDEX IF NOT EXISTS idx_card_authorizations_held_expires ON card_authorizations(expires_at) WHERE status = 'held';
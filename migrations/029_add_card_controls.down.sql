@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_card_authorizations_card_created;
+ALTER TABLE card_authorizations DROP COLUMN IF EXISTS decline_reason;
+ALTER TABLE card_authorizations DROP COLUMN IF EXISTS country;
+ALTER TABLE card_authorizations DROP COLUMN IF EXISTS entry_mode;
+ALTER TABLE card_authorizations DROP COLUMN IF EXISTS channel;
+DROP TABLE IF EXISTS card_controls;
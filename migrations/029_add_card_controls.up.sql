@@ -0,0 +1,23 @@
+-- ограничения по карте, которые задаёт владелец; NULL в лимите — без ограничения
+CREATE TABLE IF NOT EXISTS card_controls (
+    card_id BIGINT PRIMARY KEY REFERENCES cards(id) ON DELETE CASCADE,
+    daily_limit NUMERIC(14, 2),
+    monthly_limit NUMERIC(14, 2),
+    per_transaction_limit NUMERIC(14, 2),
+    atm_daily_limit NUMERIC(14, 2),
+    atm_monthly_limit NUMERIC(14, 2),
+    blocked_categories TEXT NOT NULL DEFAULT '', -- через запятую: gambling,crypto
+    blocked_mcc_codes TEXT NOT NULL DEFAULT '', -- через запятую: 5993,7273
+    online_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    contactless_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    magstripe_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    international_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE card_authorizations ADD COLUMN IF NOT EXISTS channel VARCHAR(16) NOT NULL DEFAULT 'pos';
+ALTER TABLE card_authorizations ADD COLUMN IF NOT EXISTS entry_mode VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE card_authorizations ADD COLUMN IF NOT EXISTS country VARCHAR(3) NOT NULL DEFAULT '';
+ALTER TABLE card_authorizations ADD COLUMN IF NOT EXISTS decline_reason VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_card_authorizations_card_created ON card_authorizations(card_id, created_at);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS card_reveals;
+ALTER TABLE cards DROP COLUMN IF EXISTS locked_merchant;
+ALTER TABLE cards DROP COLUMN IF EXISTS spend_cap;
+ALTER TABLE cards DROP COLUMN IF EXISTS virtual_type;
+ALTER TABLE cards DROP COLUMN IF EXISTS form_factor;
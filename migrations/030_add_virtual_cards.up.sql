@@ -0,0 +1,22 @@
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS form_factor VARCHAR(16) NOT NULL DEFAULT 'physical'; -- physical, virtual
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS virtual_type VARCHAR(16) NOT NULL DEFAULT ''; -- standard, single_use, merchant_locked
+-- лимит суммы операций за весь срок действия карты
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS spend_cap NUMERIC(14, 2);
+-- продавец, к которому привязалась карта merchant_locked при первой операции
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS locked_merchant VARCHAR(255);
+
+-- журнал показа реквизитов карты; токен одноразовый и живёт недолго
+CREATE TABLE IF NOT EXISTS card_reveals (
+    id SERIAL PRIMARY KEY,
+    card_id BIGINT NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id),
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    revealed_at TIMESTAMP,
+    revealed_ip VARCHAR(64),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_card_reveals_card_id ON card_reveals(card_id);
@@ -0,0 +1,3 @@
+ALTER TABLE cards DROP COLUMN IF EXISTS pin_set_at;
+ALTER TABLE cards DROP COLUMN IF EXISTS pin_tries;
+ALTER TABLE cards DROP COLUMN IF EXISTS pin_offset;
@@ -0,0 +1,4 @@
+-- PIN хранится только как смещение IBM 3624, вычисленное HSM
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS pin_offset VARCHAR(12);
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS pin_tries SMALLINT NOT NULL DEFAULT 0; -- неверные попытки подряд
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS pin_set_at TIMESTAMP;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_card_reveals_user_created;
+DELETE FROM card_reveals WHERE token_hash IS NULL;
+ALTER TABLE card_reveals ALTER COLUMN token_hash SET NOT NULL;
+ALTER TABLE card_reveals DROP COLUMN IF EXISTS outcome;
@@ -0,0 +1,5 @@
+-- в журнал пишутся и отказы при повторной аутентификации; у них нет токена
+ALTER TABLE card_reveals ADD COLUMN IF NOT EXISTS outcome VARCHAR(16) NOT NULL DEFAULT 'issued'; -- issued, denied
+ALTER TABLE card_reveals ALTER COLUMN token_hash DROP NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_card_reveals_user_created ON card_reveals(user_id, created_at);
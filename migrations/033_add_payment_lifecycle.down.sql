@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS payment_webhook_events;
+DROP TABLE IF EXISTS payment_status_history;
+DROP INDEX IF EXISTS idx_payments_provider_payment_id;
+ALTER TABLE payments DROP COLUMN IF EXISTS status_changed_at;
+ALTER TABLE payments DROP COLUMN IF EXISTS failure_reason;
+ALTER TABLE payments DROP COLUMN IF EXISTS provider_payment_id;
+ALTER TABLE payments ALTER COLUMN status SET DEFAULT 'pending';
+UPDATE payments SET status = 'completed' WHERE status = 'succeeded';
+UPDATE payments SET status = 'pending' WHERE status IN ('created', 'processing', 'requires_action');
@@ -0,0 +1,37 @@
+-- статусы платежа: created, requires_action, processing, succeeded, failed,
+-- canceled, refunded, partially_refunded
+UPDATE payments SET status = 'processing' WHERE status = 'pending';
+UPDATE payments SET status = 'succeeded' WHERE status = 'completed';
+ALTER TABLE payments ALTER COLUMN status SET DEFAULT 'created';
+
+-- идентификатор платежа у провайдера (PaymentIntent, платёж YooKassa)
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS provider_payment_id VARCHAR(255);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS failure_reason TEXT;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_provider_payment_id ON payments(provider, provider_payment_id);
+
+CREATE TABLE IF NOT EXISTS payment_status_history (
+    id SERIAL PRIMARY KEY,
+    payment_id BIGINT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    from_status VARCHAR(32) NOT NULL,
+    to_status VARCHAR(32) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    source VARCHAR(16) NOT NULL, -- api, provider, webhook, system
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_status_history_payment_id ON payment_status_history(payment_id);
+
+-- принятые уведомления провайдеров; повтор с тем же event_id не обрабатывается
+CREATE TABLE IF NOT EXISTS payment_webhook_events (
+    id SERIAL PRIMARY KEY,
+    provider VARCHAR(100) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    provider_payment_id VARCHAR(255) NOT NULL DEFAULT '',
+    payload TEXT NOT NULL,
+    received_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    processed_at TIMESTAMP,
+    UNIQUE (provider, event_id)
+);
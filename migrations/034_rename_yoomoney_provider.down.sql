@@ -0,0 +1,2 @@
+UPDATE payment_webhook_events SET provider = 'yoomoney' WHERE provider = 'yookassa';
+UPDATE payments SET provider = 'yoomoney' WHERE provider = 'yookassa';
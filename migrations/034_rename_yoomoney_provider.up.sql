@@ -0,0 +1,3 @@
+-- провайдер yoomoney переименован в yookassa (адаптер YooKassa API v3)
+UPDATE payments SET provider = 'yookassa' WHERE provider = 'yoomoney';
+UPDATE payment_webhook_events SET provider = 'yookassa' WHERE provider = 'yoomoney';
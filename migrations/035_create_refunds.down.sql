@@ -0,0 +1,4 @@
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS is_refunded BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE payments SET is_refunded = TRUE WHERE refunded_amount >= amount;
+ALTER TABLE payments DROP COLUMN IF EXISTS refunded_amount;
+DROP TABLE IF EXISTS refunds;
@@ -0,0 +1,32 @@
+-- возвраты по платежам: несколько частичных возвратов на один платёж
+CREATE TABLE IF NOT EXISTS refunds (
+    id SERIAL PRIMARY KEY,
+    payment_id BIGINT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    amount NUMERIC(14, 2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending', -- pending, succeeded, failed
+    provider_refund_id VARCHAR(255),
+    failure_reason TEXT,
+    transaction_id BIGINT,          -- списание со счёта при создании возврата
+    reversal_transaction_id BIGINT, -- обратное зачисление, если провайдер отказал
+    requested_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_payment_id ON refunds(payment_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_provider_refund_id ON refunds(provider_refund_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_pending ON refunds(created_at) WHERE status = 'pending';
+
+-- сумма успешных возвратов; флаг is_refunded заменяется ею
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refunded_amount NUMERIC(14, 2) NOT NULL DEFAULT 0;
+
+INSERT INTO refunds (payment_id, amount, currency, reason, status, provider_refund_id, created_at, updated_at)
+SELECT id, amount, currency, 'migrated', 'succeeded', NULL, updated_at, updated_at
+FROM payments
+WHERE is_refunded;
+
+UPDATE payments SET refunded_amount = amount WHERE is_refunded;
+
+ALTER TABLE payments DROP COLUMN IF EXISTS is_refunded;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS payment_routing_attempts;
+ALTER TABLE payments DROP COLUMN IF EXISTS routing_rule;
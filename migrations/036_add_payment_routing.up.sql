@@ -0,0 +1,15 @@
+-- правило маршрутизации, по которому выбран провайдер; пусто — по стоимости
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS routing_rule VARCHAR(64);
+
+-- обращения к провайдерам при маршрутизации платежа, включая переключения
+CREATE TABLE IF NOT EXISTS payment_routing_attempts (
+    id SERIAL PRIMARY KEY,
+    payment_id BIGINT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    attempt INT NOT NULL,
+    provider VARCHAR(32) NOT NULL,
+    outcome VARCHAR(16) NOT NULL, -- accepted, declined, unavailable, error
+    error TEXT,
+    latency_ms INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (payment_id, attempt)
+);
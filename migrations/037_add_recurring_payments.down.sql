@@ -0,0 +1,5 @@
+ALTER TABLE payments DROP COLUMN IF EXISTS saved_payment_method_id;
+ALTER TABLE payments DROP COLUMN IF EXISTS recurring_id;
+ALTER TABLE payments DROP COLUMN IF EXISTS initiator;
+DROP TABLE IF EXISTS recurring_payments;
+DROP TABLE IF EXISTS notifications;
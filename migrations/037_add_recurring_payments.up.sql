@@ -0,0 +1,44 @@
+-- входящие уведомления пользователя
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(64) NOT NULL,
+    message TEXT NOT NULL,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, id DESC);
+
+-- регулярные списания с сохранённого способа оплаты
+CREATE TABLE IF NOT EXISTS recurring_payments (
+    id SERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    payment_method_id BIGINT NOT NULL REFERENCES payment_methods(id),
+    provider VARCHAR(32),           -- провайдер, у которого сохранён способ оплаты
+    provider_method_id VARCHAR(255), -- ID способа оплаты у провайдера, разрешённый для списаний
+    amount NUMERIC(14, 2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    period VARCHAR(8) NOT NULL DEFAULT '', -- day, week, month; пусто — только по запросу
+    description TEXT NOT NULL DEFAULT '',
+    status VARCHAR(24) NOT NULL DEFAULT 'pending_authorization', -- pending_authorization, active, past_due, canceled
+    next_charge_at TIMESTAMP,
+    failed_attempts INT NOT NULL DEFAULT 0,
+    max_failures INT NOT NULL,
+    last_payment_id BIGINT,
+    cancel_reason TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    canceled_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_recurring_payments_user_id ON recurring_payments(user_id);
+CREATE INDEX IF NOT EXISTS idx_recurring_payments_due ON recurring_payments(next_charge_at)
+    WHERE status IN ('active', 'past_due');
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS initiator VARCHAR(16) NOT NULL DEFAULT 'customer';
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS recurring_id BIGINT REFERENCES recurring_payments(id) ON DELETE SET NULL;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS saved_payment_method_id VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_payments_recurring_id ON payments(recurring_id) WHERE recurring_id IS NOT NULL;
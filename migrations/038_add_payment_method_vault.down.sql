@@ -0,0 +1,18 @@
+DROP INDEX IF EXISTS idx_payment_methods_expiry;
+DROP INDEX IF EXISTS uq_payment_methods_default;
+DROP INDEX IF EXISTS uq_payment_methods_fingerprint;
+
+UPDATE payment_methods SET is_active = FALSE WHERE deleted_at IS NOT NULL;
+UPDATE payment_methods SET provider = brand WHERE provider IS NULL AND brand IS NOT NULL;
+
+ALTER TABLE payment_methods ALTER COLUMN created_at DROP NOT NULL;
+ALTER TABLE payment_methods ALTER COLUMN is_active DROP NOT NULL;
+ALTER TABLE payment_methods DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE payment_methods DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE payment_methods DROP COLUMN IF EXISTS expiry_notified_at;
+ALTER TABLE payment_methods DROP COLUMN IF EXISTS is_default;
+ALTER TABLE payment_methods DROP COLUMN IF EXISTS fingerprint;
+ALTER TABLE payment_methods DROP COLUMN IF EXISTS exp_year;
+ALTER TABLE payment_methods DROP COLUMN IF EXISTS exp_month;
+ALTER TABLE payment_methods DROP COLUMN IF EXISTS last4;
+ALTER TABLE payment_methods DROP COLUMN IF EXISTS brand;
@@ -0,0 +1,47 @@
+ALTER TABLE payment_methods ADD COLUMN IF NOT EXISTS brand VARCHAR(32);
+ALTER TABLE payment_methods ADD COLUMN IF NOT EXISTS last4 VARCHAR(4);
+ALTER TABLE payment_methods ADD COLUMN IF NOT EXISTS exp_month SMALLINT CHECK (exp_month BETWEEN 1 AND 12);
+ALTER TABLE payment_methods ADD COLUMN IF NOT EXISTS exp_year SMALLINT;
+ALTER TABLE payment_methods ADD COLUMN IF NOT EXISTS fingerprint VARCHAR(128);
+ALTER TABLE payment_methods ADD COLUMN IF NOT EXISTS is_default BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE payment_methods ADD COLUMN IF NOT EXISTS expiry_notified_at TIMESTAMP;
+ALTER TABLE payment_methods ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE payment_methods ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- provider хранил платёжную систему или кошелёк (Visa, YooMoney), теперь —
+-- провайдера, выпустившего токен
+UPDATE payment_methods SET brand = lower(provider), provider = NULL
+WHERE provider IS NOT NULL AND lower(provider) NOT IN ('stripe', 'yookassa');
+UPDATE payment_methods SET provider = lower(provider) WHERE provider IS NOT NULL;
+
+UPDATE payment_methods SET is_active = TRUE WHERE is_active IS NULL;
+UPDATE payment_methods SET deleted_at = created_at WHERE is_active = FALSE;
+ALTER TABLE payment_methods ALTER COLUMN is_active SET NOT NULL;
+ALTER TABLE payment_methods ALTER COLUMN created_at SET NOT NULL;
+
+-- отпечаток старых записей — хеш токена; совпадающие токены — один способ оплаты
+UPDATE payment_methods
+SET fingerprint = encode(sha256(convert_to(COALESCE(provider, '') || ':' || token, 'UTF8')), 'hex')
+WHERE fingerprint IS NULL;
+ALTER TABLE payment_methods ALTER COLUMN fingerprint SET NOT NULL;
+
+UPDATE payment_methods pm SET deleted_at = NOW(), is_active = FALSE
+WHERE deleted_at IS NULL AND EXISTS (
+    SELECT 1 FROM payment_methods o
+    WHERE o.user_id = pm.user_id AND o.fingerprint = pm.fingerprint AND o.deleted_at IS NULL AND o.id < pm.id
+);
+
+-- основным становится последний добавленный
+UPDATE payment_methods SET is_default = TRUE
+WHERE id IN (
+    SELECT DISTINCT ON (user_id) id FROM payment_methods
+    WHERE deleted_at IS NULL
+    ORDER BY user_id, created_at DESC, id DESC
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_payment_methods_fingerprint ON payment_methods(user_id, fingerprint)
+    WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_payment_methods_default ON payment_methods(user_id)
+    WHERE is_default AND deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_payment_methods_expiry ON payment_methods(exp_year, exp_month)
+    WHERE deleted_at IS NULL AND exp_year IS NOT NULL;
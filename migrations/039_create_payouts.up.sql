@@ -0,0 +1,30 @@
+-- выплаты со счёта на внешние карты и кошельки
+CREATE TABLE IF NOT EXISTS payouts (
+    id SERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    amount NUMERIC(14, 2) NOT NULL CHECK (amount > 0),
+    fee NUMERIC(14, 2) NOT NULL DEFAULT 0 CHECK (fee >= 0),
+    currency VARCHAR(3) NOT NULL,
+    provider VARCHAR(32) NOT NULL,
+    destination_type VARCHAR(16) NOT NULL, -- card, yoo_money
+    destination VARCHAR(255) NOT NULL,     -- токен карты или номер кошелька
+    destination_label VARCHAR(64) NOT NULL DEFAULT '',
+    payment_method_id BIGINT REFERENCES payment_methods(id),
+    status VARCHAR(16) NOT NULL DEFAULT 'pending', -- pending, processing, succeeded, failed
+    provider_payout_id VARCHAR(255),
+    failure_reason TEXT,
+    hold_transaction_id BIGINT,    -- блокировка суммы с комиссией
+    release_transaction_id BIGINT, -- снятие блокировки
+    transaction_id BIGINT,         -- списание выплаты
+    fee_transaction_id BIGINT,     -- списание комиссии
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payouts_user_id ON payouts(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_payouts_account_id ON payouts(account_id) WHERE status = 'pending';
+CREATE UNIQUE INDEX IF NOT EXISTS uq_payouts_provider_payout_id ON payouts(provider, provider_payout_id)
+    WHERE provider_payout_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_payouts_unsettled ON payouts(updated_at) WHERE status IN ('pending', 'processing');
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_payments_uncredited;
+DROP TABLE IF EXISTS reconciliation_breaks;
+DROP TABLE IF EXISTS settlement_entries;
+DROP TABLE IF EXISTS settlement_files;
@@ -0,0 +1,61 @@
+-- сверка с отчётами провайдеров о расчётах
+CREATE TABLE IF NOT EXISTS settlement_files (
+    id SERIAL PRIMARY KEY,
+    provider VARCHAR(32) NOT NULL,
+    name TEXT NOT NULL,
+    checksum VARCHAR(64) NOT NULL, -- sha256 содержимого: переименованный файл повторно не импортируется
+    entries INT NOT NULL DEFAULT 0,
+    imported_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (provider, checksum)
+);
+
+CREATE TABLE IF NOT EXISTS settlement_entries (
+    id SERIAL PRIMARY KEY,
+    file_id BIGINT NOT NULL REFERENCES settlement_files(id) ON DELETE CASCADE,
+    provider VARCHAR(32) NOT NULL,
+    kind VARCHAR(16) NOT NULL, -- payment, refund, payout
+    provider_object_id VARCHAR(255) NOT NULL,
+    amount NUMERIC(14, 2) NOT NULL,
+    fee NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL,
+    settled_at TIMESTAMP NOT NULL,
+    internal_id BIGINT,   -- payments.id, refunds.id или payouts.id по kind
+    reconciled_at TIMESTAMP,
+    UNIQUE (provider, kind, provider_object_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_settlement_entries_unreconciled ON settlement_entries(id) WHERE reconciled_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_settlement_entries_internal_id ON settlement_entries(kind, internal_id);
+
+CREATE TABLE IF NOT EXISTS reconciliation_breaks (
+    id SERIAL PRIMARY KEY,
+    provider VARCHAR(32) NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    type VARCHAR(32) NOT NULL, -- missing_internal, missing_at_provider, amount_mismatch
+    provider_object_id VARCHAR(255) NOT NULL DEFAULT '',
+    entry_id BIGINT REFERENCES settlement_entries(id) ON DELETE SET NULL,
+    internal_id BIGINT,
+    internal_amount NUMERIC(14, 2),
+    settled_amount NUMERIC(14, 2),
+    currency VARCHAR(3) NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'open', -- open, resolved
+    resolution VARCHAR(16),                     -- credited, accepted, matched
+    note TEXT,
+    transaction_id BIGINT,                      -- зачисление, проведённое при разборе
+    resolved_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_reconciliation_breaks_entry ON reconciliation_breaks(entry_id)
+    WHERE entry_id IS NOT NULL;
+-- одно расхождение каждого типа на операцию, найдена ли она по отчёту или проверкой журнала
+CREATE UNIQUE INDEX IF NOT EXISTS uq_reconciliation_breaks_internal ON reconciliation_breaks(kind, internal_id, type)
+    WHERE internal_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_reconciliation_breaks_open ON reconciliation_breaks(created_at) WHERE status = 'open';
+
+-- платежи, успешные у провайдера, но не зачисленные на счёт
+CREATE INDEX IF NOT EXISTS idx_payments_uncredited ON payments(id)
+    WHERE status IN ('succeeded', 'partially_refunded', 'refunded') AND COALESCE(transaction_id, '') = '';
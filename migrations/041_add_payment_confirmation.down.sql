@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_payments_requires_action;
+ALTER TABLE payments DROP COLUMN IF EXISTS action_expires_at;
+ALTER TABLE payments DROP COLUMN IF EXISTS confirmation_url;
@@ -0,0 +1,6 @@
+-- ссылка на подтверждение платежа (3-D Secure, страница провайдера) и срок,
+-- после которого неподтверждённый платёж отменяется
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS confirmation_url TEXT;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS action_expires_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_payments_requires_action ON payments(action_expires_at) WHERE status = 'requires_action';
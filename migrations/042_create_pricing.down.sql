@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS fee_quota_usage;
+DELETE FROM accounts WHERE kind = 'income' AND user_id IN (SELECT id FROM users WHERE username = 'system_bank');
+DELETE FROM users WHERE username = 'system_bank';
+ALTER TABLE users DROP COLUMN IF EXISTS tariff;
@@ -0,0 +1,29 @@
+-- тарифный план клиента: от него зависят комиссии
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tariff VARCHAR(32) NOT NULL DEFAULT 'basic';
+
+-- счёт доходов банка, на который зачисляются комиссии. Принадлежит служебному
+-- пользователю: такое имя не пройдёт валидацию при регистрации, а пароль не
+-- является bcrypt-хешем, поэтому войти под ним нельзя.
+INSERT INTO users (username, email, password, role)
+VALUES ('system_bank', 'bank@system.local', '!', 'system')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO accounts (user_id, kind)
+SELECT id, 'income' FROM users
+WHERE username = 'system_bank' AND NOT EXISTS (SELECT 1 FROM accounts WHERE kind = 'income');
+
+-- расход бесплатных квот: строка на операцию, которая прошла бесплатно
+-- или у которой часть суммы покрыла квота
+CREATE TABLE IF NOT EXISTS fee_quota_usage (
+    id SERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rule VARCHAR(100) NOT NULL,
+    period CHAR(7) NOT NULL, -- YYYY-MM
+    operations INT NOT NULL DEFAULT 0,
+    amount NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    transaction_id BIGINT REFERENCES transactions(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_fee_quota_usage_user ON fee_quota_usage(user_id, rule, period);
+CREATE INDEX IF NOT EXISTS idx_fee_quota_usage_transaction ON fee_quota_usage(transaction_id);
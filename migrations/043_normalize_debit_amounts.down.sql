@@ -0,0 +1,3 @@
+-- знак исторических записей не восстанавливается: отрицательные суммы
+-- запрещены ограничением amount >= 0
+SELECT 1;
@@ -0,0 +1,4 @@
+-- сумма операции всегда положительна, направление задают from_account и
+-- to_account. Списания, записанные со знаком минус, приводятся к общему виду.
+UPDATE transactions SET amount = -amount
+WHERE type IN ('withdraw', 'credit_payment') AND amount < 0;
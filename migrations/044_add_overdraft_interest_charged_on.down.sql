@@ -0,0 +1 @@
+ALTER TABLE overdrafts DROP COLUMN IF EXISTS interest_charged_on;
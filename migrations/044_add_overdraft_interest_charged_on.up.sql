@@ -0,0 +1,9 @@
+-- дата последнего списания процентов за период: списание догоняет пропущенные
+-- запуски, а не зависит от запуска строго первого числа
+ALTER TABLE overdrafts ADD COLUMN IF NOT EXISTS interest_charged_on DATE;
+
+-- раньше дата начисления сдвигалась только при отрицательном остатке; у счетов
+-- без долга она устарела, и догоняющее начисление взяло бы лишние дни
+UPDATE overdrafts o SET last_accrual_date = NULL
+FROM accounts a
+WHERE a.id = o.account_id AND a.balance >= 0;
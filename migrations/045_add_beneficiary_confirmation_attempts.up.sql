@@ -0,0 +1,2 @@
+-- число неверных вводов кода: после лимита код больше не принимается
+ALTER TABLE beneficiaries ADD COLUMN IF NOT EXISTS confirmation_attempts INT NOT NULL DEFAULT 0;